@@ -17,8 +17,10 @@ import (
 	"io/fs"
 	"maps"
 	"math"
+	"os"
 	"path"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -197,6 +199,25 @@ type Header struct {
 	// other fields in Header take precedence over PAXRecords.
 	PAXRecords map[string]string
 
+	// SparseHoles represents a sequence of holes in a sparse file.
+	//
+	// A hole is semantically a block of NUL bytes, but does not actually
+	// exist within the tar file. The holes must be sorted in ascending order,
+	// not overlap with each other, and not extend past the specified Size.
+	// Holes are rounded inwards to multiples of the tar block size (512),
+	// so holes smaller than that are not recorded.
+	//
+	// If SparseHoles is not empty, Typeflag must be TypeReg and
+	// Writer.WriteHeader encodes the file in the PAX format using the
+	// GNU sparse format version 1.0. The data written for the file is
+	// still its full logical content, but NULs written within the holes
+	// are not stored. DetectSparseHoles may be used to find the holes
+	// in an operating system file.
+	//
+	// SparseHoles is not populated by Reader.Next, which instead presents
+	// the logical content of sparse files, with the holes filled by NULs.
+	SparseHoles []SparseEntry
+
 	// Format specifies the format of the tar header.
 	//
 	// This is set by Reader.Next as a best-effort guess at the format.
@@ -209,10 +230,10 @@ type Header struct {
 	Format Format
 }
 
-// sparseEntry represents a Length-sized fragment at Offset in the file.
-type sparseEntry struct{ Offset, Length int64 }
+// A SparseEntry represents a Length-sized fragment at Offset in the file.
+type SparseEntry struct{ Offset, Length int64 }
 
-func (s sparseEntry) endOffset() int64 { return s.Offset + s.Length }
+func (s SparseEntry) endOffset() int64 { return s.Offset + s.Length }
 
 // A sparse file can be represented as either a sparseDatas or a sparseHoles.
 // As long as the total size is known, they are equivalent and one can be
@@ -233,11 +254,11 @@ func (s sparseEntry) endOffset() int64 { return s.Offset + s.Length }
 //
 // And the sparse map has the following entries:
 //
-//	var spd sparseDatas = []sparseEntry{
+//	var spd sparseDatas = []SparseEntry{
 //		{Offset: 2,  Length: 5},  // Data fragment for 2..6
 //		{Offset: 18, Length: 3},  // Data fragment for 18..20
 //	}
-//	var sph sparseHoles = []sparseEntry{
+//	var sph sparseHoles = []SparseEntry{
 //		{Offset: 0,  Length: 2},  // Hole fragment for 0..1
 //		{Offset: 7,  Length: 11}, // Hole fragment for 7..17
 //		{Offset: 21, Length: 4},  // Hole fragment for 21..24
@@ -247,19 +268,19 @@ func (s sparseEntry) endOffset() int64 { return s.Offset + s.Length }
 //
 //	var sparseFile = "\x00"*2 + "abcde" + "\x00"*11 + "fgh" + "\x00"*4
 type (
-	sparseDatas []sparseEntry
-	sparseHoles []sparseEntry
+	sparseDatas []SparseEntry
+	sparseHoles []SparseEntry
 )
 
 // validateSparseEntries reports whether sp is a valid sparse map.
 // It does not matter whether sp represents data fragments or hole fragments.
-func validateSparseEntries(sp []sparseEntry, size int64) bool {
+func validateSparseEntries(sp []SparseEntry, size int64) bool {
 	// Validate all sparse entries. These are the same checks as performed by
 	// the BSD tar utility.
 	if size < 0 {
 		return false
 	}
-	var pre sparseEntry
+	var pre SparseEntry
 	for _, cur := range sp {
 		switch {
 		case cur.Offset < 0 || cur.Length < 0:
@@ -283,7 +304,7 @@ func validateSparseEntries(sp []sparseEntry, size int64) bool {
 // Even though the Go tar Reader and the BSD tar utility can handle entries
 // with arbitrary offsets and lengths, the GNU tar utility can only handle
 // offsets and lengths that are multiples of blockSize.
-func alignSparseEntries(src []sparseEntry, size int64) []sparseEntry {
+func alignSparseEntries(src []SparseEntry, size int64) []SparseEntry {
 	dst := src[:0]
 	for _, s := range src {
 		pos, end := s.Offset, s.endOffset()
@@ -292,7 +313,7 @@ func alignSparseEntries(src []sparseEntry, size int64) []sparseEntry {
 			end -= blockPadding(-end) // Round-down to nearest blockSize
 		}
 		if pos < end {
-			dst = append(dst, sparseEntry{Offset: pos, Length: end - pos})
+			dst = append(dst, SparseEntry{Offset: pos, Length: end - pos})
 		}
 	}
 	return dst
@@ -306,9 +327,9 @@ func alignSparseEntries(src []sparseEntry, size int64) []sparseEntry {
 //   - adjacent fragments are coalesced together
 //   - only the last fragment may be empty
 //   - the endOffset of the last fragment is the total size
-func invertSparseEntries(src []sparseEntry, size int64) []sparseEntry {
+func invertSparseEntries(src []SparseEntry, size int64) []SparseEntry {
 	dst := src[:0]
-	var pre sparseEntry
+	var pre SparseEntry
 	for _, cur := range src {
 		if cur.Length == 0 {
 			continue // Skip empty fragments
@@ -490,28 +511,19 @@ func (h Header) allowedFormats() (format Format, paxHdrs map[string]string, err
 		}
 	}
 
-	// TODO(dsnet): Re-enable this when adding sparse support.
+	// Check sparse files.
+	// TODO(dsnet): Support writing TypeGNUSparse when adding GNU sparse support.
 	// See https://golang.org/issue/22735
-	/*
-		// Check sparse files.
-		if len(h.SparseHoles) > 0 || h.Typeflag == TypeGNUSparse {
-			if isHeaderOnlyType(h.Typeflag) {
-				return FormatUnknown, nil, headerError{"header-only type cannot be sparse"}
-			}
-			if !validateSparseEntries(h.SparseHoles, h.Size) {
-				return FormatUnknown, nil, headerError{"invalid sparse holes"}
-			}
-			if h.Typeflag == TypeGNUSparse {
-				whyOnlyGNU = "only GNU supports TypeGNUSparse"
-				format.mayOnlyBe(FormatGNU)
-			} else {
-				whyNoGNU = "GNU supports sparse files only with TypeGNUSparse"
-				format.mustNotBe(FormatGNU)
-			}
-			whyNoUSTAR = "USTAR does not support sparse files"
-			format.mustNotBe(FormatUSTAR)
+	if len(h.SparseHoles) > 0 {
+		if h.Typeflag != TypeReg {
+			return FormatUnknown, nil, headerError{"only TypeReg may be sparse"}
+		}
+		if !validateSparseEntries(h.SparseHoles, h.Size) {
+			return FormatUnknown, nil, headerError{"invalid sparse holes"}
 		}
-	*/
+		whyOnlyPAX = "only PAX supports sparse files"
+		format.mayOnlyBe(FormatPAX)
+	}
 
 	// Check desired format.
 	if wantFormat := h.Format; wantFormat != FormatUnknown {
@@ -615,6 +627,110 @@ func (fi headerFileInfo) String() string {
 // sysStat, if non-nil, populates h from system-dependent fields of fi.
 var sysStat func(fi fs.FileInfo, h *Header, doNameLookups bool) error
 
+// sysSparseDetect, if non-nil, reports the holes in the first size bytes of f.
+var sysSparseDetect func(f *os.File, size int64) (sparseHoles, error)
+
+// DetectSparseHoles sets h.SparseHoles to the holes in f,
+// which must be the file described by h, up to h.Size bytes.
+// The offset of f is unchanged.
+//
+// Holes are found using the SEEK_HOLE and SEEK_DATA whence values of lseek,
+// which are currently only used on Linux. On other systems, or if the
+// file system does not support them, no holes are reported.
+//
+// Since [FileInfoHeader] cannot inspect the file's contents, it never sets
+// SparseHoles. To archive a sparse file compactly, call DetectSparseHoles
+// before [Writer.WriteHeader], then write the contents with [Writer.ReadFrom],
+// which skips over the holes without reading them.
+func (h *Header) DetectSparseHoles(f *os.File) error {
+	h.SparseHoles = nil
+	if sysSparseDetect == nil || h.Size <= 0 {
+		return nil
+	}
+	sph, err := sysSparseDetect(f, h.Size)
+	if err != nil {
+		return err
+	}
+	if len(sph) > 0 {
+		h.SparseHoles = sph
+	}
+	return nil
+}
+
+// sysListXattrs, if non-nil, reports the extended attributes of the named file.
+var sysListXattrs func(name string) (map[string]string, error)
+
+// sysSetXattr, if non-nil, sets an extended attribute of the named file.
+var sysSetXattr func(name, key, value string) error
+
+// ReadXattrs replaces the "SCHILY.xattr." records in h.PAXRecords with
+// the extended attributes of the named file, which is typically the file
+// described by h. If the file is a symbolic link, the attributes of the
+// link itself are read. The deprecated Xattrs field is not modified.
+//
+// The attributes include those in every namespace the caller may read,
+// such as "user.*", file capabilities ("security.capability"), and POSIX
+// ACLs ("system.posix_acl_access" and "system.posix_acl_default").
+// Extended attributes are currently only supported on Linux.
+// On other systems, or if the file system does not support extended
+// attributes, no records are added.
+func (h *Header) ReadXattrs(name string) error {
+	for k := range h.PAXRecords {
+		if strings.HasPrefix(k, paxSchilyXattr) {
+			delete(h.PAXRecords, k)
+		}
+	}
+	if sysListXattrs == nil {
+		return nil
+	}
+	xattrs, err := sysListXattrs(name)
+	if err != nil {
+		return err
+	}
+	if len(xattrs) > 0 && h.PAXRecords == nil {
+		h.PAXRecords = make(map[string]string)
+	}
+	for k, v := range xattrs {
+		h.PAXRecords[paxSchilyXattr+k] = v
+	}
+	return nil
+}
+
+// ApplyXattrs sets the extended attributes recorded in h on the named
+// file, typically one just extracted from the archive. The attributes are
+// taken from the "SCHILY.xattr." records in h.PAXRecords and from Xattrs,
+// which takes precedence, as it does in [Writer.WriteHeader].
+// If the file is a symbolic link, the attributes are set on the link itself.
+//
+// The kernel clears file capabilities when a file is written or its owner
+// changes, so ApplyXattrs should be called after the contents, ownership,
+// and mode of the file have been set.
+//
+// Extended attributes are currently only supported on Linux.
+// On other systems, ApplyXattrs returns an error wrapping
+// [errors.ErrUnsupported] if h records any attributes.
+func (h *Header) ApplyXattrs(name string) error {
+	xattrs := make(map[string]string)
+	for k, v := range h.PAXRecords {
+		if key, ok := strings.CutPrefix(k, paxSchilyXattr); ok {
+			xattrs[key] = v
+		}
+	}
+	maps.Copy(xattrs, h.Xattrs)
+	if len(xattrs) == 0 {
+		return nil
+	}
+	if sysSetXattr == nil {
+		return &fs.PathError{Op: "setxattr", Path: name, Err: errors.ErrUnsupported}
+	}
+	for _, k := range slices.Sorted(maps.Keys(xattrs)) {
+		if err := sysSetXattr(name, k, xattrs[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 const (
 	// Mode constants from the USTAR spec:
 	// See http://pubs.opengroup.org/onlinepubs/9699919799/utilities/pax.html#tag_20_92_13_06
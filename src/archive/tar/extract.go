@@ -0,0 +1,171 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"fmt"
+	"internal/extract"
+	"io"
+	"io/fs"
+)
+
+// ExtractOptions are limits applied by [Reader.Extract].
+// A zero limit means no limit.
+type ExtractOptions struct {
+	// MaxFiles is the maximum number of entries in the archive.
+	MaxFiles int
+
+	// MaxSize is the maximum total number of bytes
+	// written to the files extracted from the archive.
+	MaxSize int64
+
+	// MaxRatio is the maximum ratio of the size of any file
+	// to the number of bytes it occupies in the archive.
+	// Only sparse files may exceed a ratio of 1.
+	MaxRatio int
+}
+
+// An InsecurePathError reports an entry whose name would place it
+// outside the extraction directory, either directly or by way of
+// a symbolic link. It wraps [ErrInsecurePath].
+type InsecurePathError struct {
+	Name string // name of the entry
+}
+
+func (e *InsecurePathError) Error() string {
+	return fmt.Sprintf("%v: %q", ErrInsecurePath, e.Name)
+}
+
+func (e *InsecurePathError) Unwrap() error { return ErrInsecurePath }
+
+// An InsecureLinkError reports a symbolic or hard link entry whose
+// target is outside the extraction directory. It wraps [ErrInsecurePath].
+type InsecureLinkError struct {
+	Name     string // name of the entry
+	Linkname string // target of the link
+}
+
+func (e *InsecureLinkError) Error() string {
+	return fmt.Sprintf("archive/tar: insecure link target: %q -> %q", e.Name, e.Linkname)
+}
+
+func (e *InsecureLinkError) Unwrap() error { return ErrInsecurePath }
+
+// A FileLimitError reports an archive with more entries than
+// permitted by [ExtractOptions.MaxFiles].
+type FileLimitError struct {
+	Limit int
+}
+
+func (e *FileLimitError) Error() string {
+	return fmt.Sprintf("archive/tar: archive has more than %d entries", e.Limit)
+}
+
+// A SizeLimitError reports an archive whose contents are larger than
+// permitted by [ExtractOptions.MaxSize].
+type SizeLimitError struct {
+	Name  string // name of the entry being extracted when the limit was reached
+	Limit int64
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("archive/tar: extracting %q exceeds size limit of %d bytes", e.Name, e.Limit)
+}
+
+// A RatioLimitError reports a sparse file that is larger, relative to
+// the data stored in the archive, than permitted by [ExtractOptions.MaxRatio].
+type RatioLimitError struct {
+	Name  string // name of the entry
+	Limit int
+}
+
+func (e *RatioLimitError) Error() string {
+	return fmt.Sprintf("archive/tar: %q exceeds compression ratio limit of %d", e.Name, e.Limit)
+}
+
+// Extract extracts the remaining entries of the archive into the directory
+// dir, which is created if necessary. If opts is nil, no limits apply.
+//
+// Extract creates directories, regular files, symbolic links, and hard links.
+// Other entry types, such as devices and FIFOs, are skipped. The permission
+// bits of each entry are preserved, subject to the umask, but not its
+// set-user-ID, set-group-ID, or sticky bits, nor its ownership. Directories
+// are always made readable, writable, and searchable by their owner, so
+// that their contents can be extracted. An entry with no permission bits
+// is created with mode 0o666, or 0o777 for a directory. The modification
+// times of regular files are preserved. An entry replaces any existing
+// file of the same name, other than a directory.
+//
+// Extract never writes outside dir. It returns an [*InsecurePathError]
+// for an entry whose name is not local (see [filepath.IsLocal]) or that
+// would be created beneath a symbolic link, and an [*InsecureLinkError]
+// for a link whose target is absolute or outside dir. A symbolic link may
+// not point through another symbolic link, and a hard link may only refer
+// to a regular file already extracted. The limits in opts are
+// reported with a [*FileLimitError], [*SizeLimitError], or [*RatioLimitError].
+// The size limit is enforced on the bytes actually written, not on the sizes
+// recorded in the archive. Extract stops at the first error, which may leave
+// some entries extracted. It assumes that dir is not concurrently modified
+// by other processes.
+func (tr *Reader) Extract(dir string, opts *ExtractOptions) error {
+	var o ExtractOptions
+	if opts != nil {
+		o = *opts
+	}
+	d, err := extract.NewDir(dir, o.MaxSize)
+	if err != nil {
+		return err
+	}
+	for n := 0; ; n++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && err != ErrInsecurePath {
+			return err
+		}
+		if o.MaxFiles > 0 && n >= o.MaxFiles {
+			return &FileLimitError{Limit: o.MaxFiles}
+		}
+		if err := tr.extract(d, &o, hdr); err != nil {
+			return err
+		}
+	}
+}
+
+// extract extracts the entry hdr, whose contents are the remaining
+// data of the current file, into d.
+func (tr *Reader) extract(d *extract.Dir, o *ExtractOptions, hdr *Header) error {
+	var err error
+	perm := fs.FileMode(hdr.Mode).Perm()
+	switch hdr.Typeflag {
+	case TypeDir:
+		err = d.Mkdir(hdr.Name, perm)
+
+	case TypeReg, TypeRegA, TypeGNUSparse:
+		if o.MaxRatio > 0 && hdr.Size/max(tr.curr.physicalRemaining(), 1) > int64(o.MaxRatio) {
+			return &RatioLimitError{Name: hdr.Name, Limit: o.MaxRatio}
+		}
+		if d.Exceeds(hdr.Size) {
+			return &SizeLimitError{Name: hdr.Name, Limit: o.MaxSize}
+		}
+		err = d.WriteFile(hdr.Name, tr, perm, hdr.ModTime)
+
+	case TypeSymlink:
+		err = d.Symlink(hdr.Name, hdr.Linkname)
+
+	case TypeLink:
+		err = d.Link(hdr.Name, hdr.Linkname)
+	}
+	switch err {
+	case extract.ErrInsecurePath:
+		return &InsecurePathError{Name: hdr.Name}
+	case extract.ErrInsecureLink:
+		return &InsecureLinkError{Name: hdr.Name, Linkname: hdr.Linkname}
+	case extract.ErrSizeLimit:
+		return &SizeLimitError{Name: hdr.Name, Limit: o.MaxSize}
+	}
+	return err
+}
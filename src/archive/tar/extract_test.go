@@ -0,0 +1,219 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"bytes"
+	"errors"
+	"internal/testenv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// makeTar returns an archive containing the given headers.
+// Regular files contain their own names.
+func makeTar(t *testing.T, hdrs ...*Header) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	tw := NewWriter(&b)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == TypeReg {
+			hdr.Size = int64(len(hdr.Name))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == TypeReg {
+			tw.Write([]byte(hdr.Name))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	testenv.MustHaveSymlink(t)
+	testenv.MustHaveLink(t)
+
+	archive := makeTar(t,
+		&Header{Typeflag: TypeDir, Name: "./", Mode: 0o755},
+		&Header{Typeflag: TypeDir, Name: "dir/", Mode: 0o755},
+		&Header{Typeflag: TypeReg, Name: "dir/file", Mode: 0o644},
+		&Header{Typeflag: TypeReg, Name: "a/b/c/implicit", Mode: 0o600},
+		&Header{Typeflag: TypeReg, Name: "exec", Mode: 0o4755},
+		&Header{Typeflag: TypeSymlink, Name: "a/link", Linkname: "../dir/file"},
+		&Header{Typeflag: TypeLink, Name: "hardlink", Linkname: "dir/file"},
+		&Header{Typeflag: TypeFifo, Name: "fifo", Mode: 0o644},
+		&Header{Typeflag: TypeReg, Name: "replaced", Mode: 0o644},
+		&Header{Typeflag: TypeSymlink, Name: "replaced", Linkname: "dir"},
+	)
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := NewReader(bytes.NewReader(archive)).Extract(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"dir/file":       "dir/file",
+		"a/b/c/implicit": "a/b/c/implicit",
+		"exec":           "exec",
+		"a/link":         "dir/file",
+		"hardlink":       "dir/file",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "exec")); err != nil || fi.Mode()&(os.ModeSetuid|0o100) != 0o100 {
+		t.Errorf("exec: mode %v, %v; want executable without setuid", fi.Mode(), err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "fifo")); err == nil {
+		t.Error("fifo was extracted")
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "replaced")); err != nil || target != "dir" {
+		t.Errorf("replaced: Readlink = %q, %v; want %q", target, err, "dir")
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	testenv.MustHaveSymlink(t)
+
+	tests := []struct {
+		name string
+		hdrs []*Header
+		opts *ExtractOptions
+		want error
+	}{{
+		name: "dotdot",
+		hdrs: []*Header{{Typeflag: TypeReg, Name: "a/../../evil"}},
+		want: &InsecurePathError{Name: "a/../../evil"},
+	}, {
+		name: "absolute",
+		hdrs: []*Header{{Typeflag: TypeReg, Name: "/evil"}},
+		want: &InsecurePathError{Name: "/evil"},
+	}, {
+		name: "through symlink",
+		hdrs: []*Header{
+			{Typeflag: TypeDir, Name: "dir/"},
+			{Typeflag: TypeSymlink, Name: "link", Linkname: "dir"},
+			{Typeflag: TypeReg, Name: "link/evil"},
+		},
+		want: &InsecurePathError{Name: "link/evil"},
+	}, {
+		name: "symlink outside",
+		hdrs: []*Header{{Typeflag: TypeSymlink, Name: "dir/link", Linkname: "../../evil"}},
+		want: &InsecureLinkError{Name: "dir/link", Linkname: "../../evil"},
+	}, {
+		name: "absolute symlink",
+		hdrs: []*Header{{Typeflag: TypeSymlink, Name: "link", Linkname: "/etc"}},
+		want: &InsecureLinkError{Name: "link", Linkname: "/etc"},
+	}, {
+		name: "chained symlink",
+		hdrs: []*Header{
+			{Typeflag: TypeDir, Name: "sub/"},
+			{Typeflag: TypeSymlink, Name: "sub/s2", Linkname: ".."},
+			{Typeflag: TypeSymlink, Name: "l1", Linkname: "sub/s2/../.."},
+		},
+		want: &InsecureLinkError{Name: "l1", Linkname: "sub/s2/../.."},
+	}, {
+		name: "symlink dotdot after missing directory",
+		hdrs: []*Header{{Typeflag: TypeSymlink, Name: "l1", Linkname: "sub/../evil"}},
+		want: &InsecureLinkError{Name: "l1", Linkname: "sub/../evil"},
+	}, {
+		name: "hard link outside",
+		hdrs: []*Header{{Typeflag: TypeLink, Name: "link", Linkname: "../evil"}},
+		want: &InsecureLinkError{Name: "link", Linkname: "../evil"},
+	}, {
+		name: "hard link to symlink",
+		hdrs: []*Header{
+			{Typeflag: TypeSymlink, Name: "symlink", Linkname: "."},
+			{Typeflag: TypeLink, Name: "link", Linkname: "symlink"},
+		},
+		want: &InsecureLinkError{Name: "link", Linkname: "symlink"},
+	}, {
+		name: "files",
+		hdrs: []*Header{
+			{Typeflag: TypeReg, Name: "a"},
+			{Typeflag: TypeReg, Name: "b"},
+			{Typeflag: TypeReg, Name: "c"},
+		},
+		opts: &ExtractOptions{MaxFiles: 2},
+		want: &FileLimitError{Limit: 2},
+	}, {
+		name: "size",
+		hdrs: []*Header{
+			{Typeflag: TypeReg, Name: "aaaa"},
+			{Typeflag: TypeReg, Name: "bbbb"},
+		},
+		opts: &ExtractOptions{MaxSize: 7},
+		want: &SizeLimitError{Name: "bbbb", Limit: 7},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "out")
+			err := NewReader(bytes.NewReader(makeTar(t, tt.hdrs...))).Extract(dir, tt.opts)
+			if !reflect.DeepEqual(err, tt.want) {
+				t.Fatalf("Extract error = %v, want %v", err, tt.want)
+			}
+			if _, ok := tt.want.(interface{ Unwrap() error }); ok && !errors.Is(err, ErrInsecurePath) {
+				t.Errorf("errors.Is(%v, ErrInsecurePath) = false", err)
+			}
+			if _, err := os.Lstat(filepath.Join(root, "evil")); err == nil {
+				t.Error("file was written outside the destination")
+			}
+		})
+	}
+}
+
+func TestExtractHardLinkMissing(t *testing.T) {
+	archive := makeTar(t, &Header{Typeflag: TypeLink, Name: "link", Linkname: "dir/file"})
+	dir := filepath.Join(t.TempDir(), "out")
+	err := NewReader(bytes.NewReader(archive)).Extract(dir, nil)
+	want := &InsecureLinkError{Name: "link", Linkname: "dir/file"}
+	if !reflect.DeepEqual(err, want) {
+		t.Fatalf("Extract error = %v, want %v", err, want)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "dir")); err == nil {
+		t.Error("rejected hard link created the directory of its target")
+	}
+}
+
+func TestExtractMode(t *testing.T) {
+	archive := makeTar(t,
+		&Header{Typeflag: TypeDir, Name: "dir/"},
+		&Header{Typeflag: TypeReg, Name: "file"},
+	)
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := NewReader(bytes.NewReader(archive)).Extract(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dir", "file"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm()&0o600 != 0o600 {
+			t.Errorf("%s: mode %v; want readable and writable by owner", name, fi.Mode())
+		}
+	}
+}
+
+func TestExtractSparseRatio(t *testing.T) {
+	f, err := os.Open("testdata/gnu-sparse-big.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	err = NewReader(f).Extract(t.TempDir(), &ExtractOptions{MaxRatio: 100})
+	var ratioErr *RatioLimitError
+	if !errors.As(err, &ratioErr) || ratioErr.Limit != 100 {
+		t.Fatalf("Extract error = %v, want RatioLimitError", err)
+	}
+}
@@ -0,0 +1,403 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// maxSymlinks is the maximum number of symbolic links
+// followed while resolving a name in an [FS].
+const maxSymlinks = 255
+
+var errTooManyLinks = errors.New("archive/tar: too many levels of symbolic links")
+
+// An FS is a read-only file system containing the files of a tar archive.
+// It implements [fs.FS], [fs.ReadDirFS], and [fs.StatFS].
+//
+// The names of the entries are cleaned, and any leading "/" or "../"
+// elements are removed. If the archive contains several entries with the
+// same name, the last one is used, as it would be by extracting the archive.
+// A directory that contains other entries takes precedence over entries of
+// other types with the same name. Directories that are not in the archive
+// but contain other entries are present in the file system, with mode
+// [fs.ModeDir] | 0555.
+// Hard links refer to the entry named by their Linkname, which must precede
+// them in the archive; hard links to unknown entries are ignored.
+//
+// Symbolic links are resolved within the file system. A target that is an
+// absolute path is resolved relative to the root of the file system, and
+// ".." elements in a target do not lead out of the root. Opened regular
+// files implement [io.Seeker] and [io.ReaderAt], as required to serve
+// them with [net/http.FileServerFS]. Entries of other types, such as
+// devices, appear as empty files.
+type FS struct {
+	files map[string]*fsEntry // keyed by cleaned name; "." is the root
+}
+
+// An fsEntry is an entry in an FS.
+type fsEntry struct {
+	hdr      *Header     // Name is the cleaned name of the entry
+	data     io.ReaderAt // the contents of a regular file
+	children []*fsEntry  // the entries in a directory, sorted by name
+	info     fs.FileInfo // the result of hdr.FileInfo
+}
+
+func (e *fsEntry) isDir() bool { return e.hdr.Typeflag == TypeDir }
+
+// NewFS returns an [FS] containing the files of the tar archive in r,
+// which is assumed to have the given size in bytes.
+// NewFS reads all the headers of the archive, but not the contents
+// of the files, which are read from r when they are needed.
+func NewFS(r io.ReaderAt, size int64) (*FS, error) {
+	fsys := &FS{files: make(map[string]*fsEntry)}
+	root := &Header{Typeflag: TypeDir, Name: ".", Mode: 0o555}
+	fsys.files["."] = &fsEntry{hdr: root}
+
+	sr := io.NewSectionReader(r, 0, size)
+	tr := NewReader(sr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != ErrInsecurePath {
+			return nil, err
+		}
+		name := fsValidName(hdr.Name)
+		if name == "." {
+			if hdr.Typeflag == TypeDir {
+				root.Mode, root.ModTime = hdr.Mode, hdr.ModTime
+			}
+			continue
+		}
+		h := *hdr
+		h.Name = name
+		e := &fsEntry{hdr: &h}
+
+		switch h.Typeflag {
+		case TypeReg, TypeRegA, TypeGNUSparse:
+			h.Typeflag = TypeReg
+			off, err := sr.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			switch fr := tr.curr.(type) {
+			case *regFileReader:
+				e.data = io.NewSectionReader(r, off, fr.nb)
+			case *sparseFileReader:
+				// Copy the holes, since they are modified by invertSparseEntries.
+				datas := invertSparseEntries(slices.Clone(fr.sp), h.Size)
+				e.data = newSparseReaderAt(io.NewSectionReader(r, off, fr.fr.physicalRemaining()), datas)
+			}
+		case TypeLink:
+			target := fsys.files[fsValidName(h.Linkname)]
+			if target == nil || target.hdr.Typeflag != TypeReg {
+				continue
+			}
+			h.Typeflag, h.Size = TypeReg, target.hdr.Size
+			e.data = target.data
+		case TypeDir, TypeSymlink:
+		case TypeXGlobalHeader:
+			continue
+		default:
+			h.Size = 0
+		}
+		fsys.add(e)
+	}
+
+	fsys.files["."].info = root.FileInfo()
+	for _, e := range fsys.files {
+		slices.SortFunc(e.children, func(a, b *fsEntry) int {
+			return strings.Compare(a.hdr.Name, b.hdr.Name)
+		})
+	}
+	return fsys, nil
+}
+
+// fsValidName coerces name to be a valid name for fs.FS.Open.
+func fsValidName(name string) string {
+	p := path.Clean("/" + name)
+	if p == "/" {
+		return "."
+	}
+	return p[1:]
+}
+
+// add adds e to fsys, replacing any existing entry with the same name
+// and creating its parent directories if necessary.
+func (fsys *FS) add(e *fsEntry) {
+	e.info = e.hdr.FileInfo()
+	name := e.hdr.Name
+	if old := fsys.files[name]; old != nil {
+		switch {
+		case old.isDir() && e.isDir():
+			e.children = old.children
+		case old.isDir() && len(old.children) > 0:
+			return
+		}
+		*old = *e
+		return
+	}
+	fsys.files[name] = e
+	dir := path.Dir(name)
+	parent := fsys.files[dir]
+	if parent == nil {
+		parent = &fsEntry{hdr: &Header{Typeflag: TypeDir, Name: dir, Mode: 0o555}}
+		fsys.add(parent)
+	} else if !parent.isDir() {
+		// A file has the same name as a directory containing other
+		// entries. The directory takes precedence.
+		parent.hdr = &Header{Typeflag: TypeDir, Name: dir, Mode: 0o555}
+		parent.data = nil
+		parent.info = parent.hdr.FileInfo()
+	}
+	parent.children = append(parent.children, e)
+}
+
+// lookup returns the entry with the given name, which must be valid.
+// It follows symbolic links in all elements of name except the last,
+// which it follows only if followLast is true.
+func (fsys *FS) lookup(name string, followLast bool) (*fsEntry, error) {
+	dir := "."   // the resolved name of the directory containing rest
+	rest := name // the elements yet to be resolved
+	links := 0
+	for rest != "" {
+		var elem string
+		elem, rest, _ = strings.Cut(rest, "/")
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			dir = path.Dir(dir)
+			continue
+		}
+		p := path.Join(dir, elem)
+		e := fsys.files[p]
+		if e == nil {
+			return nil, fs.ErrNotExist
+		}
+		if e.hdr.Typeflag == TypeSymlink && (rest != "" || followLast) {
+			if links++; links > maxSymlinks {
+				return nil, errTooManyLinks
+			}
+			target := e.hdr.Linkname
+			if strings.HasPrefix(target, "/") {
+				dir = "."
+			}
+			rest = target + "/" + rest
+			continue
+		}
+		if rest != "" && !e.isDir() {
+			return nil, fs.ErrNotExist
+		}
+		dir = p
+	}
+	return fsys.files[dir], nil
+}
+
+// Open opens the named file, following any symbolic links.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	e, err := fsys.lookupPath("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := renamedInfo(e.info, name)
+	if e.isDir() {
+		return &fsDir{e: e, info: info}, nil
+	}
+	data := e.data
+	if data == nil {
+		data = strings.NewReader("")
+	}
+	return &fsFile{SectionReader: io.NewSectionReader(data, 0, e.hdr.Size), info: info}, nil
+}
+
+// ReadDir reads the named directory, following any symbolic links,
+// and returns a list of its entries sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := fsys.lookupPath("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !e.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return dirEntries(e.children), nil
+}
+
+// Stat returns a [fs.FileInfo] describing the named file,
+// following any symbolic links. Its Sys method returns a [*Header]
+// for the entry in the archive, whose Name has been cleaned.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	e, err := fsys.lookupPath("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return renamedInfo(e.info, name), nil
+}
+
+// Lstat returns a [fs.FileInfo] describing the named file.
+// If the file is a symbolic link, the returned FileInfo describes the link
+// itself, as with [FS.Stat] otherwise.
+func (fsys *FS) Lstat(name string) (fs.FileInfo, error) {
+	e, err := fsys.lookupPath("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return e.info, nil
+}
+
+// ReadLink returns the target of the named symbolic link,
+// exactly as it is recorded in the archive.
+func (fsys *FS) ReadLink(name string) (string, error) {
+	e, err := fsys.lookupPath("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if e.hdr.Typeflag != TypeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return e.hdr.Linkname, nil
+}
+
+// lookupPath is like lookup, but checks that name is valid
+// and returns errors as an [*fs.PathError] for op.
+func (fsys *FS) lookupPath(op, name string, followLast bool) (*fsEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	e, err := fsys.lookup(name, followLast)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return e, nil
+}
+
+func dirEntries(children []*fsEntry) []fs.DirEntry {
+	list := make([]fs.DirEntry, len(children))
+	for i, c := range children {
+		list[i] = fs.FileInfoToDirEntry(c.info)
+	}
+	return list
+}
+
+// renamedInfo returns info, renamed to the base name of name
+// if it was found by following a symbolic link.
+func renamedInfo(info fs.FileInfo, name string) fs.FileInfo {
+	if base := path.Base(name); base != info.Name() {
+		return renamedFileInfo{info, base}
+	}
+	return info
+}
+
+type renamedFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (fi renamedFileInfo) Name() string   { return fi.name }
+func (fi renamedFileInfo) String() string { return fs.FormatFileInfo(fi) }
+
+// An fsFile is a regular file opened from an FS.
+type fsFile struct {
+	*io.SectionReader
+	info fs.FileInfo
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *fsFile) Close() error               { return nil }
+
+// An fsDir is a directory opened from an FS.
+type fsDir struct {
+	e      *fsEntry
+	info   fs.FileInfo
+	offset int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *fsDir) Close() error               { return nil }
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.e.hdr.Name, Err: errors.New("is a directory")}
+}
+
+func (d *fsDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	n := len(d.e.children) - d.offset
+	if count > 0 && n > count {
+		n = count
+	}
+	if n == 0 {
+		if count <= 0 {
+			return nil, nil
+		}
+		return nil, io.EOF
+	}
+	list := dirEntries(d.e.children[d.offset : d.offset+n])
+	d.offset += n
+	return list, nil
+}
+
+// A sparseReaderAt reads a sparse file whose data fragments
+// are stored contiguously in r.
+type sparseReaderAt struct {
+	r     io.ReaderAt
+	datas sparseDatas // normalized by invertSparseEntries
+	phys  []int64     // offset in r of each fragment in datas
+}
+
+func newSparseReaderAt(r io.ReaderAt, datas sparseDatas) *sparseReaderAt {
+	phys := make([]int64, len(datas))
+	var off int64
+	for i, d := range datas {
+		phys[i] = off
+		off += d.Length
+	}
+	return &sparseReaderAt{r: r, datas: datas, phys: phys}
+}
+
+func (s *sparseReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("archive/tar: negative offset")
+	}
+	size := s.datas[len(s.datas)-1].endOffset()
+	for len(p) > 0 && off < size {
+		// Find the first fragment that ends after off.
+		i, _ := slices.BinarySearchFunc(s.datas, off, func(d SparseEntry, off int64) int {
+			if d.endOffset() <= off {
+				return -1
+			}
+			return +1
+		})
+		d := s.datas[i]
+		var m int
+		if off < d.Offset {
+			// off is in a hole, which reads as zeros.
+			m = int(min(int64(len(p)), d.Offset-off))
+			clear(p[:m])
+		} else {
+			m = int(min(int64(len(p)), d.endOffset()-off))
+			m, err = s.r.ReadAt(p[:m], s.phys[i]+off-d.Offset)
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+		}
+		n += m
+		off += int64(m)
+		p = p[m:]
+		if err != nil {
+			return n, err
+		}
+	}
+	if len(p) > 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
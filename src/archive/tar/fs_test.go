@@ -0,0 +1,212 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	var b bytes.Buffer
+	tw := NewWriter(&b)
+	add := func(hdr *Header, content string) {
+		t.Helper()
+		if hdr.Typeflag == TypeReg {
+			hdr.Size = int64(len(content))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(&Header{Typeflag: TypeDir, Name: "./", Mode: 0o755}, "")
+	add(&Header{Typeflag: TypeDir, Name: "./dir/", Mode: 0o755}, "")
+	add(&Header{Typeflag: TypeReg, Name: "./dir/file", Mode: 0o644}, "old contents")
+	add(&Header{Typeflag: TypeReg, Name: "./dir/file", Mode: 0o644}, "file contents")
+	add(&Header{Typeflag: TypeReg, Name: "/abs/file", Mode: 0o644}, "abs")
+	add(&Header{Typeflag: TypeReg, Name: "implicit/a/b", Mode: 0o600}, "implicit")
+	add(&Header{Typeflag: TypeLink, Name: "hardlink", Linkname: "./dir/file"}, "")
+	add(&Header{Typeflag: TypeSymlink, Name: "dir/rel", Linkname: "../implicit/a/b"}, "")
+	add(&Header{Typeflag: TypeSymlink, Name: "abslink", Linkname: "/dir/file"}, "")
+	add(&Header{Typeflag: TypeSymlink, Name: "escape", Linkname: "../../../dir/file"}, "")
+	add(&Header{Typeflag: TypeFifo, Name: "fifo", Mode: 0o644}, "")
+	sparse := &Header{
+		Typeflag:    TypeReg,
+		Name:        "sparse",
+		Size:        3 << 10,
+		SparseHoles: []SparseEntry{{Offset: 0, Length: 1 << 10}, {Offset: 2 << 10, Length: 1 << 10}},
+		Format:      FormatPAX,
+	}
+	if err := tw.WriteHeader(sparse); err != nil {
+		t.Fatal(err)
+	}
+	sparseData := strings.Repeat("sparse!\n", 128)
+	if _, err := tw.Write(make([]byte, 1<<10)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(tw, sparseData); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(make([]byte, 1<<10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := NewFS(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"dir/file":     "file contents",
+		"abs/file":     "abs",
+		"implicit/a/b": "implicit",
+		"hardlink":     "file contents",
+		"dir/rel":      "implicit",
+		"abslink":      "file contents",
+		"escape":       "file contents",
+		"fifo":         "",
+		"sparse":       strings.Repeat("\x00", 1<<10) + sparseData + strings.Repeat("\x00", 1<<10),
+	} {
+		got, err := fs.ReadFile(fsys, name)
+		if err != nil || string(got) != want {
+			t.Errorf("ReadFile(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	for _, name := range []string{"dir/file/x", "missing", "dir/../hardlink"} {
+		if _, err := fsys.Open(name); err == nil {
+			t.Errorf("Open(%q) succeeded", name)
+		}
+	}
+	if target, err := fsys.ReadLink("dir/rel"); err != nil || target != "../implicit/a/b" {
+		t.Errorf("ReadLink(dir/rel) = %q, %v", target, err)
+	}
+	if _, err := fsys.ReadLink("dir/file"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("ReadLink(dir/file): err = %v, want %v", err, fs.ErrInvalid)
+	}
+	if fi, err := fsys.Stat("implicit"); err != nil || fi.Mode() != fs.ModeDir|0o555 {
+		t.Errorf("Stat(implicit) = %v, %v; want mode %v", fi, err, fs.ModeDir|0o555)
+	}
+
+	// The file must support random access.
+	f, err := fsys.Open("sparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.(io.Seeker).Seek(1<<10, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(f, buf); err != nil || string(buf) != "sparse!\n" {
+		t.Errorf("Read after Seek = %q, %v", buf, err)
+	}
+	if n, err := f.(io.ReaderAt).ReadAt(buf, 3<<10-4); n != 4 || err != io.EOF || string(buf[:4]) != "\x00\x00\x00\x00" {
+		t.Errorf("ReadAt at end = %d, %v", n, err)
+	}
+
+	if err := fstest.TestFS(fsys, "dir/file", "abs/file", "implicit/a/b", "hardlink", "sparse", "fifo"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFSSymlinks(t *testing.T) {
+	archive := makeTar(t,
+		&Header{Typeflag: TypeReg, Name: "dir/file"},
+		&Header{Typeflag: TypeSymlink, Name: "dirlink", Linkname: "dir"},
+		&Header{Typeflag: TypeSymlink, Name: "dir/up", Linkname: ".."},
+		&Header{Typeflag: TypeSymlink, Name: "chain", Linkname: "dir/up/dirlink/file"},
+		&Header{Typeflag: TypeSymlink, Name: "loop", Linkname: "loop2"},
+		&Header{Typeflag: TypeSymlink, Name: "loop2", Linkname: "./loop"},
+		&Header{Typeflag: TypeSymlink, Name: "dangling", Linkname: "missing"},
+	)
+	fsys, err := NewFS(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"dirlink/file", "dir/up/dir/file", "chain"} {
+		if got, err := fs.ReadFile(fsys, name); err != nil || string(got) != "dir/file" {
+			t.Errorf("ReadFile(%q) = %q, %v; want %q", name, got, err, "dir/file")
+		}
+	}
+	if fi, err := fsys.Stat("dirlink"); err != nil || !fi.IsDir() || fi.Name() != "dirlink" {
+		t.Errorf("Stat(dirlink) = %v, %v; want directory named dirlink", fi, err)
+	}
+	if fi, err := fsys.Lstat("dirlink"); err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("Lstat(dirlink) = %v, %v; want symlink", fi, err)
+	}
+	if entries, err := fsys.ReadDir("dirlink"); err != nil || len(entries) != 2 || entries[0].Name() != "file" {
+		t.Errorf("ReadDir(dirlink) = %v, %v; want [file up]", entries, err)
+	}
+	if _, err := fsys.Open("loop"); !errors.Is(err, errTooManyLinks) {
+		t.Errorf("Open(loop): err = %v, want %v", err, errTooManyLinks)
+	}
+	if _, err := fsys.Open("dangling"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open(dangling): err = %v, want %v", err, fs.ErrNotExist)
+	}
+	if _, err := fsys.Lstat("dangling"); err != nil {
+		t.Errorf("Lstat(dangling): %v", err)
+	}
+}
+
+func TestFSTestdata(t *testing.T) {
+	// Every archive that can be read must produce a valid FS.
+	files := []string{"gnu.tar", "pax.tar", "sparse-formats.tar", "star.tar", "v7.tar", "ustar.tar", "hardlink.tar"}
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			data, err := os.ReadFile("testdata/" + file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fsys, err := NewFS(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The contents of each file must match those read by a Reader.
+			want := make(map[string][]byte)
+			tr := NewReader(bytes.NewReader(data))
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if hdr.Typeflag != TypeReg && hdr.Typeflag != TypeGNUSparse {
+					continue
+				}
+				b, err := io.ReadAll(tr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want[fsValidName(hdr.Name)] = b
+			}
+			for name, b := range want {
+				got, err := fs.ReadFile(fsys, name)
+				if err != nil || !bytes.Equal(got, b) {
+					t.Errorf("%s: got %d bytes, %v; want %d bytes", name, len(got), err, len(b))
+				}
+			}
+			if err := fstest.TestFS(fsys, slices.Collect(maps.Keys(want))...); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
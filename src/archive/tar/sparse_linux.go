@@ -0,0 +1,65 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+func init() {
+	sysSparseDetect = sparseDetectLinux
+}
+
+// Values of whence for lseek on Linux.
+const (
+	seekData = 3 // SEEK_DATA: seek to the next data at or after offset
+	seekHole = 4 // SEEK_HOLE: seek to the next hole at or after offset
+)
+
+func sparseDetectLinux(f *os.File, size int64) (sph sparseHoles, err error) {
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if _, err2 := f.Seek(pos, io.SeekStart); err == nil {
+			err = err2
+		}
+	}()
+
+	for off := int64(0); off < size; {
+		// Find the start of the next hole.
+		// The end of the file counts as a hole.
+		start, err := f.Seek(off, seekHole)
+		switch {
+		case errors.Is(err, syscall.EINVAL):
+			return nil, nil // SEEK_HOLE is not supported
+		case errors.Is(err, syscall.ENXIO):
+			return sph, nil // The file is shorter than size
+		case err != nil:
+			return nil, err
+		}
+		if start >= size {
+			break
+		}
+
+		// Find the end of the hole,
+		// which may extend to the end of the file.
+		end, err := f.Seek(start, seekData)
+		switch {
+		case errors.Is(err, syscall.ENXIO):
+			end = size
+		case err != nil:
+			return nil, err
+		}
+		end = min(end, size)
+		sph = append(sph, SparseEntry{Offset: start, Length: end - start})
+		off = end
+	}
+	return sph, nil
+}
@@ -102,88 +102,88 @@ func (f *testFile) Seek(pos int64, whence int) (int64, error) {
 
 func TestSparseEntries(t *testing.T) {
 	vectors := []struct {
-		in   []sparseEntry
+		in   []SparseEntry
 		size int64
 
 		wantValid    bool          // Result of validateSparseEntries
-		wantAligned  []sparseEntry // Result of alignSparseEntries
-		wantInverted []sparseEntry // Result of invertSparseEntries
+		wantAligned  []SparseEntry // Result of alignSparseEntries
+		wantInverted []SparseEntry // Result of invertSparseEntries
 	}{{
-		in: []sparseEntry{}, size: 0,
+		in: []SparseEntry{}, size: 0,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 0}},
+		wantInverted: []SparseEntry{{0, 0}},
 	}, {
-		in: []sparseEntry{}, size: 5000,
+		in: []SparseEntry{}, size: 5000,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{0, 5000}},
 	}, {
-		in: []sparseEntry{{0, 5000}}, size: 5000,
+		in: []SparseEntry{{0, 5000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 5000}},
-		wantInverted: []sparseEntry{{5000, 0}},
+		wantAligned:  []SparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{5000, 0}},
 	}, {
-		in: []sparseEntry{{1000, 4000}}, size: 5000,
+		in: []SparseEntry{{1000, 4000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{1024, 3976}},
-		wantInverted: []sparseEntry{{0, 1000}, {5000, 0}},
+		wantAligned:  []SparseEntry{{1024, 3976}},
+		wantInverted: []SparseEntry{{0, 1000}, {5000, 0}},
 	}, {
-		in: []sparseEntry{{0, 3000}}, size: 5000,
+		in: []SparseEntry{{0, 3000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 2560}},
-		wantInverted: []sparseEntry{{3000, 2000}},
+		wantAligned:  []SparseEntry{{0, 2560}},
+		wantInverted: []SparseEntry{{3000, 2000}},
 	}, {
-		in: []sparseEntry{{3000, 2000}}, size: 5000,
+		in: []SparseEntry{{3000, 2000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{3072, 1928}},
-		wantInverted: []sparseEntry{{0, 3000}, {5000, 0}},
+		wantAligned:  []SparseEntry{{3072, 1928}},
+		wantInverted: []SparseEntry{{0, 3000}, {5000, 0}},
 	}, {
-		in: []sparseEntry{{2000, 2000}}, size: 5000,
+		in: []SparseEntry{{2000, 2000}}, size: 5000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{2048, 1536}},
-		wantInverted: []sparseEntry{{0, 2000}, {4000, 1000}},
+		wantAligned:  []SparseEntry{{2048, 1536}},
+		wantInverted: []SparseEntry{{0, 2000}, {4000, 1000}},
 	}, {
-		in: []sparseEntry{{0, 2000}, {8000, 2000}}, size: 10000,
+		in: []SparseEntry{{0, 2000}, {8000, 2000}}, size: 10000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 1536}, {8192, 1808}},
-		wantInverted: []sparseEntry{{2000, 6000}, {10000, 0}},
+		wantAligned:  []SparseEntry{{0, 1536}, {8192, 1808}},
+		wantInverted: []SparseEntry{{2000, 6000}, {10000, 0}},
 	}, {
-		in: []sparseEntry{{0, 2000}, {2000, 2000}, {4000, 0}, {4000, 3000}, {7000, 1000}, {8000, 0}, {8000, 2000}}, size: 10000,
+		in: []SparseEntry{{0, 2000}, {2000, 2000}, {4000, 0}, {4000, 3000}, {7000, 1000}, {8000, 0}, {8000, 2000}}, size: 10000,
 		wantValid:    true,
-		wantAligned:  []sparseEntry{{0, 1536}, {2048, 1536}, {4096, 2560}, {7168, 512}, {8192, 1808}},
-		wantInverted: []sparseEntry{{10000, 0}},
+		wantAligned:  []SparseEntry{{0, 1536}, {2048, 1536}, {4096, 2560}, {7168, 512}, {8192, 1808}},
+		wantInverted: []SparseEntry{{10000, 0}},
 	}, {
-		in: []sparseEntry{{0, 0}, {1000, 0}, {2000, 0}, {3000, 0}, {4000, 0}, {5000, 0}}, size: 5000,
+		in: []SparseEntry{{0, 0}, {1000, 0}, {2000, 0}, {3000, 0}, {4000, 0}, {5000, 0}}, size: 5000,
 		wantValid:    true,
-		wantInverted: []sparseEntry{{0, 5000}},
+		wantInverted: []SparseEntry{{0, 5000}},
 	}, {
-		in: []sparseEntry{{1, 0}}, size: 0,
+		in: []SparseEntry{{1, 0}}, size: 0,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{-1, 0}}, size: 100,
+		in: []SparseEntry{{-1, 0}}, size: 100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{0, -1}}, size: 100,
+		in: []SparseEntry{{0, -1}}, size: 100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{0, 0}}, size: -100,
+		in: []SparseEntry{{0, 0}}, size: -100,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{math.MaxInt64, 3}, {6, -5}}, size: 35,
+		in: []SparseEntry{{math.MaxInt64, 3}, {6, -5}}, size: 35,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{1, 3}, {6, -5}}, size: 35,
+		in: []SparseEntry{{1, 3}, {6, -5}}, size: 35,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{math.MaxInt64, math.MaxInt64}}, size: math.MaxInt64,
+		in: []SparseEntry{{math.MaxInt64, math.MaxInt64}}, size: math.MaxInt64,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{3, 3}}, size: 5,
+		in: []SparseEntry{{3, 3}}, size: 5,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{2, 0}, {1, 0}, {0, 0}}, size: 3,
+		in: []SparseEntry{{2, 0}, {1, 0}, {0, 0}}, size: 3,
 		wantValid: false,
 	}, {
-		in: []sparseEntry{{1, 3}, {2, 2}}, size: 10,
+		in: []SparseEntry{{1, 3}, {2, 2}}, size: 10,
 		wantValid: false,
 	}}
 
@@ -195,11 +195,11 @@ func TestSparseEntries(t *testing.T) {
 		if !v.wantValid {
 			continue
 		}
-		gotAligned := alignSparseEntries(append([]sparseEntry{}, v.in...), v.size)
+		gotAligned := alignSparseEntries(append([]SparseEntry{}, v.in...), v.size)
 		if !slices.Equal(gotAligned, v.wantAligned) {
 			t.Errorf("test %d, alignSparseEntries():\ngot  %v\nwant %v", i, gotAligned, v.wantAligned)
 		}
-		gotInverted := invertSparseEntries(append([]sparseEntry{}, v.in...), v.size)
+		gotInverted := invertSparseEntries(append([]SparseEntry{}, v.in...), v.size)
 		if !slices.Equal(gotInverted, v.wantInverted) {
 			t.Errorf("test %d, inverseSparseEntries():\ngot  %v\nwant %v", i, gotInverted, v.wantInverted)
 		}
@@ -329,6 +329,164 @@ func TestRoundTrip(t *testing.T) {
 	}
 }
 
+func TestDetectSparseHoles(t *testing.T) {
+	// Write a file that is mostly holes.
+	const size = 1 << 20
+	f, err := os.Create(filepath.Join(t.TempDir(), "sparse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data := bytes.Repeat([]byte("0123456789abcdef"), 4096/16)
+	if _, err := f.WriteAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(data, size/2); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, size)
+	copy(want, data)
+	copy(want[size/2:], data)
+
+	hdr := &Header{Typeflag: TypeReg, Name: "sparse", Size: size}
+	if err := hdr.DetectSparseHoles(f); err != nil {
+		t.Fatal(err)
+	}
+	if len(hdr.SparseHoles) == 0 {
+		t.Skip("no holes detected; file system or OS does not support SEEK_HOLE")
+	}
+	if pos, err := f.Seek(0, io.SeekCurrent); err != nil || pos != 0 {
+		t.Fatalf("DetectSparseHoles changed offset to %d, %v", pos, err)
+	}
+	wantHoles := []SparseEntry{
+		{Offset: int64(len(data)), Length: size/2 - int64(len(data))},
+		{Offset: size/2 + int64(len(data)), Length: size/2 - int64(len(data))},
+	}
+	if !slices.Equal(hdr.SparseHoles, wantHoles) {
+		// File systems may record holes with a coarser granularity,
+		// so just check that the holes are consistent with the data.
+		t.Logf("SparseHoles = %v, want %v", hdr.SparseHoles, wantHoles)
+		for _, s := range hdr.SparseHoles {
+			if !bytes.Equal(want[s.Offset:s.endOffset()], make([]byte, s.Length)) {
+				t.Fatalf("hole %v contains data", s)
+			}
+		}
+	}
+
+	// Archive the file, and check that it reads back correctly.
+	var b bytes.Buffer
+	tw := NewWriter(&b)
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.ReadFrom(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() > size/4 {
+		t.Errorf("archive of sparse file is %d bytes, want less than %d", b.Len(), size/4)
+	}
+	tr := NewReader(&b)
+	rHdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rHdr.Name != hdr.Name || rHdr.Size != size {
+		t.Errorf("got Name %q, Size %d; want %q, %d", rHdr.Name, rHdr.Size, hdr.Name, size)
+	}
+	got, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("sparse file contents differ after round trip")
+	}
+}
+
+func TestXattrs(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	for _, name := range []string{src, dst} {
+		if err := os.WriteFile(name, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	xattrs := map[string]string{
+		"user.gopher": "hello",
+		"user.binary": "\x00\x01\xff",
+		"user.empty":  "",
+	}
+	hdr := &Header{Name: "src", PAXRecords: make(map[string]string)}
+	for k, v := range xattrs {
+		hdr.PAXRecords[paxSchilyXattr+k] = v
+	}
+	if err := hdr.ApplyXattrs(src); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Skipf("extended attributes not supported: %v", err)
+		}
+		t.Fatal(err)
+	}
+
+	// Read the attributes back, replacing any stale records.
+	fi, err := os.Lstat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr, err = FileInfoHeader(fi, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr.PAXRecords = map[string]string{paxSchilyXattr + "user.stale": "x", "GOLANG.pkg": "tar"}
+	if err := hdr.ReadXattrs(src); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for k, v := range hdr.PAXRecords {
+		if key, ok := strings.CutPrefix(k, paxSchilyXattr); ok && strings.HasPrefix(key, "user.") {
+			got[key] = v
+		}
+	}
+	if !maps.Equal(got, xattrs) {
+		t.Fatalf("ReadXattrs: got %q, want %q", got, xattrs)
+	}
+	if hdr.PAXRecords["GOLANG.pkg"] != "tar" {
+		t.Error("ReadXattrs removed an unrelated PAX record")
+	}
+
+	// The attributes must survive a round trip through an archive.
+	var b bytes.Buffer
+	tw := NewWriter(&b)
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte("data"))
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rHdr, err := NewReader(&b).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rHdr.ApplyXattrs(dst); err != nil {
+		t.Fatal(err)
+	}
+	var dstHdr Header
+	if err := dstHdr.ReadXattrs(dst); err != nil {
+		t.Fatal(err)
+	}
+	delete(hdr.PAXRecords, "GOLANG.pkg")
+	if !maps.Equal(dstHdr.PAXRecords, hdr.PAXRecords) {
+		t.Errorf("extracted file has attributes %q, want %q", dstHdr.PAXRecords, hdr.PAXRecords)
+	}
+}
+
 type headerRoundTripTest struct {
 	h  *Header
 	fm fs.FileMode
@@ -735,6 +893,27 @@ func TestHeaderAllowedFormats(t *testing.T) {
 	}, {
 		header:  &Header{Name: "foo/", Typeflag: TypeSymlink},
 		formats: FormatUSTAR | FormatPAX | FormatGNU,
+	}, {
+		header:  &Header{Typeflag: TypeReg, Size: 1000, SparseHoles: []SparseEntry{{0, 1000}}},
+		formats: FormatPAX,
+	}, {
+		header:  &Header{Typeflag: TypeReg, Size: 1000, SparseHoles: []SparseEntry{{0, 1000}}, Format: FormatUSTAR},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Typeflag: TypeReg, Size: 1000, SparseHoles: []SparseEntry{{0, 1000}}, Format: FormatGNU},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Typeflag: TypeReg, Size: 1000, SparseHoles: []SparseEntry{{500, 501}}},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Typeflag: TypeReg, Size: 1000, SparseHoles: []SparseEntry{{500, 100}, {0, 100}}},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Typeflag: TypeGNUSparse, Size: 1000, SparseHoles: []SparseEntry{{0, 1000}}},
+		formats: FormatUnknown,
+	}, {
+		header:  &Header{Typeflag: TypeSymlink, SparseHoles: []SparseEntry{{0, 0}}},
+		formats: FormatUnknown,
 	}}
 
 	for i, v := range vectors {
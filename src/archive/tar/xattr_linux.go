@@ -0,0 +1,81 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tar
+
+import (
+	"bytes"
+	"errors"
+	"internal/syscall/unix"
+	"io/fs"
+	"syscall"
+)
+
+func init() {
+	sysListXattrs = listXattrsLinux
+	sysSetXattr = setXattrLinux
+}
+
+func listXattrsLinux(name string) (map[string]string, error) {
+	names, err := getXattr(func(b []byte) (int, error) {
+		return unix.Llistxattr(name, b)
+	})
+	switch {
+	case errors.Is(err, syscall.ENOTSUP):
+		return nil, nil // The file system does not support extended attributes
+	case err != nil:
+		return nil, &fs.PathError{Op: "llistxattr", Path: name, Err: err}
+	}
+
+	var xattrs map[string]string
+	for len(names) > 0 {
+		key, rest, _ := bytes.Cut(names, []byte{0})
+		names = rest
+		if len(key) == 0 {
+			continue
+		}
+		val, err := getXattr(func(b []byte) (int, error) {
+			return unix.Lgetxattr(name, string(key), b)
+		})
+		switch {
+		case errors.Is(err, syscall.ENODATA):
+			continue // The attribute was removed after it was listed
+		case err != nil:
+			return nil, &fs.PathError{Op: "lgetxattr", Path: name, Err: err}
+		}
+		if xattrs == nil {
+			xattrs = make(map[string]string)
+		}
+		xattrs[string(key)] = string(val)
+	}
+	return xattrs, nil
+}
+
+// getXattr calls get, which is either llistxattr or lgetxattr,
+// with a buffer large enough to hold the result.
+func getXattr(get func([]byte) (int, error)) ([]byte, error) {
+	for {
+		// Passing an empty buffer reports the size required.
+		n, err := get(nil)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		b := make([]byte, n)
+		n, err = get(b)
+		if errors.Is(err, syscall.ERANGE) {
+			continue // The result grew between the two calls
+		}
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+
+func setXattrLinux(name, key, value string) error {
+	if err := unix.Lsetxattr(name, key, []byte(value), 0); err != nil {
+		return &fs.PathError{Op: "lsetxattr", Path: name, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"hash"
+	"io"
+)
+
+// The WinZip AES format is described at
+// https://www.winzip.com/en/support/aes-encryption/.
+//
+// The stored contents of an encrypted file are a random salt, a 2-byte
+// password verification value, the encrypted data, and a 10-byte
+// authentication code. The data is encrypted with AES in CTR mode and
+// authenticated with HMAC-SHA1 of the encrypted data. The keys for both,
+// and the verification value, are derived from the password and salt
+// using PBKDF2 with HMAC-SHA1.
+
+const (
+	aesIterations  = 1000 // PBKDF2 iteration count
+	aesVerifierLen = 2    // length of the password verification value
+	aesMACLen      = 10   // length of the authentication code
+)
+
+// keyLen returns the AES key size of e in bytes,
+// or 0 if e is not an AES encryption method.
+func (e Encryption) keyLen() int {
+	switch e {
+	case AES128:
+		return 16
+	case AES192:
+		return 24
+	case AES256:
+		return 32
+	}
+	return 0
+}
+
+// saltLen returns the length of the salt used with e.
+func (e Encryption) saltLen() int {
+	return e.keyLen() / 2
+}
+
+// overhead returns the number of bytes that encryption with e
+// adds to the contents of a file.
+func (e Encryption) overhead() int {
+	return e.saltLen() + aesVerifierLen + aesMACLen
+}
+
+// aesKeys derives the encryption key, authentication key, and
+// password verification value for a file encrypted with e.
+func aesKeys(e Encryption, password string, salt []byte) (encKey, macKey, verifier []byte) {
+	n := e.keyLen()
+	k := pbkdf2([]byte(password), salt, aesIterations, 2*n+aesVerifierLen)
+	return k[:n], k[n : 2*n], k[2*n:]
+}
+
+// pbkdf2 derives a key of keyLen bytes from password and salt,
+// using PBKDF2 with HMAC-SHA1 as specified in RFC 8018, Section 5.2.
+func pbkdf2(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		// U_1 = PRF(password, salt || uint32(block)),
+		// U_n = PRF(password, U_(n-1)), and
+		// T_block = U_1 ^ U_2 ^ ... ^ U_iter.
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			subtle.XORBytes(t, t, u)
+		}
+	}
+	return dk[:keyLen]
+}
+
+// aesCTR is the AES CTR mode of the WinZip AES format. Unlike the
+// mode implemented by [cipher.NewCTR], it uses a little-endian counter
+// whose first value is 1.
+type aesCTR struct {
+	block  cipher.Block
+	ctr    [aes.BlockSize]byte
+	stream [aes.BlockSize]byte
+	used   int // number of bytes of stream already used
+}
+
+func newAESCTR(key []byte) *aesCTR {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic("zip: " + err.Error()) // key lengths are fixed by keyLen
+	}
+	return &aesCTR{block: block, used: aes.BlockSize}
+}
+
+func (c *aesCTR) XORKeyStream(dst, src []byte) {
+	for len(src) > 0 {
+		if c.used == len(c.stream) {
+			for i := range c.ctr {
+				c.ctr[i]++
+				if c.ctr[i] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.stream[:], c.ctr[:])
+			c.used = 0
+		}
+		n := subtle.XORBytes(dst, src, c.stream[c.used:])
+		c.used += n
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// aesWriter encrypts the contents of a file, writing them to w.
+// The salt and password verification value are written before
+// the first data, and the authentication code by Close.
+type aesWriter struct {
+	w      io.Writer
+	ctr    *aesCTR
+	mac    hash.Hash
+	header []byte // salt and verification value, if not yet written
+	buf    [4096]byte
+}
+
+func newAESWriter(w io.Writer, e Encryption, password string) (*aesWriter, error) {
+	salt := make([]byte, e.saltLen())
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	encKey, macKey, verifier := aesKeys(e, password, salt)
+	return &aesWriter{
+		w:      w,
+		ctr:    newAESCTR(encKey),
+		mac:    hmac.New(sha1.New, macKey),
+		header: append(salt, verifier...),
+	}, nil
+}
+
+func (w *aesWriter) writeHeader() error {
+	if w.header == nil {
+		return nil
+	}
+	_, err := w.w.Write(w.header)
+	w.header = nil
+	return err
+}
+
+func (w *aesWriter) Write(p []byte) (int, error) {
+	if err := w.writeHeader(); err != nil {
+		return 0, err
+	}
+	n := 0
+	for len(p) > 0 {
+		b := w.buf[:min(len(p), len(w.buf))]
+		w.ctr.XORKeyStream(b, p[:len(b)])
+		w.mac.Write(b)
+		m, err := w.w.Write(b)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[len(b):]
+	}
+	return n, nil
+}
+
+func (w *aesWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	_, err := w.w.Write(w.mac.Sum(nil)[:aesMACLen])
+	return err
+}
+
+// aesReader decrypts the contents of a file.
+type aesReader struct {
+	data *io.SectionReader // the encrypted data
+	code *io.SectionReader // the authentication code
+	ctr  *aesCTR
+	mac  hash.Hash
+}
+
+// newAESReader returns a reader that decrypts the stored contents r
+// of a file encrypted with e. It returns ErrPassword if the password
+// does not match the verification value.
+func newAESReader(r *io.SectionReader, e Encryption, password string) (*aesReader, error) {
+	hdr := make([]byte, e.saltLen()+aesVerifierLen)
+	size := r.Size() - int64(len(hdr)) - aesMACLen
+	if size < 0 {
+		return nil, ErrFormat
+	}
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, err
+	}
+	if password == "" {
+		return nil, ErrPassword
+	}
+	salt := hdr[:e.saltLen()]
+	encKey, macKey, verifier := aesKeys(e, password, salt)
+	if subtle.ConstantTimeCompare(verifier, hdr[len(salt):]) != 1 {
+		return nil, ErrPassword
+	}
+	return &aesReader{
+		data: io.NewSectionReader(r, int64(len(hdr)), size),
+		code: io.NewSectionReader(r, int64(len(hdr))+size, aesMACLen),
+		ctr:  newAESCTR(encKey),
+		mac:  hmac.New(sha1.New, macKey),
+	}, nil
+}
+
+func (r *aesReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	r.mac.Write(p[:n])
+	r.ctr.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+// verify reads any encrypted data not yet read
+// and checks the authentication code.
+func (r *aesReader) verify() error {
+	if _, err := io.Copy(r.mac, r.data); err != nil {
+		return err
+	}
+	code := make([]byte, aesMACLen)
+	if _, err := io.ReadFull(r.code, code); err != nil {
+		return err
+	}
+	if !hmac.Equal(r.mac.Sum(nil)[:aesMACLen], code) {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// setAESExtra removes any WinZip AES extra field from fh.Extra
+// and, if fh is encrypted, appends one describing fh.
+// It does not modify the array underlying the original fh.Extra.
+func setAESExtra(fh *FileHeader) {
+	if hasExtra(fh.Extra, aesExtraID) {
+		var extra []byte
+		for b := readBuf(fh.Extra); len(b) >= 4; {
+			field := b
+			tag := b.uint16()
+			size := int(b.uint16())
+			if len(b) < size {
+				extra = append(extra, field...)
+				break
+			}
+			b = b[size:]
+			if tag != aesExtraID {
+				extra = append(extra, field[:4+size]...)
+			}
+		}
+		fh.Extra = extra
+	}
+	if fh.Encryption != NoEncryption {
+		fh.Extra = appendAESExtra(fh.Extra[:len(fh.Extra):len(fh.Extra)], fh)
+	}
+}
+
+// appendAESExtra appends a WinZip AES extra field describing fh to extra.
+func appendAESExtra(extra []byte, fh *FileHeader) []byte {
+	var buf [11]byte
+	b := writeBuf(buf[:])
+	b.uint16(aesExtraID)
+	b.uint16(7) // size
+	b.uint16(fh.AESVersion)
+	b.uint16(0x4541) // vendor ID "AE"
+	b.uint8(uint8(fh.Encryption))
+	b.uint16(fh.Method)
+	return append(extra, buf[:]...)
+}
+
+// hasExtra reports whether extra contains a field with the given tag.
+func hasExtra(extra []byte, tag uint16) bool {
+	for b := readBuf(extra); len(b) >= 4; {
+		t := b.uint16()
+		size := int(b.uint16())
+		if len(b) < size {
+			break
+		}
+		if t == tag {
+			return true
+		}
+		b = b[size:]
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// Test vectors from RFC 6070.
+	tests := []struct {
+		password, salt string
+		iter, keyLen   int
+		want           string
+	}{
+		{"password", "salt", 1, 20, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"password", "salt", 2, 20, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{"password", "salt", 4096, 20, "4b007901b765489abead49d926f721d065a429c1"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 25, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+		{"pass\x00word", "sa\x00lt", 4096, 16, "56fa6aa75548099dcc37d7f03425e0c3"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2([]byte(tt.password), []byte(tt.salt), tt.iter, tt.keyLen))
+		if got != tt.want {
+			t.Errorf("pbkdf2(%q, %q, %d, %d) = %s, want %s", tt.password, tt.salt, tt.iter, tt.keyLen, got, tt.want)
+		}
+	}
+}
+
+func TestAESReader(t *testing.T) {
+	// winzip-aes.zip was written by libarchive, which uses AE-1
+	// for one file and AE-2 for the other.
+	r, err := OpenReader("testdata/winzip-aes.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	want := map[string]string{
+		"hello.txt":   "hello, world\n",
+		"gophers.txt": strings.Repeat("gophers ", 200) + "\n",
+	}
+	versions := map[uint16]bool{}
+	for _, f := range r.File {
+		if f.Encryption != AES256 || f.Method != Deflate {
+			t.Errorf("%s: Encryption = %d, Method = %d; want %d, %d", f.Name, f.Encryption, f.Method, AES256, Deflate)
+		}
+		versions[f.AESVersion] = true
+
+		if _, err := f.Open(); err != ErrPassword {
+			t.Errorf("%s: Open without password: err = %v, want %v", f.Name, err, ErrPassword)
+		}
+		if _, err := f.OpenWithPassword("wrong"); err != ErrPassword {
+			t.Errorf("%s: OpenWithPassword with wrong password: err = %v, want %v", f.Name, err, ErrPassword)
+		}
+		rc, err := f.OpenWithPassword("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		if string(got) != want[f.Name] {
+			t.Errorf("%s: got %q, want %q", f.Name, got, want[f.Name])
+		}
+	}
+	if !versions[1] || !versions[2] {
+		t.Errorf("got AES versions %v, want 1 and 2", versions)
+	}
+
+	r.SetPassword("secret")
+	b, err := r.Open("hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(b)
+	if err != nil || string(got) != want["hello.txt"] {
+		t.Errorf("Reader.Open = %q, %v; want %q", got, err, want["hello.txt"])
+	}
+}
+
+func TestAESRoundTrip(t *testing.T) {
+	content := bytes.Repeat([]byte("encrypted gophers\n"), 500)
+	for _, enc := range []Encryption{NoEncryption, AES128, AES192, AES256} {
+		for _, version := range []uint16{0, 1, 2} {
+			for _, method := range []uint16{Store, Deflate} {
+				t.Run(fmt.Sprintf("%d/AE-%d/%d", enc, version, method), func(t *testing.T) {
+					testAESRoundTrip(t, enc, version, method, content)
+				})
+			}
+		}
+	}
+}
+
+func testAESRoundTrip(t *testing.T, enc Encryption, version, method uint16, content []byte) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetPassword("secret")
+	fw, err := w.CreateHeader(&FileHeader{Name: "file", Method: method, Encryption: enc, AESVersion: version})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	if _, err := w.Create("dir/"); err != nil {
+		t.Fatal(err)
+	}
+	fw, err = w.Create("empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetPassword("")
+	fw, err = w.Create("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	wantEnc := enc
+	if enc == NoEncryption {
+		wantEnc = AES256
+	}
+	wantVersion := version
+	if version != 1 {
+		wantVersion = 2
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetPassword("secret")
+	for _, f := range r.File {
+		switch f.Name {
+		case "file":
+			if f.Encryption != wantEnc || f.AESVersion != wantVersion || f.Method != method {
+				t.Errorf("%s: Encryption = %d, AESVersion = %d, Method = %d; want %d, %d, %d",
+					f.Name, f.Encryption, f.AESVersion, f.Method, wantEnc, wantVersion, method)
+			}
+			if (wantVersion == 2) != (f.CRC32 == 0) {
+				t.Errorf("%s: AE-%d file has CRC32 %#x", f.Name, wantVersion, f.CRC32)
+			}
+		case "empty":
+			if f.Encryption != AES256 || f.AESVersion != 2 {
+				t.Errorf("%s: Encryption = %d, AESVersion = %d; want %d, 2", f.Name, f.Encryption, f.AESVersion, AES256)
+			}
+		default:
+			if f.Encryption != NoEncryption || f.Flags&0x1 != 0 {
+				t.Errorf("%s: Encryption = %d, Flags = %#x; want no encryption", f.Name, f.Encryption, f.Flags)
+			}
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		want := content
+		if f.Name == "dir/" || f.Name == "empty" {
+			want = nil
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: wrong content", f.Name)
+		}
+	}
+
+	// Encrypted files must survive being copied.
+	var buf2 bytes.Buffer
+	w = NewWriter(&buf2)
+	for _, f := range r.File {
+		if err := w.Copy(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r2, err := NewReader(bytes.NewReader(buf2.Bytes()), int64(buf2.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := r2.File[0].OpenWithPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(rc); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("copied file: got %d bytes, %v; want %d bytes", len(got), err, len(content))
+	}
+}
+
+func TestAESCorrupt(t *testing.T) {
+	content := []byte("some secret data")
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetPassword("secret")
+	fw, err := w.CreateHeader(&FileHeader{Name: "file", Method: Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := buf.Bytes()
+	i := bytes.Index(b, []byte("file"))
+	start := i + len("file") + 11 + 16 + 2 // name, AES extra, salt, and verifier
+	for _, off := range []int{0, len(content) - 1, len(content), len(content) + 9} {
+		corrupt := bytes.Clone(b)
+		corrupt[start+off] ^= 1
+		r, err := NewReader(bytes.NewReader(corrupt), int64(len(corrupt)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := r.File[0].OpenWithPassword("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(rc); err != ErrChecksum {
+			t.Errorf("corrupt byte %d: got err %v, want %v", off, err, ErrChecksum)
+		}
+	}
+}
+
+func TestUnsupportedEncryption(t *testing.T) {
+	// A file encrypted with the traditional PKWARE encryption,
+	// which has the encrypted flag but no AES extra field.
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	fw, err := w.CreateRaw(&FileHeader{Name: "file", Method: Store, Flags: 0x1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(make([]byte, 12))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.File[0].OpenWithPassword("secret"); err != ErrUnsupportedEncryption {
+		t.Errorf("OpenWithPassword: err = %v, want %v", err, ErrUnsupportedEncryption)
+	}
+}
+
+func TestParallelWriterAES(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewParallelWriter(&buf)
+	pw.SetPassword("secret")
+	w1, err := pw.Create("encrypted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw.SetPassword("")
+	w2, err := pw.Create("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w2, "plain text")
+	w2.Close()
+	io.WriteString(w1, "secret text")
+	w1.Close()
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetPassword("secret")
+	for i, want := range []string{"secret text", "plain text"} {
+		f := r.File[i]
+		if (f.Encryption != NoEncryption) != (i == 0) {
+			t.Errorf("%s: Encryption = %d", f.Name, f.Encryption)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, %v; want %q", f.Name, got, err, want)
+		}
+	}
+}
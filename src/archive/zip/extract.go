@@ -0,0 +1,180 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"fmt"
+	"internal/extract"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// ExtractOptions are limits applied by [Reader.Extract].
+// A zero limit means no limit.
+type ExtractOptions struct {
+	// MaxFiles is the maximum number of files in the archive.
+	MaxFiles int
+
+	// MaxSize is the maximum total number of bytes
+	// written to the files extracted from the archive.
+	MaxSize int64
+
+	// MaxRatio is the maximum ratio of the uncompressed size
+	// of any file to its compressed size.
+	MaxRatio int
+}
+
+// An InsecurePathError reports a file whose name would place it
+// outside the extraction directory, either directly or by way of
+// a symbolic link. It wraps [ErrInsecurePath].
+type InsecurePathError struct {
+	Name string // name of the file
+}
+
+func (e *InsecurePathError) Error() string {
+	return fmt.Sprintf("%v: %q", ErrInsecurePath, e.Name)
+}
+
+func (e *InsecurePathError) Unwrap() error { return ErrInsecurePath }
+
+// An InsecureLinkError reports a symbolic link whose target is
+// outside the extraction directory. It wraps [ErrInsecurePath].
+type InsecureLinkError struct {
+	Name     string // name of the file
+	Linkname string // target of the link
+}
+
+func (e *InsecureLinkError) Error() string {
+	return fmt.Sprintf("zip: insecure link target: %q -> %q", e.Name, e.Linkname)
+}
+
+func (e *InsecureLinkError) Unwrap() error { return ErrInsecurePath }
+
+// A FileLimitError reports an archive with more files than
+// permitted by [ExtractOptions.MaxFiles].
+type FileLimitError struct {
+	Limit int
+}
+
+func (e *FileLimitError) Error() string {
+	return fmt.Sprintf("zip: archive has more than %d files", e.Limit)
+}
+
+// A SizeLimitError reports an archive whose contents are larger than
+// permitted by [ExtractOptions.MaxSize].
+type SizeLimitError struct {
+	Name  string // name of the file being extracted when the limit was reached
+	Limit int64
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("zip: extracting %q exceeds size limit of %d bytes", e.Name, e.Limit)
+}
+
+// A RatioLimitError reports a file that is compressed more highly than
+// permitted by [ExtractOptions.MaxRatio].
+type RatioLimitError struct {
+	Name  string // name of the file
+	Limit int
+}
+
+func (e *RatioLimitError) Error() string {
+	return fmt.Sprintf("zip: %q exceeds compression ratio limit of %d", e.Name, e.Limit)
+}
+
+// Extract extracts the files of the archive into the directory dir,
+// which is created if necessary. If opts is nil, no limits apply.
+// Encrypted files are decrypted with the password set by [Reader.SetPassword].
+//
+// Extract creates directories, regular files, and symbolic links.
+// The permission bits of each file are preserved, subject to the umask,
+// but not its set-user-ID, set-group-ID, or sticky bits. Directories are
+// always made readable, writable, and searchable by their owner, so that
+// their contents can be extracted. A file with no permission bits is
+// created with mode 0o666, or 0o777 for a directory. The modification
+// times of regular files are preserved. A file replaces any existing file
+// of the same name, other than a directory.
+//
+// Extract never writes outside dir. It returns an [*InsecurePathError]
+// for a file whose name is not local (see [filepath.IsLocal]) or that
+// would be created beneath a symbolic link, and an [*InsecureLinkError]
+// for a symbolic link whose target is absolute, outside dir, or passes
+// through another symbolic link. The limits
+// in opts are checked before any file is extracted, and are reported with
+// a [*FileLimitError], [*SizeLimitError], or [*RatioLimitError].
+// Extract stops at the first error, which may leave some files extracted.
+// It assumes that dir is not concurrently modified by other processes.
+func (r *Reader) Extract(dir string, opts *ExtractOptions) error {
+	var o ExtractOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxFiles > 0 && len(r.File) > o.MaxFiles {
+		return &FileLimitError{Limit: o.MaxFiles}
+	}
+	var total uint64
+	for _, f := range r.File {
+		if o.MaxRatio > 0 && f.UncompressedSize64/max(f.CompressedSize64, 1) > uint64(o.MaxRatio) {
+			return &RatioLimitError{Name: f.Name, Limit: o.MaxRatio}
+		}
+		total += f.UncompressedSize64
+		if o.MaxSize > 0 && (total > uint64(o.MaxSize) || total < f.UncompressedSize64) {
+			return &SizeLimitError{Name: f.Name, Limit: o.MaxSize}
+		}
+	}
+
+	d, err := extract.NewDir(dir, o.MaxSize)
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		linkname, err := f.extract(d)
+		switch err {
+		case nil:
+			continue
+		case extract.ErrInsecurePath:
+			err = &InsecurePathError{Name: f.Name}
+		case extract.ErrInsecureLink:
+			err = &InsecureLinkError{Name: f.Name, Linkname: linkname}
+		case extract.ErrSizeLimit:
+			err = &SizeLimitError{Name: f.Name, Limit: o.MaxSize}
+		}
+		return err
+	}
+	return nil
+}
+
+// maxLinkTarget is the maximum length of a symbolic link target.
+// It applies even without a size limit, since the target is held
+// in memory.
+const maxLinkTarget = 4 << 10
+
+// extract extracts f into d. For a symbolic link,
+// it also returns the target of the link.
+func (f *File) extract(d *extract.Dir) (linkname string, err error) {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return "", d.Mkdir(f.Name, mode.Perm())
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	if mode&fs.ModeSymlink != 0 {
+		var b strings.Builder
+		if err := d.Copy(&b, io.LimitReader(rc, maxLinkTarget+1)); err != nil {
+			return "", err
+		}
+		if b.Len() > maxLinkTarget {
+			return "", fmt.Errorf("zip: symbolic link target of %q is too long", f.Name)
+		}
+		return b.String(), d.Symlink(f.Name, b.String())
+	}
+	return "", d.WriteFile(f.Name, rc, mode.Perm(), f.Modified)
+}
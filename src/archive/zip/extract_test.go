@@ -0,0 +1,170 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"bytes"
+	"errors"
+	"internal/testenv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type extractFile struct {
+	name    string
+	mode    fs.FileMode
+	content string
+}
+
+// makeZip returns a Reader for an archive containing files.
+func makeZip(t *testing.T, password string, files ...extractFile) *Reader {
+	t.Helper()
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	w.SetPassword(password)
+	for _, f := range files {
+		fh := &FileHeader{Name: f.name, Method: Deflate}
+		if f.mode != 0 {
+			fh.SetMode(f.mode)
+		}
+		fw, err := w.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(f.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetPassword(password)
+	return r
+}
+
+func TestExtract(t *testing.T) {
+	testenv.MustHaveSymlink(t)
+
+	r := makeZip(t, "secret",
+		extractFile{name: "dir/", mode: fs.ModeDir | 0o755},
+		extractFile{name: "dir/file", mode: 0o644, content: "file"},
+		extractFile{name: "a/b/implicit", content: "implicit"},
+		extractFile{name: "exec", mode: fs.ModeSetuid | 0o755, content: "exec"},
+		extractFile{name: "a/link", mode: fs.ModeSymlink | 0o777, content: "../dir/file"},
+	)
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := r.Extract(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"dir/file":     "file",
+		"a/b/implicit": "implicit",
+		"exec":         "exec",
+		"a/link":       "file",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "exec")); err != nil || fi.Mode()&(fs.ModeSetuid|0o100) != 0o100 {
+		t.Errorf("exec: mode %v, %v; want executable without setuid", fi.Mode(), err)
+	}
+
+	// Without the password, the encrypted files can't be extracted.
+	r.SetPassword("")
+	if err := r.Extract(t.TempDir(), nil); err != ErrPassword {
+		t.Errorf("Extract without password: err = %v, want %v", err, ErrPassword)
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	testenv.MustHaveSymlink(t)
+
+	zeros := strings.Repeat("\x00", 1<<20)
+	tests := []struct {
+		name  string
+		files []extractFile
+		opts  *ExtractOptions
+		want  error
+	}{{
+		name:  "dotdot",
+		files: []extractFile{{name: "a/../../evil"}},
+		want:  &InsecurePathError{Name: "a/../../evil"},
+	}, {
+		name:  "absolute",
+		files: []extractFile{{name: "/evil"}},
+		want:  &InsecurePathError{Name: "/evil"},
+	}, {
+		name: "through symlink",
+		files: []extractFile{
+			{name: "dir/"},
+			{name: "link", mode: fs.ModeSymlink | 0o777, content: "dir"},
+			{name: "link/evil"},
+		},
+		want: &InsecurePathError{Name: "link/evil"},
+	}, {
+		name:  "symlink outside",
+		files: []extractFile{{name: "dir/link", mode: fs.ModeSymlink | 0o777, content: "../../evil"}},
+		want:  &InsecureLinkError{Name: "dir/link", Linkname: "../../evil"},
+	}, {
+		name:  "absolute symlink",
+		files: []extractFile{{name: "link", mode: fs.ModeSymlink | 0o777, content: "/etc"}},
+		want:  &InsecureLinkError{Name: "link", Linkname: "/etc"},
+	}, {
+		name: "chained symlink",
+		files: []extractFile{
+			{name: "sub/"},
+			{name: "sub/s2", mode: fs.ModeSymlink | 0o777, content: ".."},
+			{name: "l1", mode: fs.ModeSymlink | 0o777, content: "sub/s2/../.."},
+		},
+		want: &InsecureLinkError{Name: "l1", Linkname: "sub/s2/../.."},
+	}, {
+		name:  "symlink dotdot after missing directory",
+		files: []extractFile{{name: "l1", mode: fs.ModeSymlink | 0o777, content: "sub/../evil"}},
+		want:  &InsecureLinkError{Name: "l1", Linkname: "sub/../evil"},
+	}, {
+		name:  "long symlink",
+		files: []extractFile{{name: "link", mode: fs.ModeSymlink | 0o777, content: strings.Repeat("a/", maxLinkTarget)}},
+		want:  errors.New(`zip: symbolic link target of "link" is too long`),
+	}, {
+		name:  "files",
+		files: []extractFile{{name: "a"}, {name: "b"}, {name: "c"}},
+		opts:  &ExtractOptions{MaxFiles: 2},
+		want:  &FileLimitError{Limit: 2},
+	}, {
+		name:  "size",
+		files: []extractFile{{name: "a", content: "aaaa"}, {name: "b", content: "bbbb"}},
+		opts:  &ExtractOptions{MaxSize: 7},
+		want:  &SizeLimitError{Name: "b", Limit: 7},
+	}, {
+		name:  "ratio",
+		files: []extractFile{{name: "text", content: "hello"}, {name: "zeros", content: zeros}},
+		opts:  &ExtractOptions{MaxRatio: 100},
+		want:  &RatioLimitError{Name: "zeros", Limit: 100},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "out")
+			err := makeZip(t, "", tt.files...).Extract(dir, tt.opts)
+			if !reflect.DeepEqual(err, tt.want) {
+				t.Fatalf("Extract error = %v, want %v", err, tt.want)
+			}
+			if _, ok := tt.want.(interface{ Unwrap() error }); ok && !errors.Is(err, ErrInsecurePath) {
+				t.Errorf("errors.Is(%v, ErrInsecurePath) = false", err)
+			}
+			if _, err := os.Lstat(filepath.Join(root, "evil")); err == nil {
+				t.Error("file was written outside the destination")
+			}
+		})
+	}
+}
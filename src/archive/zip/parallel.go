@@ -0,0 +1,232 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+	"sync"
+)
+
+var errParallelUnfinished = errors.New("zip: ParallelWriter closed with unfinished files")
+
+// A ParallelWriter writes a zip file, like a [Writer], but permits the
+// contents of several files to be written and compressed concurrently.
+//
+// Files appear in the archive in the order in which they were created.
+// The writer returned for each file may be used on a different goroutine,
+// and its compressed contents are buffered in memory until all the files
+// created before it have been written to the archive.
+//
+// The methods of ParallelWriter itself may be called concurrently,
+// but files are then added in an unspecified order.
+type ParallelWriter struct {
+	mu    sync.Mutex
+	w     *Writer
+	queue []*parallelFile // files not yet written to w, in order
+	err   error
+
+	password string
+}
+
+// A parallelFile is a file added to a ParallelWriter.
+type parallelFile struct {
+	pw       *ParallelWriter
+	fh       *FileHeader
+	file     *File  // if not nil, the file to copy
+	dir      bool   // whether the file is a directory
+	password string // the password with which to encrypt the file
+
+	buf  bytes.Buffer // compressed contents
+	comp io.WriteCloser
+	crc  hash.Hash32
+	size uint64 // uncompressed size
+
+	done bool // the contents are complete; guarded by pw.mu
+}
+
+// NewParallelWriter returns a new [ParallelWriter] writing a zip file to w.
+func NewParallelWriter(w io.Writer) *ParallelWriter {
+	return &ParallelWriter{w: NewWriter(w)}
+}
+
+// SetOffset sets the offset of the beginning of the zip data within the
+// underlying writer, as described for [Writer.SetOffset].
+// It must be called before any files are added.
+func (pw *ParallelWriter) SetOffset(n int64) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.w.SetOffset(n)
+}
+
+// SetComment sets the end-of-central-directory comment field.
+// It can only be called before [ParallelWriter.Close].
+func (pw *ParallelWriter) SetComment(comment string) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.w.SetComment(comment)
+}
+
+// RegisterCompressor registers or overrides a custom compressor for a specific
+// method ID, as described for [Writer.RegisterCompressor].
+// The compressor may be called from multiple goroutines simultaneously.
+func (pw *ParallelWriter) RegisterCompressor(method uint16, comp Compressor) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.w.RegisterCompressor(method, comp)
+}
+
+// SetPassword sets the password with which the contents of files
+// subsequently created are encrypted, as described for [Writer.SetPassword].
+func (pw *ParallelWriter) SetPassword(password string) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.password = password
+}
+
+// Create adds a file to the zip file using the provided name,
+// as described for [Writer.Create].
+// It returns a WriteCloser to which the file contents should be written.
+// The file is complete when the WriteCloser is closed.
+func (pw *ParallelWriter) Create(name string) (io.WriteCloser, error) {
+	header := &FileHeader{
+		Name:   name,
+		Method: Deflate,
+	}
+	return pw.CreateHeader(header)
+}
+
+// CreateHeader adds a file to the zip archive using the provided [FileHeader]
+// for the file metadata, as described for [Writer.CreateHeader].
+// [ParallelWriter] takes ownership of fh and may mutate its fields.
+// The caller must not modify fh after calling CreateHeader.
+//
+// It returns a WriteCloser to which the file contents should be written.
+// The file is complete when the WriteCloser is closed, which must happen
+// before the call to [ParallelWriter.Close]. Until then, files created
+// after this one are held in memory.
+func (pw *ParallelWriter) CreateHeader(fh *FileHeader) (io.WriteCloser, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.err != nil {
+		return nil, pw.err
+	}
+	if pw.w.closed {
+		return nil, errors.New("zip: ParallelWriter is closed")
+	}
+
+	f := &parallelFile{pw: pw, fh: fh, password: pw.password}
+	if strings.HasSuffix(fh.Name, "/") {
+		f.dir = true
+		f.done = true
+		pw.queue = append(pw.queue, f)
+		return nopCloser{dirWriter{}}, pw.flush()
+	}
+
+	comp := pw.w.compressor(fh.Method)
+	if comp == nil {
+		return nil, ErrAlgorithm
+	}
+	var err error
+	f.comp, err = comp(&f.buf)
+	if err != nil {
+		return nil, err
+	}
+	f.crc = crc32.NewIEEE()
+	pw.queue = append(pw.queue, f)
+	return f, nil
+}
+
+// Copy copies the file f (obtained from a [Reader]) into the archive,
+// as described for [Writer.Copy]. The file is written to the archive
+// after all the files created before it. Until then, the caller must
+// not close or modify the underlying data of the [Reader].
+func (pw *ParallelWriter) Copy(f *File) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.err != nil {
+		return pw.err
+	}
+	if pw.w.closed {
+		return errors.New("zip: ParallelWriter is closed")
+	}
+	pw.queue = append(pw.queue, &parallelFile{pw: pw, file: f, done: true})
+	return pw.flush()
+}
+
+func (f *parallelFile) Write(p []byte) (int, error) {
+	if f.comp == nil {
+		return 0, errors.New("zip: write to closed file")
+	}
+	f.crc.Write(p)
+	f.size += uint64(len(p))
+	return f.comp.Write(p)
+}
+
+func (f *parallelFile) Close() error {
+	if f.comp == nil {
+		return errors.New("zip: file closed twice")
+	}
+	err := f.comp.Close()
+	f.comp = nil
+	f.fh.CRC32 = f.crc.Sum32()
+	f.fh.CompressedSize64 = uint64(f.buf.Len())
+	f.fh.UncompressedSize64 = f.size
+
+	pw := f.pw
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	f.done = true
+	if err != nil && pw.err == nil {
+		pw.err = err
+	}
+	if err := pw.flush(); err != nil {
+		return err
+	}
+	return pw.err
+}
+
+// flush writes the completed files at the start of the queue to pw.w.
+// pw.mu must be held.
+func (pw *ParallelWriter) flush() error {
+	for pw.err == nil && len(pw.queue) > 0 && pw.queue[0].done {
+		f := pw.queue[0]
+		pw.queue[0] = nil
+		pw.queue = pw.queue[1:]
+		pw.w.SetPassword(f.password)
+		switch {
+		case f.file != nil:
+			pw.err = pw.w.Copy(f.file)
+		case f.dir:
+			_, pw.err = pw.w.CreateHeader(f.fh)
+		default:
+			var fw io.Writer
+			if fw, pw.err = pw.w.CreateCompressed(f.fh); pw.err == nil {
+				_, pw.err = fw.Write(f.buf.Bytes())
+			}
+			f.buf = bytes.Buffer{}
+		}
+	}
+	return pw.err
+}
+
+// Close finishes writing the zip file by writing the central directory.
+// All files created by [ParallelWriter.Create] and [ParallelWriter.CreateHeader]
+// must have been closed. It does not close the underlying writer.
+func (pw *ParallelWriter) Close() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.err != nil {
+		return pw.err
+	}
+	if len(pw.queue) > 0 {
+		return errParallelUnfinished
+	}
+	return pw.w.Close()
+}
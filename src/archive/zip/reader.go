@@ -29,6 +29,12 @@ var (
 	ErrAlgorithm    = errors.New("zip: unsupported compression algorithm")
 	ErrChecksum     = errors.New("zip: checksum error")
 	ErrInsecurePath = errors.New("zip: insecure file path")
+	ErrPassword     = errors.New("zip: missing or incorrect password")
+
+	// ErrUnsupportedEncryption is returned when opening a file
+	// encrypted with a method other than WinZip AES encryption,
+	// such as the traditional PKWARE encryption.
+	ErrUnsupportedEncryption = errors.New("zip: unsupported encryption method")
 )
 
 // A Reader serves content from a ZIP archive.
@@ -37,6 +43,7 @@ type Reader struct {
 	File          []*File
 	Comment       string
 	decompressors map[uint16]Decompressor
+	password      string
 
 	// Some JAR files are zip files with a prefix that is a bash script.
 	// The baseOffset field is the start of the zip file proper.
@@ -186,6 +193,13 @@ func (r *Reader) RegisterDecompressor(method uint16, dcomp Decompressor) {
 	r.decompressors[method] = dcomp
 }
 
+// SetPassword sets the password with which [File.Open] decrypts
+// encrypted files, including those opened by [Reader.Open].
+// It must not be called concurrently with opening files.
+func (r *Reader) SetPassword(password string) {
+	r.password = password
+}
+
 func (r *Reader) decompressor(method uint16) Decompressor {
 	dcomp := r.decompressors[method]
 	if dcomp == nil {
@@ -214,7 +228,22 @@ func (f *File) DataOffset() (offset int64, err error) {
 
 // Open returns a [ReadCloser] that provides access to the [File]'s contents.
 // Multiple files may be read concurrently.
+//
+// An encrypted file is decrypted with the password set by [Reader.SetPassword].
+// If that is missing or incorrect, Open returns [ErrPassword]. If the file
+// is encrypted with an unsupported method, Open returns
+// [ErrUnsupportedEncryption].
 func (f *File) Open() (io.ReadCloser, error) {
+	return f.open(f.zip.password)
+}
+
+// OpenWithPassword is like [File.Open], but decrypts
+// an encrypted file using the given password.
+func (f *File) OpenWithPassword(password string) (io.ReadCloser, error) {
+	return f.open(password)
+}
+
+func (f *File) open(password string) (io.ReadCloser, error) {
 	bodyOffset, err := f.findBodyOffset()
 	if err != nil {
 		return nil, err
@@ -236,11 +265,23 @@ func (f *File) Open() (io.ReadCloser, error) {
 		}
 	}
 	size := int64(f.CompressedSize64)
-	r := io.NewSectionReader(f.zipr, f.headerOffset+bodyOffset, size)
+	sr := io.NewSectionReader(f.zipr, f.headerOffset+bodyOffset, size)
+	var r io.Reader = sr
+	if f.Flags&0x1 != 0 && f.Encryption == NoEncryption {
+		return nil, ErrUnsupportedEncryption
+	}
 	dcomp := f.zip.decompressor(f.Method)
 	if dcomp == nil {
 		return nil, ErrAlgorithm
 	}
+	var aesr *aesReader
+	if f.Encryption != NoEncryption {
+		aesr, err = newAESReader(sr, f.Encryption, password)
+		if err != nil {
+			return nil, err
+		}
+		r = aesr
+	}
 	var rc io.ReadCloser = dcomp(r)
 	var desr io.Reader
 	if f.hasDataDescriptor() {
@@ -251,6 +292,7 @@ func (f *File) Open() (io.ReadCloser, error) {
 		hash: crc32.NewIEEE(),
 		f:    f,
 		desr: desr,
+		aes:  aesr,
 	}
 	return rc, nil
 }
@@ -283,8 +325,9 @@ type checksumReader struct {
 	hash  hash.Hash32
 	nread uint64 // number of bytes read so far
 	f     *File
-	desr  io.Reader // if non-nil, where to read the data descriptor
-	err   error     // sticky error
+	desr  io.Reader  // if non-nil, where to read the data descriptor
+	aes   *aesReader // if non-nil, the decrypter to verify at EOF
+	err   error      // sticky error
 }
 
 func (r *checksumReader) Stat() (fs.FileInfo, error) {
@@ -308,6 +351,15 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 		if r.nread != r.f.UncompressedSize64 {
 			return 0, io.ErrUnexpectedEOF
 		}
+		if r.aes != nil {
+			if err1 := r.aes.verify(); err1 != nil {
+				if err1 == io.EOF {
+					err1 = io.ErrUnexpectedEOF
+				}
+				r.err = err1
+				return n, err1
+			}
+		}
 		if r.desr != nil {
 			if err1 := readDataDescriptor(r.desr, r.f); err1 != nil {
 				if err1 == io.EOF {
@@ -315,14 +367,14 @@ func (r *checksumReader) Read(b []byte) (n int, err error) {
 				} else {
 					err = err1
 				}
-			} else if r.hash.Sum32() != r.f.CRC32 {
+			} else if r.f.hasCRC() && r.hash.Sum32() != r.f.CRC32 {
 				err = ErrChecksum
 			}
 		} else {
 			// If there's not a data descriptor, we still compare
 			// the CRC32 of what we've read against the file header
 			// or TOC's CRC32, if it seems like it was set.
-			if r.f.CRC32 != 0 && r.hash.Sum32() != r.f.CRC32 {
+			if r.f.CRC32 != 0 && r.f.hasCRC() && r.hash.Sum32() != r.f.CRC32 {
 				err = ErrChecksum
 			}
 		}
@@ -487,6 +539,22 @@ parseExtras:
 			}
 			ts := int64(fieldBuf.uint32()) // ModTime since Unix epoch
 			modified = time.Unix(ts, 0)
+		case aesExtraID:
+			// The recorded method of a file encrypted with WinZip AES
+			// is methodAES; this field holds the actual method.
+			if f.Method != methodAES || len(fieldBuf) < 7 {
+				continue parseExtras
+			}
+			version := fieldBuf.uint16()
+			vendor := fieldBuf.uint16()
+			encryption := Encryption(fieldBuf.uint8())
+			method := fieldBuf.uint16()
+			if vendor != 0x4541 || encryption.keyLen() == 0 { // "AE"
+				continue parseExtras
+			}
+			f.AESVersion = version
+			f.Encryption = encryption
+			f.Method = method
 		}
 	}
 
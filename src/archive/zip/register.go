@@ -6,6 +6,7 @@ package zip
 
 import (
 	"compress/flate"
+	"compress/zstd"
 	"errors"
 	"io"
 	"sync"
@@ -101,6 +102,80 @@ func (r *pooledFlateReader) Close() error {
 	return err
 }
 
+var zstdWriterPool sync.Pool
+
+func newZstdWriter(w io.Writer) (io.WriteCloser, error) {
+	zw, ok := zstdWriterPool.Get().(*zstd.Writer)
+	if ok {
+		zw.Reset(w)
+	} else {
+		zw = zstd.NewWriter(w)
+	}
+	return &pooledZstdWriter{zw: zw}, nil
+}
+
+type pooledZstdWriter struct {
+	mu sync.Mutex // guards Close and Write
+	zw *zstd.Writer
+}
+
+func (w *pooledZstdWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.zw == nil {
+		return 0, errors.New("Write after Close")
+	}
+	return w.zw.Write(p)
+}
+
+func (w *pooledZstdWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	if w.zw != nil {
+		err = w.zw.Close()
+		zstdWriterPool.Put(w.zw)
+		w.zw = nil
+	}
+	return err
+}
+
+var zstdReaderPool sync.Pool
+
+func newZstdReader(r io.Reader) io.ReadCloser {
+	zr, ok := zstdReaderPool.Get().(*zstd.Reader)
+	if ok {
+		zr.Reset(r)
+	} else {
+		zr = zstd.NewReader(r)
+	}
+	return &pooledZstdReader{zr: zr}
+}
+
+type pooledZstdReader struct {
+	mu sync.Mutex // guards Close and Read
+	zr *zstd.Reader
+}
+
+func (r *pooledZstdReader) Read(p []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.zr == nil {
+		return 0, errors.New("Read after Close")
+	}
+	return r.zr.Read(p)
+}
+
+func (r *pooledZstdReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.zr != nil {
+		zstdReaderPool.Put(r.zr)
+		r.zr = nil
+	}
+	return nil
+}
+
 var (
 	compressors   sync.Map // map[uint16]Compressor
 	decompressors sync.Map // map[uint16]Decompressor
@@ -112,10 +187,16 @@ func init() {
 
 	decompressors.Store(Store, Decompressor(io.NopCloser))
 	decompressors.Store(Deflate, Decompressor(newFlateReader))
+
+	// Zstd is not stored in the maps, as programs written before it was
+	// built in may register their own implementation of it.
+	// See compressor and decompressor.
 }
 
 // RegisterDecompressor allows custom decompressors for a specified method ID.
 // The common methods [Store] and [Deflate] are built in.
+// [Zstd] is also built in, but may be replaced by registering
+// a different decompressor for it.
 func RegisterDecompressor(method uint16, dcomp Decompressor) {
 	if _, dup := decompressors.LoadOrStore(method, dcomp); dup {
 		panic("decompressor already registered")
@@ -124,6 +205,8 @@ func RegisterDecompressor(method uint16, dcomp Decompressor) {
 
 // RegisterCompressor registers custom compressors for a specified method ID.
 // The common methods [Store] and [Deflate] are built in.
+// [Zstd] is also built in, but may be replaced by registering
+// a different compressor for it.
 func RegisterCompressor(method uint16, comp Compressor) {
 	if _, dup := compressors.LoadOrStore(method, comp); dup {
 		panic("compressor already registered")
@@ -133,6 +216,9 @@ func RegisterCompressor(method uint16, comp Compressor) {
 func compressor(method uint16) Compressor {
 	ci, ok := compressors.Load(method)
 	if !ok {
+		if method == Zstd {
+			return newZstdWriter
+		}
 		return nil
 	}
 	return ci.(Compressor)
@@ -141,6 +227,9 @@ func compressor(method uint16) Compressor {
 func decompressor(method uint16) Decompressor {
 	di, ok := decompressors.Load(method)
 	if !ok {
+		if method == Zstd {
+			return newZstdReader
+		}
 		return nil
 	}
 	return di.(Decompressor)
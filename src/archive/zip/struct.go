@@ -29,8 +29,23 @@ import (
 
 // Compression methods.
 const (
-	Store   uint16 = 0 // no compression
-	Deflate uint16 = 8 // DEFLATE compressed
+	Store   uint16 = 0  // no compression
+	Deflate uint16 = 8  // DEFLATE compressed
+	Zstd    uint16 = 93 // Zstandard compressed
+)
+
+// An Encryption is a method of encrypting the contents of a file.
+type Encryption uint8
+
+// Encryption methods.
+//
+// The AES methods are those of the WinZip AES format, with keys
+// derived from a password.
+const (
+	NoEncryption Encryption = 0
+	AES128       Encryption = 1 // WinZip AES with a 128-bit key
+	AES192       Encryption = 2 // WinZip AES with a 192-bit key
+	AES256       Encryption = 3 // WinZip AES with a 256-bit key
 )
 
 const (
@@ -58,6 +73,12 @@ const (
 	// Version numbers.
 	zipVersion20 = 20 // 2.0
 	zipVersion45 = 45 // 4.5 (reads and writes zip64 archives)
+	zipVersion51 = 51 // 5.1 (WinZip AES encryption)
+	zipVersion63 = 63 // 6.3 (Zstandard compression)
+
+	// methodAES is the compression method recorded for files encrypted
+	// with WinZip AES. The actual method is stored in the aesExtraID field.
+	methodAES = 99
 
 	// Limits for non zip64 files.
 	uint16max = (1 << 16) - 1
@@ -77,6 +98,7 @@ const (
 	unixExtraID        = 0x000d // UNIX
 	extTimeExtraID     = 0x5455 // Extended timestamp
 	infoZipUnixExtraID = 0x5855 // Info-ZIP Unix extension
+	aesExtraID         = 0x9901 // WinZip AES encryption
 )
 
 // FileHeader describes a file within a ZIP file.
@@ -110,8 +132,28 @@ type FileHeader struct {
 	Flags          uint16
 
 	// Method is the compression method. If zero, Store is used.
+	//
+	// For an encrypted file, Method is the method by which the contents
+	// were compressed before encryption, not the method recorded in the
+	// ZIP file headers.
 	Method uint16
 
+	// Encryption is the method by which the contents of the file are
+	// encrypted, or NoEncryption.
+	//
+	// When writing with a password set by [Writer.SetPassword],
+	// NoEncryption selects AES256.
+	Encryption Encryption
+
+	// AESVersion is the version of the WinZip AES format used for an
+	// encrypted file: 1 for AE-1 or 2 for AE-2. Unlike AE-1, AE-2 does not
+	// record the CRC-32 checksum of the unencrypted contents, which may
+	// reveal information about them; the contents are instead verified
+	// only by the authentication code of the encrypted data.
+	//
+	// When writing, zero selects AE-2.
+	AESVersion uint16
+
 	// Modified is the modified time of the file.
 	//
 	// When reading, an extended timestamp is preferred over the legacy MS-DOS
@@ -346,6 +388,20 @@ func (h *FileHeader) hasDataDescriptor() bool {
 	return h.Flags&0x8 != 0
 }
 
+// hasCRC reports whether the CRC32 field holds the checksum of the
+// file's contents, as it does for all files but those encrypted with AE-2.
+func (h *FileHeader) hasCRC() bool {
+	return h.Encryption == NoEncryption || h.AESVersion != 2
+}
+
+// rawMethod returns the compression method recorded in the ZIP file headers.
+func (h *FileHeader) rawMethod() uint16 {
+	if h.Encryption != NoEncryption {
+		return methodAES
+	}
+	return h.Method
+}
+
 func msdosModeToFileMode(m uint32) (mode fs.FileMode) {
 	if m&msdosDir != 0 {
 		mode = fs.ModeDir | 0777
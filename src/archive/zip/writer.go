@@ -29,6 +29,7 @@ type Writer struct {
 	closed      bool
 	compressors map[uint16]Compressor
 	comment     string
+	password    string
 
 	// testHookCloseSizeOffset if non-nil is called with the size
 	// of offset of the central directory at Close.
@@ -73,6 +74,16 @@ func (w *Writer) SetComment(comment string) error {
 	return nil
 }
 
+// SetPassword sets the password with which the contents of files
+// subsequently added by [Writer.Create], [Writer.CreateHeader],
+// [Writer.CreateCompressed], or [Writer.AddFS] are encrypted.
+// The encryption method is given by [FileHeader.Encryption].
+// If the password is empty, files are not encrypted.
+// Directories are never encrypted.
+func (w *Writer) SetPassword(password string) {
+	w.password = password
+}
+
 // Close finishes writing the zip file by writing the central directory.
 // It does not close the underlying writer.
 func (w *Writer) Close() error {
@@ -96,7 +107,7 @@ func (w *Writer) Close() error {
 		b.uint16(h.CreatorVersion)
 		b.uint16(h.ReaderVersion)
 		b.uint16(h.Flags)
-		b.uint16(h.Method)
+		b.uint16(h.rawMethod())
 		b.uint16(h.ModifiedTime)
 		b.uint16(h.ModifiedDate)
 		b.uint32(h.CRC32)
@@ -216,7 +227,8 @@ func (w *Writer) Close() error {
 // slash to the name. Duplicate names will not overwrite previous entries
 // and are appended to the zip file.
 // The file's contents must be written to the [io.Writer] before the next
-// call to [Writer.Create], [Writer.CreateHeader], or [Writer.Close].
+// call to [Writer.Create], [Writer.CreateHeader], [Writer.CreateCompressed],
+// or [Writer.Close].
 func (w *Writer) Create(name string) (io.Writer, error) {
 	header := &FileHeader{
 		Name:   name,
@@ -269,12 +281,82 @@ func (w *Writer) prepare(fh *FileHeader) error {
 //
 // This returns a [Writer] to which the file contents should be written.
 // The file's contents must be written to the io.Writer before the next
-// call to [Writer.Create], [Writer.CreateHeader], [Writer.CreateRaw], or [Writer.Close].
+// call to [Writer.Create], [Writer.CreateHeader], [Writer.CreateRaw],
+// [Writer.CreateCompressed], or [Writer.Close].
 func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 	if err := w.prepare(fh); err != nil {
 		return nil, err
 	}
 
+	initHeader(fh)
+	w.initEncryption(fh)
+
+	var (
+		ow io.Writer
+		fw *fileWriter
+	)
+	h := &header{
+		FileHeader: fh,
+		offset:     uint64(w.cw.count),
+	}
+
+	if strings.HasSuffix(fh.Name, "/") {
+		// Set the compression method to Store to ensure data length is truly zero,
+		// which the writeHeader method always encodes for the size fields.
+		// This is necessary as most compression formats have non-zero lengths
+		// even when compressing an empty string.
+		fh.Method = Store
+		fh.Flags &^= 0x8 // we will not write a data descriptor
+
+		// Explicitly clear sizes as they have no meaning for directories.
+		fh.CompressedSize = 0
+		fh.CompressedSize64 = 0
+		fh.UncompressedSize = 0
+		fh.UncompressedSize64 = 0
+
+		ow = dirWriter{}
+	} else {
+		fh.Flags |= 0x8 // we will write a data descriptor
+
+		fw = &fileWriter{
+			zipw:      w.cw,
+			compCount: &countWriter{w: w.cw},
+			crc32:     crc32.NewIEEE(),
+		}
+		comp := w.compressor(fh.Method)
+		if comp == nil {
+			return nil, ErrAlgorithm
+		}
+		var dst io.Writer = fw.compCount
+		if fh.Encryption != NoEncryption {
+			enc, err := newAESWriter(fw.compCount, fh.Encryption, w.password)
+			if err != nil {
+				return nil, err
+			}
+			fw.enc = enc
+			dst = enc
+		}
+		var err error
+		fw.comp, err = comp(dst)
+		if err != nil {
+			return nil, err
+		}
+		fw.rawCount = &countWriter{w: fw.comp}
+		fw.header = h
+		ow = fw
+	}
+	w.dir = append(w.dir, h)
+	if err := writeHeader(w.cw, h); err != nil {
+		return nil, err
+	}
+	// If we're creating a directory, fw is nil.
+	w.last = fw
+	return ow, nil
+}
+
+// initHeader sets up the fields of fh that are derived from others,
+// as needed by CreateHeader and CreateCompressed.
+func initHeader(fh *FileHeader) {
 	// The ZIP format has a sad state of affairs regarding character encoding.
 	// Officially, the name and comment fields are supposed to be encoded
 	// in CP-437 (which is mostly compatible with ASCII), unless the UTF-8
@@ -302,6 +384,9 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 
 	fh.CreatorVersion = fh.CreatorVersion&0xff00 | zipVersion20 // preserve compatibility byte
 	fh.ReaderVersion = zipVersion20
+	if fh.Method == Zstd {
+		fh.ReaderVersion = zipVersion63
+	}
 
 	// If Modified is set, this takes precedence over MS-DOS timestamp fields.
 	if !fh.Modified.IsZero() {
@@ -330,59 +415,26 @@ func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
 		eb.uint32(mt) // ModTime
 		fh.Extra = append(fh.Extra, mbuf[:]...)
 	}
+}
 
-	var (
-		ow io.Writer
-		fw *fileWriter
-	)
-	h := &header{
-		FileHeader: fh,
-		offset:     uint64(w.cw.count),
-	}
-
-	if strings.HasSuffix(fh.Name, "/") {
-		// Set the compression method to Store to ensure data length is truly zero,
-		// which the writeHeader method always encodes for the size fields.
-		// This is necessary as most compression formats have non-zero lengths
-		// even when compressing an empty string.
-		fh.Method = Store
-		fh.Flags &^= 0x8 // we will not write a data descriptor
-
-		// Explicitly clear sizes as they have no meaning for directories.
-		fh.CompressedSize = 0
-		fh.CompressedSize64 = 0
-		fh.UncompressedSize = 0
-		fh.UncompressedSize64 = 0
-
-		ow = dirWriter{}
+// initEncryption sets up the encryption fields of fh according to
+// the password of w, as needed by CreateHeader and CreateCompressed.
+func (w *Writer) initEncryption(fh *FileHeader) {
+	if w.password == "" || strings.HasSuffix(fh.Name, "/") {
+		fh.Encryption = NoEncryption
+		fh.AESVersion = 0
+		fh.Flags &^= 0x1
 	} else {
-		fh.Flags |= 0x8 // we will write a data descriptor
-
-		fw = &fileWriter{
-			zipw:      w.cw,
-			compCount: &countWriter{w: w.cw},
-			crc32:     crc32.NewIEEE(),
-		}
-		comp := w.compressor(fh.Method)
-		if comp == nil {
-			return nil, ErrAlgorithm
+		if fh.Encryption.keyLen() == 0 {
+			fh.Encryption = AES256
 		}
-		var err error
-		fw.comp, err = comp(fw.compCount)
-		if err != nil {
-			return nil, err
+		if fh.AESVersion != 1 {
+			fh.AESVersion = 2
 		}
-		fw.rawCount = &countWriter{w: fw.comp}
-		fw.header = h
-		ow = fw
-	}
-	w.dir = append(w.dir, h)
-	if err := writeHeader(w.cw, h); err != nil {
-		return nil, err
+		fh.Flags |= 0x1
+		fh.ReaderVersion = max(fh.ReaderVersion, zipVersion51)
 	}
-	// If we're creating a directory, fw is nil.
-	w.last = fw
-	return ow, nil
+	setAESExtra(fh)
 }
 
 func writeHeader(w io.Writer, h *header) error {
@@ -399,7 +451,7 @@ func writeHeader(w io.Writer, h *header) error {
 	b.uint32(uint32(fileHeaderSignature))
 	b.uint16(h.ReaderVersion)
 	b.uint16(h.Flags)
-	b.uint16(h.Method)
+	b.uint16(h.rawMethod())
 	b.uint16(h.ModifiedTime)
 	b.uint16(h.ModifiedDate)
 	// In raw mode (caller does the compression), the values are either
@@ -431,7 +483,7 @@ func writeHeader(w io.Writer, h *header) error {
 // CreateRaw adds a file to the zip archive using the provided [FileHeader] and
 // returns a [Writer] to which the file contents should be written. The file's
 // contents must be written to the io.Writer before the next call to [Writer.Create],
-// [Writer.CreateHeader], [Writer.CreateRaw], or [Writer.Close].
+// [Writer.CreateHeader], [Writer.CreateRaw], [Writer.CreateCompressed], or [Writer.Close].
 //
 // In contrast to [Writer.CreateHeader], the bytes passed to Writer are not compressed.
 //
@@ -445,6 +497,9 @@ func (w *Writer) CreateRaw(fh *FileHeader) (io.Writer, error) {
 
 	fh.CompressedSize = uint32(min(fh.CompressedSize64, uint32max))
 	fh.UncompressedSize = uint32(min(fh.UncompressedSize64, uint32max))
+	if fh.Encryption != NoEncryption && !hasExtra(fh.Extra, aesExtraID) {
+		fh.Extra = appendAESExtra(fh.Extra[:len(fh.Extra):len(fh.Extra)], fh)
+	}
 
 	h := &header{
 		FileHeader: fh,
@@ -469,6 +524,75 @@ func (w *Writer) CreateRaw(fh *FileHeader) (io.Writer, error) {
 	return fw, nil
 }
 
+// CreateCompressed adds a file whose contents have already been compressed
+// to the zip archive, using the provided [FileHeader] for the file metadata,
+// and returns a [Writer] to which the compressed contents should be written.
+// The file's contents must be written to the io.Writer before the next call
+// to [Writer.Create], [Writer.CreateHeader], [Writer.CreateRaw],
+// [Writer.CreateCompressed], or [Writer.Close].
+//
+// The Method, CRC32, CompressedSize64, and UncompressedSize64 fields of fh
+// must describe the compressed contents, as produced by the [Compressor]
+// for fh.Method. Otherwise, fh is treated as by [Writer.CreateHeader],
+// and the resulting entry is the same as if the uncompressed contents
+// had been written to the [Writer] returned by CreateHeader.
+// This permits compressing files concurrently, for example with a
+// [ParallelWriter], while still writing the archive sequentially.
+// If a password has been set by [Writer.SetPassword], the compressed
+// contents are encrypted as they are written.
+//
+// [Writer] takes ownership of fh and may mutate its fields.
+// The caller must not modify fh after calling CreateCompressed.
+func (w *Writer) CreateCompressed(fh *FileHeader) (io.Writer, error) {
+	if strings.HasSuffix(fh.Name, "/") {
+		return w.CreateHeader(fh)
+	}
+	if err := w.prepare(fh); err != nil {
+		return nil, err
+	}
+
+	initHeader(fh)
+	w.initEncryption(fh)
+	fh.Flags |= 0x8 // we will write a data descriptor
+	if fh.Encryption != NoEncryption {
+		fh.CompressedSize64 += uint64(fh.Encryption.overhead())
+		if !fh.hasCRC() {
+			fh.CRC32 = 0
+		}
+	}
+	if fh.isZip64() {
+		fh.CompressedSize = uint32max
+		fh.UncompressedSize = uint32max
+		fh.ReaderVersion = max(fh.ReaderVersion, zipVersion45) // requires 4.5 - File uses ZIP64 format extensions
+	} else {
+		fh.CompressedSize = uint32(fh.CompressedSize64)
+		fh.UncompressedSize = uint32(fh.UncompressedSize64)
+	}
+
+	h := &header{
+		FileHeader: fh,
+		offset:     uint64(w.cw.count),
+		raw:        true,
+	}
+	w.dir = append(w.dir, h)
+	if err := writeHeader(w.cw, h); err != nil {
+		return nil, err
+	}
+	fw := &fileWriter{
+		header: h,
+		zipw:   w.cw,
+	}
+	if fh.Encryption != NoEncryption {
+		enc, err := newAESWriter(w.cw, fh.Encryption, w.password)
+		if err != nil {
+			return nil, err
+		}
+		fw.enc = enc
+	}
+	w.last = fw
+	return fw, nil
+}
+
 // Copy copies the file f (obtained from a [Reader]) into w. It copies the raw
 // form directly bypassing decompression, compression, and validation.
 func (w *Writer) Copy(f *File) error {
@@ -561,6 +685,7 @@ type fileWriter struct {
 	rawCount  *countWriter
 	comp      io.WriteCloser
 	compCount *countWriter
+	enc       *aesWriter // if not nil, encrypts the compressed contents
 	crc32     hash.Hash32
 	closed    bool
 }
@@ -570,6 +695,9 @@ func (w *fileWriter) Write(p []byte) (int, error) {
 		return 0, errors.New("zip: write to closed file")
 	}
 	if w.raw {
+		if w.enc != nil {
+			return w.enc.Write(p)
+		}
 		return w.zipw.Write(p)
 	}
 	w.crc32.Write(p)
@@ -582,22 +710,36 @@ func (w *fileWriter) close() error {
 	}
 	w.closed = true
 	if w.raw {
+		if w.enc != nil {
+			if err := w.enc.Close(); err != nil {
+				return err
+			}
+		}
 		return w.writeDataDescriptor()
 	}
 	if err := w.comp.Close(); err != nil {
 		return err
 	}
+	if w.enc != nil {
+		if err := w.enc.Close(); err != nil {
+			return err
+		}
+	}
 
 	// update FileHeader
 	fh := w.header.FileHeader
-	fh.CRC32 = w.crc32.Sum32()
+	if fh.hasCRC() {
+		fh.CRC32 = w.crc32.Sum32()
+	} else {
+		fh.CRC32 = 0
+	}
 	fh.CompressedSize64 = uint64(w.compCount.count)
 	fh.UncompressedSize64 = uint64(w.rawCount.count)
 
 	if fh.isZip64() {
 		fh.CompressedSize = uint32max
 		fh.UncompressedSize = uint32max
-		fh.ReaderVersion = zipVersion45 // requires 4.5 - File uses ZIP64 format extensions
+		fh.ReaderVersion = max(fh.ReaderVersion, zipVersion45) // requires 4.5 - File uses ZIP64 format extensions
 	} else {
 		fh.CompressedSize = uint32(fh.CompressedSize64)
 		fh.UncompressedSize = uint32(fh.UncompressedSize64)
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"compress/flate"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"hash/crc32"
 	"io"
@@ -42,6 +43,12 @@ var writeTests = []WriteTest{
 		Method: Deflate,
 		Mode:   0644,
 	},
+	{
+		Name:   "zstd",
+		Data:   []byte("Rabbits, guinea pigs, gophers, marsupial rats, and quolls."),
+		Method: Zstd,
+		Mode:   0644,
+	},
 	{
 		Name:   "setuid",
 		Data:   []byte("setuid file"),
@@ -668,3 +675,234 @@ func TestIssue61875(t *testing.T) {
 		t.Errorf("expected error, got nil")
 	}
 }
+
+func TestZstd(t *testing.T) {
+	// A zstd frame holding "gophers " repeated 20 times,
+	// as written by the reference implementation.
+	content := bytes.Repeat([]byte("gophers "), 20)
+	frame, _ := hex.DecodeString("28b52ffd24a085000048676f70686572732067010094d43d02fb375948")
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	fw, err := w.CreateRaw(&FileHeader{
+		Name:               "raw",
+		Method:             Zstd,
+		CRC32:              crc32.ChecksumIEEE(content),
+		CompressedSize64:   uint64(len(frame)),
+		UncompressedSize64: uint64(len(content)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(frame)
+	fh := &FileHeader{Name: "compressed", Method: Zstd}
+	fw, err = w.CreateHeader(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range r.File {
+		if f.Name == "compressed" && f.ReaderVersion != zipVersion63 {
+			t.Errorf("%s: ReaderVersion = %d, want %d", f.Name, f.ReaderVersion, zipVersion63)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("%s: got %q, want %q", f.Name, got, content)
+		}
+	}
+}
+
+func TestWriterCreateCompressed(t *testing.T) {
+	content := bytes.Repeat([]byte("gophers "), 1000)
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	header := func() *FileHeader {
+		return &FileHeader{Name: "gö/pher", Method: Deflate, Modified: modified}
+	}
+
+	// Write the file normally.
+	var want bytes.Buffer
+	w := NewWriter(&want)
+	fw, err := w.CreateHeader(header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write the same file, compressed separately.
+	var compressed bytes.Buffer
+	comp, _ := compressor(Deflate)(&compressed)
+	comp.Write(content)
+	comp.Close()
+	fh := header()
+	fh.CRC32 = crc32.ChecksumIEEE(content)
+	fh.CompressedSize64 = uint64(compressed.Len())
+	fh.UncompressedSize64 = uint64(len(content))
+	var got bytes.Buffer
+	w = NewWriter(&got)
+	fw, err = w.CreateCompressed(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(compressed.Bytes())
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("CreateCompressed output differs from CreateHeader output:\ngot  %x\nwant %x", got.Bytes(), want.Bytes())
+	}
+}
+
+func TestParallelWriter(t *testing.T) {
+	// A source archive to copy a file from.
+	var src bytes.Buffer
+	sw := NewWriter(&src)
+	fw, _ := sw.Create("copied")
+	fw.Write([]byte("copied file"))
+	sw.Close()
+	sr, err := NewReader(bytes.NewReader(src.Bytes()), int64(src.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type file struct {
+		name    string
+		method  uint16
+		content []byte
+	}
+	var files []file
+	for i := 0; i < 50; i++ {
+		f := file{
+			name:    fmt.Sprintf("file%02d", i),
+			method:  []uint16{Store, Deflate, Zstd}[i%3],
+			content: bytes.Repeat([]byte(fmt.Sprintf("content of file %d\n", i)), i*100),
+		}
+		switch i {
+		case 10:
+			f.name = "dir/"
+			f.content = nil
+		case 20:
+			f.name = "copied"
+			f.method = Deflate
+			f.content = []byte("copied file")
+		}
+		files = append(files, f)
+	}
+
+	var buf bytes.Buffer
+	pw := NewParallelWriter(&buf)
+	if err := pw.SetComment("parallel"); err != nil {
+		t.Fatal(err)
+	}
+	var writers []io.WriteCloser
+	for _, f := range files {
+		if f.name == "copied" {
+			if err := pw.Copy(sr.File[0]); err != nil {
+				t.Fatal(err)
+			}
+			writers = append(writers, nil)
+			continue
+		}
+		w, err := pw.CreateHeader(&FileHeader{Name: f.name, Method: f.method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writers = append(writers, w)
+	}
+
+	// Write and close the files concurrently,
+	// in roughly reverse order.
+	errc := make(chan error, len(files))
+	for i := len(files) - 1; i >= 0; i-- {
+		w := writers[i]
+		if w == nil {
+			errc <- nil
+			continue
+		}
+		go func() {
+			if _, err := w.Write(files[i].content); err != nil {
+				errc <- err
+				return
+			}
+			errc <- w.Close()
+		}()
+	}
+	for range files {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Comment != "parallel" {
+		t.Errorf("Comment = %q, want %q", r.Comment, "parallel")
+	}
+	if len(r.File) != len(files) {
+		t.Fatalf("got %d files, want %d", len(r.File), len(files))
+	}
+	for i, f := range files {
+		zf := r.File[i]
+		if zf.Name != f.name {
+			t.Errorf("file %d is %q, want %q", i, zf.Name, f.name)
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.name, err)
+		}
+		if !bytes.Equal(got, f.content) {
+			t.Errorf("%s: wrong content", f.name)
+		}
+	}
+}
+
+func TestParallelWriterUnfinished(t *testing.T) {
+	pw := NewParallelWriter(io.Discard)
+	w1, _ := pw.Create("a")
+	w2, _ := pw.Create("b")
+	w2.Write([]byte("b"))
+	w2.Close()
+	if err := pw.Close(); err == nil {
+		t.Fatal("Close with unfinished file succeeded")
+	}
+	w1.Close()
+	if err := w1.Close(); err == nil {
+		t.Error("second Close of file succeeded")
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pw.Create("c"); err == nil {
+		t.Error("Create after Close succeeded")
+	}
+}
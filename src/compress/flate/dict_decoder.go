@@ -180,3 +180,12 @@ func (dd *dictDecoder) readFlush() []byte {
 	}
 	return toRead
 }
+
+// appendWindow appends the historical data in the dictionary to dst,
+// oldest first, and returns the extended slice.
+func (dd *dictDecoder) appendWindow(dst []byte) []byte {
+	if dd.full {
+		dst = append(dst, dd.hist[dd.wrPos:]...)
+	}
+	return append(dst, dd.hist[:dd.wrPos]...)
+}
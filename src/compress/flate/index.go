@@ -0,0 +1,390 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flate
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+// defaultSpan is the distance between checkpoints used by an [Indexer]
+// when none is specified.
+const defaultSpan = 1 << 20
+
+// A Checkpoint is a position in a DEFLATE stream at which
+// decompression can start. Checkpoints are always at the start of a block.
+type Checkpoint struct {
+	In     int64  // offset in the compressed stream of the byte holding the first bit of the block
+	Bits   int    // number of bits of the byte at In that precede the block, from 0 to 7
+	Out    int64  // offset in the uncompressed data
+	Window []byte // uncompressed data preceding Out, up to 32 KiB
+}
+
+// An Index is a list of checkpoints in a DEFLATE stream, which permits
+// decompressing the data from an arbitrary offset without reading the
+// data before it. The technique is the same as that of the zran
+// example in the zlib distribution.
+type Index struct {
+	// Checkpoints is sorted by increasing Out.
+	// The first checkpoint is at the start of the data.
+	Checkpoints []Checkpoint
+
+	// Size is the total size of the uncompressed data.
+	Size int64
+}
+
+// find returns the last checkpoint at or before the uncompressed offset off.
+func (x *Index) find(off int64) (*Checkpoint, error) {
+	i := sort.Search(len(x.Checkpoints), func(i int) bool {
+		return x.Checkpoints[i].Out > off
+	})
+	if i == 0 {
+		return nil, errIndex
+	}
+	return &x.Checkpoints[i-1], nil
+}
+
+// indexMagic starts the binary encoding of an Index.
+const indexMagic = "flidx\x01"
+
+var errIndex = errors.New("flate: invalid index")
+
+// MarshalBinary implements the [encoding.BinaryMarshaler] interface.
+func (x *Index) MarshalBinary() ([]byte, error) {
+	n := len(indexMagic) + 2*binary.MaxVarintLen64
+	for _, c := range x.Checkpoints {
+		n += 3*binary.MaxVarintLen64 + 1 + len(c.Window)
+	}
+	b := make([]byte, 0, n)
+	b = append(b, indexMagic...)
+	b = binary.AppendUvarint(b, uint64(x.Size))
+	b = binary.AppendUvarint(b, uint64(len(x.Checkpoints)))
+	for _, c := range x.Checkpoints {
+		b = binary.AppendUvarint(b, uint64(c.In))
+		b = append(b, byte(c.Bits))
+		b = binary.AppendUvarint(b, uint64(c.Out))
+		b = binary.AppendUvarint(b, uint64(len(c.Window)))
+		b = append(b, c.Window...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the [encoding.BinaryUnmarshaler] interface.
+func (x *Index) UnmarshalBinary(b []byte) error {
+	if len(b) < len(indexMagic) || string(b[:len(indexMagic)]) != indexMagic {
+		return errIndex
+	}
+	b = b[len(indexMagic):]
+	uvarint := func() int64 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 || v > math.MaxInt64 {
+			b = nil
+			return -1
+		}
+		b = b[n:]
+		return int64(v)
+	}
+	size := uvarint()
+	count := uvarint()
+	if size < 0 || count < 0 || count > int64(len(b)) {
+		return errIndex
+	}
+	checkpoints := make([]Checkpoint, 0, count)
+	for range count {
+		var c Checkpoint
+		c.In = uvarint()
+		if len(b) == 0 {
+			return errIndex
+		}
+		c.Bits = int(b[0])
+		b = b[1:]
+		c.Out = uvarint()
+		n := uvarint()
+		if c.In < 0 || c.Bits > 7 || c.Out < 0 || c.Out > size ||
+			n < 0 || n > maxMatchOffset || n > int64(len(b)) {
+			return errIndex
+		}
+		if len(checkpoints) > 0 {
+			prev := &checkpoints[len(checkpoints)-1]
+			if c.In < prev.In || c.Out < prev.Out {
+				return errIndex
+			}
+		}
+		if n > 0 {
+			c.Window = append([]byte(nil), b[:n]...)
+		}
+		b = b[n:]
+		checkpoints = append(checkpoints, c)
+	}
+	if len(b) != 0 {
+		return errIndex
+	}
+	x.Checkpoints = checkpoints
+	x.Size = size
+	return nil
+}
+
+// An Indexer decompresses a DEFLATE stream, like the [io.ReadCloser]
+// returned by [NewReader], and builds an [Index] of the stream as it goes.
+type Indexer struct {
+	f    decompressor
+	span int64
+	out  int64 // uncompressed bytes returned by Read
+	idx  Index
+}
+
+// NewIndexer returns a new [Indexer] that decompresses r.
+// The Indexer records a checkpoint at the start of the first block
+// that begins at least span bytes of uncompressed data after the
+// previous checkpoint. If span is zero or negative, a span of 1 MiB is used.
+// Each checkpoint holds up to 32 KiB of data, so smaller spans make
+// for faster access at the cost of a larger index.
+//
+// If r does not also implement [io.ByteReader],
+// the decompressor may read more data than necessary from r.
+func NewIndexer(r io.Reader, span int64) *Indexer {
+	fixedHuffmanDecoderInit()
+
+	if span <= 0 {
+		span = defaultSpan
+	}
+	x := &Indexer{span: span}
+	x.f.bits = new([maxNumLit + maxNumDist]int)
+	x.f.codebits = new([numCodes]int)
+	x.f.Reset(r, nil)
+	x.f.indexer = x
+	return x
+}
+
+// checkpoint is called by the decompressor at the start of each block.
+func (x *Indexer) checkpoint() {
+	f := &x.f
+	out := x.out + int64(f.dict.availRead())
+	if n := len(x.idx.Checkpoints); n > 0 && out-x.idx.Checkpoints[n-1].Out < x.span {
+		return
+	}
+	pos := f.roffset*8 - int64(f.nb)
+	x.idx.Checkpoints = append(x.idx.Checkpoints, Checkpoint{
+		In:     pos / 8,
+		Bits:   int(pos % 8),
+		Out:    out,
+		Window: f.dict.appendWindow(nil),
+	})
+}
+
+// Read implements [io.Reader], reading uncompressed data.
+func (x *Indexer) Read(p []byte) (int, error) {
+	n, err := x.f.Read(p)
+	x.out += int64(n)
+	return n, err
+}
+
+// Close implements [io.Closer].
+func (x *Indexer) Close() error {
+	return x.f.Close()
+}
+
+// Index returns the index of the data read so far.
+// The index is complete once [Indexer.Read] has returned [io.EOF].
+func (x *Indexer) Index() *Index {
+	return &Index{
+		Checkpoints: x.idx.Checkpoints[:len(x.idx.Checkpoints):len(x.idx.Checkpoints)],
+		Size:        x.out,
+	}
+}
+
+// NewReaderCheckpoint returns a new ReadCloser that decompresses
+// a DEFLATE stream starting at the checkpoint c.
+// The first byte read from r must be the byte at offset c.In
+// in the compressed stream.
+// If r does not also implement [io.ByteReader],
+// the decompressor may read more data than necessary from r.
+func NewReaderCheckpoint(r io.Reader, c *Checkpoint) io.ReadCloser {
+	fixedHuffmanDecoderInit()
+
+	var f decompressor
+	f.bits = new([maxNumLit + maxNumDist]int)
+	f.codebits = new([numCodes]int)
+	f.resetAt(r, c)
+	return &f
+}
+
+// resetAt resets f to decompress the stream in r from the checkpoint c.
+func (f *decompressor) resetAt(r io.Reader, c *Checkpoint) {
+	f.Reset(r, c.Window)
+	f.roffset = c.In
+	if c.Bits < 0 || c.Bits > 7 {
+		f.err = CorruptInputError(c.In)
+		return
+	}
+	if c.Bits > 0 {
+		if f.err = f.moreBits(); f.err != nil {
+			return
+		}
+		f.b >>= uint(c.Bits)
+		f.nb -= uint(c.Bits)
+	}
+}
+
+// An IndexedReader uses an [Index] to provide random access to
+// the uncompressed data of a DEFLATE stream.
+type IndexedReader struct {
+	r    io.ReaderAt
+	idx  *Index
+	open func(r io.Reader, c *Checkpoint) io.Reader
+	off  int64 // offset for Read, as set by Seek
+
+	s    *indexedStream // stream used by Read, if any
+	pool sync.Pool      // of *indexedStream, for ReadAt
+}
+
+// An indexedStream decompresses the data from a checkpoint.
+type indexedStream struct {
+	rd  io.Reader
+	f   *decompressor // reused by position, if rd is a decompressor
+	off int64         // uncompressed offset of rd, or -1 if rd can't be used
+}
+
+// NewIndexedReader returns an [IndexedReader] that reads the DEFLATE stream
+// in r, which must be the stream that idx was built from.
+// The uncompressed data is not verified in any way, so using the wrong
+// index results in garbage output or a [CorruptInputError].
+func NewIndexedReader(r io.ReaderAt, idx *Index) *IndexedReader {
+	fixedHuffmanDecoderInit()
+	return &IndexedReader{r: r, idx: idx}
+}
+
+// NewIndexedReaderFunc is like [NewIndexedReader], but decompresses the
+// data from a checkpoint with the reader returned by open, which is passed
+// the data in r starting at offset c.In.
+//
+// It allows formats that wrap DEFLATE streams to use an [Index] whose
+// checkpoints give offsets in the wrapping format, such as the index built
+// by a [compress/gzip.Indexer].
+func NewIndexedReaderFunc(r io.ReaderAt, idx *Index, open func(r io.Reader, c *Checkpoint) io.Reader) *IndexedReader {
+	return &IndexedReader{r: r, idx: idx, open: open}
+}
+
+// Size returns the size of the uncompressed data.
+func (r *IndexedReader) Size() int64 { return r.idx.Size }
+
+// position prepares s to read the uncompressed data at off.
+// If reading forward from the current offset of s is no slower than
+// starting again from the nearest checkpoint, it continues from there.
+func (r *IndexedReader) position(s *indexedStream, off int64) error {
+	c, err := r.idx.find(off)
+	if err != nil {
+		return err
+	}
+	if s.off < 0 || s.off > off || s.off < c.Out {
+		sr := io.NewSectionReader(r.r, c.In, math.MaxInt64-c.In)
+		if r.open != nil {
+			s.rd = r.open(sr, c)
+		} else {
+			if s.f == nil {
+				fixedHuffmanDecoderInit()
+				s.f = new(decompressor)
+				s.f.bits = new([maxNumLit + maxNumDist]int)
+				s.f.codebits = new([numCodes]int)
+			}
+			s.f.resetAt(sr, c)
+			s.rd = s.f
+		}
+		s.off = c.Out
+	}
+	n, err := io.CopyN(io.Discard, s.rd, off-s.off)
+	s.off += n
+	if err != nil {
+		s.off = -1
+		return noEOF(err)
+	}
+	return nil
+}
+
+// Read implements the [io.Reader] interface.
+func (r *IndexedReader) Read(p []byte) (int, error) {
+	if r.off >= r.idx.Size {
+		return 0, io.EOF
+	}
+	if r.s == nil {
+		r.s = &indexedStream{off: -1}
+	}
+	if r.s.off != r.off {
+		if err := r.position(r.s, r.off); err != nil {
+			return 0, err
+		}
+	}
+	if rem := r.idx.Size - r.off; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+	n, err := r.s.rd.Read(p)
+	r.off += int64(n)
+	r.s.off += int64(n)
+	if err != nil && err != io.EOF {
+		r.s.off = -1
+	}
+	if err == io.EOF && r.off < r.idx.Size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek implements the [io.Seeker] interface.
+func (r *IndexedReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.off + offset
+	case io.SeekEnd:
+		abs = r.idx.Size + offset
+	default:
+		return 0, errors.New("flate.IndexedReader.Seek: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("flate.IndexedReader.Seek: negative position")
+	}
+	r.off = abs
+	return abs, nil
+}
+
+// ReadAt implements the [io.ReaderAt] interface.
+// It does not affect the offset used by [IndexedReader.Read],
+// and may be called concurrently with other calls to ReadAt.
+func (r *IndexedReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("flate.IndexedReader.ReadAt: negative offset")
+	}
+	if off >= r.idx.Size {
+		return 0, io.EOF
+	}
+	s, _ := r.pool.Get().(*indexedStream)
+	if s == nil {
+		s = &indexedStream{off: -1}
+	}
+	defer r.pool.Put(s)
+	if err := r.position(s, off); err != nil {
+		return 0, err
+	}
+	want := p
+	if rem := r.idx.Size - off; int64(len(want)) > rem {
+		want = want[:rem]
+	}
+	n, err := io.ReadFull(s.rd, want)
+	s.off += int64(n)
+	if err != nil {
+		s.off = -1
+		return n, noEOF(err)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
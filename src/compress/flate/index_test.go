@@ -0,0 +1,184 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func indexTestData(t *testing.T) []byte {
+	data, err := os.ReadFile("../../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Append some incompressible data, which is stored.
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 100000)
+	r.Read(random)
+	return append(data, random...)
+}
+
+func buildIndex(t *testing.T, compressed []byte, span int64) (*Index, []byte) {
+	x := NewIndexer(bytes.NewReader(compressed), span)
+	out, err := io.ReadAll(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return x.Index(), out
+}
+
+func TestIndex(t *testing.T) {
+	data := indexTestData(t)
+	for _, level := range []int{NoCompression, BestSpeed, DefaultCompression, BestCompression, HuffmanOnly} {
+		t.Run(fmt.Sprint(level), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, _ := NewWriter(&buf, level)
+			w.Write(data)
+			w.Close()
+
+			idx, out := buildIndex(t, buf.Bytes(), 50000)
+			if !bytes.Equal(out, data) {
+				t.Fatal("Indexer output differs from input")
+			}
+			if idx.Size != int64(len(data)) {
+				t.Errorf("Size = %d, want %d", idx.Size, len(data))
+			}
+			if len(idx.Checkpoints) < len(data)/100000 {
+				t.Errorf("got %d checkpoints, want at least %d", len(idx.Checkpoints), len(data)/100000)
+			}
+			if c := idx.Checkpoints[0]; c.In != 0 || c.Bits != 0 || c.Out != 0 || len(c.Window) != 0 {
+				t.Errorf("first checkpoint is %+v, want start of stream", c)
+			}
+
+			if level != NoCompression {
+				// Blocks don't usually end on a byte boundary,
+				// so some checkpoint must start part way through a byte.
+				midByte := false
+				for _, c := range idx.Checkpoints {
+					midByte = midByte || c.Bits != 0
+				}
+				if !midByte {
+					t.Error("no checkpoint has Bits != 0")
+				}
+			}
+
+			// Decompress from each checkpoint.
+			for _, c := range idx.Checkpoints {
+				r := NewReaderCheckpoint(bytes.NewReader(buf.Bytes()[c.In:]), &c)
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("reading from checkpoint at %d: %v", c.Out, err)
+				}
+				if !bytes.Equal(got, data[c.Out:]) {
+					t.Fatalf("reading from checkpoint at %d: wrong data", c.Out)
+				}
+			}
+
+			testIndexedReader(t, NewIndexedReader(bytes.NewReader(buf.Bytes()), idx), data)
+
+			// The index must survive a round trip through its encoding.
+			b, err := idx.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var idx2 Index
+			if err := idx2.UnmarshalBinary(b); err != nil {
+				t.Fatal(err)
+			}
+			testIndexedReader(t, NewIndexedReader(bytes.NewReader(buf.Bytes()), &idx2), data)
+		})
+	}
+}
+
+// testIndexedReader checks random access to data through r.
+func testIndexedReader(t *testing.T, r interface {
+	io.ReadSeeker
+	io.ReaderAt
+	Size() int64
+}, data []byte) {
+	t.Helper()
+	if r.Size() != int64(len(data)) {
+		t.Fatalf("Size = %d, want %d", r.Size(), len(data))
+	}
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		off := rnd.Int63n(int64(len(data)))
+		n := rnd.Intn(5000)
+		got := make([]byte, n)
+		m, err := r.ReadAt(got, off)
+		want := data[off:min(off+int64(n), int64(len(data)))]
+		if m != len(want) || !bytes.Equal(got[:m], want) {
+			t.Fatalf("ReadAt(%d, %d) = %d, %v; wrong data", n, off, m, err)
+		}
+		if m < n && err != io.EOF {
+			t.Fatalf("short ReadAt(%d, %d) = %d, %v; want EOF", n, off, m, err)
+		}
+		if m == n && err != nil {
+			t.Fatalf("ReadAt(%d, %d) = %d, %v", n, off, m, err)
+		}
+
+		// Seek, then read with a mix of short sequential reads
+		// and small forward seeks.
+		if _, err := r.Seek(off, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 3; j++ {
+			got := make([]byte, 100)
+			m, err := io.ReadFull(r, got)
+			want := data[min(off, int64(len(data))):min(off+100, int64(len(data)))]
+			if m != len(want) || !bytes.Equal(got[:m], want) {
+				t.Fatalf("Read at %d = %d, %v; wrong data", off, m, err)
+			}
+			off += 100 + int64(rnd.Intn(1000))
+			if _, err := r.Seek(off, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Read everything from the start.
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("ReadAll = %d bytes, %v; wrong data", len(got), err)
+	}
+	if pos, err := r.Seek(-10, io.SeekEnd); err != nil || pos != int64(len(data))-10 {
+		t.Fatalf("Seek(-10, io.SeekEnd) = %d, %v", pos, err)
+	}
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("Seek to negative position succeeded")
+	}
+}
+
+func TestIndexUnmarshalBad(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, BestSpeed)
+	w.Write(indexTestData(t))
+	w.Close()
+	idx, _ := buildIndex(t, buf.Bytes(), 100000)
+	b, err := idx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idx2 Index
+	for i := 0; i < len(b); i += 1 + i/100 {
+		if err := idx2.UnmarshalBinary(b[:i]); err == nil {
+			t.Errorf("UnmarshalBinary of %d bytes succeeded", i)
+		}
+	}
+	if err := idx2.UnmarshalBinary(append(b, 0)); err == nil {
+		t.Error("UnmarshalBinary with trailing data succeeded")
+	}
+}
@@ -297,9 +297,15 @@ type decompressor struct {
 	hl, hd    *huffmanDecoder
 	copyLen   int
 	copyDist  int
+
+	// If non-nil, indexer records a checkpoint at the start of each block.
+	indexer *Indexer
 }
 
 func (f *decompressor) nextBlock() {
+	if f.indexer != nil {
+		f.indexer.checkpoint()
+	}
 	for f.nb < 1+2 {
 		if f.err = f.moreBits(); f.err != nil {
 			return
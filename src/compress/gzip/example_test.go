@@ -216,3 +216,36 @@ func Example_compressingReader() {
 
 	// Output: the data to be compressed
 }
+
+func ExampleParallelWriter() {
+	var buf bytes.Buffer
+
+	// Compress in 256 KiB blocks, using up to 4 goroutines.
+	zw, err := gzip.NewParallelWriter(&buf, gzip.DefaultCompression, 256<<10, 4)
+	if err != nil {
+		log.Fatal(err)
+	}
+	zw.Name = "log.txt"
+
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(zw, "%d: request served\n", i)
+	}
+
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	// The output is an ordinary GZIP stream.
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	n, err := io.Copy(io.Discard, zr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s: %d bytes\n", zr.Name, n)
+
+	// Output:
+	// log.txt: 2188890 bytes
+}
@@ -80,6 +80,13 @@ type Reader struct {
 	buf          [512]byte
 	err          error
 	multistream  bool
+
+	// If non-nil, indexer builds an index of the data as it is read.
+	indexer *Indexer
+
+	// partial is set when reading from the middle of a member,
+	// whose checksum and size therefore can't be verified.
+	partial bool
 }
 
 // NewReader creates a new [Reader] reading the given reader.
@@ -234,7 +241,9 @@ func (z *Reader) readHeader() (hdr Header, err error) {
 	}
 
 	z.digest = 0
-	if z.decompressor == nil {
+	if z.indexer != nil {
+		z.decompressor = z.indexer.newMember()
+	} else if z.decompressor == nil {
 		z.decompressor = flate.NewReader(z.r)
 	} else {
 		z.decompressor.(flate.Resetter).Reset(z.r, nil)
@@ -264,11 +273,11 @@ func (z *Reader) Read(p []byte) (n int, err error) {
 		}
 		digest := le.Uint32(z.buf[:4])
 		size := le.Uint32(z.buf[4:8])
-		if digest != z.digest || size != z.size {
+		if !z.partial && (digest != z.digest || size != z.size) {
 			z.err = ErrChecksum
 			return n, z.err
 		}
-		z.digest, z.size = 0, 0
+		z.digest, z.size, z.partial = 0, 0, false
 
 		// File is ok; check if there is another.
 		if !z.multistream {
@@ -134,6 +134,50 @@ func (z *Writer) writeString(s string) (err error) {
 	return err
 }
 
+// writeHeader writes the GZIP header for z.Header to z.w.
+func (z *Writer) writeHeader() error {
+	z.buf = [10]byte{0: gzipID1, 1: gzipID2, 2: gzipDeflate}
+	if z.Extra != nil {
+		z.buf[3] |= 0x04
+	}
+	if z.Name != "" {
+		z.buf[3] |= 0x08
+	}
+	if z.Comment != "" {
+		z.buf[3] |= 0x10
+	}
+	if z.ModTime.After(time.Unix(0, 0)) {
+		// Section 2.3.1, the zero value for MTIME means that the
+		// modified time is not set.
+		le.PutUint32(z.buf[4:8], uint32(z.ModTime.Unix()))
+	}
+	if z.level == BestCompression {
+		z.buf[8] = 2
+	} else if z.level == BestSpeed {
+		z.buf[8] = 4
+	}
+	z.buf[9] = z.OS
+	if _, err := z.w.Write(z.buf[:10]); err != nil {
+		return err
+	}
+	if z.Extra != nil {
+		if err := z.writeBytes(z.Extra); err != nil {
+			return err
+		}
+	}
+	if z.Name != "" {
+		if err := z.writeString(z.Name); err != nil {
+			return err
+		}
+	}
+	if z.Comment != "" {
+		if err := z.writeString(z.Comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Write writes a compressed form of p to the underlying [io.Writer]. The
 // compressed bytes are not necessarily flushed until the [Writer] is closed.
 func (z *Writer) Write(p []byte) (int, error) {
@@ -144,49 +188,10 @@ func (z *Writer) Write(p []byte) (int, error) {
 	// Write the GZIP header lazily.
 	if !z.wroteHeader {
 		z.wroteHeader = true
-		z.buf = [10]byte{0: gzipID1, 1: gzipID2, 2: gzipDeflate}
-		if z.Extra != nil {
-			z.buf[3] |= 0x04
-		}
-		if z.Name != "" {
-			z.buf[3] |= 0x08
-		}
-		if z.Comment != "" {
-			z.buf[3] |= 0x10
-		}
-		if z.ModTime.After(time.Unix(0, 0)) {
-			// Section 2.3.1, the zero value for MTIME means that the
-			// modified time is not set.
-			le.PutUint32(z.buf[4:8], uint32(z.ModTime.Unix()))
-		}
-		if z.level == BestCompression {
-			z.buf[8] = 2
-		} else if z.level == BestSpeed {
-			z.buf[8] = 4
-		}
-		z.buf[9] = z.OS
-		_, z.err = z.w.Write(z.buf[:10])
+		z.err = z.writeHeader()
 		if z.err != nil {
 			return 0, z.err
 		}
-		if z.Extra != nil {
-			z.err = z.writeBytes(z.Extra)
-			if z.err != nil {
-				return 0, z.err
-			}
-		}
-		if z.Name != "" {
-			z.err = z.writeString(z.Name)
-			if z.err != nil {
-				return 0, z.err
-			}
-		}
-		if z.Comment != "" {
-			z.err = z.writeString(z.Comment)
-			if z.err != nil {
-				return 0, z.err
-			}
-		}
 		if z.compressor == nil {
 			z.compressor, _ = flate.NewWriter(z.w, z.level)
 		}
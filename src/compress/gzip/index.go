@@ -0,0 +1,180 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bufio"
+	"compress/flate"
+	"io"
+)
+
+// defaultSpan is the distance between checkpoints used by an [Indexer]
+// when none is specified. It matches that of [flate.NewIndexer].
+const defaultSpan = 1 << 20
+
+// An Indexer is a [Reader] that also builds an index of the data it reads.
+// The index can then be used with an [IndexedReader] to read the data
+// from any offset without decompressing the data before it.
+//
+// The index is a [flate.Index] whose checkpoints give offsets in the
+// gzip file. The file may contain multiple members.
+type Indexer struct {
+	Header // the header of the first member, valid after NewIndexer
+
+	z    Reader
+	cr   countingReader
+	span int64
+
+	fx      *flate.Indexer // indexer for the current member
+	inBase  int64          // offset of the compressed data of the current member
+	outBase int64          // uncompressed offset of the current member
+
+	checkpoints []flate.Checkpoint // checkpoints of previous members
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r flate.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	c, err := cr.r.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return c, err
+}
+
+// NewIndexer returns a new [Indexer] reading the given reader,
+// which must be positioned at the start of the gzip file.
+// The Indexer records checkpoints about every span bytes of
+// uncompressed data, as described in [flate.NewIndexer].
+// If span is zero or negative, a span of 1 MiB is used.
+//
+// The [Indexer.Header] fields will be valid in the [Indexer] returned.
+func NewIndexer(r io.Reader, span int64) (*Indexer, error) {
+	if span <= 0 {
+		span = defaultSpan
+	}
+	x := &Indexer{span: span}
+	if rr, ok := r.(flate.Reader); ok {
+		x.cr.r = rr
+	} else {
+		x.cr.r = bufio.NewReader(r)
+	}
+	x.z = Reader{
+		r:           &x.cr,
+		multistream: true,
+		indexer:     x,
+	}
+	var err error
+	if x.z.Header, err = x.z.readHeader(); err != nil {
+		return nil, err
+	}
+	x.Header = x.z.Header
+	return x, nil
+}
+
+// newMember is called by x.z when it starts a new member.
+// It returns the decompressor for the member.
+func (x *Indexer) newMember() io.ReadCloser {
+	if x.fx != nil {
+		x.checkpoints = x.appendMember(x.checkpoints)
+		x.outBase += x.fx.Index().Size
+	}
+	x.inBase = x.cr.n
+	x.fx = flate.NewIndexer(&x.cr, x.span)
+	return x.fx
+}
+
+// appendMember appends the checkpoints of the current member to dst,
+// adjusted to be relative to the start of the file.
+func (x *Indexer) appendMember(dst []flate.Checkpoint) []flate.Checkpoint {
+	for _, c := range x.fx.Index().Checkpoints {
+		c.In += x.inBase
+		c.Out += x.outBase
+		// Drop the checkpoint at the start of a member
+		// if it is close to the previous one.
+		if n := len(dst); n > 0 && c.Out-dst[n-1].Out < x.span {
+			continue
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// Read implements [io.Reader], reading uncompressed bytes
+// as described for [Reader.Read].
+func (x *Indexer) Read(p []byte) (int, error) {
+	return x.z.Read(p)
+}
+
+// Close closes the [Indexer]. It does not close the underlying [io.Reader].
+func (x *Indexer) Close() error { return x.z.Close() }
+
+// Index returns the index of the data read so far.
+// The index is complete once [Indexer.Read] has returned [io.EOF].
+func (x *Indexer) Index() *flate.Index {
+	checkpoints := x.appendMember(x.checkpoints[:len(x.checkpoints):len(x.checkpoints)])
+	return &flate.Index{
+		Checkpoints: checkpoints,
+		Size:        x.outBase + x.fx.Index().Size,
+	}
+}
+
+// resetAt discards z's state and prepares it to read the gzip file
+// in r starting at checkpoint c. The first byte read from r
+// must be the byte at offset c.In in the file.
+// The checksum of the member containing c is not verified.
+func (z *Reader) resetAt(r io.Reader, c *flate.Checkpoint) {
+	*z = Reader{
+		r:           bufio.NewReader(r),
+		multistream: true,
+		partial:     true,
+	}
+	z.decompressor = flate.NewReaderCheckpoint(z.r, c)
+}
+
+// An IndexedReader uses an index built by an [Indexer] to provide
+// random access to the uncompressed data of a gzip file.
+// Since an IndexedReader does not read the whole of each member,
+// it does not verify the checksums of the data it reads.
+type IndexedReader struct {
+	fr *flate.IndexedReader
+}
+
+// NewIndexedReader returns an [IndexedReader] that reads the gzip file
+// in r, which must be the file that idx was built from.
+func NewIndexedReader(r io.ReaderAt, idx *flate.Index) *IndexedReader {
+	// The gzip Reader continues with the following members
+	// when a member ends.
+	return &IndexedReader{flate.NewIndexedReaderFunc(r, idx, func(r io.Reader, c *flate.Checkpoint) io.Reader {
+		z := new(Reader)
+		z.resetAt(r, c)
+		return z
+	})}
+}
+
+// Size returns the size of the uncompressed data.
+func (r *IndexedReader) Size() int64 { return r.fr.Size() }
+
+// Read implements the [io.Reader] interface.
+func (r *IndexedReader) Read(p []byte) (int, error) { return r.fr.Read(p) }
+
+// Seek implements the [io.Seeker] interface.
+func (r *IndexedReader) Seek(offset int64, whence int) (int64, error) {
+	return r.fr.Seek(offset, whence)
+}
+
+// ReadAt implements the [io.ReaderAt] interface,
+// as described for [flate.IndexedReader.ReadAt].
+func (r *IndexedReader) ReadAt(p []byte, off int64) (int, error) { return r.fr.ReadAt(p, off) }
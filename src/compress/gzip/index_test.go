@@ -0,0 +1,116 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	text, err := os.ReadFile("../../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Build a file with several members, including an empty one
+	// and one written by a ParallelWriter.
+	var file, data bytes.Buffer
+	member := func(b []byte) {
+		w := NewWriter(&file)
+		w.Name = "member"
+		w.Write(b)
+		w.Close()
+		data.Write(b)
+	}
+	member(text[:100000])
+	member(nil)
+	member([]byte("a short member\n"))
+	member(text[100000:])
+	pw, err := NewParallelWriter(&file, BestSpeed, 40000, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw.Write(text)
+	pw.Close()
+	data.Write(text)
+
+	x, err := NewIndexer(bytes.NewReader(file.Bytes()), 30000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Name != "member" {
+		t.Errorf("Name = %q, want %q", x.Name, "member")
+	}
+	out, err := io.ReadAll(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data.Bytes()) {
+		t.Fatal("Indexer output differs from input")
+	}
+	idx := x.Index()
+	if idx.Size != int64(data.Len()) {
+		t.Errorf("Size = %d, want %d", idx.Size, data.Len())
+	}
+	if min := data.Len() / 60000; len(idx.Checkpoints) < min {
+		t.Errorf("got %d checkpoints, want at least %d", len(idx.Checkpoints), min)
+	}
+
+	r := NewIndexedReader(bytes.NewReader(file.Bytes()), idx)
+	want := data.Bytes()
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		off := rnd.Int63n(int64(len(want)))
+		n := rnd.Intn(100000)
+		got := make([]byte, n)
+		m, err := r.ReadAt(got, off)
+		end := min(off+int64(n), int64(len(want)))
+		if m != int(end-off) || !bytes.Equal(got[:m], want[off:end]) {
+			t.Fatalf("ReadAt(%d, %d) = %d, %v; wrong data", n, off, m, err)
+		}
+		if (m < n) != (err == io.EOF) {
+			t.Fatalf("ReadAt(%d, %d) = %d, %v", n, off, m, err)
+		}
+
+		if _, err := r.Seek(off, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		got = make([]byte, 1000)
+		m, _ = io.ReadFull(r, got)
+		end = min(off+1000, int64(len(want)))
+		if !bytes.Equal(got[:m], want[off:end]) {
+			t.Fatalf("Read at %d: wrong data", off)
+		}
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("ReadAll = %d bytes, %v; wrong data", len(got), err)
+	}
+}
+
+func TestIndexerChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write([]byte("hello, world\n"))
+	w.Close()
+	b := buf.Bytes()
+	b[len(b)-5] ^= 1 // corrupt the CRC
+
+	x, err := NewIndexer(bytes.NewReader(b), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(x); err != ErrChecksum {
+		t.Fatalf("ReadAll error = %v, want %v", err, ErrChecksum)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"runtime"
+)
+
+// defaultBlockSize is the block size used by a [ParallelWriter]
+// when none is specified.
+const defaultBlockSize = 1 << 20
+
+var errParallelClosed = errors.New("gzip: write to closed ParallelWriter")
+
+// A ParallelWriter is an io.WriteCloser that compresses data on
+// several goroutines. Writes to a ParallelWriter are split into blocks,
+// which are compressed concurrently and written to w in order.
+//
+// The output is a single GZIP member, so any GZIP reader can decompress it.
+// Each block is compressed independently and ends with a sync flush,
+// as in [Writer.Flush], so the output is typically slightly larger
+// than that of a [Writer].
+type ParallelWriter struct {
+	Header      // written at first call to Write, Flush, or Close
+	w           io.Writer
+	level       int
+	blockSize   int
+	concurrency int
+	wroteHeader bool
+	closed      bool
+	cur         *parallelBlock   // block being filled by Write
+	pending     []*parallelBlock // blocks being compressed, in output order
+	free        []*parallelBlock // blocks available for reuse
+	digest      uint32           // CRC-32, IEEE polynomial (section 8)
+	size        uint32           // Uncompressed size (section 2.3.1)
+	err         error
+}
+
+// A parallelBlock is a block of input and its compressed form.
+type parallelBlock struct {
+	in   []byte
+	out  bytes.Buffer
+	fw   *flate.Writer
+	last bool
+	done chan struct{} // closed when out is ready
+}
+
+// compress compresses b.in into b.out and closes b.done.
+func (b *parallelBlock) compress(level int) {
+	defer close(b.done)
+	b.out.Reset()
+	if b.fw == nil {
+		b.fw, _ = flate.NewWriter(&b.out, level)
+	} else {
+		b.fw.Reset(&b.out)
+	}
+	// Writes to a bytes.Buffer can't fail.
+	b.fw.Write(b.in)
+	if b.last {
+		b.fw.Close()
+	} else {
+		b.fw.Flush()
+	}
+}
+
+// NewParallelWriter returns a new [ParallelWriter].
+// Writes to the returned writer are compressed and written to w.
+//
+// The compression level is as for [NewWriterLevel].
+// The input is compressed in blocks of blockSize bytes, using up to
+// concurrency goroutines at a time. If blockSize is zero or negative,
+// a block size of 1 MiB is used. If concurrency is zero or negative,
+// the value of runtime.GOMAXPROCS(0) is used.
+// The writer may hold up to concurrency+1 blocks in memory.
+//
+// It is the caller's responsibility to call Close on the [ParallelWriter]
+// when done. Writes are buffered and not flushed until Flush or Close.
+//
+// Callers that wish to set the fields in ParallelWriter.Header must do so
+// before the first call to Write, Flush, or Close.
+func NewParallelWriter(w io.Writer, level, blockSize, concurrency int) (*ParallelWriter, error) {
+	if level < HuffmanOnly || level > BestCompression {
+		return nil, fmt.Errorf("gzip: invalid compression level: %d", level)
+	}
+	if blockSize <= 0 {
+		blockSize = defaultBlockSize
+	}
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	z := &ParallelWriter{
+		level:       level,
+		blockSize:   blockSize,
+		concurrency: concurrency,
+	}
+	z.Reset(w)
+	return z, nil
+}
+
+// Reset discards the [ParallelWriter] z's state and makes it equivalent
+// to the result of its original state from [NewParallelWriter], but
+// writing to w instead. This permits reusing a [ParallelWriter] rather
+// than allocating a new one.
+func (z *ParallelWriter) Reset(w io.Writer) {
+	// Wait for any blocks that are still being compressed,
+	// so that they can be reused.
+	for _, b := range z.pending {
+		<-b.done
+		z.recycle(b)
+	}
+	if z.cur != nil {
+		z.recycle(z.cur)
+	}
+	z.Header = Header{
+		OS: 255, // unknown
+	}
+	z.w = w
+	z.wroteHeader = false
+	z.closed = false
+	z.cur = nil
+	z.pending = z.pending[:0]
+	z.digest = 0
+	z.size = 0
+	z.err = nil
+}
+
+func (z *ParallelWriter) recycle(b *parallelBlock) {
+	b.in = b.in[:0]
+	z.free = append(z.free, b)
+}
+
+// writeHeader writes the GZIP header, if it has not been written yet.
+func (z *ParallelWriter) writeHeader() error {
+	if z.wroteHeader {
+		return nil
+	}
+	z.wroteHeader = true
+	hw := Writer{Header: z.Header, w: z.w, level: z.level}
+	z.err = hw.writeHeader()
+	return z.err
+}
+
+// Write writes a compressed form of p to the underlying [io.Writer].
+// The compressed bytes are not necessarily flushed until the
+// [ParallelWriter] is flushed or closed.
+func (z *ParallelWriter) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errParallelClosed
+	}
+	if err := z.writeHeader(); err != nil {
+		return 0, err
+	}
+	// The checksum is computed here rather than per block,
+	// as it is cheap compared to compression.
+	z.size += uint32(len(p))
+	z.digest = crc32.Update(z.digest, crc32.IEEETable, p)
+	n := 0
+	for len(p) > 0 {
+		if z.cur == nil {
+			z.cur = z.newBlock()
+		}
+		c := min(len(p), z.blockSize-len(z.cur.in))
+		z.cur.in = append(z.cur.in, p[:c]...)
+		p = p[c:]
+		n += c
+		if len(z.cur.in) == z.blockSize {
+			if err := z.startBlock(false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// newBlock returns an empty block to fill.
+func (z *ParallelWriter) newBlock() *parallelBlock {
+	if n := len(z.free); n > 0 {
+		b := z.free[n-1]
+		z.free = z.free[:n-1]
+		return b
+	}
+	return &parallelBlock{in: make([]byte, 0, z.blockSize)}
+}
+
+// startBlock starts compressing z.cur. If concurrency blocks are already
+// being compressed, it first waits for the oldest one and writes it out.
+func (z *ParallelWriter) startBlock(last bool) error {
+	if len(z.pending) >= z.concurrency {
+		if err := z.writeBlock(); err != nil {
+			return err
+		}
+	}
+	b := z.cur
+	z.cur = nil
+	b.last = last
+	b.done = make(chan struct{})
+	z.pending = append(z.pending, b)
+	go b.compress(z.level)
+	return nil
+}
+
+// writeBlock waits for the oldest pending block and writes it to z.w.
+func (z *ParallelWriter) writeBlock() error {
+	b := z.pending[0]
+	<-b.done
+	n := copy(z.pending, z.pending[1:])
+	z.pending = z.pending[:n]
+	_, z.err = z.w.Write(b.out.Bytes())
+	z.recycle(b)
+	return z.err
+}
+
+// Flush compresses any buffered data and writes it, along with the
+// data from all previous writes, to the underlying writer.
+// As with [Writer.Flush], the data written so far can then be decompressed.
+// Flush does not return until the data has been written.
+// If the underlying writer returns an error, Flush returns that error.
+//
+// Each call to Flush ends the current block,
+// so frequent calls reduce both parallelism and compression.
+func (z *ParallelWriter) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+	if z.cur != nil && len(z.cur.in) > 0 {
+		if err := z.startBlock(false); err != nil {
+			return err
+		}
+	}
+	for len(z.pending) > 0 {
+		if err := z.writeBlock(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the [ParallelWriter] by compressing any buffered data,
+// waiting for all blocks to be written to the underlying [io.Writer],
+// and writing the GZIP footer.
+// It does not close the underlying [io.Writer].
+func (z *ParallelWriter) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+	if z.cur == nil {
+		z.cur = z.newBlock()
+	}
+	if err := z.startBlock(true); err != nil {
+		return err
+	}
+	for len(z.pending) > 0 {
+		if err := z.writeBlock(); err != nil {
+			return err
+		}
+	}
+	var buf [8]byte
+	le.PutUint32(buf[:4], z.digest)
+	le.PutUint32(buf[4:8], z.size)
+	_, z.err = z.w.Write(buf[:8])
+	return z.err
+}
@@ -0,0 +1,230 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gzip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func parallelInput(t *testing.T) []byte {
+	e, err := os.ReadFile("../testdata/e.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	var b []byte
+	for len(b) < 1<<20 {
+		b = append(b, e[:r.Intn(len(e))]...)
+		b = append(b, byte(r.Intn(256)))
+	}
+	return b
+}
+
+func TestParallelWriterRoundTrip(t *testing.T) {
+	data := parallelInput(t)
+	for _, tt := range []struct {
+		level, blockSize, concurrency int
+		writeSize                     int
+	}{
+		{DefaultCompression, 0, 0, 1 << 20},
+		{DefaultCompression, 64 << 10, 4, 1000},
+		{BestSpeed, 100000, 3, 64 << 10},
+		{BestCompression, 1 << 16, 1, 1 << 16},
+		{NoCompression, 50000, 2, 7777},
+		{HuffmanOnly, 30000, 8, 1 << 20},
+		{DefaultCompression, 1, 2, 3},
+	} {
+		name := fmt.Sprintf("level=%d/block=%d/conc=%d", tt.level, tt.blockSize, tt.concurrency)
+		t.Run(name, func(t *testing.T) {
+			input := data
+			if tt.blockSize == 1 {
+				input = input[:5000]
+			}
+			var buf bytes.Buffer
+			w, err := NewParallelWriter(&buf, tt.level, tt.blockSize, tt.concurrency)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for p := input; len(p) > 0; {
+				n := min(len(p), tt.writeSize)
+				if _, err := w.Write(p[:n]); err != nil {
+					t.Fatal(err)
+				}
+				p = p[n:]
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The output is a single member.
+			r.Multistream(false)
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, input) {
+				t.Fatal("round trip mismatch")
+			}
+			if _, err := r.Read(make([]byte, 1)); err != io.EOF {
+				t.Fatalf("Read after end = %v, want EOF", err)
+			}
+			if err := r.Reset(&buf); err != io.EOF {
+				t.Fatalf("data after first member: Reset = %v", err)
+			}
+		})
+	}
+}
+
+func TestParallelWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewParallelWriter(&buf, DefaultCompression, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Header{OS: 255}); !reflect.DeepEqual(r.Header, want) {
+		t.Errorf("Header mismatch:\ngot  %#v\nwant %#v", r.Header, want)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil || len(b) != 0 {
+		t.Fatalf("ReadAll = %d bytes, %v; want 0 bytes, nil", len(b), err)
+	}
+}
+
+func TestParallelWriterHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewParallelWriter(&buf, BestCompression, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Comment = "comment"
+	w.Extra = []byte("extra")
+	w.ModTime = time.Unix(1e8, 0)
+	w.Name = "name"
+	w.Write([]byte("payload"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("more")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Header{
+		Comment: "comment",
+		Extra:   []byte("extra"),
+		ModTime: time.Unix(1e8, 0),
+		Name:    "name",
+		OS:      255,
+	}
+	if !reflect.DeepEqual(r.Header, want) {
+		t.Errorf("Header mismatch:\ngot  %#v\nwant %#v", r.Header, want)
+	}
+	b, err := io.ReadAll(r)
+	if string(b) != "payload" || err != nil {
+		t.Errorf("ReadAll = %q, %v; want %q, nil", b, err, "payload")
+	}
+}
+
+func TestParallelWriterFlush(t *testing.T) {
+	pr, pw := io.Pipe()
+	w, err := NewParallelWriter(pw, DefaultCompression, 1000, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(w, "line %d\n", i)
+			if err := w.Flush(); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(w.Close())
+	}()
+
+	r, err := NewReader(pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each line must be readable before the next is written.
+	for i := 0; i < 10; i++ {
+		want := fmt.Sprintf("line %d\n", i)
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParallelWriterReset(t *testing.T) {
+	data := parallelInput(t)
+	var buf1, buf2 bytes.Buffer
+	w, err := NewParallelWriter(&buf1, DefaultCompression, 100000, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	w.Close()
+
+	// Reset in the middle of a stream, then write the same data.
+	w.Reset(io.Discard)
+	w.Write(data[:500000])
+	w.Reset(&buf2)
+	w.Write(data)
+	w.Close()
+
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Error("output after Reset differs from original output")
+	}
+}
+
+func TestParallelWriterErrors(t *testing.T) {
+	if _, err := NewParallelWriter(io.Discard, BestCompression+1, 0, 0); err == nil {
+		t.Error("NewParallelWriter with invalid level succeeded")
+	}
+
+	w, err := NewParallelWriter(&limitedWriter{N: 100}, DefaultCompression, 1000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := parallelInput(t)[:10000]
+	n, err := w.Write(data)
+	if err == nil {
+		t.Fatal("Write to short writer succeeded")
+	}
+	if n > len(data) {
+		t.Errorf("Write returned %d bytes written, more than %d", n, len(data))
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close after error succeeded")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd_test
+
+import (
+	"bytes"
+	"compress/zstd"
+	"io"
+	"log"
+	"os"
+)
+
+func Example_writerReader() {
+	var buf bytes.Buffer
+	zw := zstd.NewWriter(&buf)
+
+	_, err := zw.Write([]byte("A long time ago in a galaxy far, far away..."))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	zr := zstd.NewReader(&buf)
+	if _, err := io.Copy(os.Stdout, zr); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// A long time ago in a galaxy far, far away...
+}
+
+// A dictionary that contains data similar to the data being compressed
+// can greatly improve compression of small inputs. The same dictionary
+// must be used to decompress the data.
+func Example_dictionary() {
+	dict := []byte(`{"name": "", "email": "", "roles": ["admin", "user"], "active": true}`)
+	record := []byte(`{"name": "gopher", "email": "gopher@example.com", "roles": ["user"], "active": true}`)
+
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriterDict(&buf, zstd.BestCompression, dict)
+	if err != nil {
+		log.Fatal(err)
+	}
+	zw.Write(record)
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	zr, err := zstd.NewReaderDict(&buf, dict)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := io.Copy(os.Stdout, zr); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// {"name": "gopher", "email": "gopher@example.com", "roles": ["user"], "active": true}
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zstd implements reading and writing of zstd compressed data,
+// as specified in RFC 8878.
+package zstd
+
+import (
+	"internal/zstd"
+	"io"
+)
+
+// A Reader is an [io.Reader] that can be read to retrieve
+// uncompressed data from a zstd compressed stream.
+//
+// A stream may consist of several frames, which are decompressed
+// in sequence. Skippable frames are ignored.
+type Reader struct {
+	r *zstd.Reader
+}
+
+// NewReader creates a new [Reader] reading the given reader.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: zstd.NewReader(r)}
+}
+
+// NewReaderDict is like [NewReader] but decompresses using the
+// dictionary dict. The dictionary may be either a formatted zstd
+// dictionary, as produced by the zstd command's --train option, or
+// raw content. The dictionary is used for frames that either request
+// it by its ID or do not request any dictionary.
+//
+// NewReaderDict retains dict; the caller must not modify it.
+func NewReaderDict(r io.Reader, dict []byte) (*Reader, error) {
+	d, err := zstd.ParseDict(dict)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: zstd.NewReaderDict(r, d)}, nil
+}
+
+// Read implements [io.Reader], reading uncompressed bytes from its
+// underlying reader.
+func (z *Reader) Read(p []byte) (int, error) {
+	return z.r.Read(p)
+}
+
+// ReadByte implements [io.ByteReader].
+func (z *Reader) ReadByte() (byte, error) {
+	return z.r.ReadByte()
+}
+
+// Reset discards the [Reader] z's state and makes it equivalent to the
+// result of its original state from [NewReader] or [NewReaderDict],
+// but reading from r instead. This permits reusing a [Reader] rather
+// than allocating a new one. The dictionary, if any, is retained.
+func (z *Reader) Reset(r io.Reader) {
+	z.r.Reset(r)
+}
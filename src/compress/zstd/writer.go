@@ -0,0 +1,120 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"fmt"
+	"internal/zstd"
+	"io"
+)
+
+// Compression levels accepted by [NewWriterLevel] and [NewWriterDict].
+const (
+	// NoCompression stores the data in raw blocks without compressing it.
+	// The output is still a valid zstd frame.
+	NoCompression = 0
+
+	// BestSpeed is the fastest level, trading compression ratio for speed.
+	BestSpeed = 1
+
+	// BestCompression is the level that compresses best, and most slowly.
+	BestCompression = zstd.MaxLevel
+
+	// DefaultCompression selects a level that balances speed and
+	// compression ratio.
+	DefaultCompression = -1
+)
+
+// defaultLevel is the level used for DefaultCompression.
+// It is a good balance of speed and compression ratio.
+const defaultLevel = 3
+
+// A Writer is an [io.WriteCloser].
+// Writes to a Writer are compressed and written to w.
+//
+// A Writer writes a single zstd frame that includes a checksum
+// of the uncompressed data, which the reader verifies.
+type Writer struct {
+	w *zstd.Writer
+}
+
+// NewWriter returns a new [Writer] compressing data at the default level.
+// Writes to the returned writer are compressed and written to w.
+//
+// It is the caller's responsibility to call Close on the [Writer] when done.
+// Writes may be buffered and not flushed until Close.
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterLevel(w, DefaultCompression)
+	return z
+}
+
+// NewWriterLevel is like [NewWriter] but specifies the compression level
+// instead of assuming [DefaultCompression].
+//
+// The compression level can be [DefaultCompression], [NoCompression],
+// or any integer value between [BestSpeed] and [BestCompression] inclusive.
+// Higher levels compress better but more slowly, and use more memory.
+// The error returned will be nil if the level is valid.
+func NewWriterLevel(w io.Writer, level int) (*Writer, error) {
+	return NewWriterDict(w, level, nil)
+}
+
+// NewWriterDict is like [NewWriterLevel] but compresses using the
+// dictionary dict. The dictionary may be either a formatted zstd
+// dictionary, as produced by the zstd command's --train option, or
+// raw content. If dict is a formatted dictionary, the written frame
+// records the dictionary ID. Data compressed with a dictionary can
+// only be decompressed with the same dictionary, as by [NewReaderDict].
+//
+// NewWriterDict retains dict; the caller must not modify it.
+func NewWriterDict(w io.Writer, level int, dict []byte) (*Writer, error) {
+	if level == DefaultCompression {
+		level = defaultLevel
+	}
+	if level < NoCompression || level > BestCompression {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", level)
+	}
+	var d *zstd.Dict
+	if dict != nil {
+		var err error
+		d, err = zstd.ParseDict(dict)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Writer{w: zstd.NewWriter(w, level, d)}, nil
+}
+
+// Write writes a compressed form of p to the underlying [io.Writer]. The
+// compressed bytes are not necessarily flushed until the [Writer] is closed
+// or flushed.
+func (z *Writer) Write(p []byte) (int, error) {
+	return z.w.Write(p)
+}
+
+// Flush flushes any pending compressed data to the underlying writer.
+//
+// It is useful mainly in compressed network protocols, to ensure that
+// a remote reader has enough data to reconstruct a packet. Flush does
+// not return until the data has been written. If the underlying
+// writer returns an error, Flush returns that error.
+func (z *Writer) Flush() error {
+	return z.w.Flush()
+}
+
+// Close closes the [Writer] by flushing any unwritten data to the underlying
+// [io.Writer] and writing the end of the frame, including the checksum.
+// It does not close the underlying [io.Writer].
+func (z *Writer) Close() error {
+	return z.w.Close()
+}
+
+// Reset discards the [Writer] z's state and makes it equivalent to the
+// result of its original state from [NewWriter], [NewWriterLevel] or
+// [NewWriterDict], but writing to w instead. This permits reusing a
+// [Writer] rather than allocating a new one.
+func (z *Writer) Reset(w io.Writer) {
+	z.w.Reset(w)
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data, err := os.ReadFile("../testdata/e.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels := []int{DefaultCompression, NoCompression}
+	for level := BestSpeed; level <= BestCompression; level++ {
+		levels = append(levels, level)
+	}
+	for _, level := range levels {
+		t.Run(fmt.Sprint(level), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriterLevel(&buf, level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if level != NoCompression && buf.Len() >= len(data) {
+				t.Errorf("compressed %d bytes to %d", len(data), buf.Len())
+			}
+			got, err := io.ReadAll(NewReader(&buf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("round trip mismatch")
+			}
+		})
+	}
+}
+
+func TestInvalidLevel(t *testing.T) {
+	for _, level := range []int{-2, BestCompression + 1} {
+		if _, err := NewWriterLevel(io.Discard, level); err == nil {
+			t.Errorf("NewWriterLevel(%d) succeeded", level)
+		}
+	}
+}
+
+func TestDict(t *testing.T) {
+	dict := []byte(strings.Repeat("Go is an open source programming language. ", 20))
+	data := []byte("Go is an open source programming language that makes it simple to build secure, scalable systems.")
+
+	var buf bytes.Buffer
+	w, err := NewWriterDict(&buf, DefaultCompression, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	compressed := bytes.Clone(buf.Bytes())
+
+	r, err := NewReaderDict(bytes.NewReader(compressed), dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("got %q, want %q", got, data)
+	}
+
+	// Reset keeps the dictionary.
+	r.Reset(bytes.NewReader(compressed))
+	got, err = io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("after Reset got %q, want %q", got, data)
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	var buf1, buf2 bytes.Buffer
+	w := NewWriter(&buf1)
+	msg := []byte("hello, world\n")
+	w.Write(msg)
+	w.Close()
+
+	w.Reset(&buf2)
+	w.Write(msg)
+	w.Close()
+
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Errorf("Reset output %x differs from %x", buf2.Bytes(), buf1.Bytes())
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write([]byte("hello, world\n"))
+	w.Close()
+
+	// Corrupt the checksum at the end of the frame.
+	b := buf.Bytes()
+	b[len(b)-1] ^= 0xff
+	if _, err := io.ReadAll(NewReader(bytes.NewReader(b))); err == nil {
+		t.Error("corrupted checksum not detected")
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hpke implements Hybrid Public Key Encryption (HPKE), as specified
+// in RFC 9180.
+//
+// HPKE combines a key encapsulation method (KEM), a key derivation function
+// (KDF) and an authenticated encryption scheme (AEAD), selected by a
+// [Suite], to encrypt messages to the holder of a private key. A [Sender]
+// sets up an encryption context for a recipient's public key, producing an
+// encapsulated key that the [Recipient] uses to set up the matching context.
+//
+// All four modes of RFC 9180 are supported. The Base mode is used by default,
+// and [Options] selects the PSK mode, which authenticates the sender by a
+// pre-shared key, the Auth mode, which authenticates the sender by its
+// private key, or the AuthPSK mode, which combines the two.
+//
+// The keys of the supported KEMs are [crypto/ecdh] keys.
+package hpke
+
+import (
+	"crypto/ecdh"
+	"crypto/internal/hpke"
+	"crypto/rand"
+	"errors"
+	"strconv"
+)
+
+// A KEM is a key encapsulation method, identified by its RFC 9180 value.
+type KEM uint16
+
+const (
+	DHKEM_P256_HKDF_SHA256   KEM = 0x0010
+	DHKEM_P384_HKDF_SHA384   KEM = 0x0011
+	DHKEM_P521_HKDF_SHA512   KEM = 0x0012
+	DHKEM_X25519_HKDF_SHA256 KEM = 0x0020
+)
+
+// Curve returns the curve of the keys of kem, or nil if kem is not
+// supported. It can be used to parse keys, such as with
+// [ecdh.Curve.NewPublicKey].
+func (kem KEM) Curve() ecdh.Curve {
+	c, err := hpke.Curve(uint16(kem))
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// GenerateKey generates a new private key for kem, drawing random bytes from
+// crypto/rand.
+func (kem KEM) GenerateKey() (*ecdh.PrivateKey, error) {
+	c, err := hpke.Curve(uint16(kem))
+	if err != nil {
+		return nil, errUnsupported("KEM", uint16(kem))
+	}
+	return c.GenerateKey(rand.Reader)
+}
+
+// DeriveKeyPair deterministically derives a private key for kem from the
+// input keying material ikm, which must have at least as much entropy as
+// the private key, according to RFC 9180, Section 7.1.3.
+func (kem KEM) DeriveKeyPair(ikm []byte) (*ecdh.PrivateKey, error) {
+	if kem.Curve() == nil {
+		return nil, errUnsupported("KEM", uint16(kem))
+	}
+	return hpke.DeriveKeyPair(uint16(kem), ikm)
+}
+
+// A KDF is a key derivation function, identified by its RFC 9180 value.
+type KDF uint16
+
+const (
+	HKDF_SHA256 KDF = 0x0001
+	HKDF_SHA384 KDF = 0x0002
+	HKDF_SHA512 KDF = 0x0003
+)
+
+// An AEAD is an authenticated encryption scheme, identified by its RFC 9180
+// value.
+type AEAD uint16
+
+const (
+	AES_128_GCM       AEAD = 0x0001
+	AES_256_GCM       AEAD = 0x0002
+	CHACHA20_POLY1305 AEAD = 0x0003
+
+	// EXPORT_ONLY sets up contexts that can only be used with Export.
+	EXPORT_ONLY AEAD = 0xffff
+)
+
+// A Suite is a combination of a KEM, a KDF and an AEAD.
+type Suite struct {
+	KEM  KEM
+	KDF  KDF
+	AEAD AEAD
+}
+
+// Options selects the mode of a context other than the Base mode.
+//
+// The sender and the recipient must use the same mode, and the same PSK
+// and PSKID.
+type Options struct {
+	// PSK and PSKID are a pre-shared key and its identifier, which select
+	// the PSK mode, or the AuthPSK mode together with a sender key.
+	// Either both or neither must be set. The PSK must have at least 32 bytes
+	// of entropy.
+	PSK, PSKID []byte
+
+	// SenderKey is the private key of the sender, which selects the Auth
+	// mode, or the AuthPSK mode together with a PSK. It is used by
+	// [Suite.NewSender] and [Suite.Seal].
+	SenderKey *ecdh.PrivateKey
+
+	// SenderPublicKey is the public key of the sender in the Auth and
+	// AuthPSK modes. It is used by [Suite.NewRecipient] and [Suite.Open].
+	SenderPublicKey *ecdh.PublicKey
+}
+
+func (o *Options) internal() *hpke.Options {
+	if o == nil {
+		return nil
+	}
+	return &hpke.Options{
+		PSK:              o.PSK,
+		PSKID:            o.PSKID,
+		SenderPrivateKey: o.SenderKey,
+		SenderPublicKey:  o.SenderPublicKey,
+	}
+}
+
+// check returns an error if s is not supported.
+func (s Suite) check() error {
+	if s.KEM.Curve() == nil {
+		return errUnsupported("KEM", uint16(s.KEM))
+	}
+	if _, ok := hpke.SupportedKDFs[uint16(s.KDF)]; !ok {
+		return errUnsupported("KDF", uint16(s.KDF))
+	}
+	if _, ok := hpke.SupportedAEADs[uint16(s.AEAD)]; !ok && s.AEAD != EXPORT_ONLY {
+		return errUnsupported("AEAD", uint16(s.AEAD))
+	}
+	return nil
+}
+
+func errUnsupported(kind string, id uint16) error {
+	return errors.New("hpke: unsupported " + kind + " 0x" + strconv.FormatUint(uint64(id), 16))
+}
+
+// NewSender sets up an encryption context for the recipient's public key
+// pub, binding it to the application-supplied info. opts may be nil for the
+// Base mode.
+//
+// It returns the encapsulated key that must be sent to the recipient.
+func (s Suite) NewSender(pub *ecdh.PublicKey, info []byte, opts *Options) (enc []byte, sender *Sender, err error) {
+	if err := s.check(); err != nil {
+		return nil, nil, err
+	}
+	enc, ctx, err := hpke.NewSender(uint16(s.KEM), uint16(s.KDF), uint16(s.AEAD), pub, info, opts.internal())
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, &Sender{ctx: ctx}, nil
+}
+
+// NewRecipient sets up the decryption context matching the one set up by
+// the sender that produced the encapsulated key enc, with the recipient's
+// private key priv. info and opts must be the same as the sender's, except
+// that opts holds the sender's public key in the Auth and AuthPSK modes.
+func (s Suite) NewRecipient(enc []byte, priv *ecdh.PrivateKey, info []byte, opts *Options) (*Recipient, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	ctx, err := hpke.NewRecipient(uint16(s.KEM), uint16(s.KDF), uint16(s.AEAD), enc, priv, info, opts.internal())
+	if err != nil {
+		return nil, err
+	}
+	return &Recipient{ctx: ctx}, nil
+}
+
+// Seal encrypts and authenticates a single message to the recipient's public
+// key pub, together with the additional data aad, and returns the
+// encapsulated key and the ciphertext, according to RFC 9180, Section 6.1.
+func (s Suite) Seal(pub *ecdh.PublicKey, info, aad, plaintext []byte, opts *Options) (enc, ciphertext []byte, err error) {
+	enc, sender, err := s.NewSender(pub, info, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphertext, err = sender.Seal(aad, plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, ciphertext, nil
+}
+
+// Open decrypts and authenticates a single message sealed by [Suite.Seal].
+func (s Suite) Open(enc []byte, priv *ecdh.PrivateKey, info, aad, ciphertext []byte, opts *Options) ([]byte, error) {
+	r, err := s.NewRecipient(enc, priv, info, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.Open(aad, ciphertext)
+}
+
+// A Sender is the encryption context of a sender.
+//
+// A Sender is not safe for concurrent use, as the messages must be sealed
+// in the order in which the recipient opens them.
+type Sender struct {
+	ctx *hpke.Sender
+}
+
+// Seal encrypts and authenticates the next message of the context,
+// together with the additional data aad.
+//
+// It returns an error if the context uses the [EXPORT_ONLY] AEAD, or if the
+// maximum number of messages has been reached.
+func (s *Sender) Seal(aad, plaintext []byte) ([]byte, error) {
+	return s.ctx.Seal(aad, plaintext)
+}
+
+// Export derives a secret of length bytes from the context, bound to
+// exporterContext, according to RFC 9180, Section 5.3. The recipient derives
+// the same secret from the same exporterContext.
+func (s *Sender) Export(exporterContext []byte, length int) ([]byte, error) {
+	return s.ctx.Export(exporterContext, length)
+}
+
+// A Recipient is the decryption context of a recipient.
+//
+// A Recipient is not safe for concurrent use, as the messages must be opened
+// in the order in which the sender sealed them.
+type Recipient struct {
+	ctx *hpke.Recipient
+}
+
+// Open decrypts and authenticates the next message of the context, which
+// must have been sealed with the additional data aad. If Open fails, the
+// context is unchanged, and still expects the same message.
+func (r *Recipient) Open(aad, ciphertext []byte) ([]byte, error) {
+	return r.ctx.Open(aad, ciphertext)
+}
+
+// Export derives a secret of length bytes from the context, bound to
+// exporterContext, according to RFC 9180, Section 5.3.
+func (r *Recipient) Export(exporterContext []byte, length int) ([]byte, error) {
+	return r.ctx.Export(exporterContext, length)
+}
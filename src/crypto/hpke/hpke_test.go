@@ -0,0 +1,260 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	. "crypto/hpke"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, in string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestRFC9180AuthPSK checks the first message and export of the RFC 9180
+// vector for the AuthPSK mode with DHKEM(P-256, HKDF-SHA256), HKDF-SHA256
+// and AES-128-GCM. The other vectors are checked by crypto/internal/hpke.
+func TestRFC9180AuthPSK(t *testing.T) {
+	suite := Suite{DHKEM_P256_HKDF_SHA256, HKDF_SHA256, AES_128_GCM}
+	skR, err := suite.KEM.Curve().NewPrivateKey(mustDecodeHex(t, "bdf4e2e587afdf0930644a0c45053889ebcadeca662d7c755a353d5b4e2a8394"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	skS, err := suite.KEM.Curve().NewPrivateKey(mustDecodeHex(t, "b0ed8721db6185435898650f7a677affce925aba7975a582653c4cb13c72d240"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{
+		PSK:             mustDecodeHex(t, "0247fd33b913760fa1fa51e1892d9f307fbe65eb171e8132c2af18555a738b82"),
+		PSKID:           mustDecodeHex(t, "456e6e796e20447572696e206172616e204d6f726961"),
+		SenderPublicKey: skS.PublicKey(),
+	}
+	enc := mustDecodeHex(t, "046a1de3fc26a3d43f4e4ba97dbe24f7e99181136129c48fbe872d4743e2b131357ed4f29a7b317dc22509c7b00991ae990bf65f8b236700c82ab7c11a84511401")
+	info := mustDecodeHex(t, "4f6465206f6e2061204772656369616e2055726e")
+	r, err := suite.NewRecipient(enc, skR, info, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := r.Open(mustDecodeHex(t, "436f756e742d30"), mustDecodeHex(t, "b9f36d58d9eb101629a3e5a7b63d2ee4af42b3644209ab37e0a272d44365407db8e655c72e4fa46f4ff81b9246"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Beauty is truth, truth beauty"; string(pt) != want {
+		t.Errorf("Open = %q, want %q", pt, want)
+	}
+	exported, err := r.Export(nil, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustDecodeHex(t, "595ce0eff405d4b3bb1d08308d70a4e77226ce11766e0a94c4fdb5d90025c978"); !bytes.Equal(exported, want) {
+		t.Errorf("Export = %x, want %x", exported, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, kem := range []KEM{DHKEM_P256_HKDF_SHA256, DHKEM_P384_HKDF_SHA384, DHKEM_P521_HKDF_SHA512, DHKEM_X25519_HKDF_SHA256} {
+		skR, err := kem.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		skS, err := kem.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, kdf := range []KDF{HKDF_SHA256, HKDF_SHA384, HKDF_SHA512} {
+			for _, aead := range []AEAD{AES_128_GCM, AES_256_GCM, CHACHA20_POLY1305} {
+				for _, mode := range []string{"Base", "PSK", "Auth", "AuthPSK"} {
+					suite := Suite{kem, kdf, aead}
+					var sendOpts, recvOpts Options
+					if mode == "PSK" || mode == "AuthPSK" {
+						sendOpts.PSK = bytes.Repeat([]byte{0x42}, 32)
+						sendOpts.PSKID = []byte("psk id")
+						recvOpts = sendOpts
+					}
+					if mode == "Auth" || mode == "AuthPSK" {
+						sendOpts.SenderKey = skS
+						recvOpts.SenderPublicKey = skS.PublicKey()
+					}
+					t.Run(fmt.Sprintf("%#x/%#x/%#x/%s", kem, kdf, aead, mode), func(t *testing.T) {
+						testRoundTrip(t, suite, skR, &sendOpts, &recvOpts)
+					})
+				}
+			}
+		}
+	}
+}
+
+func testRoundTrip(t *testing.T, suite Suite, skR *ecdh.PrivateKey, sendOpts, recvOpts *Options) {
+	info := []byte("info")
+	enc, s, err := suite.NewSender(skR.PublicKey(), info, sendOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := suite.NewRecipient(enc, skR, info, recvOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		aad := []byte{byte(i)}
+		msg := []byte(fmt.Sprintf("message %d", i))
+		ct, err := s.Seal(aad, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pt, err := r.Open(aad, ct)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if !bytes.Equal(pt, msg) {
+			t.Errorf("message %d: Open = %q, want %q", i, pt, msg)
+		}
+	}
+	se, err := s.Export([]byte("context"), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := r.Export([]byte("context"), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(se) != 42 || !bytes.Equal(se, re) {
+		t.Errorf("exported secrets %x and %x differ", se, re)
+	}
+
+	// A recipient with a different info, or without the sender's PSK or
+	// key, derives a different context.
+	if r, err := suite.NewRecipient(enc, skR, []byte("other"), recvOpts); err == nil {
+		if re, _ := r.Export([]byte("context"), 42); bytes.Equal(re, se) {
+			t.Error("recipient with a different info exported the same secret")
+		}
+	}
+	if r, err := suite.NewRecipient(enc, skR, info, nil); err == nil && (recvOpts.PSK != nil || recvOpts.SenderPublicKey != nil) {
+		if re, _ := r.Export([]byte("context"), 42); bytes.Equal(re, se) {
+			t.Error("recipient in Base mode exported the same secret")
+		}
+	}
+}
+
+func TestSingleShot(t *testing.T) {
+	suite := Suite{DHKEM_X25519_HKDF_SHA256, HKDF_SHA256, CHACHA20_POLY1305}
+	skR, err := suite.KEM.DeriveKeyPair(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, ct, err := suite.Seal(skR.PublicKey(), []byte("info"), []byte("aad"), []byte("hello"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := suite.Open(enc, skR, []byte("info"), []byte("aad"), ct, nil)
+	if err != nil || string(pt) != "hello" {
+		t.Fatalf("Open = %q, %v; want %q", pt, err, "hello")
+	}
+	ct[0] ^= 1
+	if _, err := suite.Open(enc, skR, []byte("info"), []byte("aad"), ct, nil); err == nil {
+		t.Error("Open of a modified ciphertext succeeded")
+	}
+}
+
+func TestRecipientOpenFailure(t *testing.T) {
+	suite := Suite{DHKEM_P256_HKDF_SHA256, HKDF_SHA256, AES_128_GCM}
+	skR, err := suite.KEM.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, s, err := suite.NewSender(skR.PublicKey(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := suite.NewRecipient(enc, skR, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := s.Seal(nil, []byte("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A failed Open leaves the context expecting the same message.
+	if _, err := r.Open([]byte("wrong aad"), ct); err == nil {
+		t.Fatal("Open with the wrong additional data succeeded")
+	}
+	if pt, err := r.Open(nil, ct); err != nil || string(pt) != "first" {
+		t.Errorf("Open after failure = %q, %v; want %q", pt, err, "first")
+	}
+}
+
+func TestExportOnly(t *testing.T) {
+	suite := Suite{DHKEM_X25519_HKDF_SHA256, HKDF_SHA512, EXPORT_ONLY}
+	skR, err := suite.KEM.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, s, err := suite.NewSender(skR.PublicKey(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Seal(nil, []byte("message")); err == nil {
+		t.Error("Seal with EXPORT_ONLY succeeded")
+	}
+	r, err := suite.NewRecipient(enc, skR, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se, err := s.Export(nil, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := r.Export(nil, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(se, re) {
+		t.Errorf("exported secrets %x and %x differ", se, re)
+	}
+	if _, err := s.Export(nil, 255*64+1); err == nil {
+		t.Error("Export of more than 255*Nh bytes succeeded")
+	}
+}
+
+func TestInvalidSetup(t *testing.T) {
+	suite := Suite{DHKEM_P256_HKDF_SHA256, HKDF_SHA256, AES_128_GCM}
+	skR, err := suite.KEM.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	x25519Key, err := DHKEM_X25519_HKDF_SHA256.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name  string
+		suite Suite
+		pub   *ecdh.PublicKey
+		opts  *Options
+	}{
+		{"unsupported KEM", Suite{0x0021, HKDF_SHA256, AES_128_GCM}, skR.PublicKey(), nil},
+		{"unsupported KDF", Suite{DHKEM_P256_HKDF_SHA256, 0x0004, AES_128_GCM}, skR.PublicKey(), nil},
+		{"unsupported AEAD", Suite{DHKEM_P256_HKDF_SHA256, HKDF_SHA256, 0x0004}, skR.PublicKey(), nil},
+		{"recipient key of other KEM", suite, x25519Key.PublicKey(), nil},
+		{"sender key of other KEM", suite, skR.PublicKey(), &Options{SenderKey: x25519Key}},
+		{"PSK without ID", suite, skR.PublicKey(), &Options{PSK: make([]byte, 32)}},
+		{"PSK ID without PSK", suite, skR.PublicKey(), &Options{PSKID: []byte("id")}},
+	} {
+		if _, _, err := test.suite.NewSender(test.pub, nil, test.opts); err == nil {
+			t.Errorf("%s: NewSender succeeded", test.name)
+		}
+	}
+	if _, err := suite.NewRecipient([]byte("invalid"), skR, nil, nil); err == nil {
+		t.Error("NewRecipient with an invalid encapsulated key succeeded")
+	}
+}
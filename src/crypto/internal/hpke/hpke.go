@@ -10,6 +10,8 @@ import (
 	"crypto/cipher"
 	"crypto/ecdh"
 	"crypto/rand"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
 	"encoding/binary"
 	"errors"
 	"math/bits"
@@ -57,15 +59,22 @@ type dhKEM struct {
 
 	suiteID []byte
 	nSecret uint16
+	nSk     uint16
+	bitmask byte
 }
 
 var SupportedKEMs = map[uint16]struct {
 	curve   ecdh.Curve
 	hash    crypto.Hash
 	nSecret uint16
+	nSk     uint16
+	bitmask byte // 0 if any Nsk-byte string is a valid private key
 }{
 	// RFC 9180 Section 7.1
-	0x0020: {ecdh.X25519(), crypto.SHA256, 32},
+	0x0010: {ecdh.P256(), crypto.SHA256, 32, 32, 0xff},
+	0x0011: {ecdh.P384(), crypto.SHA384, 48, 48, 0xff},
+	0x0012: {ecdh.P521(), crypto.SHA512, 64, 66, 0x01},
+	0x0020: {ecdh.X25519(), crypto.SHA256, 32, 32, 0},
 }
 
 func newDHKem(kemID uint16) (*dhKEM, error) {
@@ -78,6 +87,8 @@ func newDHKem(kemID uint16) (*dhKEM, error) {
 		kdf:     hkdfKDF{suite.hash},
 		suiteID: binary.BigEndian.AppendUint16([]byte("KEM"), kemID),
 		nSecret: suite.nSecret,
+		nSk:     suite.nSk,
+		bitmask: suite.bitmask,
 	}, nil
 }
 
@@ -86,13 +97,23 @@ func (dh *dhKEM) ExtractAndExpand(dhKey, kemContext []byte) []byte {
 	return dh.kdf.LabeledExpand(dh.suiteID[:], eaePRK, "shared_secret", kemContext, dh.nSecret)
 }
 
-func (dh *dhKEM) Encap(pubRecipient *ecdh.PublicKey) (sharedSecret []byte, encapPub []byte, err error) {
-	var privEph *ecdh.PrivateKey
+func (dh *dhKEM) generateEphemeral() (*ecdh.PrivateKey, error) {
 	if testingOnlyGenerateKey != nil {
-		privEph, err = testingOnlyGenerateKey()
-	} else {
-		privEph, err = dh.dh.GenerateKey(rand.Reader)
+		return testingOnlyGenerateKey()
 	}
+	return dh.dh.GenerateKey(rand.Reader)
+}
+
+// Encap implements Encap, or AuthEncap if privSender is not nil,
+// according to RFC 9180, Sections 4.1 and 4.1.1.
+func (dh *dhKEM) Encap(pubRecipient *ecdh.PublicKey, privSender *ecdh.PrivateKey) (sharedSecret []byte, encapPub []byte, err error) {
+	if pubRecipient.Curve() != dh.dh {
+		return nil, nil, errors.New("hpke: recipient public key does not match KEM")
+	}
+	if privSender != nil && privSender.Curve() != dh.dh {
+		return nil, nil, errors.New("hpke: sender private key does not match KEM")
+	}
+	privEph, err := dh.generateEphemeral()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -105,12 +126,79 @@ func (dh *dhKEM) Encap(pubRecipient *ecdh.PublicKey) (sharedSecret []byte, encap
 	encPubRecip := pubRecipient.Bytes()
 	kemContext := append(encPubEph, encPubRecip...)
 
+	if privSender != nil {
+		dhStatic, err := privSender.ECDH(pubRecipient)
+		if err != nil {
+			return nil, nil, err
+		}
+		dhVal = append(dhVal, dhStatic...)
+		kemContext = append(kemContext, privSender.PublicKey().Bytes()...)
+	}
+
 	return dh.ExtractAndExpand(dhVal, kemContext), encPubEph, nil
 }
 
-type Sender struct {
-	aead cipher.AEAD
-	kem  *dhKEM
+// Decap implements Decap, or AuthDecap if pubSender is not nil,
+// according to RFC 9180, Sections 4.1 and 4.1.1.
+func (dh *dhKEM) Decap(encPubEph []byte, privRecipient *ecdh.PrivateKey, pubSender *ecdh.PublicKey) ([]byte, error) {
+	if privRecipient.Curve() != dh.dh {
+		return nil, errors.New("hpke: recipient private key does not match KEM")
+	}
+	if pubSender != nil && pubSender.Curve() != dh.dh {
+		return nil, errors.New("hpke: sender public key does not match KEM")
+	}
+	pubEph, err := dh.dh.NewPublicKey(encPubEph)
+	if err != nil {
+		return nil, err
+	}
+	dhVal, err := privRecipient.ECDH(pubEph)
+	if err != nil {
+		return nil, err
+	}
+	kemContext := append(encPubEph[:len(encPubEph):len(encPubEph)], privRecipient.PublicKey().Bytes()...)
+
+	if pubSender != nil {
+		dhStatic, err := privRecipient.ECDH(pubSender)
+		if err != nil {
+			return nil, err
+		}
+		dhVal = append(dhVal, dhStatic...)
+		kemContext = append(kemContext, pubSender.Bytes()...)
+	}
+
+	return dh.ExtractAndExpand(dhVal, kemContext), nil
+}
+
+// DeriveKeyPair implements DeriveKeyPair according to RFC 9180, Section 7.1.3.
+func (dh *dhKEM) DeriveKeyPair(ikm []byte) (*ecdh.PrivateKey, error) {
+	dkpPRK := dh.kdf.LabeledExtract(dh.suiteID, nil, "dkp_prk", ikm)
+	if dh.bitmask == 0 {
+		return dh.dh.NewPrivateKey(dh.kdf.LabeledExpand(dh.suiteID, dkpPRK, "sk", nil, dh.nSk))
+	}
+	for counter := 0; counter < 256; counter++ {
+		sk := dh.kdf.LabeledExpand(dh.suiteID, dkpPRK, "candidate", []byte{byte(counter)}, dh.nSk)
+		sk[0] &= dh.bitmask
+		// NewPrivateKey rejects zero and values not less than the order.
+		if key, err := dh.dh.NewPrivateKey(sk); err == nil {
+			return key, nil
+		}
+	}
+	return nil, errors.New("hpke: DeriveKeyPair failed")
+}
+
+// The HPKE modes, according to RFC 9180, Section 5.
+const (
+	modeBase    = 0x00
+	modePSK     = 0x01
+	modeAuth    = 0x02
+	modeAuthPSK = 0x03
+)
+
+// context is the encryption context of RFC 9180, Section 5.1, shared by
+// the Sender and the Recipient.
+type context struct {
+	aead cipher.AEAD // nil for the export-only AEAD
+	kdf  *hkdfKDF
 
 	sharedSecret []byte
 
@@ -123,6 +211,15 @@ type Sender struct {
 	seqNum uint128
 }
 
+type Sender struct {
+	*context
+	kem *dhKEM
+}
+
+type Recipient struct {
+	*context
+}
+
 var aesGCMNew = func(key []byte) (cipher.AEAD, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -145,82 +242,202 @@ var SupportedAEADs = map[uint16]struct {
 var SupportedKDFs = map[uint16]func() *hkdfKDF{
 	// RFC 9180, Section 7.2
 	0x0001: func() *hkdfKDF { return &hkdfKDF{crypto.SHA256} },
+	0x0002: func() *hkdfKDF { return &hkdfKDF{crypto.SHA384} },
+	0x0003: func() *hkdfKDF { return &hkdfKDF{crypto.SHA512} },
+}
+
+// ExportOnlyAEAD is the AEAD identifier of contexts that can only be used
+// to export secrets, according to RFC 9180, Section 7.3.
+const ExportOnlyAEAD = 0xffff
+
+// Options holds the inputs of the PSK, Auth and AuthPSK modes.
+type Options struct {
+	// PSK and PSKID select the PSK or AuthPSK mode. Either both or neither
+	// must be set.
+	PSK, PSKID []byte
+
+	// SenderPrivateKey, used by the sender, and SenderPublicKey, used by
+	// the recipient, select the Auth or AuthPSK mode.
+	SenderPrivateKey *ecdh.PrivateKey
+	SenderPublicKey  *ecdh.PublicKey
+}
+
+func (o *Options) mode(auth bool) (byte, error) {
+	if (len(o.PSK) == 0) != (len(o.PSKID) == 0) {
+		return 0, errors.New("hpke: PSK and PSK ID must be set together")
+	}
+	switch psk := len(o.PSK) != 0; {
+	case psk && auth:
+		return modeAuthPSK, nil
+	case psk:
+		return modePSK, nil
+	case auth:
+		return modeAuth, nil
+	default:
+		return modeBase, nil
+	}
 }
 
 func SetupSender(kemID, kdfID, aeadID uint16, pub crypto.PublicKey, info []byte) ([]byte, *Sender, error) {
-	suiteID := SuiteID(kemID, kdfID, aeadID)
+	pubRecipient, ok := pub.(*ecdh.PublicKey)
+	if !ok {
+		return nil, nil, errors.New("incorrect public key type")
+	}
+	return NewSender(kemID, kdfID, aeadID, pubRecipient, info, nil)
+}
 
+// NewSender sets up a sender context according to RFC 9180, Section 5.1, in
+// the mode selected by opts, which may be nil for the Base mode. It returns
+// the encapsulated key to be sent to the recipient.
+func NewSender(kemID, kdfID, aeadID uint16, pub *ecdh.PublicKey, info []byte, opts *Options) ([]byte, *Sender, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	mode, err := opts.mode(opts.SenderPrivateKey != nil)
+	if err != nil {
+		return nil, nil, err
+	}
 	kem, err := newDHKem(kemID)
 	if err != nil {
 		return nil, nil, err
 	}
-	pubRecipient, ok := pub.(*ecdh.PublicKey)
-	if !ok {
-		return nil, nil, errors.New("incorrect public key type")
+	kdf, err := checkSuite(kdfID, aeadID)
+	if err != nil {
+		return nil, nil, err
 	}
-	sharedSecret, encapsulatedKey, err := kem.Encap(pubRecipient)
+
+	sharedSecret, encapsulatedKey, err := kem.Encap(pub, opts.SenderPrivateKey)
 	if err != nil {
 		return nil, nil, err
 	}
+	c, err := newContext(mode, SuiteID(kemID, kdfID, aeadID), kdf, aeadID, sharedSecret, info, opts.PSK, opts.PSKID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encapsulatedKey, &Sender{context: c, kem: kem}, nil
+}
 
-	kdfInit, ok := SupportedKDFs[kdfID]
-	if !ok {
-		return nil, nil, errors.New("unsupported KDF id")
+// NewRecipient sets up a recipient context according to RFC 9180, Section
+// 5.1, from the encapsulated key produced by the sender, in the mode
+// selected by opts, which may be nil for the Base mode.
+func NewRecipient(kemID, kdfID, aeadID uint16, encapsulatedKey []byte, priv *ecdh.PrivateKey, info []byte, opts *Options) (*Recipient, error) {
+	if opts == nil {
+		opts = &Options{}
 	}
-	kdf := kdfInit()
+	mode, err := opts.mode(opts.SenderPublicKey != nil)
+	if err != nil {
+		return nil, err
+	}
+	kem, err := newDHKem(kemID)
+	if err != nil {
+		return nil, err
+	}
+	kdf, err := checkSuite(kdfID, aeadID)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedSecret, err := kem.Decap(encapsulatedKey, priv, opts.SenderPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newContext(mode, SuiteID(kemID, kdfID, aeadID), kdf, aeadID, sharedSecret, info, opts.PSK, opts.PSKID)
+	if err != nil {
+		return nil, err
+	}
+	return &Recipient{context: c}, nil
+}
 
-	aeadInfo, ok := SupportedAEADs[aeadID]
+func checkSuite(kdfID, aeadID uint16) (*hkdfKDF, error) {
+	kdfInit, ok := SupportedKDFs[kdfID]
 	if !ok {
-		return nil, nil, errors.New("unsupported AEAD id")
+		return nil, errors.New("unsupported KDF id")
 	}
+	if _, ok := SupportedAEADs[aeadID]; !ok && aeadID != ExportOnlyAEAD {
+		return nil, errors.New("unsupported AEAD id")
+	}
+	return kdfInit(), nil
+}
 
-	pskIDHash := kdf.LabeledExtract(suiteID, nil, "psk_id_hash", nil)
+// newContext implements KeySchedule according to RFC 9180, Section 5.1.
+func newContext(mode byte, suiteID []byte, kdf *hkdfKDF, aeadID uint16, sharedSecret, info, psk, pskID []byte) (*context, error) {
+	pskIDHash := kdf.LabeledExtract(suiteID, nil, "psk_id_hash", pskID)
 	infoHash := kdf.LabeledExtract(suiteID, nil, "info_hash", info)
-	ksContext := append([]byte{0}, pskIDHash...)
+	ksContext := append([]byte{mode}, pskIDHash...)
 	ksContext = append(ksContext, infoHash...)
 
-	secret := kdf.LabeledExtract(suiteID, sharedSecret, "secret", nil)
+	secret := kdf.LabeledExtract(suiteID, sharedSecret, "secret", psk)
 
-	key := kdf.LabeledExpand(suiteID, secret, "key", ksContext, uint16(aeadInfo.keySize) /* Nk - key size for AEAD */)
-	baseNonce := kdf.LabeledExpand(suiteID, secret, "base_nonce", ksContext, uint16(aeadInfo.nonceSize) /* Nn - nonce size for AEAD */)
-	exporterSecret := kdf.LabeledExpand(suiteID, secret, "exp", ksContext, uint16(kdf.hash.Size()) /* Nh - hash output size of the kdf*/)
-
-	aead, err := aeadInfo.aead(key)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return encapsulatedKey, &Sender{
-		kem:            kem,
-		aead:           aead,
+	c := &context{
+		kdf:            kdf,
 		sharedSecret:   sharedSecret,
 		suiteID:        suiteID,
-		key:            key,
-		baseNonce:      baseNonce,
-		exporterSecret: exporterSecret,
-	}, nil
+		exporterSecret: kdf.LabeledExpand(suiteID, secret, "exp", ksContext, uint16(kdf.hash.Size()) /* Nh - hash output size of the kdf*/),
+	}
+	if aeadID == ExportOnlyAEAD {
+		return c, nil
+	}
+	aeadInfo := SupportedAEADs[aeadID]
+	c.key = kdf.LabeledExpand(suiteID, secret, "key", ksContext, uint16(aeadInfo.keySize) /* Nk - key size for AEAD */)
+	c.baseNonce = kdf.LabeledExpand(suiteID, secret, "base_nonce", ksContext, uint16(aeadInfo.nonceSize) /* Nn - nonce size for AEAD */)
+	aead, err := aeadInfo.aead(c.key)
+	if err != nil {
+		return nil, err
+	}
+	c.aead = aead
+	return c, nil
 }
 
-func (s *Sender) nextNonce() []byte {
-	nonce := s.seqNum.bytes()[16-s.aead.NonceSize():]
-	for i := range s.baseNonce {
-		nonce[i] ^= s.baseNonce[i]
+func (c *context) nextNonce() ([]byte, error) {
+	if c.aead == nil {
+		return nil, errors.New("hpke: encryption with the export-only AEAD")
 	}
 	// Message limit is, according to the RFC, 2^95+1, which
 	// is somewhat confusing, but we do as we're told.
-	if s.seqNum.bitLen() >= (s.aead.NonceSize()*8)-1 {
-		panic("message limit reached")
+	if c.seqNum.bitLen() >= (c.aead.NonceSize()*8)-1 {
+		return nil, errors.New("hpke: message limit reached")
 	}
-	s.seqNum = s.seqNum.addOne()
-	return nonce
+	nonce := c.seqNum.bytes()[16-c.aead.NonceSize():]
+	for i := range c.baseNonce {
+		nonce[i] ^= c.baseNonce[i]
+	}
+	return nonce, nil
 }
 
 func (s *Sender) Seal(aad, plaintext []byte) ([]byte, error) {
-
-	ciphertext := s.aead.Seal(nil, s.nextNonce(), plaintext, aad)
+	nonce, err := s.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := s.aead.Seal(nil, nonce, plaintext, aad)
+	s.seqNum = s.seqNum.addOne()
 	return ciphertext, nil
 }
 
+// Open decrypts a ciphertext sealed by the sender. The sequence number only
+// advances if the decryption is successful.
+func (r *Recipient) Open(aad, ciphertext []byte) ([]byte, error) {
+	nonce, err := r.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := r.aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, err
+	}
+	r.seqNum = r.seqNum.addOne()
+	return plaintext, nil
+}
+
+// Export derives a secret of the given length from the context, according to
+// RFC 9180, Section 5.3.
+func (c *context) Export(exporterContext []byte, length int) ([]byte, error) {
+	if length < 0 || length > 255*c.kdf.hash.Size() {
+		return nil, errors.New("hpke: invalid export length")
+	}
+	return c.kdf.LabeledExpand(c.suiteID, c.exporterSecret, "sec", exporterContext, uint16(length)), nil
+}
+
 func SuiteID(kemID, kdfID, aeadID uint16) []byte {
 	suiteID := make([]byte, 0, 4+2+2+2)
 	suiteID = append(suiteID, []byte("HPKE")...)
@@ -238,6 +455,25 @@ func ParseHPKEPublicKey(kemID uint16, bytes []byte) (*ecdh.PublicKey, error) {
 	return kemInfo.curve.NewPublicKey(bytes)
 }
 
+// Curve returns the curve of the keys of a KEM.
+func Curve(kemID uint16) (ecdh.Curve, error) {
+	kemInfo, ok := SupportedKEMs[kemID]
+	if !ok {
+		return nil, errors.New("unsupported KEM id")
+	}
+	return kemInfo.curve, nil
+}
+
+// DeriveKeyPair deterministically derives a private key for a KEM from the
+// input keying material ikm, according to RFC 9180, Section 7.1.3.
+func DeriveKeyPair(kemID uint16, ikm []byte) (*ecdh.PrivateKey, error) {
+	kem, err := newDHKem(kemID)
+	if err != nil {
+		return nil, err
+	}
+	return kem.DeriveKeyPair(ikm)
+}
+
 type uint128 struct {
 	hi, lo uint64
 }
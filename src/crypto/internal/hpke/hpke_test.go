@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -167,3 +168,144 @@ func TestRFC9180Vectors(t *testing.T) {
 		})
 	}
 }
+
+func TestRFC9180AllModes(t *testing.T) {
+	vectorsJSON, err := os.ReadFile("testdata/rfc9180-all-modes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors []struct {
+		Mode           int    `json:"mode"`
+		KEMID          uint16 `json:"kem_id"`
+		KDFID          uint16 `json:"kdf_id"`
+		AEADID         uint16 `json:"aead_id"`
+		Info           string `json:"info"`
+		IKME           string `json:"ikmE"`
+		SKEm           string `json:"skEm"`
+		IKMR           string `json:"ikmR"`
+		SKRm           string `json:"skRm"`
+		IKMS           string `json:"ikmS"`
+		SKSm           string `json:"skSm"`
+		PSK            string `json:"psk"`
+		PSKID          string `json:"psk_id"`
+		Enc            string `json:"enc"`
+		SharedSecret   string `json:"shared_secret"`
+		Key            string `json:"key"`
+		BaseNonce      string `json:"base_nonce"`
+		ExporterSecret string `json:"exporter_secret"`
+		Encryptions    []struct {
+			AAD string `json:"aad"`
+			CT  string `json:"ct"`
+			PT  string `json:"pt"`
+		} `json:"encryptions"`
+		Exports []struct {
+			Context string `json:"exporter_context"`
+			L       int    `json:"L"`
+			Value   string `json:"exported_value"`
+		} `json:"exports"`
+	}
+	if err := json.Unmarshal(vectorsJSON, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vector := range vectors {
+		name := fmt.Sprintf("mode %d KEM %#04x KDF %#04x AEAD %#04x", vector.Mode, vector.KEMID, vector.KDFID, vector.AEADID)
+		t.Run(name, func(t *testing.T) {
+			deriveKey := func(ikm, sk string) *ecdh.PrivateKey {
+				t.Helper()
+				key, err := DeriveKeyPair(vector.KEMID, mustDecodeHex(t, ikm))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := key.Bytes(); !bytes.Equal(got, mustDecodeHex(t, sk)) {
+					t.Errorf("DeriveKeyPair(%s) = %x, want %s", ikm, got, sk)
+				}
+				return key
+			}
+			ephemeral := deriveKey(vector.IKME, vector.SKEm)
+			recipient := deriveKey(vector.IKMR, vector.SKRm)
+			var senderOpts, recipientOpts Options
+			senderOpts.PSK = mustDecodeHex(t, vector.PSK)
+			senderOpts.PSKID = mustDecodeHex(t, vector.PSKID)
+			recipientOpts = senderOpts
+			if vector.IKMS != "" {
+				senderOpts.SenderPrivateKey = deriveKey(vector.IKMS, vector.SKSm)
+				recipientOpts.SenderPublicKey = senderOpts.SenderPrivateKey.PublicKey()
+			}
+
+			testingOnlyGenerateKey = func() (*ecdh.PrivateKey, error) { return ephemeral, nil }
+			t.Cleanup(func() { testingOnlyGenerateKey = nil })
+
+			info := mustDecodeHex(t, vector.Info)
+			enc, sender, err := NewSender(vector.KEMID, vector.KDFID, vector.AEADID, recipient.PublicKey(), info, &senderOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := mustDecodeHex(t, vector.Enc); !bytes.Equal(enc, want) {
+				t.Errorf("unexpected encapsulated key, got: %x, want %x", enc, want)
+			}
+			for _, x := range []struct {
+				name      string
+				got, want []byte
+			}{
+				{"shared secret", sender.sharedSecret, mustDecodeHex(t, vector.SharedSecret)},
+				{"key", sender.key, mustDecodeHex(t, vector.Key)},
+				{"base nonce", sender.baseNonce, mustDecodeHex(t, vector.BaseNonce)},
+				{"exporter secret", sender.exporterSecret, mustDecodeHex(t, vector.ExporterSecret)},
+			} {
+				if !bytes.Equal(x.got, x.want) {
+					t.Errorf("unexpected %s, got: %x, want %x", x.name, x.got, x.want)
+				}
+			}
+
+			receiver, err := NewRecipient(vector.KEMID, vector.KDFID, vector.AEADID, enc, recipient, info, &recipientOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(receiver.exporterSecret, sender.exporterSecret) {
+				t.Errorf("recipient exporter secret %x, want %x", receiver.exporterSecret, sender.exporterSecret)
+			}
+
+			if vector.AEADID == ExportOnlyAEAD {
+				if _, err := sender.Seal(nil, nil); err == nil {
+					t.Error("Seal with the export-only AEAD succeeded")
+				}
+			}
+			for i, e := range vector.Encryptions {
+				aad, pt := mustDecodeHex(t, e.AAD), mustDecodeHex(t, e.PT)
+				ct, err := sender.Seal(aad, pt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := mustDecodeHex(t, e.CT); !bytes.Equal(ct, want) {
+					t.Errorf("encryption %d: unexpected ciphertext: got %x want %x", i, ct, want)
+				}
+				// A failed Open doesn't advance the sequence number.
+				if _, err := receiver.Open([]byte("wrong"), ct); err == nil {
+					t.Errorf("encryption %d: Open with the wrong AAD succeeded", i)
+				}
+				got, err := receiver.Open(aad, ct)
+				if err != nil {
+					t.Fatalf("encryption %d: Open: %v", i, err)
+				}
+				if !bytes.Equal(got, pt) {
+					t.Errorf("encryption %d: unexpected plaintext: got %x want %x", i, got, pt)
+				}
+			}
+
+			for i, e := range vector.Exports {
+				want := mustDecodeHex(t, e.Value)
+				for _, c := range []*context{sender.context, receiver.context} {
+					got, err := c.Export(mustDecodeHex(t, e.Context), e.L)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(got, want) {
+						t.Errorf("export %d: got %x, want %x", i, got, want)
+					}
+				}
+			}
+		})
+	}
+}
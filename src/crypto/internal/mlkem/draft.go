@@ -0,0 +1,164 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mlkem
+
+// This file implements ML-KEM-768 as specified by the draft of FIPS 203,
+// for the X25519Kyber768Draft00 key exchange of crypto/tls. New uses
+// should use DecapsulationKey and EncapsulationKey instead.
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// DraftDecapsulationKeySize is the size of the extended encoding of
+// a DraftDecapsulationKey.
+const DraftDecapsulationKeySize = decryptionKeySize + EncapsulationKeySize768 + 32 + 32
+
+// draft768 is the ML-KEM-768 parameter set of FIPS 203 (DRAFT).
+var draft768 = &Params{k: k768, du: du768, dv: dv768, draft: true}
+
+// A DraftDecapsulationKey is the secret key used to decapsulate a shared key
+// from a ciphertext, according to FIPS 203 (DRAFT). It includes various
+// precomputed values.
+type DraftDecapsulationKey struct {
+	dk  [DraftDecapsulationKeySize]byte
+	key decapsulationKey
+}
+
+// Bytes returns the extended encoding of the decapsulation key, according to
+// FIPS 203 (DRAFT).
+func (dk *DraftDecapsulationKey) Bytes() []byte {
+	var b [DraftDecapsulationKeySize]byte
+	copy(b[:], dk.dk[:])
+	return b[:]
+}
+
+// EncapsulationKey returns the public encapsulation key necessary to produce
+// ciphertexts.
+func (dk *DraftDecapsulationKey) EncapsulationKey() []byte {
+	var b [EncapsulationKeySize768]byte
+	copy(b[:], dk.dk[decryptionKeySize:])
+	return b[:]
+}
+
+// GenerateDraftKey generates a new decapsulation key, drawing random bytes
+// from crypto/rand. The decapsulation key must be kept secret.
+func GenerateDraftKey() (*DraftDecapsulationKey, error) {
+	var d [32]byte
+	if _, err := rand.Read(d[:]); err != nil {
+		return nil, errors.New("mlkem: crypto/rand Read failed: " + err.Error())
+	}
+	var z [32]byte
+	if _, err := rand.Read(z[:]); err != nil {
+		return nil, errors.New("mlkem: crypto/rand Read failed: " + err.Error())
+	}
+	return draftKeyGen(&d, &z), nil
+}
+
+// NewDraftKeyFromSeed deterministically generates a decapsulation key from
+// a 64-byte seed in the "d || z" form. The seed must be uniformly random.
+func NewDraftKeyFromSeed(seed []byte) (*DraftDecapsulationKey, error) {
+	if len(seed) != SeedSize {
+		return nil, errors.New("mlkem: invalid seed length")
+	}
+	return draftKeyGen((*[32]byte)(seed[:32]), (*[32]byte)(seed[32:])), nil
+}
+
+// draftKeyGen generates a decapsulation key according to FIPS 203 (DRAFT),
+// Algorithm 15, and encodes it in the extended encoding.
+func draftKeyGen(d, z *[32]byte) *DraftDecapsulationKey {
+	dk := &DraftDecapsulationKey{}
+	kemKeyGen(&dk.key, draft768, d, z)
+
+	// dkPKE ← ByteEncode₁₂(s)
+	// dk ← dkPKE || ek || H(ek) || z
+	b := dk.dk[:0]
+	for i := range dk.key.s {
+		b = polyByteEncode(b, dk.key.s[i])
+	}
+	b = dk.key.bytes(b)
+	b = append(b, dk.key.h[:]...)
+	b = append(b, z[:]...)
+	if len(b) != len(dk.dk) {
+		panic("mlkem: internal error: invalid decapsulation key size")
+	}
+	return dk
+}
+
+// NewDraftKeyFromExtendedEncoding parses a decapsulation key from its FIPS 203
+// (DRAFT) extended encoding.
+func NewDraftKeyFromExtendedEncoding(decapsulationKey []byte) (*DraftDecapsulationKey, error) {
+	if len(decapsulationKey) != DraftDecapsulationKeySize {
+		return nil, errors.New("mlkem: invalid decapsulation key length")
+	}
+	dk := &DraftDecapsulationKey{dk: [DraftDecapsulationKeySize]byte(decapsulationKey)}
+
+	// It implements the computation of s from K-PKE.Decrypt according to
+	// FIPS 203 (DRAFT), Algorithm 14.
+	dkPKE := decapsulationKey[:decryptionKeySize]
+	dk.key.s = make([]nttElement, k768)
+	for i := range dk.key.s {
+		var err error
+		dk.key.s[i], err = polyByteDecode[nttElement](dkPKE[:encodingSize12])
+		if err != nil {
+			return nil, err
+		}
+		dkPKE = dkPKE[encodingSize12:]
+	}
+
+	ekPKE := decapsulationKey[decryptionKeySize : decryptionKeySize+EncapsulationKeySize768]
+	if err := parseEK(&dk.key.encryptionKey, draft768, ekPKE); err != nil {
+		return nil, err
+	}
+
+	// Note that we don't check that H(ek) matches ekPKE, as that's not
+	// specified in FIPS 203 (DRAFT). This is one reason to prefer the seed
+	// private key format.
+	rest := decapsulationKey[decryptionKeySize+EncapsulationKeySize768:]
+	copy(dk.key.h[:], rest[:32])
+	copy(dk.key.z[:], rest[32:])
+
+	return dk, nil
+}
+
+// DraftEncapsulate generates a shared key and an associated ciphertext from
+// an encapsulation key, drawing random bytes from crypto/rand.
+// If the encapsulation key is not valid, DraftEncapsulate returns an error.
+//
+// The shared key must be kept secret.
+func DraftEncapsulate(encapsulationKey []byte) (ciphertext, sharedKey []byte, err error) {
+	if len(encapsulationKey) != EncapsulationKeySize768 {
+		return nil, nil, errors.New("mlkem: invalid encapsulation key length")
+	}
+	var m [messageSize]byte
+	if _, err := rand.Read(m[:]); err != nil {
+		return nil, nil, errors.New("mlkem: crypto/rand Read failed: " + err.Error())
+	}
+	return draftEncaps(encapsulationKey, &m)
+}
+
+// draftEncaps generates a shared key and an associated ciphertext for the
+// encoded encapsulation key ek.
+func draftEncaps(ek []byte, m *[messageSize]byte) (c, K []byte, err error) {
+	var ex encryptionKey
+	if err := parseEK(&ex, draft768, ek); err != nil {
+		return nil, nil, err
+	}
+	c, K = kemEncaps(&ex, m)
+	return c, K, nil
+}
+
+// DraftDecapsulate generates a shared key from a ciphertext and a
+// decapsulation key. If the ciphertext is not valid, DraftDecapsulate
+// returns an error.
+//
+// The shared key must be kept secret.
+func DraftDecapsulate(dk *DraftDecapsulationKey, ciphertext []byte) (sharedKey []byte, err error) {
+	if len(ciphertext) != CiphertextSize768 {
+		return nil, errors.New("mlkem: invalid ciphertext length")
+	}
+	return kemDecaps(&dk.key, ciphertext)
+}
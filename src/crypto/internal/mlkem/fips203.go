@@ -0,0 +1,67 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mlkem
+
+// A DecapsulationKey is the secret key used to decapsulate a shared key
+// from a ciphertext, generated according to FIPS 203 for any parameter set.
+// It includes various precomputed values.
+type DecapsulationKey struct {
+	key decapsulationKey
+}
+
+// NewDecapsulationKey deterministically generates a decapsulation key
+// for the parameter set p from a 64-byte seed in the "d || z" form.
+func NewDecapsulationKey(p *Params, seed []byte) (*DecapsulationKey, error) {
+	dk := &DecapsulationKey{}
+	if err := newKeyFromSeed(&dk.key, p, seed); err != nil {
+		return nil, err
+	}
+	return dk, nil
+}
+
+// Seed returns the decapsulation key as a 64-byte seed in the "d || z" form.
+func (dk *DecapsulationKey) Seed() []byte {
+	return dk.key.seed(make([]byte, 0, SeedSize))
+}
+
+// Decapsulate generates a shared key from a ciphertext. If the ciphertext is
+// not valid, Decapsulate returns an error.
+func (dk *DecapsulationKey) Decapsulate(ciphertext []byte) (sharedKey []byte, err error) {
+	return kemDecaps(&dk.key, ciphertext)
+}
+
+// EncapsulationKey returns the public encapsulation key necessary to produce
+// ciphertexts.
+func (dk *DecapsulationKey) EncapsulationKey() *EncapsulationKey {
+	return &EncapsulationKey{key: dk.key.encryptionKey}
+}
+
+// An EncapsulationKey is the public key used to produce ciphertexts, for any
+// parameter set.
+type EncapsulationKey struct {
+	key encryptionKey
+}
+
+// NewEncapsulationKey parses an encapsulation key for the parameter set p
+// from its encoded form. If the encapsulation key is not valid,
+// NewEncapsulationKey returns an error.
+func NewEncapsulationKey(p *Params, encapsulationKey []byte) (*EncapsulationKey, error) {
+	ek := &EncapsulationKey{}
+	if err := parseEK(&ek.key, p, encapsulationKey); err != nil {
+		return nil, err
+	}
+	return ek, nil
+}
+
+// Bytes returns the encapsulation key as a byte slice.
+func (ek *EncapsulationKey) Bytes() []byte {
+	return ek.key.bytes(make([]byte, 0, ek.key.p.encapsulationKeySize()))
+}
+
+// Encapsulate generates a shared key and an associated ciphertext, drawing
+// random bytes from crypto/rand.
+func (ek *EncapsulationKey) Encapsulate() (ciphertext, sharedKey []byte) {
+	return encapsulate(&ek.key)
+}
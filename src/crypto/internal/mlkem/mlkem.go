@@ -0,0 +1,355 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mlkem implements the quantum-resistant key encapsulation method
+// ML-KEM (formerly known as Kyber).
+//
+// The [DecapsulationKey] and [EncapsulationKey] API implements the
+// ML-KEM-768 and ML-KEM-1024 parameter sets as specified by [NIST FIPS 203].
+// It is used by crypto/mlkem.
+//
+// The separate [DraftDecapsulationKey] API implements the ML-KEM-768
+// parameter set as specified by [NIST FIPS 203 ipd], with the unintentional
+// transposition of the matrix A reverted to match the behavior of
+// [Kyber version 3.0]. The draft differs from the final standard only in
+// the domain separation of key generation. It is used by the
+// X25519Kyber768Draft00 key exchange of crypto/tls.
+//
+// [Kyber version 3.0]: https://pq-crystals.org/kyber/data/kyber-specification-round3-20210804.pdf
+// [NIST FIPS 203 ipd]: https://doi.org/10.6028/NIST.FIPS.203.ipd
+// [NIST FIPS 203]: https://doi.org/10.6028/NIST.FIPS.203
+package mlkem
+
+// This package targets security, correctness, simplicity, readability, and
+// reviewability as its primary goals. All critical operations are performed in
+// constant time.
+//
+// Variable and function names, as well as code layout, are selected to
+// facilitate reviewing the implementation against the NIST FIPS 203 document.
+//
+// Reviewers unfamiliar with polynomials or linear algebra might find the
+// background at https://words.filippo.io/kyber-math/ useful.
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// ML-KEM global constants.
+	n = 256
+	q = 3329
+
+	log2q = 12
+
+	// encodingSize12 is the byte size of a ringElement or nttElement encoded
+	// by ByteEncode₁₂ (FIPS 203, Algorithm 5).
+	encodingSize12 = n * log2q / 8
+	encodingSize1  = n * 1 / 8
+
+	messageSize = encodingSize1
+
+	SharedKeySize = 32
+	SeedSize      = 32 + 32
+)
+
+const (
+	// ML-KEM-768 parameters.
+	k768  = 3
+	du768 = 10
+	dv768 = 4
+
+	decryptionKeySize = k768 * encodingSize12
+
+	CiphertextSize768       = k768*n*du768/8 + n*dv768/8
+	EncapsulationKeySize768 = k768*encodingSize12 + 32
+)
+
+const (
+	// ML-KEM-1024 parameters.
+	k1024  = 4
+	du1024 = 11
+	dv1024 = 5
+
+	CiphertextSize1024       = k1024*n*du1024/8 + n*dv1024/8
+	EncapsulationKeySize1024 = k1024*encodingSize12 + 32
+)
+
+// Params is an ML-KEM parameter set, according to FIPS 203, Section 8.
+//
+// η₁ and η₂ are 2 for both ML-KEM-768 and ML-KEM-1024, which samplePolyCBD
+// assumes.
+type Params struct {
+	k      int
+	du, dv uint8
+
+	// draft selects the key generation of FIPS 203 (DRAFT), which does not
+	// use the module dimension as a domain separator.
+	draft bool
+}
+
+var (
+	// MLKEM768 is the ML-KEM-768 parameter set.
+	MLKEM768 = &Params{k: k768, du: du768, dv: dv768}
+
+	// MLKEM1024 is the ML-KEM-1024 parameter set.
+	MLKEM1024 = &Params{k: k1024, du: du1024, dv: dv1024}
+)
+
+func (p *Params) encapsulationKeySize() int {
+	return p.k*encodingSize12 + 32
+}
+
+func (p *Params) ciphertextSize() int {
+	return p.k*encodingSize(p.du) + encodingSize(p.dv)
+}
+
+// encryptionKey is the parsed and expanded form of an encapsulation key,
+// which is also a K-PKE encryption key.
+type encryptionKey struct {
+	p *Params
+	ρ [32]byte     // sampleNTT seed for A, stored for the encapsulation key encoding
+	h [32]byte     // H(ek), stored for ML-KEM.Encaps_internal
+	t []nttElement // ByteDecode₁₂(ek[:384k])
+	a []nttElement // a[i*k+j] = sampleNTT(ρ, j, i)
+}
+
+// decapsulationKey is the expanded form of a decapsulation key.
+type decapsulationKey struct {
+	d [32]byte // decapsulation key seed
+	z [32]byte // implicit rejection sampling seed
+
+	encryptionKey
+	s []nttElement // the K-PKE decryption key
+}
+
+// bytes appends the encoding of the encapsulation key to b, according to
+// FIPS 203, Algorithm 16.
+func (ex *encryptionKey) bytes(b []byte) []byte {
+	for i := range ex.t {
+		b = polyByteEncode(b, ex.t[i])
+	}
+	return append(b, ex.ρ[:]...)
+}
+
+// seed appends the seed of the decapsulation key to b.
+func (dx *decapsulationKey) seed(b []byte) []byte {
+	b = append(b, dx.d[:]...)
+	return append(b, dx.z[:]...)
+}
+
+// newKeyFromSeed deterministically generates a decapsulation key from a
+// 64-byte seed in the "d || z" form.
+func newKeyFromSeed(dk *decapsulationKey, p *Params, seed []byte) error {
+	if len(seed) != SeedSize {
+		return errors.New("mlkem: invalid seed length")
+	}
+	kemKeyGen(dk, p, (*[32]byte)(seed[:32]), (*[32]byte)(seed[32:]))
+	return nil
+}
+
+// kemKeyGen generates a decapsulation key.
+//
+// It implements ML-KEM.KeyGen_internal according to FIPS 203, Algorithm 16,
+// and K-PKE.KeyGen according to FIPS 203, Algorithm 13. The two are merged to
+// save copies and allocations.
+func kemKeyGen(dk *decapsulationKey, p *Params, d, z *[32]byte) {
+	dk.d, dk.z = *d, *z
+
+	g := sha3.New512()
+	g.Write(d[:])
+	if !p.draft {
+		g.Write([]byte{byte(p.k)}) // Module dimension as a domain separator.
+	}
+	G := g.Sum(nil)
+	ρ, σ := G[:32], G[32:]
+
+	ex := &dk.encryptionKey
+	ex.p = p
+	copy(ex.ρ[:], ρ)
+	ex.a = sampleMatrix(p, ρ)
+
+	var N byte
+	dk.s = make([]nttElement, p.k)
+	for i := range dk.s {
+		dk.s[i] = ntt(samplePolyCBD(σ, N))
+		N++
+	}
+	e := make([]nttElement, p.k)
+	for i := range e {
+		e[i] = ntt(samplePolyCBD(σ, N))
+		N++
+	}
+
+	ex.t = make([]nttElement, p.k)
+	for i := range ex.t { // t = A ◦ s + e
+		ex.t[i] = e[i]
+		for j := range dk.s {
+			ex.t[i] = polyAdd(ex.t[i], nttMul(ex.a[i*p.k+j], dk.s[j]))
+		}
+	}
+
+	ex.h = sha3.Sum256(ex.bytes(make([]byte, 0, p.encapsulationKeySize())))
+}
+
+// sampleMatrix expands the seed ρ into the matrix A.
+func sampleMatrix(p *Params, ρ []byte) []nttElement {
+	a := make([]nttElement, p.k*p.k)
+	for i := 0; i < p.k; i++ {
+		for j := 0; j < p.k; j++ {
+			// Note that this is consistent with Kyber round 3, rather than with
+			// the initial draft of FIPS 203, because NIST signaled that the
+			// change was involuntary and will be reverted.
+			a[i*p.k+j] = sampleNTT(ρ, byte(j), byte(i))
+		}
+	}
+	return a
+}
+
+// parseEK parses an encapsulation key from its encoded form, performing the
+// "Modulus check" of ML-KEM Encapsulation Input Validation.
+//
+// It implements the initial stages of K-PKE.Encrypt according to FIPS 203,
+// Algorithm 14.
+func parseEK(ex *encryptionKey, p *Params, ek []byte) error {
+	if len(ek) != p.encapsulationKeySize() {
+		return errors.New("mlkem: invalid encapsulation key length")
+	}
+	ex.p = p
+	ex.h = sha3.Sum256(ek)
+
+	ex.t = make([]nttElement, p.k)
+	for i := range ex.t {
+		var err error
+		ex.t[i], err = polyByteDecode[nttElement](ek[:encodingSize12])
+		if err != nil {
+			return err
+		}
+		ek = ek[encodingSize12:]
+	}
+	copy(ex.ρ[:], ek)
+	ex.a = sampleMatrix(p, ek)
+
+	return nil
+}
+
+// encapsulate generates a shared key and an associated ciphertext for ex,
+// drawing the message from crypto/rand.
+//
+// It implements ML-KEM.Encaps according to FIPS 203, Algorithm 20.
+func encapsulate(ex *encryptionKey) (c, K []byte) {
+	var m [messageSize]byte
+	if _, err := rand.Read(m[:]); err != nil {
+		// crypto/rand.Read only returns errors when explicitly configured to
+		// with a GODEBUG setting, otherwise it crashes the program.
+		panic("mlkem: crypto/rand Read failed: " + err.Error())
+	}
+	return kemEncaps(ex, &m)
+}
+
+// kemEncaps generates a shared key and an associated ciphertext.
+//
+// It implements ML-KEM.Encaps_internal according to FIPS 203, Algorithm 17.
+func kemEncaps(ex *encryptionKey, m *[messageSize]byte) (c, K []byte) {
+	g := sha3.New512()
+	g.Write(m[:])
+	g.Write(ex.h[:])
+	G := g.Sum(nil)
+	K, r := G[:SharedKeySize], G[SharedKeySize:]
+	c = pkeEncrypt(ex, m, r)
+	return c, K
+}
+
+// pkeEncrypt encrypt a plaintext message.
+//
+// It implements K-PKE.Encrypt according to FIPS 203, Algorithm 14, although
+// the computation of t and A is done in parseEK.
+func pkeEncrypt(ex *encryptionKey, m *[messageSize]byte, rnd []byte) []byte {
+	p := ex.p
+	var N byte
+	r, e1 := make([]nttElement, p.k), make([]ringElement, p.k)
+	for i := range r {
+		r[i] = ntt(samplePolyCBD(rnd, N))
+		N++
+	}
+	for i := range e1 {
+		e1[i] = samplePolyCBD(rnd, N)
+		N++
+	}
+	e2 := samplePolyCBD(rnd, N)
+
+	u := make([]ringElement, p.k) // NTT⁻¹(Aᵀ ◦ r) + e1
+	for i := range u {
+		var uNTT nttElement
+		for j := range r {
+			// Note that i and j are inverted, as we need the transposed of A.
+			uNTT = polyAdd(uNTT, nttMul(ex.a[j*p.k+i], r[j]))
+		}
+		u[i] = polyAdd(e1[i], inverseNTT(uNTT))
+	}
+
+	μ := ringDecodeAndDecompress(m[:], 1)
+
+	var vNTT nttElement // tᵀ ◦ r
+	for i := range ex.t {
+		vNTT = polyAdd(vNTT, nttMul(ex.t[i], r[i]))
+	}
+	v := polyAdd(polyAdd(inverseNTT(vNTT), e2), μ)
+
+	c := make([]byte, 0, p.ciphertextSize())
+	for _, f := range u {
+		c = ringCompressAndEncode(c, f, p.du)
+	}
+	c = ringCompressAndEncode(c, v, p.dv)
+
+	return c
+}
+
+// kemDecaps produces a shared key from a ciphertext.
+//
+// It implements ML-KEM.Decaps_internal according to FIPS 203, Algorithm 18.
+func kemDecaps(dk *decapsulationKey, c []byte) (K []byte, err error) {
+	if len(c) != dk.p.ciphertextSize() {
+		return nil, errors.New("mlkem: invalid ciphertext length")
+	}
+
+	m := pkeDecrypt(dk, c)
+	g := sha3.New512()
+	g.Write(m[:])
+	g.Write(dk.h[:])
+	G := g.Sum(nil)
+	Kprime, r := G[:SharedKeySize], G[SharedKeySize:]
+	J := sha3.NewShake256()
+	J.Write(dk.z[:])
+	J.Write(c)
+	Kout := make([]byte, SharedKeySize)
+	J.Read(Kout)
+	c1 := pkeEncrypt(&dk.encryptionKey, m, r)
+
+	subtle.ConstantTimeCopy(subtle.ConstantTimeCompare(c, c1), Kout, Kprime)
+	return Kout, nil
+}
+
+// pkeDecrypt decrypts a ciphertext.
+//
+// It implements K-PKE.Decrypt according to FIPS 203, Algorithm 15.
+func pkeDecrypt(dk *decapsulationKey, c []byte) *[messageSize]byte {
+	p := dk.p
+	uSize := encodingSize(p.du)
+
+	var mask nttElement // sᵀ ◦ NTT(u)
+	for i := range dk.s {
+		u := ringDecodeAndDecompress(c[uSize*i:uSize*(i+1)], p.du)
+		mask = polyAdd(mask, nttMul(dk.s[i], ntt(u)))
+	}
+	v := ringDecodeAndDecompress(c[uSize*p.k:], p.dv)
+	w := polySub(v, inverseNTT(mask))
+
+	var m [messageSize]byte
+	ringCompressAndEncode(m[:0], w, 1)
+	return &m
+}
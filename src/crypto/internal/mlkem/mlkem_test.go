@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package mlkem768
+package mlkem
 
 import (
 	"bytes"
@@ -199,15 +199,15 @@ func TestGammas(t *testing.T) {
 }
 
 func TestRoundTrip(t *testing.T) {
-	dk, err := GenerateKey()
+	dk, err := GenerateDraftKey()
 	if err != nil {
 		t.Fatal(err)
 	}
-	c, Ke, err := Encapsulate(dk.EncapsulationKey())
+	c, Ke, err := DraftEncapsulate(dk.EncapsulationKey())
 	if err != nil {
 		t.Fatal(err)
 	}
-	Kd, err := Decapsulate(dk, c)
+	Kd, err := DraftDecapsulate(dk, c)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -215,7 +215,7 @@ func TestRoundTrip(t *testing.T) {
 		t.Fail()
 	}
 
-	dk1, err := GenerateKey()
+	dk1, err := GenerateDraftKey()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -225,11 +225,11 @@ func TestRoundTrip(t *testing.T) {
 	if bytes.Equal(dk.Bytes(), dk1.Bytes()) {
 		t.Fail()
 	}
-	if bytes.Equal(dk.Bytes()[EncapsulationKeySize-32:], dk1.Bytes()[EncapsulationKeySize-32:]) {
+	if bytes.Equal(dk.Bytes()[EncapsulationKeySize768-32:], dk1.Bytes()[EncapsulationKeySize768-32:]) {
 		t.Fail()
 	}
 
-	c1, Ke1, err := Encapsulate(dk.EncapsulationKey())
+	c1, Ke1, err := DraftEncapsulate(dk.EncapsulationKey())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -242,52 +242,52 @@ func TestRoundTrip(t *testing.T) {
 }
 
 func TestBadLengths(t *testing.T) {
-	dk, err := GenerateKey()
+	dk, err := GenerateDraftKey()
 	if err != nil {
 		t.Fatal(err)
 	}
 	ek := dk.EncapsulationKey()
 
 	for i := 0; i < len(ek)-1; i++ {
-		if _, _, err := Encapsulate(ek[:i]); err == nil {
+		if _, _, err := DraftEncapsulate(ek[:i]); err == nil {
 			t.Errorf("expected error for ek length %d", i)
 		}
 	}
 	ekLong := ek
 	for i := 0; i < 100; i++ {
 		ekLong = append(ekLong, 0)
-		if _, _, err := Encapsulate(ekLong); err == nil {
+		if _, _, err := DraftEncapsulate(ekLong); err == nil {
 			t.Errorf("expected error for ek length %d", len(ekLong))
 		}
 	}
 
-	c, _, err := Encapsulate(ek)
+	c, _, err := DraftEncapsulate(ek)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	for i := 0; i < len(dk.Bytes())-1; i++ {
-		if _, err := NewKeyFromExtendedEncoding(dk.Bytes()[:i]); err == nil {
+		if _, err := NewDraftKeyFromExtendedEncoding(dk.Bytes()[:i]); err == nil {
 			t.Errorf("expected error for dk length %d", i)
 		}
 	}
 	dkLong := dk.Bytes()
 	for i := 0; i < 100; i++ {
 		dkLong = append(dkLong, 0)
-		if _, err := NewKeyFromExtendedEncoding(dkLong); err == nil {
+		if _, err := NewDraftKeyFromExtendedEncoding(dkLong); err == nil {
 			t.Errorf("expected error for dk length %d", len(dkLong))
 		}
 	}
 
 	for i := 0; i < len(c)-1; i++ {
-		if _, err := Decapsulate(dk, c[:i]); err == nil {
+		if _, err := DraftDecapsulate(dk, c[:i]); err == nil {
 			t.Errorf("expected error for c length %d", i)
 		}
 	}
 	cLong := c
 	for i := 0; i < 100; i++ {
 		cLong = append(cLong, 0)
-		if _, err := Decapsulate(dk, cLong); err == nil {
+		if _, err := DraftDecapsulate(dk, cLong); err == nil {
 			t.Errorf("expected error for c length %d", len(cLong))
 		}
 	}
@@ -297,25 +297,122 @@ func EncapsulateDerand(ek, m []byte) (c, K []byte, err error) {
 	if len(m) != messageSize {
 		return nil, nil, errors.New("bad message length")
 	}
-	return kemEncaps(nil, ek, (*[messageSize]byte)(m))
+	return draftEncaps(ek, (*[messageSize]byte)(m))
 }
 
 func DecapsulateFromBytes(dkBytes []byte, c []byte) ([]byte, error) {
-	dk, err := NewKeyFromExtendedEncoding(dkBytes)
+	dk, err := NewDraftKeyFromExtendedEncoding(dkBytes)
 	if err != nil {
 		return nil, err
 	}
-	return Decapsulate(dk, c)
+	return DraftDecapsulate(dk, c)
 }
 
-func GenerateKeyDerand(t testing.TB, d, z []byte) ([]byte, *DecapsulationKey) {
+func GenerateKeyDerand(t testing.TB, d, z []byte) ([]byte, *DraftDecapsulationKey) {
 	if len(d) != 32 || len(z) != 32 {
 		t.Fatal("bad length")
 	}
-	dk := kemKeyGen(nil, (*[32]byte)(d), (*[32]byte)(z))
+	dk := draftKeyGen((*[32]byte)(d), (*[32]byte)(z))
 	return dk.EncapsulationKey(), dk
 }
 
+func TestRingCompressAndEncode(t *testing.T) {
+	for _, d := range []uint8{1, 4, 5, 10, 11} {
+		var f ringElement
+		for i := range f {
+			f[i] = fieldElement(i * 13 % q)
+		}
+		b := ringCompressAndEncode(nil, f, d)
+		if len(b) != encodingSize(d) {
+			t.Fatalf("d = %d: encoding length %d, want %d", d, len(b), encodingSize(d))
+		}
+		// ByteEncode_d puts bit j of coefficient i at bit i*d+j of the output.
+		for i := range f {
+			c := compress(f[i], d)
+			for j := 0; j < int(d); j++ {
+				bit := i*int(d) + j
+				if got, want := b[bit/8]>>(bit%8)&1, byte(c>>j&1); got != want {
+					t.Fatalf("d = %d: bit %d of coefficient %d is %d, want %d", d, j, i, got, want)
+				}
+			}
+		}
+		g := ringDecodeAndDecompress(b, d)
+		for i := range g {
+			if want := decompress(compress(f[i], d), d); g[i] != want {
+				t.Fatalf("d = %d: coefficient %d decoded as %d, want %d", d, i, g[i], want)
+			}
+		}
+	}
+}
+
+// TestFIPSAccumulated accumulates the results of 10k (or, in short mode, 100)
+// deterministic vectors and checks the hash of the result, to avoid checking in
+// megabytes of test vectors. The procedure is the one of the accumulated
+// vectors of the C2SP/CCTV project, which are generated with the reference
+// implementation of FIPS 203.
+func TestFIPSAccumulated(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		p             *Params
+		expected      string
+		expectedShort string
+	}{
+		{"768", MLKEM768,
+			"8a518cc63da366322a8e7a818c7a0d63483cb3528d34a4cf42f35d5ad73f22fc",
+			"1114b1b6699ed191734fa339376afa7e285c9e6acf6ff0177d346696ce564415"},
+		{"1024", MLKEM1024,
+			"f1a3925c9cf8538bb104c56efb2f5ecb74cc3df25087460b73f6c873e96bcb6a",
+			"800018fec3e2723f73f1d657fe239b4d5d8782efaade297e8cd448e54cc2ac00"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			n, expected := 10000, test.expected
+			if testing.Short() {
+				n, expected = 100, test.expectedShort
+			}
+
+			s := sha3.NewShake128()
+			o := sha3.NewShake128()
+			seed := make([]byte, SeedSize)
+			var msg [messageSize]byte
+			ct1 := make([]byte, test.p.ciphertextSize())
+
+			for i := 0; i < n; i++ {
+				s.Read(seed)
+				var dk decapsulationKey
+				if err := newKeyFromSeed(&dk, test.p, seed); err != nil {
+					t.Fatal(err)
+				}
+				o.Write(dk.bytes(nil))
+
+				s.Read(msg[:])
+				ct, k := kemEncaps(&dk.encryptionKey, &msg)
+				o.Write(ct)
+				o.Write(k)
+
+				kk, err := kemDecaps(&dk, ct)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(kk, k) {
+					t.Errorf("k: got %x, expected %x", kk, k)
+				}
+
+				s.Read(ct1)
+				k1, err := kemDecaps(&dk, ct1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				o.Write(k1)
+			}
+
+			got := hex.EncodeToString(o.Sum(nil))
+			if got != expected {
+				t.Errorf("got %s, expected %s", got, expected)
+			}
+		})
+	}
+}
+
 var millionFlag = flag.Bool("million", false, "run the million vector test")
 
 // TestPQCrystalsAccumulated accumulates the 10k vectors generated by the
@@ -338,7 +435,7 @@ func TestPQCrystalsAccumulated(t *testing.T) {
 	d := make([]byte, 32)
 	z := make([]byte, 32)
 	msg := make([]byte, 32)
-	ct1 := make([]byte, CiphertextSize)
+	ct1 := make([]byte, CiphertextSize768)
 
 	for i := 0; i < n; i++ {
 		s.Read(d)
@@ -355,7 +452,7 @@ func TestPQCrystalsAccumulated(t *testing.T) {
 		o.Write(ct)
 		o.Write(k)
 
-		kk, err := Decapsulate(dk, ct)
+		kk, err := DraftDecapsulate(dk, ct)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -364,7 +461,7 @@ func TestPQCrystalsAccumulated(t *testing.T) {
 		}
 
 		s.Read(ct1)
-		k1, err := Decapsulate(dk, ct1)
+		k1, err := DraftDecapsulate(dk, ct1)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -380,14 +477,14 @@ func TestPQCrystalsAccumulated(t *testing.T) {
 var sink byte
 
 func BenchmarkKeyGen(b *testing.B) {
-	var dk DecapsulationKey
+	var dk decapsulationKey
 	var d, z [32]byte
 	rand.Read(d[:])
 	rand.Read(z[:])
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		dk := kemKeyGen(&dk, &d, &z)
-		sink ^= dk.EncapsulationKey()[0]
+		kemKeyGen(&dk, draft768, &d, &z)
+		sink ^= dk.h[0]
 	}
 }
 
@@ -399,10 +496,9 @@ func BenchmarkEncaps(b *testing.B) {
 	var m [messageSize]byte
 	rand.Read(m[:])
 	ek, _ := GenerateKeyDerand(b, d, z)
-	var c [CiphertextSize]byte
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		c, K, err := kemEncaps(&c, ek, &m)
+		c, K, err := draftEncaps(ek, &m)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -424,31 +520,34 @@ func BenchmarkDecaps(b *testing.B) {
 	}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		K := kemDecaps(dk, (*[CiphertextSize]byte)(c))
+		K, err := kemDecaps(&dk.key, c)
+		if err != nil {
+			b.Fatal(err)
+		}
 		sink ^= K[0]
 	}
 }
 
 func BenchmarkRoundTrip(b *testing.B) {
-	dk, err := GenerateKey()
+	dk, err := GenerateDraftKey()
 	if err != nil {
 		b.Fatal(err)
 	}
 	ek := dk.EncapsulationKey()
-	c, _, err := Encapsulate(ek)
+	c, _, err := DraftEncapsulate(ek)
 	if err != nil {
 		b.Fatal(err)
 	}
 	b.Run("Alice", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			dkS, err := GenerateKey()
+			dkS, err := GenerateDraftKey()
 			if err != nil {
 				b.Fatal(err)
 			}
 			ekS := dkS.EncapsulationKey()
 			sink ^= ekS[0]
 
-			Ks, err := Decapsulate(dk, c)
+			Ks, err := DraftDecapsulate(dk, c)
 			if err != nil {
 				b.Fatal(err)
 			}
@@ -457,7 +556,7 @@ func BenchmarkRoundTrip(b *testing.B) {
 	})
 	b.Run("Bob", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			cS, Ks, err := Encapsulate(ek)
+			cS, Ks, err := DraftEncapsulate(ek)
 			if err != nil {
 				b.Fatal(err)
 			}
@@ -0,0 +1,36 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mlkem implements the quantum-resistant key encapsulation method
+// ML-KEM (formerly known as Kyber), as specified in [NIST FIPS 203].
+//
+// A key encapsulation method lets a party holding an encapsulation key
+// establish a shared secret with the party holding the matching
+// decapsulation key, by sending it a ciphertext. Like the keys of
+// [crypto/ecdh], the keys of this package are opaque values that can be
+// serialized with their Bytes method and parsed back with the matching
+// constructor.
+//
+// Two parameter sets are provided: ML-KEM-768, which is recommended for
+// most uses, and ML-KEM-1024, which offers a higher security margin at the
+// cost of larger keys and ciphertexts.
+//
+// Decapsulation keys are serialized as the 64-byte seed from which they are
+// derived, rather than in the expanded form of FIPS 203.
+//
+// [NIST FIPS 203]: https://doi.org/10.6028/NIST.FIPS.203
+package mlkem
+
+import "crypto/internal/mlkem"
+
+const (
+	SharedKeySize = mlkem.SharedKeySize
+	SeedSize      = mlkem.SeedSize
+
+	CiphertextSize768       = mlkem.CiphertextSize768
+	EncapsulationKeySize768 = mlkem.EncapsulationKeySize768
+
+	CiphertextSize1024       = mlkem.CiphertextSize1024
+	EncapsulationKeySize1024 = mlkem.EncapsulationKeySize1024
+)
@@ -0,0 +1,189 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mlkem
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testEncapsulationKey interface {
+	Bytes() []byte
+	Encapsulate() (ciphertext, sharedKey []byte)
+}
+
+type testDecapsulationKey[E testEncapsulationKey] interface {
+	Bytes() []byte
+	Decapsulate(ciphertext []byte) (sharedKey []byte, err error)
+	EncapsulationKey() E
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Run("768", func(t *testing.T) {
+		testRoundTrip(t, GenerateKey768, NewDecapsulationKey768, NewEncapsulationKey768)
+	})
+	t.Run("1024", func(t *testing.T) {
+		testRoundTrip(t, GenerateKey1024, NewDecapsulationKey1024, NewEncapsulationKey1024)
+	})
+}
+
+func testRoundTrip[E testEncapsulationKey, D testDecapsulationKey[E]](t *testing.T,
+	generateKey func() (D, error), newDK func([]byte) (D, error), newEK func([]byte) (E, error)) {
+	dk, err := generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	c, Ke := ek.Encapsulate()
+	Kd, err := dk.Decapsulate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Errorf("shared keys differ: %x, %x", Ke, Kd)
+	}
+
+	ek1, err := newEK(ek.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ek.Bytes(), ek1.Bytes()) {
+		t.Error("parsed encapsulation key encodes differently")
+	}
+	c1, Ke1 := ek1.Encapsulate()
+	if bytes.Equal(c, c1) || bytes.Equal(Ke, Ke1) {
+		t.Error("two encapsulations produced the same ciphertext or shared key")
+	}
+	dk1, err := newDK(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dk.Bytes(), dk1.Bytes()) {
+		t.Error("decapsulation key from seed encodes differently")
+	}
+	if !bytes.Equal(ek.Bytes(), dk1.EncapsulationKey().Bytes()) {
+		t.Error("decapsulation key from seed has a different encapsulation key")
+	}
+	if Kd1, err := dk1.Decapsulate(c1); err != nil || !bytes.Equal(Kd1, Ke1) {
+		t.Errorf("Decapsulate with key from seed = %x, %v; want %x", Kd1, err, Ke1)
+	}
+
+	// A modified ciphertext is implicitly rejected, producing an unrelated key.
+	c1[0] ^= 1
+	if Kd1, err := dk1.Decapsulate(c1); err != nil || bytes.Equal(Kd1, Ke1) {
+		t.Errorf("Decapsulate of modified ciphertext = %x, %v; want an unrelated key", Kd1, err)
+	}
+
+	dk2, err := generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(dk.Bytes(), dk2.Bytes()) || bytes.Equal(ek.Bytes(), dk2.EncapsulationKey().Bytes()) {
+		t.Error("two generated keys are equal")
+	}
+}
+
+func TestBadLengths(t *testing.T) {
+	t.Run("768", func(t *testing.T) {
+		testBadLengths(t, GenerateKey768, NewDecapsulationKey768, NewEncapsulationKey768)
+	})
+	t.Run("1024", func(t *testing.T) {
+		testBadLengths(t, GenerateKey1024, NewDecapsulationKey1024, NewEncapsulationKey1024)
+	})
+}
+
+func testBadLengths[E testEncapsulationKey, D testDecapsulationKey[E]](t *testing.T,
+	generateKey func() (D, error), newDK func([]byte) (D, error), newEK func([]byte) (E, error)) {
+	dk, err := generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	c, _ := ek.Encapsulate()
+
+	for _, test := range []struct {
+		name  string
+		b     []byte
+		parse func([]byte) error
+	}{
+		{"seed", dk.Bytes(), func(b []byte) error { _, err := newDK(b); return err }},
+		{"encapsulation key", ek.Bytes(), func(b []byte) error { _, err := newEK(b); return err }},
+		{"ciphertext", c, func(b []byte) error { _, err := dk.Decapsulate(b); return err }},
+	} {
+		for i := 0; i < len(test.b); i++ {
+			if err := test.parse(test.b[:i]); err == nil {
+				t.Errorf("expected error for %s length %d", test.name, i)
+			}
+		}
+		long := append([]byte(nil), test.b...)
+		for i := 0; i < 100; i++ {
+			long = append(long, 0)
+			if err := test.parse(long); err == nil {
+				t.Errorf("expected error for %s length %d", test.name, len(long))
+			}
+		}
+	}
+}
+
+func TestEncapsulationKeyModulusCheck(t *testing.T) {
+	dk, err := GenerateKey1024()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey().Bytes()
+	// Set the first coefficient of the last polynomial of t to q.
+	const q = 3329
+	off := 3 * 384 // (k - 1) * 384 bytes, with k = 4
+	ek[off] = q & 0xff
+	ek[off+1] = ek[off+1]&0xf0 | q>>8
+	if _, err := NewEncapsulationKey1024(ek); err == nil {
+		t.Error("NewEncapsulationKey1024 accepted an unreduced coefficient")
+	}
+}
+
+var sink byte
+
+func BenchmarkKeyGen(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		dk, err := GenerateKey768()
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink ^= dk.Bytes()[0]
+	}
+}
+
+func BenchmarkEncaps(b *testing.B) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ekBytes := dk.EncapsulationKey().Bytes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ek, err := NewEncapsulationKey768(ekBytes)
+		if err != nil {
+			b.Fatal(err)
+		}
+		c, K := ek.Encapsulate()
+		sink ^= c[0] ^ K[0]
+	}
+}
+
+func BenchmarkDecaps(b *testing.B) {
+	dk, err := GenerateKey768()
+	if err != nil {
+		b.Fatal(err)
+	}
+	c, _ := dk.EncapsulationKey().Encapsulate()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		K, err := dk.Decapsulate(c)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink ^= K[0]
+	}
+}
@@ -640,6 +640,24 @@ type Config struct {
 	// settings.
 	VerifyConnection func(ConnectionState) error
 
+	// Revocation, if not nil, enables checking the revocation status of the
+	// peer's certificates during normal certificate verification, as
+	// described in x509.VerifyOptions.Revocation. The OCSP response stapled
+	// by the peer, if any, is added to the Revocation.OCSPResponses, and
+	// Revocation.FetchOCSP and Revocation.FetchCRL are called with the
+	// context of the handshake.
+	//
+	// If the peer's certificate is revoked, the handshake is aborted with a
+	// certificate_revoked alert.
+	Revocation *x509.RevocationOptions
+
+	// CertificateTransparency, if not nil, requires the peer's leaf
+	// certificate to have valid signed certificate timestamps during normal
+	// certificate verification, as described in
+	// x509.VerifyOptions.CertificateTransparency. The SCTs sent by the peer,
+	// if any, are added to the CertificateTransparency.SCTs.
+	CertificateTransparency *x509.CTPolicy
+
 	// RootCAs defines the set of root certificate authorities
 	// that clients use when verifying server certificates.
 	// If RootCAs is nil, TLS uses the host's root CA set.
@@ -891,6 +909,8 @@ func (c *Config) Clone() *Config {
 		GetConfigForClient:                  c.GetConfigForClient,
 		VerifyPeerCertificate:               c.VerifyPeerCertificate,
 		VerifyConnection:                    c.VerifyConnection,
+		Revocation:                          c.Revocation,
+		CertificateTransparency:             c.CertificateTransparency,
 		RootCAs:                             c.RootCAs,
 		NextProtos:                          c.NextProtos,
 		ServerName:                          c.ServerName,
@@ -1059,6 +1079,28 @@ func (c *Config) time() time.Time {
 	return t()
 }
 
+// revocationOptions returns the options to check the revocation status of
+// the certificates of a peer that stapled the OCSP response staple.
+func (c *Config) revocationOptions(staple []byte) *x509.RevocationOptions {
+	if c.Revocation == nil || len(staple) == 0 {
+		return c.Revocation
+	}
+	opts := *c.Revocation
+	opts.OCSPResponses = append(slices.Clip(opts.OCSPResponses), staple)
+	return &opts
+}
+
+// ctPolicy returns the Certificate Transparency policy to enforce on the
+// certificate of a peer that sent the SCTs scts.
+func (c *Config) ctPolicy(scts [][]byte) *x509.CTPolicy {
+	if c.CertificateTransparency == nil || len(scts) == 0 {
+		return c.CertificateTransparency
+	}
+	policy := *c.CertificateTransparency
+	policy.SCTs = append(slices.Clip(policy.SCTs), scts...)
+	return &policy
+}
+
 func (c *Config) cipherSuites() []uint16 {
 	if c.CipherSuites == nil {
 		if needFIPS() {
@@ -11,7 +11,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/internal/hpke"
-	"crypto/internal/mlkem768"
+	"crypto/internal/mlkem"
 	"crypto/rsa"
 	"crypto/subtle"
 	"crypto/x509"
@@ -159,11 +159,11 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, *keySharePrivateKeys, *echCon
 			if err != nil {
 				return nil, nil, nil, err
 			}
-			seed := make([]byte, mlkem768.SeedSize)
+			seed := make([]byte, mlkem.SeedSize)
 			if _, err := io.ReadFull(config.rand(), seed); err != nil {
 				return nil, nil, nil, err
 			}
-			keyShareKeys.kyber, err = mlkem768.NewKeyFromSeed(seed)
+			keyShareKeys.kyber, err = mlkem.NewDraftKeyFromSeed(seed)
 			if err != nil {
 				return nil, nil, nil, err
 			}
@@ -687,7 +687,7 @@ func (hs *clientHandshakeState) doFullHandshake() error {
 	if c.handshakes == 0 {
 		// If this is the first handshake on a connection, process and
 		// (optionally) verify the server's certificates.
-		if err := c.verifyServerCertificate(certMsg.certificates); err != nil {
+		if err := c.verifyServerCertificate(hs.ctx, certMsg.certificates); err != nil {
 			return err
 		}
 	} else {
@@ -1073,7 +1073,7 @@ func checkKeySize(n int) (max int, ok bool) {
 
 // verifyServerCertificate parses and verifies the provided chain, setting
 // c.verifiedChains and c.peerCertificates or sending the appropriate alert.
-func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
+func (c *Conn) verifyServerCertificate(ctx context.Context, certificates [][]byte) error {
 	activeHandles := make([]*activeCert, len(certificates))
 	certs := make([]*x509.Certificate, len(certificates))
 	for i, asn1Data := range certificates {
@@ -1120,19 +1120,26 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 		}
 	} else if !c.config.InsecureSkipVerify {
 		opts := x509.VerifyOptions{
-			Roots:         c.config.RootCAs,
-			CurrentTime:   c.config.time(),
-			DNSName:       c.config.ServerName,
-			Intermediates: x509.NewCertPool(),
+			Roots:                   c.config.RootCAs,
+			CurrentTime:             c.config.time(),
+			DNSName:                 c.config.ServerName,
+			Intermediates:           x509.NewCertPool(),
+			Revocation:              c.config.revocationOptions(c.ocspResponse),
+			CertificateTransparency: c.config.ctPolicy(c.scts),
 		}
 
 		for _, cert := range certs[1:] {
 			opts.Intermediates.AddCert(cert)
 		}
 		var err error
-		c.verifiedChains, err = certs[0].Verify(opts)
+		c.verifiedChains, _, err = certs[0].VerifyContext(ctx, opts)
 		if err != nil {
-			c.sendAlert(alertBadCertificate)
+			var errCertificateInvalid x509.CertificateInvalidError
+			if errors.As(err, &errCertificateInvalid) && errCertificateInvalid.Reason == x509.Revoked {
+				c.sendAlert(alertCertificateRevoked)
+			} else {
+				c.sendAlert(alertBadCertificate)
+			}
 			return &CertificateVerificationError{UnverifiedCertificates: certs, Err: err}
 		}
 	}
@@ -651,7 +651,7 @@ func (hs *serverHandshakeState) doFullHandshake() error {
 			return unexpectedMessageError(certMsg, msg)
 		}
 
-		if err := c.processCertsFromClient(Certificate{
+		if err := c.processCertsFromClient(hs.ctx, Certificate{
 			Certificate: certMsg.certificates,
 		}); err != nil {
 			return err
@@ -871,7 +871,7 @@ func (hs *serverHandshakeState) sendFinished(out []byte) error {
 
 // processCertsFromClient takes a chain of client certificates either from a
 // Certificates message and verifies them.
-func (c *Conn) processCertsFromClient(certificate Certificate) error {
+func (c *Conn) processCertsFromClient(ctx context.Context, certificate Certificate) error {
 	certificates := certificate.Certificate
 	certs := make([]*x509.Certificate, len(certificates))
 	var err error
@@ -900,23 +900,27 @@ func (c *Conn) processCertsFromClient(certificate Certificate) error {
 
 	if c.config.ClientAuth >= VerifyClientCertIfGiven && len(certs) > 0 {
 		opts := x509.VerifyOptions{
-			Roots:         c.config.ClientCAs,
-			CurrentTime:   c.config.time(),
-			Intermediates: x509.NewCertPool(),
-			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			Roots:                   c.config.ClientCAs,
+			CurrentTime:             c.config.time(),
+			Intermediates:           x509.NewCertPool(),
+			KeyUsages:               []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			Revocation:              c.config.revocationOptions(certificate.OCSPStaple),
+			CertificateTransparency: c.config.ctPolicy(certificate.SignedCertificateTimestamps),
 		}
 
 		for _, cert := range certs[1:] {
 			opts.Intermediates.AddCert(cert)
 		}
 
-		chains, err := certs[0].Verify(opts)
+		chains, _, err := certs[0].VerifyContext(ctx, opts)
 		if err != nil {
 			var errCertificateInvalid x509.CertificateInvalidError
 			if errors.As(err, &x509.UnknownAuthorityError{}) {
 				c.sendAlert(alertUnknownCA)
 			} else if errors.As(err, &errCertificateInvalid) && errCertificateInvalid.Reason == x509.Expired {
 				c.sendAlert(alertCertificateExpired)
+			} else if errors.As(err, &errCertificateInvalid) && errCertificateInvalid.Reason == x509.Revoked {
+				c.sendAlert(alertCertificateRevoked)
 			} else {
 				c.sendAlert(alertBadCertificate)
 			}
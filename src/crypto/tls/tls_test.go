@@ -11,6 +11,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
@@ -29,6 +30,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/cryptobyte"
 )
 
 var rsaCertPEM = `-----BEGIN CERTIFICATE-----
@@ -854,6 +857,10 @@ func TestCloneNonFuncFields(t *testing.T) {
 			}))
 		case "NameToCertificate":
 			f.Set(reflect.ValueOf(map[string]*Certificate{"a": nil}))
+		case "Revocation":
+			f.Set(reflect.ValueOf(&x509.RevocationOptions{HardFail: true}))
+		case "CertificateTransparency":
+			f.Set(reflect.ValueOf(&x509.CTPolicy{MinSCTs: 2}))
 		case "RootCAs", "ClientCAs":
 			f.Set(reflect.ValueOf(x509.NewCertPool()))
 		case "ClientSessionCache":
@@ -2075,3 +2082,281 @@ func TestLargeCertMsg(t *testing.T) {
 		t.Fatalf("unexpected failure :%s", err)
 	}
 }
+
+func TestRevocation(t *testing.T) {
+	now := time.Now()
+	newCert := func(cn string, isCA bool, issuer *x509.Certificate, issuerKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          serial,
+			Subject:               pkix.Name{CommonName: cn},
+			DNSNames:              []string{cn},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(time.Hour),
+			KeyUsage:              x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+		}
+		if isCA {
+			tmpl.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		}
+		if issuer == nil {
+			issuer, issuerKey = tmpl, k
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, k.Public(), issuerKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, k
+	}
+	newCRL := func(issuer *x509.Certificate, key crypto.Signer, revoked ...*x509.Certificate) *x509.RevocationList {
+		tmpl := &x509.RevocationList{Number: big.NewInt(1), ThisUpdate: now.Add(-time.Hour), NextUpdate: now.Add(time.Hour)}
+		for _, c := range revoked {
+			tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{
+				SerialNumber: c.SerialNumber, RevocationTime: now.Add(-time.Minute),
+			})
+		}
+		der, err := x509.CreateRevocationList(rand.Reader, tmpl, issuer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rl
+	}
+
+	ca, caKey := newCert("ca", true, nil, nil)
+	serverCert, serverKey := newCert("server.example", false, ca, caKey)
+	clientCert, clientKey := newCert("client.example", false, ca, caKey)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	emptyCRL := newCRL(ca, caKey)
+	serverRevoked := newCRL(ca, caKey, serverCert)
+	clientRevoked := newCRL(ca, caKey, clientCert)
+
+	for _, test := range []struct {
+		name                   string
+		staple                 []byte
+		clientOpts, serverOpts *x509.RevocationOptions
+		// maxVersion is set so that the client waits for the server to
+		// verify its certificate during the handshake.
+		maxVersion uint16
+		wantErr    string
+	}{
+		{
+			name:       "good",
+			clientOpts: &x509.RevocationOptions{CRLs: []*x509.RevocationList{emptyCRL}, HardFail: true},
+			serverOpts: &x509.RevocationOptions{CRLs: []*x509.RevocationList{emptyCRL}, HardFail: true},
+		},
+		{
+			name:       "server revoked",
+			clientOpts: &x509.RevocationOptions{CRLs: []*x509.RevocationList{serverRevoked}},
+			wantErr:    "certificate has been revoked",
+		},
+		{
+			name:       "client revoked",
+			serverOpts: &x509.RevocationOptions{CRLs: []*x509.RevocationList{clientRevoked}},
+			maxVersion: VersionTLS12,
+			wantErr:    "certificate has been revoked",
+		},
+		{
+			name:       "stapled response is checked",
+			staple:     []byte("not an OCSP response"),
+			clientOpts: &x509.RevocationOptions{HardFail: true},
+			wantErr:    "malformed ocsp response",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			serverConfig := &Config{
+				Certificates: []Certificate{{
+					Certificate: [][]byte{serverCert.Raw},
+					PrivateKey:  serverKey,
+					OCSPStaple:  test.staple,
+				}},
+				ClientAuth: RequireAndVerifyClientCert,
+				ClientCAs:  pool,
+				Revocation: test.serverOpts,
+			}
+			clientConfig := &Config{
+				Certificates: []Certificate{{
+					Certificate: [][]byte{clientCert.Raw},
+					PrivateKey:  clientKey,
+				}},
+				RootCAs:    pool,
+				ServerName: "server.example",
+				MaxVersion: test.maxVersion,
+				Revocation: test.clientOpts,
+			}
+			_, _, err := testHandshake(t, clientConfig, serverConfig)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("handshake failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("handshake error = %v, want %q", err, test.wantErr)
+			}
+			if strings.Contains(test.wantErr, "revoked") && !strings.Contains(err.Error(), "revoked certificate") {
+				t.Errorf("handshake error = %v, want a certificate_revoked alert", err)
+			}
+		})
+	}
+}
+
+func TestCertificateTransparency(t *testing.T) {
+	now := time.Now()
+	newKey := func() *ecdsa.PrivateKey {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	newCert := func(cn string, isCA bool, issuer *x509.Certificate, issuerKey, key crypto.Signer) *x509.Certificate {
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(int64(len(cn))),
+			Subject:               pkix.Name{CommonName: cn},
+			DNSNames:              []string{cn},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(time.Hour),
+			KeyUsage:              x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+		}
+		if isCA {
+			tmpl.KeyUsage |= x509.KeyUsageCertSign
+		}
+		if issuer == nil {
+			issuer, issuerKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, key.Public(), issuerKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	// newSCT returns an SCT issued by the log with the given key for cert,
+	// as specified in RFC 6962, Section 3.2.
+	newSCT := func(logKey *ecdsa.PrivateKey, cert *x509.Certificate) []byte {
+		log := &x509.CTLog{PublicKey: &logKey.PublicKey}
+		id, err := log.ID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		timestamp := uint64(now.Add(-time.Minute).UnixMilli())
+		var signed cryptobyte.Builder
+		signed.AddUint16(0) // v1, certificate_timestamp
+		signed.AddUint64(timestamp)
+		signed.AddUint16(0) // x509_entry
+		signed.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(cert.Raw)
+		})
+		signed.AddUint16(0) // no extensions
+		digest := sha256.Sum256(signed.BytesOrPanic())
+		sig, err := ecdsa.SignASN1(rand.Reader, logKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b cryptobyte.Builder
+		b.AddUint8(0)
+		b.AddBytes(id[:])
+		b.AddUint64(timestamp)
+		b.AddUint16(0)
+		b.AddUint16(0x0403) // ecdsa_secp256r1_sha256
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(sig)
+		})
+		return b.BytesOrPanic()
+	}
+
+	caKey, serverKey, clientKey := newKey(), newKey(), newKey()
+	ca := newCert("ca", true, nil, nil, caKey)
+	serverCert := newCert("server.example", false, ca, caKey, serverKey)
+	clientCert := newCert("client.example", false, ca, caKey, clientKey)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	logKeyA, logKeyB := newKey(), newKey()
+	logs := []*x509.CTLog{{PublicKey: &logKeyA.PublicKey}, {PublicKey: &logKeyB.PublicKey}}
+	serverSCTs := [][]byte{newSCT(logKeyA, serverCert), newSCT(logKeyB, serverCert)}
+	clientSCTs := [][]byte{newSCT(logKeyA, clientCert)}
+
+	for _, test := range []struct {
+		name                       string
+		serverSCTs                 [][]byte
+		clientPolicy, serverPolicy *x509.CTPolicy
+		wantErr                    string
+	}{
+		{
+			name:         "good",
+			serverSCTs:   serverSCTs,
+			clientPolicy: &x509.CTPolicy{Logs: logs, MinSCTs: 2},
+			serverPolicy: &x509.CTPolicy{Logs: logs},
+		},
+		{
+			name:         "not enough SCTs",
+			serverSCTs:   serverSCTs[:1],
+			clientPolicy: &x509.CTPolicy{Logs: logs, MinSCTs: 2},
+			wantErr:      "not enough valid signed certificate timestamps",
+		},
+		{
+			name:         "untrusted log",
+			serverSCTs:   serverSCTs,
+			clientPolicy: &x509.CTPolicy{Logs: logs[1:], MinSCTs: 2},
+			wantErr:      "not enough valid signed certificate timestamps",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			serverConfig := &Config{
+				Certificates: []Certificate{{
+					Certificate:                 [][]byte{serverCert.Raw},
+					PrivateKey:                  serverKey,
+					SignedCertificateTimestamps: test.serverSCTs,
+				}},
+				ClientAuth:              RequireAndVerifyClientCert,
+				ClientCAs:               pool,
+				CertificateTransparency: test.serverPolicy,
+			}
+			clientConfig := &Config{
+				Certificates: []Certificate{{
+					Certificate:                 [][]byte{clientCert.Raw},
+					PrivateKey:                  clientKey,
+					SignedCertificateTimestamps: clientSCTs,
+				}},
+				RootCAs:                 pool,
+				ServerName:              "server.example",
+				CertificateTransparency: test.clientPolicy,
+			}
+			_, _, err := testHandshake(t, clientConfig, serverConfig)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("handshake failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("handshake error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// oidExtensionSCTList is the extension that embeds SCTs in a certificate, as
+// specified in RFC 6962, Section 3.3.
+var oidExtensionSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// A SignedCertificateTimestamp is a promise by a Certificate Transparency log
+// to include a certificate in the log, as specified in RFC 6962, Section 3.2.
+type SignedCertificateTimestamp struct {
+	Raw []byte // Complete TLS encoded SCT.
+
+	// LogID is the SHA-256 hash of the DER encoded public key of the log.
+	LogID     [32]byte
+	Timestamp time.Time
+	// Extensions contains the opaque CtExtensions of the SCT.
+	Extensions []byte
+
+	// SignatureAlgorithm is the algorithm of Signature, which is
+	// UnknownSignatureAlgorithm if not supported.
+	SignatureAlgorithm SignatureAlgorithm
+	Signature          []byte
+}
+
+// ParseSignedCertificateTimestamp parses a single TLS encoded SCT, such as an
+// element of the tls.ConnectionState.SignedCertificateTimestamps. Only
+// version 1 SCTs are supported.
+func ParseSignedCertificateTimestamp(data []byte) (*SignedCertificateTimestamp, error) {
+	s := cryptobyte.String(data)
+	sct, err := parseSCT(&s)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Empty() {
+		return nil, errors.New("x509: trailing data after SCT")
+	}
+	return sct, nil
+}
+
+// ParseSignedCertificateTimestampList parses a TLS encoded
+// SignedCertificateTimestampList, as specified in RFC 6962, Section 3.3.
+func ParseSignedCertificateTimestampList(data []byte) ([]*SignedCertificateTimestamp, error) {
+	s := cryptobyte.String(data)
+	var list cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&list) || !s.Empty() {
+		return nil, errors.New("x509: malformed SCT list")
+	}
+	var scts []*SignedCertificateTimestamp
+	for !list.Empty() {
+		var raw cryptobyte.String
+		if !list.ReadUint16LengthPrefixed(&raw) {
+			return nil, errors.New("x509: malformed SCT list")
+		}
+		sct, err := ParseSignedCertificateTimestamp(raw)
+		if err != nil {
+			return nil, err
+		}
+		scts = append(scts, sct)
+	}
+	return scts, nil
+}
+
+func parseSCT(s *cryptobyte.String) (*SignedCertificateTimestamp, error) {
+	// struct {
+	//     Version sct_version;
+	//     LogID id;
+	//     uint64 timestamp;
+	//     CtExtensions extensions;
+	//     digitally-signed struct { ... };
+	// } SignedCertificateTimestamp;
+	start := *s
+	var version uint8
+	if !s.ReadUint8(&version) {
+		return nil, errors.New("x509: malformed SCT")
+	}
+	if version != 0 {
+		return nil, fmt.Errorf("x509: unsupported SCT version %d", version)
+	}
+	sct := &SignedCertificateTimestamp{}
+	var logID, extensions, signature []byte
+	var timestamp uint64
+	var hash, sig uint8
+	if !s.ReadBytes(&logID, 32) ||
+		!s.ReadUint64(&timestamp) ||
+		!s.ReadUint16LengthPrefixed((*cryptobyte.String)(&extensions)) ||
+		!s.ReadUint8(&hash) || !s.ReadUint8(&sig) ||
+		!s.ReadUint16LengthPrefixed((*cryptobyte.String)(&signature)) {
+		return nil, errors.New("x509: malformed SCT")
+	}
+	sct.Raw = start[:len(start)-len(*s)]
+	copy(sct.LogID[:], logID)
+	sct.Timestamp = time.UnixMilli(int64(timestamp))
+	sct.Extensions = extensions
+	sct.Signature = signature
+
+	// Logs use SHA-256 with either ECDSA or RSA, as specified in RFC 6962,
+	// Section 2.1.4. The values are those of the TLS 1.2 SignatureAndHashAlgorithm.
+	switch {
+	case hash == 4 && sig == 3:
+		sct.SignatureAlgorithm = ECDSAWithSHA256
+	case hash == 4 && sig == 1:
+		sct.SignatureAlgorithm = SHA256WithRSA
+	}
+	return sct, nil
+}
+
+// EmbeddedSCTs returns the SCTs embedded in the certificate, which are
+// verified with [SignedCertificateTimestamp.CheckEmbeddedSignature]. It
+// returns nil if the certificate has no embedded SCTs.
+func (c *Certificate) EmbeddedSCTs() ([]*SignedCertificateTimestamp, error) {
+	for _, ext := range c.Extensions {
+		if !ext.Id.Equal(oidExtensionSCTList) {
+			continue
+		}
+		val := cryptobyte.String(ext.Value)
+		var list cryptobyte.String
+		if !val.ReadASN1(&list, cryptobyte_asn1.OCTET_STRING) || !val.Empty() {
+			return nil, errors.New("x509: malformed SCT list extension")
+		}
+		return ParseSignedCertificateTimestampList(list)
+	}
+	return nil, nil
+}
+
+// A CTLog is a Certificate Transparency log trusted to issue SCTs.
+type CTLog struct {
+	// PublicKey is the public key of the log, an *ecdsa.PublicKey or an
+	// *rsa.PublicKey.
+	PublicKey any
+
+	// Description is a human readable name of the log.
+	Description string
+}
+
+// ID returns the log ID of l, the SHA-256 hash of its DER encoded public key.
+func (l *CTLog) ID() ([32]byte, error) {
+	der, err := MarshalPKIXPublicKey(l.PublicKey)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(der), nil
+}
+
+// Values of the LogEntryType of RFC 6962, Section 3.1.
+const (
+	ctEntryX509    = 0
+	ctEntryPrecert = 1
+)
+
+// CheckSignature verifies that sct is a valid SCT issued by log for cert,
+// such as an SCT delivered in a TLS extension or in an OCSP response.
+func (sct *SignedCertificateTimestamp) CheckSignature(log *CTLog, cert *Certificate) error {
+	var b cryptobyte.Builder
+	b.AddUint16(ctEntryX509)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(cert.Raw)
+	})
+	return sct.checkSignature(log, b.BytesOrPanic())
+}
+
+// CheckEmbeddedSignature verifies that sct is a valid SCT issued by log for
+// the precertificate of cert, and embedded in cert, which was issued by
+// issuer.
+//
+// Precertificates signed by a dedicated precertificate signing certificate
+// are not supported.
+func (sct *SignedCertificateTimestamp) CheckEmbeddedSignature(log *CTLog, cert, issuer *Certificate) error {
+	tbs, err := removeSCTList(cert.RawTBSCertificate)
+	if err != nil {
+		return err
+	}
+	var b cryptobyte.Builder
+	b.AddUint16(ctEntryPrecert)
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	b.AddBytes(issuerKeyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	return sct.checkSignature(log, b.BytesOrPanic())
+}
+
+// checkSignature verifies the signature of sct over the given entry, as
+// specified in RFC 6962, Section 3.2.
+func (sct *SignedCertificateTimestamp) checkSignature(log *CTLog, entry []byte) error {
+	id, err := log.ID()
+	if err != nil {
+		return err
+	}
+	if id != sct.LogID {
+		return errors.New("x509: SCT was not issued by the log")
+	}
+	if sct.SignatureAlgorithm == UnknownSignatureAlgorithm {
+		return ErrUnsupportedAlgorithm
+	}
+	var b cryptobyte.Builder
+	b.AddUint8(0) // sct_version v1
+	b.AddUint8(0) // signature_type certificate_timestamp
+	b.AddUint64(uint64(sct.Timestamp.UnixMilli()))
+	b.AddBytes(entry)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sct.Extensions)
+	})
+	return checkSignature(sct.SignatureAlgorithm, b.BytesOrPanic(), sct.Signature, log.PublicKey, false)
+}
+
+// removeSCTList returns the DER encoded TBSCertificate tbs without the
+// embedded SCT list extension, which is the TBSCertificate signed by the log
+// as specified in RFC 6962, Section 3.2.
+func removeSCTList(tbs []byte) ([]byte, error) {
+	input := cryptobyte.String(tbs)
+	var fields cryptobyte.String
+	if !input.ReadASN1(&fields, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed tbs certificate")
+	}
+	extensionsTag := cryptobyte_asn1.Tag(3).Constructed().ContextSpecific()
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !fields.Empty() {
+			var field, body cryptobyte.String
+			var tag cryptobyte_asn1.Tag
+			if !fields.ReadAnyASN1Element(&field, &tag) {
+				b.SetError(errors.New("x509: malformed tbs certificate"))
+				return
+			}
+			if tag != extensionsTag {
+				b.AddBytes(field)
+				continue
+			}
+			var exts cryptobyte.String
+			if !field.ReadASN1(&body, extensionsTag) || !body.ReadASN1(&exts, cryptobyte_asn1.SEQUENCE) {
+				b.SetError(errors.New("x509: malformed extensions"))
+				return
+			}
+			var kept [][]byte
+			for !exts.Empty() {
+				var ext, extBody cryptobyte.String
+				var oid asn1.ObjectIdentifier
+				if !exts.ReadASN1Element(&ext, cryptobyte_asn1.SEQUENCE) {
+					b.SetError(errors.New("x509: malformed extension"))
+					return
+				}
+				extBody = ext
+				if !extBody.ReadASN1(&extBody, cryptobyte_asn1.SEQUENCE) || !extBody.ReadASN1ObjectIdentifier(&oid) {
+					b.SetError(errors.New("x509: malformed extension"))
+					return
+				}
+				if !oid.Equal(oidExtensionSCTList) {
+					kept = append(kept, ext)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			b.AddASN1(extensionsTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for _, ext := range kept {
+						b.AddBytes(ext)
+					}
+				})
+			})
+		}
+	})
+	return b.Bytes()
+}
+
+// CTPolicy configures the Certificate Transparency requirements enforced by
+// [Certificate.Verify] through [VerifyOptions.CertificateTransparency], or
+// by [CTPolicy.Check].
+//
+// The SCTs embedded in the leaf certificate and the SCTs in SCTs are
+// considered. An SCT is valid if it was issued by one of Logs for the leaf
+// certificate, and its timestamp is not in the future.
+type CTPolicy struct {
+	// Logs contains the trusted logs.
+	Logs []*CTLog
+
+	// MinSCTs is the number of valid SCTs from distinct logs that the leaf
+	// certificate must have. If zero, one is required.
+	MinSCTs int
+
+	// SCTs contains TLS encoded SCTs for the leaf certificate delivered
+	// outside of it, such as the SCTs sent by a TLS peer.
+	SCTs [][]byte
+}
+
+// Check returns the valid SCTs for the leaf certificate of chain, which must
+// be a verified chain, as returned by [Certificate.Verify], at time now. If
+// now is zero, the current time is used.
+//
+// It returns an error if the SCTs are not issued by enough distinct logs, in
+// which case the returned SCTs are still valid.
+func (p *CTPolicy) Check(chain []*Certificate, now time.Time) ([]*SignedCertificateTimestamp, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("x509: empty chain")
+	}
+	if now.IsZero() {
+		now = time.Now()
+	}
+	leaf := chain[0]
+
+	logs := make(map[[32]byte]*CTLog)
+	for _, log := range p.Logs {
+		id, err := log.ID()
+		if err != nil {
+			return nil, err
+		}
+		logs[id] = log
+	}
+
+	var valid []*SignedCertificateTimestamp
+	seen := make(map[[32]byte]bool)
+	use := func(sct *SignedCertificateTimestamp, check func(*CTLog) error) {
+		log, ok := logs[sct.LogID]
+		if !ok || sct.Timestamp.After(now) || check(log) != nil {
+			return
+		}
+		valid = append(valid, sct)
+		seen[sct.LogID] = true
+	}
+
+	// Embedded SCTs can only be checked with the issuer of the leaf.
+	if len(chain) > 1 {
+		embedded, err := leaf.EmbeddedSCTs()
+		if err != nil {
+			return nil, err
+		}
+		for _, sct := range embedded {
+			use(sct, func(log *CTLog) error {
+				return sct.CheckEmbeddedSignature(log, leaf, chain[1])
+			})
+		}
+	}
+	for _, raw := range p.SCTs {
+		sct, err := ParseSignedCertificateTimestamp(raw)
+		if err != nil {
+			continue
+		}
+		use(sct, func(log *CTLog) error {
+			return sct.CheckSignature(log, leaf)
+		})
+	}
+
+	minSCTs := max(p.MinSCTs, 1)
+	if len(seen) < minSCTs {
+		return valid, CertificateInvalidError{
+			Cert:   leaf,
+			Reason: InsufficientSCTs,
+			Detail: "found " + strconv.Itoa(len(seen)) + ", need " + strconv.Itoa(minSCTs),
+		}
+	}
+	return valid, nil
+}
@@ -0,0 +1,365 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+type testCTLog struct {
+	*CTLog
+	key *ecdsa.PrivateKey
+}
+
+func newTestCTLog(t *testing.T, name string) testCTLog {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCTLog{&CTLog{PublicKey: &key.PublicKey, Description: name}, key}
+}
+
+// sign returns a TLS encoded SCT issued by l over the given log entry.
+func (l testCTLog) sign(t *testing.T, timestamp time.Time, entryType uint16, entry []byte) []byte {
+	t.Helper()
+	id, err := l.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signed cryptobyte.Builder
+	signed.AddUint8(0)
+	signed.AddUint8(0)
+	signed.AddUint64(uint64(timestamp.UnixMilli()))
+	signed.AddUint16(entryType)
+	signed.AddBytes(entry)
+	signed.AddUint16(0)
+	digest := sha256.Sum256(signed.BytesOrPanic())
+	sig, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint8(0)
+	b.AddBytes(id[:])
+	b.AddUint64(uint64(timestamp.UnixMilli()))
+	b.AddUint16(0)
+	b.AddUint8(4)
+	b.AddUint8(3)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sig)
+	})
+	return b.BytesOrPanic()
+}
+
+// signCert returns a TLS encoded SCT issued by l for cert, as delivered
+// outside of the certificate.
+func (l testCTLog) signCert(t *testing.T, timestamp time.Time, cert *Certificate) []byte {
+	var entry cryptobyte.Builder
+	entry.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(cert.Raw)
+	})
+	return l.sign(t, timestamp, ctEntryX509, entry.BytesOrPanic())
+}
+
+// signPrecert returns a TLS encoded SCT issued by l for the precertificate
+// with the given TBSCertificate, issued by issuer.
+func (l testCTLog) signPrecert(t *testing.T, timestamp time.Time, tbs []byte, issuer *Certificate) []byte {
+	var entry cryptobyte.Builder
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	entry.AddBytes(issuerKeyHash[:])
+	entry.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	return l.sign(t, timestamp, ctEntryPrecert, entry.BytesOrPanic())
+}
+
+// sctList returns a TLS encoded SignedCertificateTimestampList.
+func sctList(scts ...[]byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, sct := range scts {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(sct)
+			})
+		}
+	})
+	return b.BytesOrPanic()
+}
+
+func sctListExtension(scts ...[]byte) pkix.Extension {
+	var b cryptobyte.Builder
+	b.AddASN1OctetString(sctList(scts...))
+	return pkix.Extension{Id: oidExtensionSCTList, Value: b.BytesOrPanic()}
+}
+
+// certWithEmbeddedSCTs creates a leaf certificate issued by issuer, with
+// SCTs embedded by each of logs.
+func certWithEmbeddedSCTs(t *testing.T, issuer *Certificate, issuerKey crypto.Signer, timestamp time.Time, logs ...testCTLog) *Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber: bigFromString("1234"),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     KeyUsageDigitalSignature,
+		ExtKeyUsage:  []ExtKeyUsage{ExtKeyUsageServerAuth},
+	}
+	// The TBSCertificate of the precertificate is the one of the final
+	// certificate without the SCT list extension.
+	der, err := CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	precert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scts [][]byte
+	for _, l := range logs {
+		scts = append(scts, l.signPrecert(t, timestamp, precert.RawTBSCertificate, issuer))
+	}
+	template.ExtraExtensions = []pkix.Extension{sctListExtension(scts...)}
+	der, err = CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tbs, err := removeSCTList(cert.RawTBSCertificate); err != nil || !bytes.Equal(tbs, precert.RawTBSCertificate) {
+		t.Fatalf("removeSCTList did not recover the precertificate TBSCertificate: %v", err)
+	}
+	return cert
+}
+
+func TestParseSignedCertificateTimestamp(t *testing.T) {
+	log := newTestCTLog(t, "log")
+	ca, _, err := generateCert("CA", true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := time.UnixMilli(time.Now().UnixMilli())
+	raw := log.signCert(t, timestamp, ca)
+
+	sct, err := ParseSignedCertificateTimestamp(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := log.ID()
+	if !bytes.Equal(sct.Raw, raw) || sct.LogID != id || !sct.Timestamp.Equal(timestamp) ||
+		sct.SignatureAlgorithm != ECDSAWithSHA256 || len(sct.Extensions) != 0 {
+		t.Errorf("unexpected SCT: %+v", sct)
+	}
+	if err := sct.CheckSignature(log.CTLog, ca); err != nil {
+		t.Errorf("CheckSignature: %v", err)
+	}
+	if err := sct.CheckSignature(newTestCTLog(t, "other").CTLog, ca); err == nil {
+		t.Error("CheckSignature succeeded with the wrong log")
+	}
+
+	list, err := ParseSignedCertificateTimestampList(sctList(raw, raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || !bytes.Equal(list[1].Raw, raw) {
+		t.Errorf("unexpected SCT list: %v", list)
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"truncated": raw[:len(raw)-1],
+		"trailing":  append(raw[:len(raw):len(raw)], 0),
+		"version":   append([]byte{1}, raw[1:]...),
+	} {
+		if _, err := ParseSignedCertificateTimestamp(data); err == nil {
+			t.Errorf("%s: ParseSignedCertificateTimestamp succeeded", name)
+		}
+	}
+	if _, err := ParseSignedCertificateTimestampList([]byte{0, 5, 0, 1}); err == nil {
+		t.Error("ParseSignedCertificateTimestampList succeeded on a truncated list")
+	}
+}
+
+// TestEmbeddedSCTsFixture checks SCTs embedded in a certificate issued by
+// OpenSSL, and produced independently of this package. See testdata/ct/README.
+func TestEmbeddedSCTsFixture(t *testing.T) {
+	ca := loadTestCert(t, "ct", "ca")
+	leaf := loadTestCert(t, "ct", "leaf")
+	var logs []*CTLog
+	for _, name := range []string{"log1", "log2"} {
+		data, err := os.ReadFile(filepath.Join("testdata", "ct", name+".pub"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			t.Fatalf("%s.pub: no public key", name)
+		}
+		pub, err := ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs = append(logs, &CTLog{PublicKey: pub, Description: name})
+	}
+
+	scts, err := leaf.EmbeddedSCTs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scts) != 2 {
+		t.Fatalf("got %d SCTs, want 2", len(scts))
+	}
+	timestamp := time.Date(2024, time.October, 1, 0, 0, 1, 234e6, time.UTC)
+	for i, sct := range scts {
+		if !sct.Timestamp.Equal(timestamp.Add(time.Duration(i) * time.Second)) {
+			t.Errorf("SCT %d: Timestamp = %v", i, sct.Timestamp)
+		}
+		if sct.SignatureAlgorithm != ECDSAWithSHA256 || len(sct.Extensions) != 0 {
+			t.Errorf("unexpected SCT %d: %+v", i, sct)
+		}
+		if err := sct.CheckEmbeddedSignature(logs[i], leaf, ca); err != nil {
+			t.Errorf("SCT %d: CheckEmbeddedSignature: %v", i, err)
+		}
+		if err := sct.CheckEmbeddedSignature(logs[1-i], leaf, ca); err == nil {
+			t.Errorf("SCT %d: CheckEmbeddedSignature succeeded with the wrong log", i)
+		}
+		if err := sct.CheckEmbeddedSignature(logs[i], leaf, leaf); err == nil {
+			t.Errorf("SCT %d: CheckEmbeddedSignature succeeded with the wrong issuer", i)
+		}
+		if err := sct.CheckSignature(logs[i], leaf); err == nil {
+			t.Errorf("SCT %d: CheckSignature succeeded for an embedded SCT", i)
+		}
+	}
+
+	policy := &CTPolicy{Logs: logs, MinSCTs: 2}
+	if valid, err := policy.Check([]*Certificate{leaf, ca}, timestamp.Add(time.Hour)); err != nil || len(valid) != 2 {
+		t.Errorf("CTPolicy.Check: %d valid SCTs, %v", len(valid), err)
+	}
+}
+
+func TestVerifyCertificateTransparency(t *testing.T) {
+	now := time.Now()
+	ca, caKey, err := generateCert("CA", true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCA, _, err := generateCert("Other CA", true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logA := newTestCTLog(t, "A")
+	logB := newTestCTLog(t, "B")
+	logC := newTestCTLog(t, "C")
+	untrusted := newTestCTLog(t, "untrusted")
+
+	leaf := certWithEmbeddedSCTs(t, ca, caKey, now.Add(-time.Minute), logA, logB, untrusted)
+	future := certWithEmbeddedSCTs(t, ca, caKey, now.Add(time.Hour), logA, logB)
+
+	embedded, err := leaf.EmbeddedSCTs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embedded) != 3 {
+		t.Fatalf("got %d embedded SCTs, want 3", len(embedded))
+	}
+	if err := embedded[0].CheckEmbeddedSignature(logA.CTLog, leaf, ca); err != nil {
+		t.Errorf("CheckEmbeddedSignature: %v", err)
+	}
+	if err := embedded[0].CheckEmbeddedSignature(logA.CTLog, leaf, otherCA); err == nil {
+		t.Error("CheckEmbeddedSignature succeeded with the wrong issuer")
+	}
+	if err := embedded[0].CheckSignature(logA.CTLog, leaf); err == nil {
+		t.Error("CheckSignature succeeded for an embedded SCT")
+	}
+
+	tlsSCT := logC.signCert(t, now.Add(-time.Minute), leaf)
+	duplicateSCT := logA.signCert(t, now.Add(-time.Minute), leaf)
+	trustedLogs := []*CTLog{logA.CTLog, logB.CTLog, logC.CTLog}
+
+	for _, tt := range []struct {
+		name    string
+		cert    *Certificate
+		policy  *CTPolicy
+		wantErr bool
+	}{
+		{"default", leaf, &CTPolicy{Logs: trustedLogs}, false},
+		{"two embedded", leaf, &CTPolicy{Logs: trustedLogs, MinSCTs: 2}, false},
+		{"untrusted log", leaf, &CTPolicy{Logs: trustedLogs, MinSCTs: 3}, true},
+		{"tls extension", leaf, &CTPolicy{Logs: trustedLogs, MinSCTs: 3, SCTs: [][]byte{tlsSCT}}, false},
+		{"same log", leaf, &CTPolicy{Logs: trustedLogs, MinSCTs: 3, SCTs: [][]byte{duplicateSCT}}, true},
+		{"malformed sct", leaf, &CTPolicy{Logs: trustedLogs, MinSCTs: 3, SCTs: [][]byte{{0}}}, true},
+		{"no logs", leaf, &CTPolicy{}, true},
+		{"future timestamp", future, &CTPolicy{Logs: trustedLogs}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			roots := NewCertPool()
+			roots.AddCert(ca)
+			opts := VerifyOptions{
+				Roots:                   roots,
+				CurrentTime:             now,
+				DNSName:                 "example.com",
+				CertificateTransparency: tt.policy,
+			}
+			_, err := tt.cert.Verify(opts)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Verify failed: %v", err)
+				}
+				return
+			}
+			var invalid CertificateInvalidError
+			if !errors.As(err, &invalid) || invalid.Reason != InsufficientSCTs {
+				t.Fatalf("Verify error = %v, want InsufficientSCTs", err)
+			}
+			if !strings.Contains(err.Error(), "not enough valid signed certificate timestamps") {
+				t.Errorf("unexpected error message: %v", err)
+			}
+		})
+	}
+
+	// The policy is enforced together with revocation checking.
+	roots := NewCertPool()
+	roots.AddCert(ca)
+	_, err = leaf.Verify(VerifyOptions{
+		Roots:                   roots,
+		CurrentTime:             now,
+		Revocation:              &RevocationOptions{},
+		CertificateTransparency: &CTPolicy{Logs: trustedLogs, MinSCTs: 3},
+	})
+	if err == nil {
+		t.Error("Verify with revocation checking ignored the Certificate Transparency policy")
+	}
+
+	valid, err := (&CTPolicy{Logs: trustedLogs, SCTs: [][]byte{tlsSCT}}).Check([]*Certificate{leaf, ca}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(valid) != 3 {
+		t.Errorf("Check returned %d valid SCTs, want 3", len(valid))
+	}
+}
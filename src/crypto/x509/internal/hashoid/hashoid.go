@@ -0,0 +1,38 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hashoid maps between hash functions and the object identifiers
+// of the hash algorithms in OCSP requests and responses. It is shared by
+// crypto/x509 and crypto/x509/ocsp.
+package hashoid
+
+import (
+	"crypto"
+	"encoding/asn1"
+)
+
+var oids = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+	crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+	crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+}
+
+// OID returns the object identifier of h, and reports whether h
+// is one of SHA-1, SHA-256, SHA-384, and SHA-512.
+func OID(h crypto.Hash) (asn1.ObjectIdentifier, bool) {
+	oid, ok := oids[h]
+	return oid, ok
+}
+
+// Hash returns the hash function identified by oid,
+// or zero if it is not one of those supported by OID.
+func Hash(oid asn1.ObjectIdentifier) crypto.Hash {
+	for h, o := range oids {
+		if o.Equal(oid) {
+			return h
+		}
+	}
+	return 0
+}
@@ -0,0 +1,478 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509/internal/hashoid"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// An OCSPResponse is a successful OCSP response of the basic response type,
+// as specified in RFC 6960, Section 4.2.1.
+type OCSPResponse struct {
+	// Raw contains the complete ASN.1 DER content of the response.
+	Raw []byte
+	// RawResponseData contains just the tbsResponseData portion of the ASN.1
+	// DER, which is covered by the signature.
+	RawResponseData []byte
+
+	// RawResponderName contains the DER encoded name of the responder, if it
+	// is identified by name. Otherwise, ResponderKeyHash contains the SHA-1
+	// hash of the responder's public key.
+	RawResponderName []byte
+	ResponderKeyHash []byte
+
+	// ProducedAt is the time at which the response was signed.
+	ProducedAt time.Time
+
+	// Responses contains the status of each certificate covered by the
+	// response.
+	Responses []OCSPSingleResponse
+
+	Signature          []byte
+	SignatureAlgorithm SignatureAlgorithm
+
+	// Certificates contains the certificates included in the response to
+	// help verify its signature, such as the certificate of a delegated
+	// responder.
+	Certificates []*Certificate
+
+	// Extensions contains the raw response extensions.
+	Extensions []pkix.Extension
+}
+
+// An OCSPSingleResponse is the status of a single certificate in an
+// [OCSPResponse].
+type OCSPSingleResponse struct {
+	// HashAlgorithm is the hash function used to compute IssuerNameHash and
+	// IssuerKeyHash. It is zero if the hash function is not supported.
+	HashAlgorithm crypto.Hash
+	// IssuerNameHash and IssuerKeyHash are the hashes of the DER encoded
+	// subject and of the public key of the certificate's issuer.
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+
+	// Status is the status of the certificate.
+	Status RevocationStatus
+	// RevocationTime and ReasonCode are set if Status is
+	// RevocationStatusRevoked. ReasonCode is one of the CRL reason codes of
+	// RFC 5280, Section 5.3.1, or zero if it was omitted.
+	RevocationTime time.Time
+	ReasonCode     int
+
+	// ThisUpdate is the time at which the status was known to be correct,
+	// and NextUpdate, if not zero, the time at or before which newer
+	// information will be available.
+	ThisUpdate time.Time
+	NextUpdate time.Time
+
+	// Extensions contains the raw single response extensions.
+	Extensions []pkix.Extension
+}
+
+var oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+var ocspResponseStatusNames = map[int]string{
+	1: "malformedRequest",
+	2: "internalError",
+	3: "tryLater",
+	5: "sigRequired",
+	6: "unauthorized",
+}
+
+// ParseOCSPResponse parses an OCSP response from the given ASN.1 DER data.
+// Only successful responses of the basic response type are supported,
+// ParseOCSPResponse returns an error for any other response.
+//
+// The signature of the response is not checked, see
+// [OCSPResponse.CheckSignatureFrom].
+func ParseOCSPResponse(der []byte) (*OCSPResponse, error) {
+	resp := &OCSPResponse{}
+
+	// OCSPResponse ::= SEQUENCE {
+	//     responseStatus          OCSPResponseStatus,
+	//     responseBytes       [0] EXPLICIT ResponseBytes OPTIONAL }
+	input := cryptobyte.String(der)
+	if !input.ReadASN1Element(&input, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed ocsp response")
+	}
+	resp.Raw = input
+	if !input.ReadASN1(&input, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed ocsp response")
+	}
+	var status int
+	if !input.ReadASN1Enum(&status) {
+		return nil, errors.New("x509: malformed ocsp response status")
+	}
+	if status != 0 {
+		if name, ok := ocspResponseStatusNames[status]; ok {
+			return nil, fmt.Errorf("x509: ocsp responder returned error status %s", name)
+		}
+		return nil, fmt.Errorf("x509: ocsp responder returned unknown status %d", status)
+	}
+
+	// ResponseBytes ::= SEQUENCE {
+	//     responseType   OBJECT IDENTIFIER,
+	//     response       OCTET STRING }
+	var responseBytes cryptobyte.String
+	if !input.ReadASN1(&responseBytes, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		!responseBytes.ReadASN1(&responseBytes, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed ocsp response bytes")
+	}
+	var responseType asn1.ObjectIdentifier
+	if !responseBytes.ReadASN1ObjectIdentifier(&responseType) {
+		return nil, errors.New("x509: malformed ocsp response type")
+	}
+	if !responseType.Equal(oidOCSPBasicResponse) {
+		return nil, fmt.Errorf("x509: unsupported ocsp response type %s", responseType)
+	}
+
+	// BasicOCSPResponse ::= SEQUENCE {
+	//     tbsResponseData      ResponseData,
+	//     signatureAlgorithm   AlgorithmIdentifier,
+	//     signature            BIT STRING,
+	//     certs            [0] EXPLICIT SEQUENCE OF Certificate OPTIONAL }
+	var basic cryptobyte.String
+	if !responseBytes.ReadASN1(&basic, cryptobyte_asn1.OCTET_STRING) ||
+		!basic.ReadASN1(&basic, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed basic ocsp response")
+	}
+	var tbs cryptobyte.String
+	if !basic.ReadASN1Element(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed ocsp response data")
+	}
+	resp.RawResponseData = tbs
+	if !tbs.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed ocsp response data")
+	}
+
+	var sigAISeq cryptobyte.String
+	if !basic.ReadASN1(&sigAISeq, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed signature algorithm identifier")
+	}
+	sigAI, err := parseAI(sigAISeq)
+	if err != nil {
+		return nil, err
+	}
+	resp.SignatureAlgorithm = getSignatureAlgorithmFromAI(sigAI)
+
+	var signature asn1.BitString
+	if !basic.ReadASN1BitString(&signature) {
+		return nil, errors.New("x509: malformed signature")
+	}
+	resp.Signature = signature.RightAlign()
+
+	var certs cryptobyte.String
+	var present bool
+	if !basic.ReadOptionalASN1(&certs, &present, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errors.New("x509: malformed ocsp response certificates")
+	}
+	if present {
+		if !certs.ReadASN1(&certs, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed ocsp response certificates")
+		}
+		for !certs.Empty() {
+			var certDER cryptobyte.String
+			if !certs.ReadASN1Element(&certDER, cryptobyte_asn1.SEQUENCE) {
+				return nil, errors.New("x509: malformed ocsp response certificates")
+			}
+			cert, err := parseCertificate(certDER)
+			if err != nil {
+				return nil, err
+			}
+			resp.Certificates = append(resp.Certificates, cert)
+		}
+	}
+
+	// ResponseData ::= SEQUENCE {
+	//     version              [0] EXPLICIT Version DEFAULT v1,
+	//     responderID              ResponderID,
+	//     producedAt               GeneralizedTime,
+	//     responses                SEQUENCE OF SingleResponse,
+	//     responseExtensions   [1] EXPLICIT Extensions OPTIONAL }
+	//
+	// ResponderID ::= CHOICE {
+	//     byName               [1] Name,
+	//     byKey                [2] KeyHash }
+	var version cryptobyte.String
+	if !tbs.ReadOptionalASN1(&version, &present, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errors.New("x509: malformed ocsp response version")
+	}
+	if present {
+		var v int
+		if !version.ReadASN1Integer(&v) {
+			return nil, errors.New("x509: malformed ocsp response version")
+		}
+		if v != 0 {
+			return nil, fmt.Errorf("x509: unsupported ocsp response version: %d", v)
+		}
+	}
+
+	var responderID cryptobyte.String
+	switch {
+	case tbs.PeekASN1Tag(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific()):
+		var name cryptobyte.String
+		if !tbs.ReadASN1(&responderID, cryptobyte_asn1.Tag(1).Constructed().ContextSpecific()) ||
+			!responderID.ReadASN1Element(&name, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed ocsp responder id")
+		}
+		resp.RawResponderName = name
+	case tbs.PeekASN1Tag(cryptobyte_asn1.Tag(2).Constructed().ContextSpecific()):
+		var keyHash cryptobyte.String
+		if !tbs.ReadASN1(&responderID, cryptobyte_asn1.Tag(2).Constructed().ContextSpecific()) ||
+			!responderID.ReadASN1(&keyHash, cryptobyte_asn1.OCTET_STRING) {
+			return nil, errors.New("x509: malformed ocsp responder id")
+		}
+		resp.ResponderKeyHash = keyHash
+	default:
+		return nil, errors.New("x509: malformed ocsp responder id")
+	}
+
+	resp.ProducedAt, err = parseTime(&tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses cryptobyte.String
+	if !tbs.ReadASN1(&responses, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed ocsp responses")
+	}
+	for !responses.Empty() {
+		var single cryptobyte.String
+		if !responses.ReadASN1(&single, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed ocsp single response")
+		}
+		sr, err := parseOCSPSingleResponse(single)
+		if err != nil {
+			return nil, err
+		}
+		resp.Responses = append(resp.Responses, sr)
+	}
+
+	resp.Extensions, err = parseOCSPExtensions(&tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func parseOCSPSingleResponse(der cryptobyte.String) (OCSPSingleResponse, error) {
+	var sr OCSPSingleResponse
+
+	// SingleResponse ::= SEQUENCE {
+	//     certID                   CertID,
+	//     certStatus               CertStatus,
+	//     thisUpdate               GeneralizedTime,
+	//     nextUpdate           [0] EXPLICIT GeneralizedTime OPTIONAL,
+	//     singleExtensions     [1] EXPLICIT Extensions OPTIONAL }
+	//
+	// CertID ::= SEQUENCE {
+	//     hashAlgorithm            AlgorithmIdentifier,
+	//     issuerNameHash           OCTET STRING,
+	//     issuerKeyHash            OCTET STRING,
+	//     serialNumber             CertificateSerialNumber }
+	var certID, hashAISeq, nameHash, keyHash cryptobyte.String
+	sr.SerialNumber = new(big.Int)
+	if !der.ReadASN1(&certID, cryptobyte_asn1.SEQUENCE) ||
+		!certID.ReadASN1(&hashAISeq, cryptobyte_asn1.SEQUENCE) ||
+		!certID.ReadASN1(&nameHash, cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1(&keyHash, cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1Integer(sr.SerialNumber) {
+		return sr, errors.New("x509: malformed ocsp cert id")
+	}
+	hashAI, err := parseAI(hashAISeq)
+	if err != nil {
+		return sr, err
+	}
+	sr.HashAlgorithm = hashoid.Hash(hashAI.Algorithm)
+	sr.IssuerNameHash = nameHash
+	sr.IssuerKeyHash = keyHash
+
+	// CertStatus ::= CHOICE {
+	//     good                 [0] IMPLICIT NULL,
+	//     revoked              [1] IMPLICIT RevokedInfo,
+	//     unknown              [2] IMPLICIT UnknownInfo }
+	//
+	// RevokedInfo ::= SEQUENCE {
+	//     revocationTime           GeneralizedTime,
+	//     revocationReason     [0] EXPLICIT CRLReason OPTIONAL }
+	var certStatus cryptobyte.String
+	var statusTag cryptobyte_asn1.Tag
+	if !der.ReadAnyASN1(&certStatus, &statusTag) {
+		return sr, errors.New("x509: malformed ocsp cert status")
+	}
+	switch statusTag {
+	case cryptobyte_asn1.Tag(0).ContextSpecific():
+		sr.Status = RevocationStatusGood
+	case cryptobyte_asn1.Tag(1).Constructed().ContextSpecific():
+		sr.Status = RevocationStatusRevoked
+		sr.RevocationTime, err = parseTime(&certStatus)
+		if err != nil {
+			return sr, err
+		}
+		var reason cryptobyte.String
+		var present bool
+		if !certStatus.ReadOptionalASN1(&reason, &present, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+			return sr, errors.New("x509: malformed ocsp revocation reason")
+		}
+		if present && !reason.ReadASN1Enum(&sr.ReasonCode) {
+			return sr, errors.New("x509: malformed ocsp revocation reason")
+		}
+	case cryptobyte_asn1.Tag(2).ContextSpecific():
+		sr.Status = RevocationStatusUnknown
+	default:
+		return sr, errors.New("x509: malformed ocsp cert status")
+	}
+
+	sr.ThisUpdate, err = parseTime(&der)
+	if err != nil {
+		return sr, err
+	}
+	var nextUpdate cryptobyte.String
+	var present bool
+	if !der.ReadOptionalASN1(&nextUpdate, &present, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return sr, errors.New("x509: malformed ocsp next update")
+	}
+	if present {
+		sr.NextUpdate, err = parseTime(&nextUpdate)
+		if err != nil {
+			return sr, err
+		}
+	}
+
+	sr.Extensions, err = parseOCSPExtensions(&der)
+	if err != nil {
+		return sr, err
+	}
+
+	return sr, nil
+}
+
+// parseOCSPExtensions parses the optional [1] EXPLICIT Extensions field that
+// ends both the response data and single responses.
+func parseOCSPExtensions(der *cryptobyte.String) ([]pkix.Extension, error) {
+	var extensions cryptobyte.String
+	var present bool
+	if !der.ReadOptionalASN1(&extensions, &present, cryptobyte_asn1.Tag(1).Constructed().ContextSpecific()) {
+		return nil, errors.New("x509: malformed extensions")
+	}
+	if !present {
+		return nil, nil
+	}
+	if !extensions.ReadASN1(&extensions, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("x509: malformed extensions")
+	}
+	var exts []pkix.Extension
+	for !extensions.Empty() {
+		var extension cryptobyte.String
+		if !extensions.ReadASN1(&extension, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("x509: malformed extension")
+		}
+		ext, err := parseExtension(extension)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// Matches reports whether r is the status of cert, issued by issuer.
+func (r *OCSPSingleResponse) Matches(cert, issuer *Certificate) bool {
+	if r.HashAlgorithm == 0 || r.SerialNumber == nil || r.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		return false
+	}
+	publicKey, err := subjectPublicKeyBits(issuer)
+	if err != nil {
+		return false
+	}
+	h := r.HashAlgorithm.New()
+	h.Write(issuer.RawSubject)
+	if !bytes.Equal(h.Sum(nil), r.IssuerNameHash) {
+		return false
+	}
+	h.Reset()
+	h.Write(publicKey)
+	return bytes.Equal(h.Sum(nil), r.IssuerKeyHash)
+}
+
+// subjectPublicKeyBits returns the contents of the subjectPublicKey BIT
+// STRING of cert, which is what OCSP key hashes are computed over.
+func subjectPublicKeyBits(cert *Certificate) ([]byte, error) {
+	spki := cryptobyte.String(cert.RawSubjectPublicKeyInfo)
+	var key asn1.BitString
+	if !spki.ReadASN1(&spki, cryptobyte_asn1.SEQUENCE) ||
+		!spki.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!spki.ReadASN1BitString(&key) {
+		return nil, errors.New("x509: malformed subject public key info")
+	}
+	return key.RightAlign(), nil
+}
+
+// CheckSignatureFrom verifies that the signature on r is valid from issuer,
+// the issuer of the certificates whose status r reports.
+//
+// The response may be signed by issuer itself, or by a delegated responder
+// whose certificate is included in the response, is signed by issuer, and
+// has the OCSP signing extended key usage. The validity period of the
+// delegated responder certificate is not checked.
+func (r *OCSPResponse) CheckSignatureFrom(issuer *Certificate) error {
+	_, err := r.signer(issuer)
+	return err
+}
+
+// signer returns the certificate that signed r on behalf of issuer.
+func (r *OCSPResponse) signer(issuer *Certificate) (*Certificate, error) {
+	if issuer.PublicKeyAlgorithm == UnknownPublicKeyAlgorithm {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	err := issuer.CheckSignature(r.SignatureAlgorithm, r.RawResponseData, r.Signature)
+	if err == nil {
+		return issuer, nil
+	}
+	for _, cert := range r.Certificates {
+		if !r.identifiesResponder(cert) {
+			continue
+		}
+		if !slices.Contains(cert.ExtKeyUsage, ExtKeyUsageOCSPSigning) {
+			return nil, errors.New("x509: ocsp responder certificate is not authorized for ocsp signing")
+		}
+		if err := cert.CheckSignatureFrom(issuer); err != nil {
+			return nil, err
+		}
+		if err := cert.CheckSignature(r.SignatureAlgorithm, r.RawResponseData, r.Signature); err != nil {
+			return nil, err
+		}
+		return cert, nil
+	}
+	return nil, err
+}
+
+// identifiesResponder reports whether cert matches the responder ID of r.
+func (r *OCSPResponse) identifiesResponder(cert *Certificate) bool {
+	if r.RawResponderName != nil {
+		return bytes.Equal(r.RawResponderName, cert.RawSubject)
+	}
+	publicKey, err := subjectPublicKeyBits(cert)
+	if err != nil {
+		return false
+	}
+	keyHash := sha1.Sum(publicKey)
+	return bytes.Equal(keyHash[:], r.ResponderKeyHash)
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ocsp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxResponseSize is the maximum size of an OCSP response read by [Fetch].
+const maxResponseSize = 1 << 20
+
+// Fetch requests the status of cert, which was issued by issuer, from the
+// OCSP responder at the URL server, using an HTTP POST request, as specified
+// in RFC 6960, Appendix A.1. If client is nil, [http.DefaultClient] is used.
+//
+// It returns the DER encoded response, after checking that it is signed by
+// issuer or by a delegated responder of issuer and that it covers cert.
+//
+// Fetch can be used to fetch responses while verifying certificates with
+// [x509.Certificate.VerifyContext]:
+//
+//	opts.Revocation = &x509.RevocationOptions{
+//		FetchOCSP: func(ctx context.Context, server string, cert, issuer *x509.Certificate) ([]byte, error) {
+//			return ocsp.Fetch(ctx, nil, server, cert, issuer)
+//		},
+//	}
+func Fetch(ctx context.Context, client *http.Client, server string, cert, issuer *x509.Certificate) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	reqDER, err := CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", server, bytes.NewReader(reqDER))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ocsp: unexpected HTTP status from responder: %s", resp.Status)
+	}
+	der, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(der) > maxResponseSize {
+		return nil, errors.New("ocsp: response too large")
+	}
+	if _, err := ParseResponseForCert(der, cert, issuer); err != nil {
+		return nil, err
+	}
+	return der, nil
+}
+
+// A Responder is a minimal OCSP responder for the certificates issued by a
+// single CA, such as a test CA. It serves requests sent with HTTP GET or
+// POST, as specified in RFC 6960, Appendix A.1.
+//
+// Certificates are considered good unless they have been revoked with
+// [Responder.Revoke]. A Responder does not keep track of the certificates
+// issued by the CA, so it never answers that a certificate is unknown.
+//
+// A Responder must not be copied after first use. Its exported fields must
+// not be modified while it serves requests.
+type Responder struct {
+	// Issuer is the CA whose certificates the Responder covers.
+	Issuer *x509.Certificate
+
+	// Certificate is the delegated responder certificate that signs
+	// responses. If nil, responses are signed by Issuer.
+	Certificate *x509.Certificate
+
+	// Key is the private key of Certificate, or of Issuer if Certificate is
+	// nil.
+	Key crypto.Signer
+
+	// Validity is the period between the thisUpdate and nextUpdate times of
+	// the responses. If zero, one hour is used.
+	Validity time.Duration
+
+	// Time returns the current time. If nil, time.Now is used.
+	Time func() time.Time
+
+	mu      sync.Mutex
+	revoked map[string]revocation
+}
+
+type revocation struct {
+	time       time.Time
+	reasonCode int
+}
+
+// Revoke marks the certificate with the given serial number as revoked at
+// the current time, for the given reason code, as defined in RFC 5280,
+// Section 5.3.1.
+func (r *Responder) Revoke(serial *big.Int, reasonCode int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.revoked == nil {
+		r.revoked = make(map[string]revocation)
+	}
+	r.revoked[serial.String()] = revocation{r.now(), reasonCode}
+}
+
+func (r *Responder) now() time.Time {
+	if r.Time != nil {
+		return r.Time()
+	}
+	return time.Now()
+}
+
+// ServeHTTP implements [http.Handler].
+func (r *Responder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var reqDER []byte
+	switch req.Method {
+	case "GET":
+		// The request is the last path segment, which may contain slashes
+		// of the base64 encoding.
+		s := strings.TrimPrefix(req.URL.Path, "/")
+		der, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			r.writeResponse(w, errorResponse(statusMalformedRequest))
+			return
+		}
+		reqDER = der
+	case "POST":
+		der, err := io.ReadAll(io.LimitReader(req.Body, maxResponseSize))
+		if err != nil {
+			r.writeResponse(w, errorResponse(statusMalformedRequest))
+			return
+		}
+		reqDER = der
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.writeResponse(w, r.respond(reqDER))
+}
+
+func (r *Responder) writeResponse(w http.ResponseWriter, der []byte) {
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(der)
+}
+
+// respond returns the DER encoded response to the given request.
+func (r *Responder) respond(reqDER []byte) []byte {
+	ocspReq, err := ParseRequest(reqDER)
+	if err != nil {
+		return errorResponse(statusMalformedRequest)
+	}
+	if !ocspReq.MatchesIssuer(r.Issuer) {
+		return errorResponse(statusUnauthorized)
+	}
+
+	now := r.now()
+	validity := r.Validity
+	if validity == 0 {
+		validity = time.Hour
+	}
+	single := x509.OCSPSingleResponse{
+		HashAlgorithm: ocspReq.HashAlgorithm,
+		SerialNumber:  ocspReq.SerialNumber,
+		Status:        x509.RevocationStatusGood,
+		ThisUpdate:    now,
+		NextUpdate:    now.Add(validity),
+	}
+	r.mu.Lock()
+	if rev, ok := r.revoked[ocspReq.SerialNumber.String()]; ok {
+		single.Status = x509.RevocationStatusRevoked
+		single.RevocationTime = rev.time
+		single.ReasonCode = rev.reasonCode
+	}
+	r.mu.Unlock()
+
+	responder := r.Certificate
+	if responder == nil {
+		responder = r.Issuer
+	}
+	der, err := CreateResponse(rand.Reader, &ResponseTemplate{
+		Responses:  []x509.OCSPSingleResponse{single},
+		ProducedAt: now,
+	}, r.Issuer, responder, r.Key)
+	if err != nil {
+		return errorResponse(statusInternalError)
+	}
+	return der
+}
@@ -0,0 +1,496 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ocsp implements the Online Certificate Status Protocol, as specified
+// in RFC 6960.
+//
+// OCSP responses are parsed by [crypto/x509.ParseOCSPResponse], and can be
+// used to check the revocation status of certificates with
+// [crypto/x509.VerifyOptions.Revocation]. This package creates and parses
+// OCSP requests, creates signed OCSP responses, and fetches responses from
+// responders over HTTP.
+//
+// It also provides a [Stapler], which keeps the OCSP responses stapled by a
+// [crypto/tls] server fresh, and a [Responder], a minimal responder for the
+// certificates of a test CA.
+package ocsp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/internal/hashoid"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+var (
+	oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+	oidSignatureSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSignatureECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSignatureECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidSignatureECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidSignatureEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// Response status values other than successful, as specified in RFC 6960,
+// Section 4.2.1.
+const (
+	statusMalformedRequest = 1
+	statusInternalError    = 2
+	statusUnauthorized     = 6
+)
+
+// errorResponse returns an unsuccessful OCSP response with the given status.
+func errorResponse(status int) []byte {
+	return []byte{0x30, 0x03, 0x0a, 0x01, byte(status)}
+}
+
+// A Request is an OCSP request for the status of a single certificate, as
+// specified in RFC 6960, Section 4.1.1.
+type Request struct {
+	// HashAlgorithm is the hash function used to compute IssuerNameHash and
+	// IssuerKeyHash. It is zero if the hash function is not supported.
+	HashAlgorithm crypto.Hash
+	// IssuerNameHash and IssuerKeyHash are the hashes of the DER encoded
+	// subject and of the public key of the certificate's issuer.
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+// RequestOptions contains options for [CreateRequest].
+type RequestOptions struct {
+	// Hash is the hash function used to identify the issuer of the
+	// certificate. If zero, SHA-1 is used, as it is the only hash function
+	// that all responders are required to support.
+	Hash crypto.Hash
+}
+
+// CreateRequest returns a DER encoded OCSP request for the status of cert,
+// which was issued by issuer. opts may be nil.
+func CreateRequest(cert, issuer *x509.Certificate, opts *RequestOptions) ([]byte, error) {
+	h := crypto.SHA1
+	if opts != nil && opts.Hash != 0 {
+		h = opts.Hash
+	}
+	nameHash, keyHash, err := issuerHashes(issuer, h)
+	if err != nil {
+		return nil, err
+	}
+
+	// OCSPRequest ::= SEQUENCE {
+	//     tbsRequest               TBSRequest,
+	//     optionalSignature    [0] EXPLICIT Signature OPTIONAL }
+	//
+	// TBSRequest ::= SEQUENCE {
+	//     version              [0] EXPLICIT Version DEFAULT v1,
+	//     requestorName        [1] EXPLICIT GeneralName OPTIONAL,
+	//     requestList              SEQUENCE OF Request,
+	//     requestExtensions    [2] EXPLICIT Extensions OPTIONAL }
+	//
+	// Request ::= SEQUENCE {
+	//     reqCert                  CertID,
+	//     singleRequestExtensions  [0] EXPLICIT Extensions OPTIONAL }
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					addCertID(b, h, nameHash, keyHash, cert.SerialNumber)
+				})
+			})
+		})
+	})
+	return b.Bytes()
+}
+
+// ParseRequest parses an OCSP request from the given ASN.1 DER data. Only
+// requests for the status of a single certificate are supported.
+//
+// Request signatures and extensions are ignored.
+func ParseRequest(der []byte) (*Request, error) {
+	input := cryptobyte.String(der)
+	var tbs cryptobyte.String
+	if !input.ReadASN1(&input, cryptobyte_asn1.SEQUENCE) ||
+		!input.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("ocsp: malformed request")
+	}
+	var version cryptobyte.String
+	var present bool
+	if !tbs.ReadOptionalASN1(&version, &present, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errors.New("ocsp: malformed request version")
+	}
+	if present {
+		var v int
+		if !version.ReadASN1Integer(&v) {
+			return nil, errors.New("ocsp: malformed request version")
+		}
+		if v != 0 {
+			return nil, fmt.Errorf("ocsp: unsupported request version: %d", v)
+		}
+	}
+	if !tbs.SkipOptionalASN1(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific()) {
+		return nil, errors.New("ocsp: malformed requestor name")
+	}
+	var requestList, request cryptobyte.String
+	if !tbs.ReadASN1(&requestList, cryptobyte_asn1.SEQUENCE) ||
+		!requestList.ReadASN1(&request, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("ocsp: malformed request list")
+	}
+	if !requestList.Empty() {
+		return nil, errors.New("ocsp: requests for multiple certificates are not supported")
+	}
+
+	// CertID ::= SEQUENCE {
+	//     hashAlgorithm            AlgorithmIdentifier,
+	//     issuerNameHash           OCTET STRING,
+	//     issuerKeyHash            OCTET STRING,
+	//     serialNumber             CertificateSerialNumber }
+	req := &Request{SerialNumber: new(big.Int)}
+	var certID, hashAI, nameHash, keyHash cryptobyte.String
+	var hashOID asn1.ObjectIdentifier
+	if !request.ReadASN1(&certID, cryptobyte_asn1.SEQUENCE) ||
+		!certID.ReadASN1(&hashAI, cryptobyte_asn1.SEQUENCE) ||
+		!hashAI.ReadASN1ObjectIdentifier(&hashOID) ||
+		!certID.ReadASN1(&nameHash, cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1(&keyHash, cryptobyte_asn1.OCTET_STRING) ||
+		!certID.ReadASN1Integer(req.SerialNumber) {
+		return nil, errors.New("ocsp: malformed cert id")
+	}
+	req.HashAlgorithm = hashoid.Hash(hashOID)
+	req.IssuerNameHash = nameHash
+	req.IssuerKeyHash = keyHash
+	return req, nil
+}
+
+// MatchesIssuer reports whether r asks for the status of a certificate
+// issued by issuer.
+func (r *Request) MatchesIssuer(issuer *x509.Certificate) bool {
+	if r.HashAlgorithm == 0 {
+		return false
+	}
+	nameHash, keyHash, err := issuerHashes(issuer, r.HashAlgorithm)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(nameHash, r.IssuerNameHash) && bytes.Equal(keyHash, r.IssuerKeyHash)
+}
+
+// issuerHashes returns the hashes of the subject and of the public key of
+// issuer that identify it in a CertID.
+func issuerHashes(issuer *x509.Certificate, hash crypto.Hash) (nameHash, keyHash []byte, err error) {
+	if _, ok := hashoid.OID(hash); !ok || !hash.Available() {
+		return nil, nil, errors.New("ocsp: unsupported hash function")
+	}
+	spki := cryptobyte.String(issuer.RawSubjectPublicKeyInfo)
+	var key asn1.BitString
+	if !spki.ReadASN1(&spki, cryptobyte_asn1.SEQUENCE) ||
+		!spki.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!spki.ReadASN1BitString(&key) {
+		return nil, nil, errors.New("ocsp: malformed issuer public key")
+	}
+	h := hash.New()
+	h.Write(issuer.RawSubject)
+	nameHash = h.Sum(nil)
+	h.Reset()
+	h.Write(key.RightAlign())
+	keyHash = h.Sum(nil)
+	return nameHash, keyHash, nil
+}
+
+func addCertID(b *cryptobyte.Builder, hash crypto.Hash, nameHash, keyHash []byte, serial *big.Int) {
+	hashOID, _ := hashoid.OID(hash)
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1ObjectIdentifier(hashOID)
+			b.AddASN1NULL()
+		})
+		b.AddASN1OctetString(nameHash)
+		b.AddASN1OctetString(keyHash)
+		b.AddASN1BigInt(serial)
+	})
+}
+
+// A ResponseTemplate holds the contents of an OCSP response created by
+// [CreateResponse].
+type ResponseTemplate struct {
+	// Responses contains the status of each certificate covered by the
+	// response. The HashAlgorithm of each response is used to identify the
+	// issuer, or SHA-1 if zero. The IssuerNameHash and IssuerKeyHash fields
+	// are ignored, and computed from the issuer certificate.
+	Responses []x509.OCSPSingleResponse
+
+	// ProducedAt is the time at which the response is signed. If zero, the
+	// current time is used.
+	ProducedAt time.Time
+
+	// ExtraExtensions contains the response extensions to add, such as a
+	// nonce.
+	ExtraExtensions []pkix.Extension
+}
+
+// CreateResponse creates a new successful OCSP response of the basic type,
+// according to RFC 6960, based on template, for certificates issued by
+// issuer. It is primarily meant for test CAs and responders.
+//
+// The response is signed by priv, which must be the private key of
+// responder. If responder is not issuer, it must be a delegated responder
+// certificate issued by issuer with the OCSP signing extended key usage,
+// and it is included in the response.
+//
+// The signature algorithm is selected based on the key: SHA-256 with
+// PKCS #1 v1.5 padding for RSA keys, ECDSA with the hash matching the curve
+// for ECDSA keys, and Ed25519 for Ed25519 keys.
+func CreateResponse(rand io.Reader, template *ResponseTemplate, issuer, responder *x509.Certificate, priv crypto.Signer) ([]byte, error) {
+	if template == nil {
+		return nil, errors.New("ocsp: template can not be nil")
+	}
+	if issuer == nil || responder == nil {
+		return nil, errors.New("ocsp: issuer and responder can not be nil")
+	}
+	sigAlgo, sigAI, hash, err := signingParamsForKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	producedAt := template.ProducedAt
+	if producedAt.IsZero() {
+		producedAt = time.Now()
+	}
+
+	// ResponseData ::= SEQUENCE {
+	//     version              [0] EXPLICIT Version DEFAULT v1,
+	//     responderID              ResponderID,
+	//     producedAt               GeneralizedTime,
+	//     responses                SEQUENCE OF SingleResponse,
+	//     responseExtensions   [1] EXPLICIT Extensions OPTIONAL }
+	var tbs cryptobyte.Builder
+	tbs.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+			b.AddBytes(responder.RawSubject)
+		})
+		b.AddASN1GeneralizedTime(producedAt.UTC())
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			for _, r := range template.Responses {
+				addSingleResponse(b, &r, issuer)
+			}
+		})
+		if len(template.ExtraExtensions) > 0 {
+			b.AddASN1(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+				addExtensions(b, template.ExtraExtensions)
+			})
+		}
+	})
+	tbsDER, err := tbs.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	signed := tbsDER
+	if hash != 0 {
+		h := hash.New()
+		h.Write(tbsDER)
+		signed = h.Sum(nil)
+	}
+	signature, err := priv.Sign(rand, signed, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// OCSPResponse ::= SEQUENCE {
+	//     responseStatus          OCSPResponseStatus,
+	//     responseBytes       [0] EXPLICIT ResponseBytes OPTIONAL }
+	//
+	// BasicOCSPResponse ::= SEQUENCE {
+	//     tbsResponseData      ResponseData,
+	//     signatureAlgorithm   AlgorithmIdentifier,
+	//     signature            BIT STRING,
+	//     certs            [0] EXPLICIT SEQUENCE OF Certificate OPTIONAL }
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Enum(0) // successful
+		b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(oidOCSPBasicResponse)
+				b.AddASN1(cryptobyte_asn1.OCTET_STRING, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddBytes(tbsDER)
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(sigAI.Algorithm)
+							if len(sigAI.Parameters.FullBytes) > 0 {
+								b.AddBytes(sigAI.Parameters.FullBytes)
+							}
+						})
+						b.AddASN1BitString(signature)
+						if responder != issuer && !bytes.Equal(responder.Raw, issuer.Raw) {
+							b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+								b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+									b.AddBytes(responder.Raw)
+								})
+							})
+						}
+					})
+				})
+			})
+		})
+	})
+	der, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the signature to ensure the crypto.Signer behaved correctly, and
+	// that responder is authorized to sign for issuer.
+	resp, err := x509.ParseOCSPResponse(der)
+	if err != nil {
+		return nil, err
+	}
+	if resp.SignatureAlgorithm != sigAlgo {
+		return nil, errors.New("ocsp: unexpected signature algorithm")
+	}
+	if err := resp.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("ocsp: signature verification failed: %w", err)
+	}
+	return der, nil
+}
+
+func addSingleResponse(b *cryptobyte.Builder, r *x509.OCSPSingleResponse, issuer *x509.Certificate) {
+	hash := r.HashAlgorithm
+	if hash == 0 {
+		hash = crypto.SHA1
+	}
+	nameHash, keyHash, err := issuerHashes(issuer, hash)
+	if err != nil {
+		b.SetError(err)
+		return
+	}
+	if r.SerialNumber == nil {
+		b.SetError(errors.New("ocsp: missing serial number"))
+		return
+	}
+
+	// SingleResponse ::= SEQUENCE {
+	//     certID                   CertID,
+	//     certStatus               CertStatus,
+	//     thisUpdate               GeneralizedTime,
+	//     nextUpdate           [0] EXPLICIT GeneralizedTime OPTIONAL,
+	//     singleExtensions     [1] EXPLICIT Extensions OPTIONAL }
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		addCertID(b, hash, nameHash, keyHash, r.SerialNumber)
+		switch r.Status {
+		case x509.RevocationStatusGood:
+			b.AddASN1(cryptobyte_asn1.Tag(0).ContextSpecific(), func(b *cryptobyte.Builder) {})
+		case x509.RevocationStatusRevoked:
+			b.AddASN1(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+				b.AddASN1GeneralizedTime(r.RevocationTime.UTC())
+				if r.ReasonCode != 0 {
+					b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+						b.AddASN1Enum(int64(r.ReasonCode))
+					})
+				}
+			})
+		case x509.RevocationStatusUnknown:
+			b.AddASN1(cryptobyte_asn1.Tag(2).ContextSpecific(), func(b *cryptobyte.Builder) {})
+		default:
+			b.SetError(fmt.Errorf("ocsp: invalid certificate status %v", r.Status))
+			return
+		}
+		b.AddASN1GeneralizedTime(r.ThisUpdate.UTC())
+		if !r.NextUpdate.IsZero() {
+			b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+				b.AddASN1GeneralizedTime(r.NextUpdate.UTC())
+			})
+		}
+	})
+}
+
+func addExtensions(b *cryptobyte.Builder, extensions []pkix.Extension) {
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for _, ext := range extensions {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(ext.Id)
+				if ext.Critical {
+					b.AddASN1Boolean(true)
+				}
+				b.AddASN1OctetString(ext.Value)
+			})
+		}
+	})
+}
+
+// signingParamsForKey returns the signature algorithm, its identifier, and
+// the hash function to use with priv.
+func signingParamsForKey(priv crypto.Signer) (x509.SignatureAlgorithm, pkix.AlgorithmIdentifier, crypto.Hash, error) {
+	if priv == nil {
+		return 0, pkix.AlgorithmIdentifier{}, 0, errors.New("ocsp: private key can not be nil")
+	}
+	switch pub := priv.Public().(type) {
+	case *rsa.PublicKey:
+		return x509.SHA256WithRSA, pkix.AlgorithmIdentifier{
+			Algorithm:  oidSignatureSHA256WithRSA,
+			Parameters: asn1.NullRawValue,
+		}, crypto.SHA256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return x509.ECDSAWithSHA256, pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA256}, crypto.SHA256, nil
+		case elliptic.P384():
+			return x509.ECDSAWithSHA384, pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA384}, crypto.SHA384, nil
+		case elliptic.P521():
+			return x509.ECDSAWithSHA512, pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA512}, crypto.SHA512, nil
+		}
+		return 0, pkix.AlgorithmIdentifier{}, 0, errors.New("ocsp: unsupported elliptic curve")
+	case ed25519.PublicKey:
+		return x509.PureEd25519, pkix.AlgorithmIdentifier{Algorithm: oidSignatureEd25519}, 0, nil
+	}
+	return 0, pkix.AlgorithmIdentifier{}, 0, errors.New("ocsp: only RSA, ECDSA and Ed25519 keys supported")
+}
+
+// ParseResponse parses an OCSP response from the given ASN.1 DER data, like
+// [x509.ParseOCSPResponse]. If issuer is not nil, it also checks that the
+// response is signed by issuer or by a delegated responder of issuer.
+func ParseResponse(der []byte, issuer *x509.Certificate) (*x509.OCSPResponse, error) {
+	resp, err := x509.ParseOCSPResponse(der)
+	if err != nil {
+		return nil, err
+	}
+	if issuer != nil {
+		if err := resp.CheckSignatureFrom(issuer); err != nil {
+			return nil, fmt.Errorf("ocsp: bad signature on response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// ParseResponseForCert parses an OCSP response from the given ASN.1 DER
+// data, checks that it is signed by issuer or by a delegated responder of
+// issuer, and returns the status of cert it contains.
+//
+// It returns an error if the response does not cover cert. The validity
+// period of the status is not checked.
+func ParseResponseForCert(der []byte, cert, issuer *x509.Certificate) (*x509.OCSPSingleResponse, error) {
+	resp, err := ParseResponse(der, issuer)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resp.Responses {
+		if resp.Responses[i].Matches(cert, issuer) {
+			return &resp.Responses[i], nil
+		}
+	}
+	return nil, errors.New("ocsp: response does not cover the certificate")
+}
@@ -0,0 +1,316 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ocsp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadTestCert returns the certificate and private key in the file
+// name.pem of the test PKI in ../testdata/ocsp, described in its README.
+func loadTestCert(t *testing.T, name string) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	file := filepath.Join("..", "testdata", "ocsp", name+".pem")
+	cert, err := tls.LoadX509KeyPair(file, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Leaf, cert.PrivateKey.(crypto.Signer)
+}
+
+func TestRequest(t *testing.T) {
+	ca, _ := loadTestCert(t, "ca")
+	other, _ := loadTestCert(t, "other-ca")
+	leaf, _ := loadTestCert(t, "leaf")
+
+	for _, h := range []crypto.Hash{0, crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		der, err := CreateRequest(leaf, ca, &RequestOptions{Hash: h})
+		if err != nil {
+			t.Fatalf("CreateRequest(%v): %v", h, err)
+		}
+		req, err := ParseRequest(der)
+		if err != nil {
+			t.Fatalf("ParseRequest(%v): %v", h, err)
+		}
+		want := h
+		if want == 0 {
+			want = crypto.SHA1
+		}
+		if req.HashAlgorithm != want {
+			t.Errorf("HashAlgorithm = %v, want %v", req.HashAlgorithm, want)
+		}
+		if req.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+			t.Errorf("SerialNumber = %v, want %v", req.SerialNumber, leaf.SerialNumber)
+		}
+		if !req.MatchesIssuer(ca) {
+			t.Errorf("request with %v does not match its issuer", want)
+		}
+		if req.MatchesIssuer(other) {
+			t.Errorf("request with %v matches the wrong issuer", want)
+		}
+	}
+
+	if _, err := CreateRequest(leaf, ca, &RequestOptions{Hash: crypto.MD5}); err == nil {
+		t.Error("CreateRequest with MD5 succeeded")
+	}
+	for _, der := range [][]byte{nil, {0x30, 0x00}, {0x30, 0x02, 0x30, 0x00}} {
+		if _, err := ParseRequest(der); err == nil {
+			t.Errorf("ParseRequest(%x) succeeded", der)
+		}
+	}
+}
+
+func TestCreateResponse(t *testing.T) {
+	ca, caKey := loadTestCert(t, "ca")
+	leaf, _ := loadTestCert(t, "leaf")
+	revoked, _ := loadTestCert(t, "revoked")
+	delegate, delegateKey := loadTestCert(t, "responder")
+	notDelegate, notDelegateKey := loadTestCert(t, "not-responder")
+
+	now := time.Now().Truncate(time.Second)
+	template := &ResponseTemplate{
+		Responses: []x509.OCSPSingleResponse{
+			{
+				SerialNumber: leaf.SerialNumber,
+				Status:       x509.RevocationStatusGood,
+				ThisUpdate:   now,
+				NextUpdate:   now.Add(time.Hour),
+			},
+			{
+				HashAlgorithm:  crypto.SHA256,
+				SerialNumber:   revoked.SerialNumber,
+				Status:         x509.RevocationStatusRevoked,
+				RevocationTime: now.Add(-time.Minute),
+				ReasonCode:     1,
+				ThisUpdate:     now,
+			},
+		},
+		ProducedAt: now,
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}, Value: []byte{0x04, 0x02, 0x01, 0x02}},
+		},
+	}
+
+	for _, tt := range []struct {
+		name      string
+		responder *x509.Certificate
+		key       crypto.Signer
+		certs     int
+	}{
+		{"issuer", ca, caKey, 0},
+		{"delegate", delegate, delegateKey, 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := CreateResponse(rand.Reader, template, ca, tt.responder, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := ParseResponse(der, ca)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !resp.ProducedAt.Equal(now) {
+				t.Errorf("ProducedAt = %v, want %v", resp.ProducedAt, now)
+			}
+			if len(resp.Certificates) != tt.certs {
+				t.Errorf("got %d certificates, want %d", len(resp.Certificates), tt.certs)
+			}
+			if len(resp.Extensions) != 1 || !resp.Extensions[0].Id.Equal(template.ExtraExtensions[0].Id) {
+				t.Errorf("Extensions = %v, want %v", resp.Extensions, template.ExtraExtensions)
+			}
+
+			r, err := ParseResponseForCert(der, leaf, ca)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Status != x509.RevocationStatusGood || r.HashAlgorithm != crypto.SHA1 ||
+				!r.ThisUpdate.Equal(now) || !r.NextUpdate.Equal(now.Add(time.Hour)) {
+				t.Errorf("unexpected response for leaf: %+v", r)
+			}
+			r, err = ParseResponseForCert(der, revoked, ca)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Status != x509.RevocationStatusRevoked || r.HashAlgorithm != crypto.SHA256 ||
+				r.ReasonCode != 1 || !r.RevocationTime.Equal(now.Add(-time.Minute)) || !r.NextUpdate.IsZero() {
+				t.Errorf("unexpected response for revoked: %+v", r)
+			}
+			if _, err := ParseResponseForCert(der, ca, ca); err == nil {
+				t.Error("ParseResponseForCert succeeded for a certificate not covered by the response")
+			}
+			if _, err := ParseResponse(der, leaf); err == nil {
+				t.Error("ParseResponse succeeded with the wrong issuer")
+			}
+		})
+	}
+
+	if _, err := CreateResponse(rand.Reader, template, ca, notDelegate, notDelegateKey); err == nil {
+		t.Error("CreateResponse succeeded with an unauthorized responder")
+	}
+	if _, err := CreateResponse(rand.Reader, template, ca, ca, notDelegateKey); err == nil {
+		t.Error("CreateResponse succeeded with the wrong key")
+	}
+}
+
+func TestCreateResponseKeyTypes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		key  crypto.Signer
+		algo x509.SignatureAlgorithm
+	}{
+		{rsaKey, x509.SHA256WithRSA},
+		{p384Key, x509.ECDSAWithSHA384},
+		{edKey, x509.PureEd25519},
+	} {
+		t.Run(tt.algo.String(), func(t *testing.T) {
+			template := &x509.Certificate{
+				SerialNumber:          big.NewInt(1),
+				Subject:               pkix.Name{CommonName: "CA"},
+				NotBefore:             time.Now().Add(-time.Hour),
+				NotAfter:              time.Now().Add(time.Hour),
+				KeyUsage:              x509.KeyUsageCertSign,
+				BasicConstraintsValid: true,
+				IsCA:                  true,
+			}
+			der, err := x509.CreateCertificate(rand.Reader, template, template, tt.key.Public(), tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ca, err := x509.ParseCertificate(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			der, err = CreateResponse(rand.Reader, &ResponseTemplate{
+				Responses: []x509.OCSPSingleResponse{{
+					SerialNumber: big.NewInt(2),
+					Status:       x509.RevocationStatusUnknown,
+					ThisUpdate:   time.Now(),
+				}},
+			}, ca, ca, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := ParseResponse(der, ca)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.SignatureAlgorithm != tt.algo {
+				t.Errorf("SignatureAlgorithm = %v, want %v", resp.SignatureAlgorithm, tt.algo)
+			}
+			if resp.Responses[0].Status != x509.RevocationStatusUnknown {
+				t.Errorf("Status = %v, want unknown", resp.Responses[0].Status)
+			}
+		})
+	}
+}
+
+func TestResponder(t *testing.T) {
+	ca, _ := loadTestCert(t, "ca")
+	other, _ := loadTestCert(t, "other-ca")
+	leaf, _ := loadTestCert(t, "leaf")
+	otherLeaf, _ := loadTestCert(t, "other-leaf")
+	delegate, delegateKey := loadTestCert(t, "responder")
+
+	now := time.Now().Truncate(time.Second)
+	responder := &Responder{
+		Issuer:      ca,
+		Certificate: delegate,
+		Key:         delegateKey,
+		Validity:    10 * time.Minute,
+		Time:        func() time.Time { return now },
+	}
+	srv := httptest.NewServer(responder)
+	defer srv.Close()
+	ctx := context.Background()
+
+	der, err := Fetch(ctx, srv.Client(), srv.URL, leaf, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := ParseResponseForCert(der, leaf, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != x509.RevocationStatusGood || !r.ThisUpdate.Equal(now) || !r.NextUpdate.Equal(now.Add(10*time.Minute)) {
+		t.Errorf("unexpected response: %+v", r)
+	}
+
+	responder.Revoke(leaf.SerialNumber, 4)
+	der, err = Fetch(ctx, srv.Client(), srv.URL, leaf, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = ParseResponseForCert(der, leaf, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != x509.RevocationStatusRevoked || r.ReasonCode != 4 || !r.RevocationTime.Equal(now) {
+		t.Errorf("unexpected response after revocation: %+v", r)
+	}
+
+	if _, err := Fetch(ctx, srv.Client(), srv.URL, otherLeaf, other); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Fetch for another issuer: got %v, want unauthorized error", err)
+	}
+
+	// Responses can also be requested with GET.
+	reqDER, err := CreateRequest(leaf, ca, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Get(srv.URL + "/" + base64.StdEncoding.EncodeToString(reqDER))
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/ocsp-response" {
+		t.Errorf("Content-Type = %q, want application/ocsp-response", got)
+	}
+	if r, err := ParseResponseForCert(der, leaf, ca); err != nil || r.Status != x509.RevocationStatusRevoked {
+		t.Errorf("GET response: %+v, %v", r, err)
+	}
+
+	resp, err = srv.Client().Post(srv.URL, "application/ocsp-request", strings.NewReader("garbage"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if _, err := x509.ParseOCSPResponse(der); err == nil || !strings.Contains(err.Error(), "malformedRequest") {
+		t.Errorf("malformed request: got %v, want malformedRequest error", err)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ocsp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StaplerOptions contains options for [NewStapler].
+type StaplerOptions struct {
+	// HTTPClient is the client used to fetch responses. If nil,
+	// [http.DefaultClient] is used.
+	HTTPClient *http.Client
+
+	// Responder is the URL of the OCSP responder to query. If empty, the
+	// first OCSP server listed in each certificate is used.
+	Responder string
+}
+
+// A Stapler keeps the OCSP responses stapled by a TLS server fresh.
+//
+// It holds a set of certificate chains, and periodically fetches the status
+// of their leaf certificates from the OCSP responder, setting the
+// OCSPStaple field of the [tls.Certificate] served by
+// [Stapler.GetCertificate]. A response is fetched again after half of its
+// validity period has elapsed.
+//
+// Typical use is
+//
+//	stapler, err := ocsp.NewStapler(certs, nil)
+//	if err != nil {
+//		// ...
+//	}
+//	go stapler.Run(ctx)
+//	config := &tls.Config{GetCertificate: stapler.GetCertificate}
+type Stapler struct {
+	client    *http.Client
+	responder string
+
+	// now returns the current time. It is overridden in tests.
+	now func() time.Time
+
+	// refreshMu serializes calls to Refresh.
+	refreshMu sync.Mutex
+	entries   []*stapleEntry
+
+	mu    sync.RWMutex
+	certs []tls.Certificate
+}
+
+type stapleEntry struct {
+	leaf, issuer *x509.Certificate
+	server       string
+
+	// thisUpdate and nextUpdate are the validity period of the current
+	// staple, if any.
+	thisUpdate, nextUpdate time.Time
+	hasStaple              bool
+}
+
+// NewStapler returns a Stapler for the given certificate chains, which must
+// include the certificate of the issuer of each leaf, immediately following
+// it. opts may be nil.
+//
+// NewStapler does not fetch any response. Call [Stapler.Refresh] or
+// [Stapler.Run] before serving connections to staple responses.
+func NewStapler(certs []tls.Certificate, opts *StaplerOptions) (*Stapler, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("ocsp: no certificates")
+	}
+	s := &Stapler{
+		client: http.DefaultClient,
+		now:    time.Now,
+		certs:  make([]tls.Certificate, len(certs)),
+	}
+	if opts != nil {
+		if opts.HTTPClient != nil {
+			s.client = opts.HTTPClient
+		}
+		s.responder = opts.Responder
+	}
+	copy(s.certs, certs)
+	for i := range s.certs {
+		c := &s.certs[i]
+		if len(c.Certificate) < 2 {
+			return nil, fmt.Errorf("ocsp: certificate chain %d does not include the issuer", i)
+		}
+		leaf := c.Leaf
+		if leaf == nil {
+			var err error
+			leaf, err = x509.ParseCertificate(c.Certificate[0])
+			if err != nil {
+				return nil, err
+			}
+			c.Leaf = leaf
+		}
+		issuer, err := x509.ParseCertificate(c.Certificate[1])
+		if err != nil {
+			return nil, err
+		}
+		server := s.responder
+		if server == "" {
+			if len(leaf.OCSPServer) == 0 {
+				return nil, fmt.Errorf("ocsp: certificate chain %d has no OCSP server", i)
+			}
+			server = leaf.OCSPServer[0]
+		}
+		e := &stapleEntry{leaf: leaf, issuer: issuer, server: server}
+		// Keep a staple provided by the caller if it is still valid.
+		if c.OCSPStaple != nil {
+			if r, err := ParseResponseForCert(c.OCSPStaple, leaf, issuer); err == nil && r.Status == x509.RevocationStatusGood &&
+				s.now().Before(stapleExpiry(r.ThisUpdate, r.NextUpdate)) {
+				e.thisUpdate, e.nextUpdate, e.hasStaple = r.ThisUpdate, r.NextUpdate, true
+			} else {
+				c.OCSPStaple = nil
+			}
+		}
+		s.entries = append(s.entries, e)
+	}
+	return s, nil
+}
+
+// maxStapleAge is how long after its ThisUpdate time a response without
+// a NextUpdate time may be stapled.
+const maxStapleAge = 24 * time.Hour
+
+// stapleExpiry returns the time at which a response with the given
+// validity period may no longer be stapled.
+func stapleExpiry(thisUpdate, nextUpdate time.Time) time.Time {
+	if nextUpdate.IsZero() {
+		return thisUpdate.Add(maxStapleAge)
+	}
+	return nextUpdate
+}
+
+// refreshTime returns the time at which the staple of e should be fetched
+// again.
+func (e *stapleEntry) refreshTime() time.Time {
+	if !e.hasStaple {
+		return time.Time{}
+	}
+	if e.nextUpdate.IsZero() {
+		return e.thisUpdate.Add(time.Hour)
+	}
+	return e.thisUpdate.Add(e.nextUpdate.Sub(e.thisUpdate) / 2)
+}
+
+// Refresh fetches a response for every certificate whose staple is missing
+// or due for renewal. It returns the errors encountered, if any.
+//
+// A response is fetched again after half of its validity period, or after
+// an hour if it has no NextUpdate time. If a response can't be fetched, the
+// previous staple is kept until its NextUpdate time, or until 24 hours after
+// its ThisUpdate time if it has none. Responses that report a status other
+// than good are not stapled.
+func (s *Stapler) Refresh(ctx context.Context) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	now := s.now()
+	var errs []error
+	for i, e := range s.entries {
+		if now.Before(e.refreshTime()) {
+			continue
+		}
+		staple, err := s.fetch(ctx, e, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ocsp: refreshing staple for %q: %w", e.leaf.Subject, err))
+			if !now.Before(stapleExpiry(e.thisUpdate, e.nextUpdate)) {
+				e.hasStaple = false
+			}
+			if !e.hasStaple {
+				s.setStaple(i, nil)
+			}
+			continue
+		}
+		s.setStaple(i, staple)
+	}
+	return errors.Join(errs...)
+}
+
+// fetch fetches and checks a new staple for e, updating its validity period.
+func (s *Stapler) fetch(ctx context.Context, e *stapleEntry, now time.Time) ([]byte, error) {
+	der, err := Fetch(ctx, s.client, e.server, e.leaf, e.issuer)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ParseResponseForCert(der, e.leaf, e.issuer)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != x509.RevocationStatusGood {
+		// Stop stapling the previous response too, as it is now stale.
+		e.hasStaple = false
+		return nil, fmt.Errorf("certificate status is %v", r.Status)
+	}
+	if !now.Before(stapleExpiry(r.ThisUpdate, r.NextUpdate)) {
+		return nil, errors.New("response is expired")
+	}
+	e.thisUpdate, e.nextUpdate, e.hasStaple = r.ThisUpdate, r.NextUpdate, true
+	return der, nil
+}
+
+// setStaple publishes a copy of the i-th certificate with the given staple.
+func (s *Stapler) setStaple(i int, staple []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	certs := make([]tls.Certificate, len(s.certs))
+	copy(certs, s.certs)
+	certs[i].OCSPStaple = staple
+	s.certs = certs
+}
+
+// Run calls [Stapler.Refresh] until ctx is done, waking up when the next
+// staple is due for renewal, and retrying failed fetches every minute. It
+// always returns a non-nil error, ctx.Err().
+func (s *Stapler) Run(ctx context.Context) error {
+	for {
+		err := s.Refresh(ctx)
+		wait := time.Minute
+		if err == nil {
+			wait = s.nextRefresh().Sub(s.now())
+		}
+		t := time.NewTimer(max(wait, time.Second))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// nextRefresh returns the earliest time at which a staple is due.
+func (s *Stapler) nextRefresh() time.Time {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+	var next time.Time
+	for i, e := range s.entries {
+		if t := e.refreshTime(); i == 0 || t.Before(next) {
+			next = t
+		}
+	}
+	return next
+}
+
+// Certificates returns the certificate chains held by s, with their current
+// staples.
+func (s *Stapler) Certificates() []tls.Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	certs := make([]tls.Certificate, len(s.certs))
+	copy(certs, s.certs)
+	return certs
+}
+
+// GetCertificate returns the first certificate chain supported by the
+// client, with its current staple. If none is supported, it returns the
+// first chain. It can be used as [tls.Config.GetCertificate].
+func (s *Stapler) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	certs := s.certs
+	s.mu.RUnlock()
+	for i := range certs {
+		if hello.SupportsCertificate(&certs[i]) == nil {
+			return &certs[i], nil
+		}
+	}
+	return &certs[0], nil
+}
@@ -0,0 +1,304 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ocsp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stapleHandshake performs a handshake with a server using stapler, and
+// returns the staple received by a client that requires a valid staple.
+func stapleHandshake(t *testing.T, stapler *Stapler, ca *x509.Certificate, now time.Time) ([]byte, error) {
+	t.Helper()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientConfig := &tls.Config{
+		RootCAs:    roots,
+		ServerName: "example.com",
+		Time:       func() time.Time { return now },
+		Revocation: &x509.RevocationOptions{HardFail: true, LeafOnly: true},
+	}
+	serverConfig := &tls.Config{
+		GetCertificate: stapler.GetCertificate,
+		Time:           func() time.Time { return now },
+	}
+
+	c, s := net.Pipe()
+	defer c.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer s.Close()
+		tls.Server(s, serverConfig).Handshake()
+	}()
+	client := tls.Client(c, clientConfig)
+	err := client.Handshake()
+	c.Close()
+	<-done
+	if err != nil {
+		return nil, err
+	}
+	return client.ConnectionState().OCSPResponse, nil
+}
+
+// responderClient returns a client that sends all requests to srv, whatever
+// their URL, so that the OCSP server of the test certificates reaches it.
+func responderClient(srv *httptest.Server) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}}
+}
+
+func TestStapler(t *testing.T) {
+	ca, caKey := loadTestCert(t, "ca")
+	leaf, leafKey := loadTestCert(t, "leaf")
+
+	// The clock is shared with the responder handler goroutines.
+	var clock atomic.Int64
+	clock.Store(time.Now().Unix())
+	nowFunc := func() time.Time { return time.Unix(clock.Load(), 0) }
+	advance := func(d time.Duration) { clock.Add(int64(d / time.Second)) }
+	responder := &Responder{
+		Issuer:   ca,
+		Key:      caKey,
+		Validity: 10 * time.Minute,
+		Time:     nowFunc,
+	}
+	var requests atomic.Int32
+	var broken atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if broken.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		responder.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	stapler, err := NewStapler([]tls.Certificate{{
+		Certificate: [][]byte{leaf.Raw, ca.Raw},
+		PrivateKey:  leafKey,
+	}}, &StaplerOptions{HTTPClient: responderClient(srv)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stapler.now = nowFunc
+	ctx := context.Background()
+
+	if _, err := stapleHandshake(t, stapler, ca, nowFunc()); err == nil {
+		t.Fatal("handshake without a staple succeeded")
+	}
+
+	if err := stapler.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	staple, err := stapleHandshake(t, stapler, ca, nowFunc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(staple) == 0 {
+		t.Fatal("no staple received")
+	}
+
+	// The staple is not fetched again until half of its validity has
+	// elapsed.
+	advance(4 * time.Minute)
+	if err := stapler.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+
+	// A failed fetch keeps the previous staple while it is valid.
+	advance(2 * time.Minute)
+	broken.Store(true)
+	if err := stapler.Refresh(ctx); err == nil {
+		t.Error("Refresh with a broken responder succeeded")
+	}
+	if got, err := stapleHandshake(t, stapler, ca, nowFunc()); err != nil || string(got) != string(staple) {
+		t.Errorf("staple not kept after a failed fetch: %v", err)
+	}
+
+	// An expired staple is dropped.
+	advance(5 * time.Minute)
+	if err := stapler.Refresh(ctx); err == nil {
+		t.Error("Refresh with a broken responder succeeded")
+	}
+	if got := stapler.Certificates()[0].OCSPStaple; got != nil {
+		t.Error("expired staple was not dropped")
+	}
+
+	broken.Store(false)
+	if err := stapler.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stapleHandshake(t, stapler, ca, nowFunc()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A revoked certificate is not stapled.
+	advance(6 * time.Minute)
+	responder.Revoke(leaf.SerialNumber, 1)
+	if err := stapler.Refresh(ctx); err == nil {
+		t.Error("Refresh for a revoked certificate succeeded")
+	}
+	if got := stapler.Certificates()[0].OCSPStaple; got != nil {
+		t.Error("staple was not dropped after revocation")
+	}
+	if _, err := stapleHandshake(t, stapler, ca, nowFunc()); err == nil {
+		t.Error("handshake with a revoked certificate succeeded")
+	}
+}
+
+func TestStaplerNoNextUpdate(t *testing.T) {
+	ca, caKey := loadTestCert(t, "ca")
+	leaf, leafKey := loadTestCert(t, "leaf")
+
+	start := time.Now().Truncate(time.Second)
+	now := start
+	response := func(thisUpdate time.Time) []byte {
+		der, err := CreateResponse(rand.Reader, &ResponseTemplate{
+			Responses: []x509.OCSPSingleResponse{{
+				SerialNumber: leaf.SerialNumber,
+				Status:       x509.RevocationStatusGood,
+				ThisUpdate:   thisUpdate,
+			}},
+			ProducedAt: thisUpdate,
+		}, ca, ca, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	der := response(start)
+	var requests atomic.Int32
+	var broken atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if broken.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(der)
+	}))
+	defer srv.Close()
+
+	chain := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw, ca.Raw},
+		PrivateKey:  leafKey,
+	}
+	stapler, err := NewStapler([]tls.Certificate{chain}, &StaplerOptions{HTTPClient: responderClient(srv)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stapler.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := stapler.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := stapler.Certificates()[0].OCSPStaple; got == nil {
+		t.Fatal("no staple")
+	}
+
+	// The staple is fetched again after an hour.
+	now = start.Add(30 * time.Minute)
+	if err := stapler.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+
+	// A failed fetch keeps the previous staple for up to 24 hours.
+	broken.Store(true)
+	now = start.Add(2 * time.Hour)
+	if err := stapler.Refresh(ctx); err == nil {
+		t.Error("Refresh with a broken responder succeeded")
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+	if got := stapler.Certificates()[0].OCSPStaple; got == nil {
+		t.Error("staple not kept after a failed fetch")
+	}
+	now = start.Add(24 * time.Hour)
+	if err := stapler.Refresh(ctx); err == nil {
+		t.Error("Refresh with a broken responder succeeded")
+	}
+	if got := stapler.Certificates()[0].OCSPStaple; got != nil {
+		t.Error("staple without NextUpdate kept for more than 24 hours")
+	}
+
+	// A response more than 24 hours old is not stapled.
+	broken.Store(false)
+	if err := stapler.Refresh(ctx); err == nil {
+		t.Error("Refresh with a stale response succeeded")
+	}
+	if got := stapler.Certificates()[0].OCSPStaple; got != nil {
+		t.Error("stale response was stapled")
+	}
+
+	// Nor is one provided to NewStapler.
+	chain.OCSPStaple = response(time.Now().Add(-25 * time.Hour))
+	stapler, err = NewStapler([]tls.Certificate{chain}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stapler.Certificates()[0].OCSPStaple; got != nil {
+		t.Error("NewStapler kept a stale staple")
+	}
+	chain.OCSPStaple = response(time.Now().Add(-time.Hour))
+	stapler, err = NewStapler([]tls.Certificate{chain}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stapler.Certificates()[0].OCSPStaple; got == nil {
+		t.Error("NewStapler dropped a valid staple")
+	}
+}
+
+func TestNewStaplerErrors(t *testing.T) {
+	ca, _ := loadTestCert(t, "ca")
+	leaf, leafKey := loadTestCert(t, "leaf")
+	noServer, noServerKey := loadTestCert(t, "not-responder")
+
+	if _, err := NewStapler(nil, nil); err == nil {
+		t.Error("NewStapler with no certificates succeeded")
+	}
+	if _, err := NewStapler([]tls.Certificate{{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  leafKey,
+	}}, nil); err == nil {
+		t.Error("NewStapler without the issuer succeeded")
+	}
+	if _, err := NewStapler([]tls.Certificate{{
+		Certificate: [][]byte{noServer.Raw, ca.Raw},
+		PrivateKey:  noServerKey,
+	}}, nil); err == nil {
+		t.Error("NewStapler without an OCSP server succeeded")
+	}
+	if _, err := NewStapler([]tls.Certificate{{
+		Certificate: [][]byte{noServer.Raw, ca.Raw},
+		PrivateKey:  noServerKey,
+	}}, &StaplerOptions{Responder: "http://ocsp.example.com"}); err != nil {
+		t.Errorf("NewStapler with a responder: %v", err)
+	}
+}
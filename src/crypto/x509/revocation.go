@@ -0,0 +1,406 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// RevocationStatus is the revocation status of a certificate.
+type RevocationStatus int
+
+const (
+	// RevocationStatusUnknown means that the status of the certificate could
+	// not be determined.
+	RevocationStatusUnknown RevocationStatus = iota
+	// RevocationStatusGood means that the certificate is not revoked.
+	RevocationStatusGood
+	// RevocationStatusRevoked means that the certificate is revoked.
+	RevocationStatusRevoked
+)
+
+func (s RevocationStatus) String() string {
+	switch s {
+	case RevocationStatusUnknown:
+		return "unknown"
+	case RevocationStatusGood:
+		return "good"
+	case RevocationStatusRevoked:
+		return "revoked"
+	}
+	return "RevocationStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// RevocationOptions configures revocation checking, by
+// [Certificate.Verify] and [Certificate.VerifyContext] through
+// [VerifyOptions.Revocation], or by [RevocationOptions.Check].
+//
+// The status of each certificate is looked up, in order, in OCSPResponses,
+// in CRLs, from the responders in the certificate's OCSPServer field using
+// FetchOCSP, and from the CRLs at the certificate's CRLDistributionPoints
+// using FetchCRL, until it is found. Responses and CRLs are only used if
+// they are signed by the issuer of the certificate, and current.
+type RevocationOptions struct {
+	// OCSPResponses contains DER encoded OCSP responses, such as the
+	// responses stapled by a TLS peer.
+	OCSPResponses [][]byte
+
+	// CRLs contains revocation lists to consult. Delta CRLs, indirect CRLs,
+	// and CRLs that only cover some revocation reasons are not used.
+	CRLs []*RevocationList
+
+	// FetchOCSP, if not nil, is called to query the OCSP responder at server,
+	// one of the URLs in the OCSPServer field of cert, for the status of
+	// cert, which is issued by issuer. It returns a DER encoded OCSP
+	// response. ctx is the context passed to [Certificate.VerifyContext] or
+	// [RevocationOptions.Check].
+	FetchOCSP func(ctx context.Context, server string, cert, issuer *Certificate) ([]byte, error)
+
+	// FetchCRL, if not nil, is called to retrieve the CRL at url, one of the
+	// CRLDistributionPoints of a certificate. It returns a DER encoded CRL.
+	// ctx is the context passed to [Certificate.VerifyContext] or
+	// [RevocationOptions.Check].
+	FetchCRL func(ctx context.Context, url string) ([]byte, error)
+
+	// HardFail rejects certificates whose status can't be determined. By
+	// default, only certificates known to be revoked are rejected.
+	HardFail bool
+
+	// LeafOnly restricts checking to the leaf certificate of each chain.
+	// By default, all certificates are checked except the root.
+	LeafOnly bool
+}
+
+// A RevocationResult is the revocation status of a certificate, as
+// determined by [Certificate.VerifyContext] or [RevocationOptions.Check].
+type RevocationResult struct {
+	// Certificate is the certificate whose status was checked.
+	Certificate *Certificate
+	// Status is the revocation status of Certificate.
+	Status RevocationStatus
+
+	// RevocationTime and ReasonCode are set if Status is
+	// RevocationStatusRevoked.
+	RevocationTime time.Time
+	ReasonCode     int
+
+	// OCSPResponse or CRL is the source of Status, unless Status is
+	// RevocationStatusUnknown.
+	OCSPResponse *OCSPResponse
+	CRL          *RevocationList
+
+	// Err, if Status is RevocationStatusUnknown, holds the errors encountered
+	// while looking up the status, if any.
+	Err error
+}
+
+// Check determines the revocation status of the certificates of chain, a
+// chain returned by [Certificate.Verify] which starts with the leaf
+// certificate, at time now, or at the current time if now is zero. ctx is
+// passed to FetchOCSP and FetchCRL.
+//
+// It returns the status of each certificate that is checked, in the order of
+// the chain, and a [CertificateInvalidError] if a certificate is revoked, or
+// if HardFail is set and the status of a certificate can't be determined.
+func (opts *RevocationOptions) Check(ctx context.Context, chain []*Certificate, now time.Time) ([]RevocationResult, error) {
+	return opts.newChecker(ctx, now).check(chain)
+}
+
+type revocationChecker struct {
+	ctx  context.Context
+	opts *RevocationOptions
+	now  time.Time
+
+	ocsp     []*OCSPResponse
+	ocspErrs []error
+
+	// results caches the status of certificates shared by multiple chains,
+	// so that they are fetched only once.
+	results map[[2]*Certificate]RevocationResult
+}
+
+func (opts *RevocationOptions) newChecker(ctx context.Context, now time.Time) *revocationChecker {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	rc := &revocationChecker{ctx: ctx, opts: opts, now: now, results: make(map[[2]*Certificate]RevocationResult)}
+	for _, der := range opts.OCSPResponses {
+		resp, err := ParseOCSPResponse(der)
+		if err != nil {
+			rc.ocspErrs = append(rc.ocspErrs, err)
+			continue
+		}
+		rc.ocsp = append(rc.ocsp, resp)
+	}
+	return rc
+}
+
+func (rc *revocationChecker) check(chain []*Certificate) ([]RevocationResult, error) {
+	n := len(chain) - 1
+	if rc.opts.LeafOnly {
+		n = min(n, 1)
+	}
+	var results []RevocationResult
+	var err error
+	for i := 0; i < n; i++ {
+		res := rc.status(chain[i], chain[i+1])
+		results = append(results, res)
+		if err != nil {
+			continue
+		}
+		switch {
+		case res.Status == RevocationStatusRevoked:
+			err = CertificateInvalidError{res.Certificate, Revoked, "revoked at " + res.RevocationTime.Format(time.RFC3339)}
+		case res.Status == RevocationStatusUnknown && rc.opts.HardFail:
+			detail := "no revocation information"
+			if res.Err != nil {
+				detail = res.Err.Error()
+			}
+			err = CertificateInvalidError{res.Certificate, RevocationCheckFailed, detail}
+		}
+	}
+	return results, err
+}
+
+func (rc *revocationChecker) status(cert, issuer *Certificate) RevocationResult {
+	key := [2]*Certificate{cert, issuer}
+	if res, ok := rc.results[key]; ok {
+		return res
+	}
+	res := rc.lookup(cert, issuer)
+	rc.results[key] = res
+	return res
+}
+
+func (rc *revocationChecker) lookup(cert, issuer *Certificate) RevocationResult {
+	res := RevocationResult{Certificate: cert}
+	errs := slices.Clone(rc.ocspErrs)
+
+	for _, resp := range rc.ocsp {
+		ok, err := rc.useOCSP(&res, resp, cert, issuer)
+		if ok {
+			return res
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, rl := range rc.opts.CRLs {
+		ok, err := rc.useCRL(&res, rl, cert, issuer)
+		if ok {
+			return res
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if rc.opts.FetchOCSP != nil {
+		for _, server := range cert.OCSPServer {
+			der, err := rc.opts.FetchOCSP(rc.ctx, server, cert, issuer)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			resp, err := ParseOCSPResponse(der)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ok, err := rc.useOCSP(&res, resp, cert, issuer)
+			if ok {
+				return res
+			}
+			if err == nil {
+				err = fmt.Errorf("x509: ocsp response from %s does not cover the certificate", server)
+			}
+			errs = append(errs, err)
+		}
+	}
+	if rc.opts.FetchCRL != nil {
+		for _, url := range cert.CRLDistributionPoints {
+			der, err := rc.opts.FetchCRL(rc.ctx, url)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			rl, err := ParseRevocationList(der)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ok, err := rc.useCRL(&res, rl, cert, issuer)
+			if ok {
+				return res
+			}
+			if err == nil {
+				err = fmt.Errorf("x509: crl from %s does not cover the certificate", url)
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	res.Err = errors.Join(errs...)
+	return res
+}
+
+// useOCSP sets the status in res from resp, if resp is a valid and current
+// response for cert. It returns false and a nil error if resp does not
+// cover cert.
+func (rc *revocationChecker) useOCSP(res *RevocationResult, resp *OCSPResponse, cert, issuer *Certificate) (bool, error) {
+	i := slices.IndexFunc(resp.Responses, func(sr OCSPSingleResponse) bool {
+		return sr.Matches(cert, issuer)
+	})
+	if i < 0 {
+		return false, nil
+	}
+	sr := &resp.Responses[i]
+	signer, err := resp.signer(issuer)
+	if err != nil {
+		return false, fmt.Errorf("x509: invalid ocsp response signature: %w", err)
+	}
+	if signer != issuer && (rc.now.Before(signer.NotBefore) || rc.now.After(signer.NotAfter)) {
+		return false, errors.New("x509: ocsp responder certificate has expired or is not yet valid")
+	}
+	if rc.now.Before(sr.ThisUpdate) || !sr.NextUpdate.IsZero() && rc.now.After(sr.NextUpdate) {
+		return false, errors.New("x509: ocsp response is not current")
+	}
+	if sr.Status == RevocationStatusUnknown {
+		return false, errors.New("x509: ocsp responder does not know the certificate")
+	}
+	res.Status = sr.Status
+	res.RevocationTime = sr.RevocationTime
+	res.ReasonCode = sr.ReasonCode
+	res.OCSPResponse = resp
+	return true, nil
+}
+
+// useCRL sets the status in res from rl, if rl is a valid and current CRL
+// covering cert. It returns false and a nil error if rl does not cover cert.
+func (rc *revocationChecker) useCRL(res *RevocationResult, rl *RevocationList, cert, issuer *Certificate) (bool, error) {
+	if !bytes.Equal(rl.RawIssuer, issuer.RawSubject) || !crlCovers(rl, cert) {
+		return false, nil
+	}
+	if err := rl.CheckSignatureFrom(issuer); err != nil {
+		return false, fmt.Errorf("x509: invalid crl signature: %w", err)
+	}
+	if rc.now.Before(rl.ThisUpdate) || !rl.NextUpdate.IsZero() && rc.now.After(rl.NextUpdate) {
+		return false, errors.New("x509: crl is not current")
+	}
+	res.Status = RevocationStatusGood
+	res.CRL = rl
+	for _, rce := range rl.RevokedCertificateEntries {
+		if rce.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			res.Status = RevocationStatusRevoked
+			res.RevocationTime = rce.RevocationTime
+			res.ReasonCode = rce.ReasonCode
+			break
+		}
+	}
+	return true, nil
+}
+
+var (
+	oidExtensionDeltaCRLIndicator        = []int{2, 5, 29, 27}
+	oidExtensionIssuingDistributionPoint = []int{2, 5, 29, 28}
+)
+
+// crlCovers reports whether rl is a complete CRL whose scope includes cert.
+func crlCovers(rl *RevocationList, cert *Certificate) bool {
+	for _, ext := range rl.Extensions {
+		switch {
+		case ext.Id.Equal(oidExtensionIssuingDistributionPoint):
+			if !idpCovers(ext.Value, cert) {
+				return false
+			}
+		case ext.Id.Equal(oidExtensionDeltaCRLIndicator), ext.Critical:
+			return false
+		}
+	}
+	return true
+}
+
+// idpCovers reports whether the scope defined by an issuing distribution
+// point extension includes cert.
+func idpCovers(der []byte, cert *Certificate) bool {
+	// RFC 5280, 5.2.5
+	//
+	// IssuingDistributionPoint ::= SEQUENCE {
+	//     distributionPoint          [0] DistributionPointName OPTIONAL,
+	//     onlyContainsUserCerts      [1] BOOLEAN DEFAULT FALSE,
+	//     onlyContainsCACerts        [2] BOOLEAN DEFAULT FALSE,
+	//     onlySomeReasons            [3] ReasonFlags OPTIONAL,
+	//     indirectCRL                [4] BOOLEAN DEFAULT FALSE,
+	//     onlyContainsAttributeCerts [5] BOOLEAN DEFAULT FALSE }
+	val := cryptobyte.String(der)
+	if !val.ReadASN1(&val, cryptobyte_asn1.SEQUENCE) {
+		return false
+	}
+	var dpName cryptobyte.String
+	var present bool
+	if !val.ReadOptionalASN1(&dpName, &present, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return false
+	}
+	if present {
+		// Only the fullName form of the distribution point name is
+		// supported, and it must include one of the certificate's
+		// distribution point URIs.
+		if !dpName.ReadASN1(&dpName, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+			return false
+		}
+		matched := false
+		for !dpName.Empty() {
+			var name cryptobyte.String
+			var tag cryptobyte_asn1.Tag
+			if !dpName.ReadAnyASN1(&name, &tag) {
+				return false
+			}
+			if tag == cryptobyte_asn1.Tag(6).ContextSpecific() && slices.Contains(cert.CRLDistributionPoints, string(name)) {
+				matched = true
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	readBool := func(out *bool, tag cryptobyte_asn1.Tag) bool {
+		var v cryptobyte.String
+		var present bool
+		if !val.ReadOptionalASN1(&v, &present, tag.ContextSpecific()) {
+			return false
+		}
+		if present {
+			if len(v) != 1 {
+				return false
+			}
+			*out = v[0] != 0
+		}
+		return true
+	}
+	var onlyUser, onlyCA, indirect, onlyAttr bool
+	if !readBool(&onlyUser, 1) || !readBool(&onlyCA, 2) {
+		return false
+	}
+	if val.PeekASN1Tag(cryptobyte_asn1.Tag(3).ContextSpecific()) {
+		return false // onlySomeReasons
+	}
+	if !readBool(&indirect, 4) || !readBool(&onlyAttr, 5) || !val.Empty() {
+		return false
+	}
+	if indirect || onlyAttr {
+		return false
+	}
+	return !(onlyUser && cert.IsCA) && !(onlyCA && !cert.IsCA)
+}
@@ -0,0 +1,564 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+func revocationTestCRL(t *testing.T, issuer *Certificate, key crypto.Signer, revoked []*Certificate, thisUpdate, nextUpdate time.Time, extensions []pkix.Extension) *RevocationList {
+	t.Helper()
+	template := &RevocationList{
+		Number:          big.NewInt(1),
+		ThisUpdate:      thisUpdate,
+		NextUpdate:      nextUpdate,
+		ExtraExtensions: extensions,
+	}
+	for _, c := range revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, RevocationListEntry{
+			SerialNumber:   c.SerialNumber,
+			RevocationTime: thisUpdate.Add(-time.Hour),
+			ReasonCode:     1,
+		})
+	}
+	der, err := CreateRevocationList(rand.Reader, template, issuer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+// createTestOCSPResponse creates an OCSP response signed by signer, for
+// certificates issued by issuer. Responses only need Status, SerialNumber,
+// the update times and the revocation details.
+func createTestOCSPResponse(t *testing.T, issuer, signer *Certificate, key crypto.Signer, responses []OCSPSingleResponse, certs ...*Certificate) []byte {
+	t.Helper()
+	issuerKey, err := subjectPublicKeyBits(issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := crypto.SHA256.New()
+	h.Write(issuer.RawSubject)
+	nameHash := h.Sum(nil)
+	h.Reset()
+	h.Write(issuerKey)
+	keyHash := h.Sum(nil)
+
+	var tbs cryptobyte.Builder
+	tbs.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+			b.AddBytes(signer.RawSubject)
+		})
+		b.AddASN1GeneralizedTime(time.Now().UTC().Truncate(time.Second))
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			for _, r := range responses {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+							b.AddASN1ObjectIdentifier(oidSHA256)
+							b.AddASN1NULL()
+						})
+						b.AddASN1OctetString(nameHash)
+						b.AddASN1OctetString(keyHash)
+						b.AddASN1BigInt(r.SerialNumber)
+					})
+					switch r.Status {
+					case RevocationStatusGood:
+						b.AddASN1(cryptobyte_asn1.Tag(0).ContextSpecific(), func(b *cryptobyte.Builder) {})
+					case RevocationStatusRevoked:
+						b.AddASN1(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+							b.AddASN1GeneralizedTime(r.RevocationTime.UTC())
+							b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+								b.AddASN1Enum(int64(r.ReasonCode))
+							})
+						})
+					default:
+						b.AddASN1(cryptobyte_asn1.Tag(2).ContextSpecific(), func(b *cryptobyte.Builder) {})
+					}
+					b.AddASN1GeneralizedTime(r.ThisUpdate.UTC())
+					if !r.NextUpdate.IsZero() {
+						b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+							b.AddASN1GeneralizedTime(r.NextUpdate.UTC())
+						})
+					}
+				})
+			}
+		})
+	})
+	tbsDER, err := tbs.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sigAI, err := signingParamsForKey(key, ECDSAWithSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigAIDER, err := asn1.Marshal(sigAI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := crypto.SHA256.New()
+	digest.Write(tbsDER)
+	signature, err := key.Sign(rand.Reader, digest.Sum(nil), crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Enum(0)
+		b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(oidOCSPBasicResponse)
+				b.AddASN1(cryptobyte_asn1.OCTET_STRING, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddBytes(tbsDER)
+						b.AddBytes(sigAIDER)
+						b.AddASN1BitString(signature)
+						if len(certs) > 0 {
+							b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+								b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+									for _, c := range certs {
+										b.AddBytes(c.Raw)
+									}
+								})
+							})
+						}
+					})
+				})
+			})
+		})
+	})
+	return b.BytesOrPanic()
+}
+
+func TestParseOCSPResponse(t *testing.T) {
+	root, rootKey, err := generateCert("root", true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder, responderKey, err := generateCert("responder", false, root, rootKey, func(c *Certificate) {
+		c.ExtKeyUsage = []ExtKeyUsage{ExtKeyUsageOCSPSigning}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("leaf", false, root, rootKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, otherKey, err := generateCert("other", false, root, rootKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	der := createTestOCSPResponse(t, root, responder, responderKey, []OCSPSingleResponse{
+		{SerialNumber: leaf.SerialNumber, Status: RevocationStatusGood, ThisUpdate: now, NextUpdate: now.Add(time.Hour)},
+		{SerialNumber: other.SerialNumber, Status: RevocationStatusRevoked, RevocationTime: now.Add(-time.Hour), ReasonCode: 4, ThisUpdate: now},
+	}, responder)
+
+	resp, err := ParseOCSPResponse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Responses) != 2 || len(resp.Certificates) != 1 {
+		t.Fatalf("got %d responses and %d certificates, want 2 and 1", len(resp.Responses), len(resp.Certificates))
+	}
+	if string(resp.RawResponderName) != string(responder.RawSubject) {
+		t.Errorf("unexpected responder name")
+	}
+	good, revoked := resp.Responses[0], resp.Responses[1]
+	if good.HashAlgorithm != crypto.SHA256 || good.Status != RevocationStatusGood ||
+		!good.ThisUpdate.Equal(now) || !good.NextUpdate.Equal(now.Add(time.Hour)) {
+		t.Errorf("unexpected good response: %+v", good)
+	}
+	if revoked.Status != RevocationStatusRevoked || revoked.ReasonCode != 4 ||
+		!revoked.RevocationTime.Equal(now.Add(-time.Hour)) || !revoked.NextUpdate.IsZero() {
+		t.Errorf("unexpected revoked response: %+v", revoked)
+	}
+	if !good.Matches(leaf, root) || good.Matches(other, root) || good.Matches(leaf, responder) {
+		t.Errorf("Matches reported the wrong certificates")
+	}
+
+	if err := resp.CheckSignatureFrom(root); err != nil {
+		t.Errorf("CheckSignatureFrom failed for a delegated responder: %v", err)
+	}
+	if err := resp.CheckSignatureFrom(other); err == nil {
+		t.Errorf("CheckSignatureFrom succeeded for the wrong issuer")
+	}
+
+	// A responder without the OCSP signing EKU is not authorized.
+	der = createTestOCSPResponse(t, root, other, otherKey, nil, other)
+	resp, err = ParseOCSPResponse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.CheckSignatureFrom(root); err == nil {
+		t.Errorf("CheckSignatureFrom succeeded for a responder without the OCSP signing EKU")
+	}
+}
+
+func TestParseOCSPResponseErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		der  []byte
+	}{
+		{"empty", nil},
+		{"tryLater", []byte{0x30, 0x03, 0x0a, 0x01, 0x03}},
+		{"no response bytes", []byte{0x30, 0x03, 0x0a, 0x01, 0x00}},
+		{"trailing data", []byte{0x30, 0x03, 0x0a, 0x01, 0x03, 0x00}},
+	} {
+		if _, err := ParseOCSPResponse(test.der); err == nil {
+			t.Errorf("%s: ParseOCSPResponse succeeded, want error", test.name)
+		}
+	}
+}
+
+// loadTestCert returns the first certificate in testdata/dir/name.pem.
+func loadTestCert(t *testing.T, dir, name string) *Certificate {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", dir, name+".pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		t.Fatalf("%s.pem: no certificate", name)
+	}
+	cert, err := ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// TestParseOCSPResponseFixtures checks responses produced by the OpenSSL
+// OCSP responder, one signed by the CA and one by a delegated responder.
+// See testdata/ocsp/README.
+func TestParseOCSPResponseFixtures(t *testing.T) {
+	ca := loadTestCert(t, "ocsp", "ca")
+	responder := loadTestCert(t, "ocsp", "responder")
+	leaf := loadTestCert(t, "ocsp", "leaf")
+	revokedLeaf := loadTestCert(t, "ocsp", "revoked")
+
+	thisUpdate := time.Date(2024, time.October, 15, 12, 0, 0, 0, time.UTC)
+	nextUpdate := thisUpdate.Add(7 * 24 * time.Hour)
+	revocationTime := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, name := range []string{"direct", "delegated"} {
+		t.Run(name, func(t *testing.T) {
+			der, err := os.ReadFile(filepath.Join("testdata", "ocsp", name+".der"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := ParseOCSPResponse(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !resp.ProducedAt.Equal(thisUpdate) || resp.SignatureAlgorithm != ECDSAWithSHA256 {
+				t.Errorf("unexpected response: ProducedAt %v, SignatureAlgorithm %v", resp.ProducedAt, resp.SignatureAlgorithm)
+			}
+			switch name {
+			case "direct":
+				if resp.RawResponderName != nil || len(resp.ResponderKeyHash) != 20 || len(resp.Certificates) != 0 {
+					t.Errorf("expected a responder ID by key hash and no certificates")
+				}
+			case "delegated":
+				if string(resp.RawResponderName) != string(responder.RawSubject) ||
+					len(resp.Certificates) != 1 || !resp.Certificates[0].Equal(responder) {
+					t.Errorf("expected a responder ID by name and the responder certificate")
+				}
+			}
+
+			if len(resp.Responses) != 2 {
+				t.Fatalf("got %d responses, want 2", len(resp.Responses))
+			}
+			good, revoked := resp.Responses[0], resp.Responses[1]
+			if good.HashAlgorithm != crypto.SHA1 || good.Status != RevocationStatusGood ||
+				!good.ThisUpdate.Equal(thisUpdate) || !good.NextUpdate.Equal(nextUpdate) {
+				t.Errorf("unexpected good response: %+v", good)
+			}
+			if revoked.Status != RevocationStatusRevoked || revoked.ReasonCode != 1 ||
+				!revoked.RevocationTime.Equal(revocationTime) {
+				t.Errorf("unexpected revoked response: %+v", revoked)
+			}
+			if !good.Matches(leaf, ca) || !revoked.Matches(revokedLeaf, ca) || good.Matches(revokedLeaf, ca) {
+				t.Errorf("Matches reported the wrong certificates")
+			}
+
+			if err := resp.CheckSignatureFrom(ca); err != nil {
+				t.Errorf("CheckSignatureFrom: %v", err)
+			}
+			if err := resp.CheckSignatureFrom(leaf); err == nil {
+				t.Errorf("CheckSignatureFrom succeeded for the wrong issuer")
+			}
+		})
+	}
+}
+
+// revocationTestKey is the key of a value in the context passed to
+// Certificate.VerifyContext by TestVerifyRevocation.
+type revocationTestKey struct{}
+
+func TestVerifyRevocation(t *testing.T) {
+	now := time.Now()
+	root, rootKey, err := generateCert("root", true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inter, interKey, err := generateCert("intermediate", true, root, rootKey, func(c *Certificate) {
+		c.CRLDistributionPoints = []string{"http://crl.example/root.crl"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("leaf", false, inter, interKey, func(c *Certificate) {
+		c.DNSNames = []string{"leaf.example"}
+		c.OCSPServer = []string{"http://ocsp.example"}
+		c.CRLDistributionPoints = []string{"http://crl.example/inter.crl"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	intermediates := NewCertPool()
+	intermediates.AddCert(inter)
+
+	rootCRL := revocationTestCRL(t, root, rootKey, nil, now.Add(-time.Hour), now.Add(time.Hour), nil)
+	goodCRL := revocationTestCRL(t, inter, interKey, nil, now.Add(-time.Hour), now.Add(time.Hour), nil)
+	revokedCRL := revocationTestCRL(t, inter, interKey, []*Certificate{leaf}, now.Add(-time.Hour), now.Add(time.Hour), nil)
+	staleCRL := revocationTestCRL(t, inter, interKey, nil, now.Add(-2*time.Hour), now.Add(-time.Hour), nil)
+	wrongCRL := revocationTestCRL(t, root, rootKey, []*Certificate{leaf}, now.Add(-time.Hour), now.Add(time.Hour), nil)
+
+	ocspGood := createTestOCSPResponse(t, inter, inter, interKey, []OCSPSingleResponse{
+		{SerialNumber: leaf.SerialNumber, Status: RevocationStatusGood, ThisUpdate: now.Add(-time.Minute), NextUpdate: now.Add(time.Hour)},
+	})
+	ocspRevoked := createTestOCSPResponse(t, inter, inter, interKey, []OCSPSingleResponse{
+		{SerialNumber: leaf.SerialNumber, Status: RevocationStatusRevoked, RevocationTime: now.Add(-time.Hour), ThisUpdate: now.Add(-time.Minute)},
+	})
+	ocspForged := createTestOCSPResponse(t, inter, root, rootKey, []OCSPSingleResponse{
+		{SerialNumber: leaf.SerialNumber, Status: RevocationStatusGood, ThisUpdate: now.Add(-time.Minute)},
+	})
+
+	for _, test := range []struct {
+		name       string
+		opts       RevocationOptions
+		wantReason InvalidReason
+		wantErr    bool
+		// wantStatus is the status of the leaf and the intermediate.
+		wantStatus [2]RevocationStatus
+	}{
+		{
+			name:       "no information, soft-fail",
+			wantStatus: [2]RevocationStatus{RevocationStatusUnknown, RevocationStatusUnknown},
+		},
+		{
+			name:       "no information, hard-fail",
+			opts:       RevocationOptions{HardFail: true},
+			wantErr:    true,
+			wantReason: RevocationCheckFailed,
+		},
+		{
+			name:       "good CRLs, hard-fail",
+			opts:       RevocationOptions{CRLs: []*RevocationList{rootCRL, goodCRL}, HardFail: true},
+			wantStatus: [2]RevocationStatus{RevocationStatusGood, RevocationStatusGood},
+		},
+		{
+			name:       "revoked by CRL",
+			opts:       RevocationOptions{CRLs: []*RevocationList{revokedCRL}},
+			wantErr:    true,
+			wantReason: Revoked,
+		},
+		{
+			name:       "stale CRL",
+			opts:       RevocationOptions{CRLs: []*RevocationList{staleCRL, rootCRL}, HardFail: true},
+			wantErr:    true,
+			wantReason: RevocationCheckFailed,
+		},
+		{
+			name:       "CRL from the wrong issuer",
+			opts:       RevocationOptions{CRLs: []*RevocationList{wrongCRL}},
+			wantStatus: [2]RevocationStatus{RevocationStatusUnknown, RevocationStatusGood},
+		},
+		{
+			name:       "stapled OCSP, leaf only, hard-fail",
+			opts:       RevocationOptions{OCSPResponses: [][]byte{ocspGood}, LeafOnly: true, HardFail: true},
+			wantStatus: [2]RevocationStatus{RevocationStatusGood},
+		},
+		{
+			name:       "stapled OCSP takes precedence",
+			opts:       RevocationOptions{OCSPResponses: [][]byte{ocspRevoked}, CRLs: []*RevocationList{goodCRL}},
+			wantErr:    true,
+			wantReason: Revoked,
+		},
+		{
+			name:       "forged OCSP",
+			opts:       RevocationOptions{OCSPResponses: [][]byte{ocspForged, {0x30, 0x00}}, LeafOnly: true, HardFail: true},
+			wantErr:    true,
+			wantReason: RevocationCheckFailed,
+		},
+		{
+			name: "fetched",
+			opts: RevocationOptions{
+				FetchOCSP: func(ctx context.Context, server string, cert, issuer *Certificate) ([]byte, error) {
+					if ctx.Value(revocationTestKey{}) == nil {
+						return nil, errors.New("FetchOCSP called without the Verify context")
+					}
+					if server != "http://ocsp.example" || cert != leaf || issuer != inter {
+						return nil, errors.New("unexpected OCSP fetch")
+					}
+					return ocspGood, nil
+				},
+				FetchCRL: func(ctx context.Context, url string) ([]byte, error) {
+					if ctx.Value(revocationTestKey{}) == nil {
+						return nil, errors.New("FetchCRL called without the Verify context")
+					}
+					if url != "http://crl.example/root.crl" {
+						return nil, errors.New("unexpected CRL fetch")
+					}
+					return rootCRL.Raw, nil
+				},
+				HardFail: true,
+			},
+			wantStatus: [2]RevocationStatus{RevocationStatusGood, RevocationStatusGood},
+		},
+		{
+			name: "fetch failure, soft-fail",
+			opts: RevocationOptions{
+				FetchOCSP: func(ctx context.Context, server string, cert, issuer *Certificate) ([]byte, error) {
+					return nil, errors.New("responder unavailable")
+				},
+			},
+			wantStatus: [2]RevocationStatus{RevocationStatusUnknown, RevocationStatusUnknown},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts := VerifyOptions{
+				DNSName:       "leaf.example",
+				Roots:         roots,
+				Intermediates: intermediates,
+				Revocation:    &test.opts,
+			}
+			ctx := context.WithValue(context.Background(), revocationTestKey{}, true)
+			chains, revocation, err := leaf.VerifyContext(ctx, opts)
+			if test.wantErr {
+				var invalid CertificateInvalidError
+				if !errors.As(err, &invalid) || invalid.Reason != test.wantReason {
+					t.Fatalf("Verify error = %v, want reason %d", err, test.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if len(revocation) != len(chains) {
+				t.Fatalf("got %d revocation results for %d chains", len(revocation), len(chains))
+			}
+			results := revocation[0]
+			checked, err := test.opts.Check(ctx, chains[0], time.Time{})
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if len(checked) != len(results) {
+				t.Fatalf("Check returned %d results, VerifyContext %d", len(checked), len(results))
+			}
+			wantLen := 2
+			if test.opts.LeafOnly {
+				wantLen = 1
+			}
+			if len(results) != wantLen {
+				t.Fatalf("got %d results, want %d", len(results), wantLen)
+			}
+			for i, res := range results {
+				if res.Certificate != chains[0][i] || res.Status != test.wantStatus[i] {
+					t.Errorf("result %d: status of %s is %v, want %v (err: %v)", i, res.Certificate.Subject.CommonName, res.Status, test.wantStatus[i], res.Err)
+				}
+				if res.Status == RevocationStatusGood && res.CRL == nil && res.OCSPResponse == nil {
+					t.Errorf("result %d: missing status source", i)
+				}
+				if checked[i].Status != res.Status {
+					t.Errorf("result %d: Check status is %v, VerifyContext status is %v", i, checked[i].Status, res.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestRevocationCRLScope(t *testing.T) {
+	now := time.Now()
+	root, rootKey, err := generateCert("root", true, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("leaf", false, root, rootKey, func(c *Certificate) {
+		c.CRLDistributionPoints = []string{"http://crl.example/1.crl"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := func(uri string, onlyCA bool) []pkix.Extension {
+		var b cryptobyte.Builder
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.Tag(6).ContextSpecific(), func(b *cryptobyte.Builder) {
+						b.AddBytes([]byte(uri))
+					})
+				})
+			})
+			if onlyCA {
+				b.AddASN1(cryptobyte_asn1.Tag(2).ContextSpecific(), func(b *cryptobyte.Builder) {
+					b.AddUint8(0xff)
+				})
+			}
+		})
+		return []pkix.Extension{{Id: oidExtensionIssuingDistributionPoint, Critical: true, Value: b.BytesOrPanic()}}
+	}
+
+	for _, test := range []struct {
+		name       string
+		extensions []pkix.Extension
+		want       RevocationStatus
+	}{
+		{"complete", nil, RevocationStatusGood},
+		{"matching partition", idp("http://crl.example/1.crl", false), RevocationStatusGood},
+		{"other partition", idp("http://crl.example/2.crl", false), RevocationStatusUnknown},
+		{"CA certificates only", idp("http://crl.example/1.crl", true), RevocationStatusUnknown},
+		{"delta CRL", []pkix.Extension{{Id: oidExtensionDeltaCRLIndicator, Critical: true, Value: []byte{0x02, 0x01, 0x01}}}, RevocationStatusUnknown},
+	} {
+		rl := revocationTestCRL(t, root, rootKey, nil, now.Add(-time.Hour), now.Add(time.Hour), test.extensions)
+		opts := &RevocationOptions{CRLs: []*RevocationList{rl}}
+		results, err := opts.Check(context.Background(), []*Certificate{leaf, root}, now)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if results[0].Status != test.want {
+			t.Errorf("%s: status is %v, want %v", test.name, results[0].Status, test.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package x509
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/x509/pkix"
 	"errors"
@@ -56,6 +57,17 @@ const (
 	// CANotAuthorizedForExtKeyUsage results when an intermediate or root
 	// certificate does not permit a requested extended key usage.
 	CANotAuthorizedForExtKeyUsage
+	// Revoked results when a certificate has been revoked, according to
+	// the VerifyOptions.Revocation checks.
+	Revoked
+	// RevocationCheckFailed results when the revocation status of a
+	// certificate can't be determined and VerifyOptions.Revocation
+	// requires it.
+	RevocationCheckFailed
+	// InsufficientSCTs results when a leaf certificate does not have enough
+	// valid SCTs from distinct logs, as required by
+	// VerifyOptions.CertificateTransparency.
+	InsufficientSCTs
 )
 
 // CertificateInvalidError results when an odd error occurs. Users of this
@@ -86,6 +98,12 @@ func (e CertificateInvalidError) Error() string {
 		return "x509: issuer has name constraints but leaf doesn't have a SAN extension"
 	case UnconstrainedName:
 		return "x509: issuer has name constraints but leaf contains unknown or unconstrained name: " + e.Detail
+	case Revoked:
+		return "x509: certificate has been revoked: " + e.Detail
+	case RevocationCheckFailed:
+		return "x509: cannot determine certificate revocation status: " + e.Detail
+	case InsufficientSCTs:
+		return "x509: not enough valid signed certificate timestamps from distinct logs: " + e.Detail
 	}
 	return "x509: unknown error"
 }
@@ -201,6 +219,19 @@ type VerifyOptions struct {
 	// certificates from consuming excessive amounts of CPU time when
 	// validating. It does not apply to the platform verifier.
 	MaxConstraintComparisions int
+
+	// Revocation, if not nil, enables checking the revocation status of the
+	// certificates of the verified chains, including the chains returned by
+	// the platform verifier. Chains with a revoked certificate, or with a
+	// certificate whose status can't be determined if Revocation.HardFail is
+	// set, are discarded.
+	Revocation *RevocationOptions
+
+	// CertificateTransparency, if not nil, requires the leaf certificate to
+	// have valid signed certificate timestamps from a number of distinct
+	// Certificate Transparency logs. Chains for which the leaf SCTs can't
+	// be verified are discarded.
+	CertificateTransparency *CTPolicy
 }
 
 const (
@@ -749,8 +780,65 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 //
 // Certificates other than c in the returned chains should not be modified.
 //
-// WARNING: this function doesn't do any revocation checking.
+// Revocation checking is only performed if opts.Revocation is set. The
+// status of the certificates of the returned chains is returned by
+// [Certificate.VerifyContext].
+//
+// Certificate Transparency policies are only enforced if
+// opts.CertificateTransparency is set.
 func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err error) {
+	chains, _, err = c.VerifyContext(context.Background(), opts)
+	return chains, err
+}
+
+// VerifyContext is like [Certificate.Verify], but passes ctx to the
+// FetchOCSP and FetchCRL functions of opts.Revocation, and also returns the
+// revocation status of the certificates of the returned chains.
+//
+// If opts.Revocation is set, revocation[i] is the status of the certificates
+// of chains[i], as returned by [RevocationOptions.Check]. Otherwise,
+// revocation is nil.
+func (c *Certificate) VerifyContext(ctx context.Context, opts VerifyOptions) (chains [][]*Certificate, revocation [][]RevocationResult, err error) {
+	candidateChains, err := c.verify(opts)
+	if err != nil || opts.Revocation == nil && opts.CertificateTransparency == nil {
+		return candidateChains, nil, err
+	}
+
+	// The Certificate Transparency policy is checked first, as it doesn't
+	// require fetching anything.
+	var rc *revocationChecker
+	if opts.Revocation != nil {
+		rc = opts.Revocation.newChecker(ctx, opts.CurrentTime)
+	}
+	for _, chain := range candidateChains {
+		var chainErr error
+		if opts.CertificateTransparency != nil {
+			_, chainErr = opts.CertificateTransparency.Check(chain, opts.CurrentTime)
+		}
+		var results []RevocationResult
+		if chainErr == nil && rc != nil {
+			results, chainErr = rc.check(chain)
+		}
+		if chainErr != nil {
+			if err == nil {
+				err = chainErr
+			}
+			continue
+		}
+		chains = append(chains, chain)
+		if rc != nil {
+			revocation = append(revocation, results)
+		}
+	}
+	if len(chains) == 0 {
+		return nil, nil, err
+	}
+	return chains, revocation, nil
+}
+
+// verify builds the chains for Verify, without checking revocation or
+// Certificate Transparency.
+func (c *Certificate) verify(opts VerifyOptions) (chains [][]*Certificate, err error) {
 	// Platform-specific verification needs the ASN.1 contents so
 	// this makes the behavior consistent across platforms.
 	if len(c.Raw) == 0 {
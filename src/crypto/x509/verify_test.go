@@ -1716,7 +1716,7 @@ func TestValidHostname(t *testing.T) {
 	}
 }
 
-func generateCert(cn string, isCA bool, issuer *Certificate, issuerKey crypto.PrivateKey) (*Certificate, crypto.PrivateKey, error) {
+func generateCert(cn string, isCA bool, issuer *Certificate, issuerKey crypto.Signer, mutate func(*Certificate)) (*Certificate, crypto.Signer, error) {
 	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		return nil, nil, err
@@ -1736,6 +1736,12 @@ func generateCert(cn string, isCA bool, issuer *Certificate, issuerKey crypto.Pr
 		BasicConstraintsValid: true,
 		IsCA:                  isCA,
 	}
+	if isCA {
+		template.KeyUsage |= KeyUsageCRLSign
+	}
+	if mutate != nil {
+		mutate(template)
+	}
 	if issuer == nil {
 		issuer = template
 		issuerKey = priv
@@ -1762,21 +1768,21 @@ func TestPathologicalChain(t *testing.T) {
 	// path building worst behavior.
 	roots, intermediates := NewCertPool(), NewCertPool()
 
-	parent, parentKey, err := generateCert("Root CA", true, nil, nil)
+	parent, parentKey, err := generateCert("Root CA", true, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 	roots.AddCert(parent)
 
 	for i := 1; i < 100; i++ {
-		parent, parentKey, err = generateCert("Intermediate CA", true, parent, parentKey)
+		parent, parentKey, err = generateCert("Intermediate CA", true, parent, parentKey, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
 		intermediates.AddCert(parent)
 	}
 
-	leaf, _, err := generateCert("Leaf", false, parent, parentKey)
+	leaf, _, err := generateCert("Leaf", false, parent, parentKey, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1800,7 +1806,7 @@ func TestLongChain(t *testing.T) {
 
 	roots, intermediates := NewCertPool(), NewCertPool()
 
-	parent, parentKey, err := generateCert("Root CA", true, nil, nil)
+	parent, parentKey, err := generateCert("Root CA", true, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1808,14 +1814,14 @@ func TestLongChain(t *testing.T) {
 
 	for i := 1; i < 15; i++ {
 		name := fmt.Sprintf("Intermediate CA #%d", i)
-		parent, parentKey, err = generateCert(name, true, parent, parentKey)
+		parent, parentKey, err = generateCert(name, true, parent, parentKey, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
 		intermediates.AddCert(parent)
 	}
 
-	leaf, _, err := generateCert("Leaf", false, parent, parentKey)
+	leaf, _, err := generateCert("Leaf", false, parent, parentKey, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -268,7 +268,8 @@ var depsRules = `
 	# compression
 	FMT, encoding/binary, hash/adler32, hash/crc32, sort
 	< compress/bzip2, compress/flate, compress/lzw, internal/zstd
-	< archive/zip, compress/gzip, compress/zlib;
+	< compress/zstd
+	< compress/gzip, compress/zlib;
 
 	# templates
 	FMT
@@ -377,7 +378,10 @@ var depsRules = `
 	CGO, OS
 	< plugin;
 
-	CGO, FMT
+	OS
+	< internal/extract;
+
+	CGO, FMT, internal/extract
 	< os/user
 	< archive/tar;
 
@@ -497,7 +501,8 @@ var depsRules = `
 	CRYPTO, FMT, math/big
 	< crypto/internal/boring/bbig
 	< crypto/rand
-	< crypto/internal/mlkem768
+	< crypto/internal/mlkem
+	< crypto/mlkem
 	< crypto/ed25519
 	< encoding/asn1
 	< golang.org/x/crypto/cryptobyte/asn1
@@ -509,6 +514,10 @@ var depsRules = `
 
 	CGO, net !< CRYPTO-MATH;
 
+	# archive/zip supports WinZip AES encryption.
+	compress/zstd, internal/extract, CRYPTO-MATH
+	< archive/zip;
+
 	# TLS, Prince of Dependencies.
 	CRYPTO-MATH, NET, container/list, encoding/hex, encoding/pem
 	< golang.org/x/crypto/internal/alias
@@ -518,6 +527,7 @@ var depsRules = `
 	< golang.org/x/crypto/chacha20poly1305
 	< golang.org/x/crypto/hkdf
 	< crypto/internal/hpke
+	< crypto/x509/internal/hashoid
 	< crypto/x509/internal/macos
 	< crypto/x509/pkix;
 
@@ -525,6 +535,9 @@ var depsRules = `
 	< crypto/x509
 	< crypto/tls;
 
+	crypto/internal/hpke
+	< crypto/hpke;
+
 	# crypto-aware packages
 
 	DEBUG, go/build, go/types, text/scanner, crypto/md5
@@ -559,6 +572,7 @@ var depsRules = `
 	< net/http/httptrace;
 
 	compress/gzip,
+	compress/zlib,
 	golang.org/x/net/http/httpguts,
 	golang.org/x/net/http/httpproxy,
 	golang.org/x/net/http2/hpack,
@@ -575,10 +589,17 @@ var depsRules = `
 	encoding/json, net/http
 	< expvar;
 
+	net/http
+	< crypto/x509/ocsp;
+
 	net/http, net/http/internal/ascii
-	< net/http/cookiejar, net/http/httputil;
+	< net/http/cookiejar, net/http/httpcache, net/http/httputil, net/http/sse,
+	  net/http/websocket;
 
-	net/http, flag
+	FMT
+	< internal/txtar;
+
+	net/http, flag, internal/txtar
 	< net/http/httptest;
 
 	net/http, regexp
@@ -640,9 +661,6 @@ var depsRules = `
 	FMT, sort
 	< internal/diff;
 
-	FMT
-	< internal/txtar;
-
 	CRYPTO-MATH, testing
 	< crypto/internal/cryptotest;
 
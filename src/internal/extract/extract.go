@@ -0,0 +1,265 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package extract implements the parts of archive extraction
+// shared by archive/tar and archive/zip.
+//
+// Entry names and link targets are slash-separated paths as recorded
+// in the archive. The errors returned for an insecure path, an insecure
+// link, or an exceeded size limit are the sentinels below; callers
+// report them with their own error types.
+package extract
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInsecurePath reports an entry whose name is not local,
+	// or that would be created beneath a symbolic link.
+	ErrInsecurePath = errors.New("insecure path")
+
+	// ErrInsecureLink reports a link whose target is outside
+	// the extraction directory.
+	ErrInsecureLink = errors.New("insecure link")
+
+	// ErrSizeLimit reports that the total size limit was exceeded.
+	ErrSizeLimit = errors.New("size limit exceeded")
+)
+
+// A Dir is a directory that archive entries are extracted into.
+// It assumes that the directory is not concurrently modified
+// by other processes.
+type Dir struct {
+	dir     string
+	maxSize int64 // if > 0, limit on the total bytes written
+	size    int64 // bytes written so far
+}
+
+// NewDir creates dir if necessary and returns a Dir for it.
+// If maxSize is positive, it limits the total number of bytes
+// written to the files in dir.
+func NewDir(dir string, maxSize int64) (*Dir, error) {
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, err
+	}
+	return &Dir{dir: dir, maxSize: maxSize}, nil
+}
+
+// Exceeds reports whether writing size more bytes
+// would exceed the size limit.
+func (d *Dir) Exceeds(size int64) bool {
+	return d.maxSize > 0 && size > d.maxSize-d.size
+}
+
+// Mkdir creates the directory name. An existing directory is left
+// unchanged. The directory is always readable, writable, and searchable
+// by its owner, so that entries may be extracted into it. If perm is
+// zero, no mode was recorded and 0o777 is used.
+func (d *Dir) Mkdir(name string, perm fs.FileMode) error {
+	p, err := d.path(name, true)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Lstat(p); err == nil && fi.IsDir() {
+		return nil
+	}
+	if err := removeExisting(p); err != nil {
+		return err
+	}
+	if perm == 0 {
+		perm = 0o777
+	}
+	return os.Mkdir(p, perm|0o700)
+}
+
+// WriteFile creates the regular file name with the contents of r,
+// replacing any existing file other than a directory. If perm is zero,
+// no mode was recorded and 0o666 is used. The modification time
+// of the file is set to modTime.
+func (d *Dir) WriteFile(name string, r io.Reader, perm fs.FileMode, modTime time.Time) error {
+	p, err := d.path(name, true)
+	if err != nil {
+		return err
+	}
+	if err := removeExisting(p); err != nil {
+		return err
+	}
+	if perm == 0 {
+		perm = 0o666
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	err = d.Copy(f, r)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(p, time.Time{}, modTime)
+}
+
+// Symlink creates the symbolic link name pointing to target.
+//
+// The target must be a relative path that stays within the directory
+// when resolved from the directory containing the link. It is resolved
+// one element at a time against the entries already extracted: it may
+// not pass through an existing symbolic link, and ".." may only follow
+// an existing directory, so that the target means what it says when
+// read lexically.
+func (d *Dir) Symlink(name, target string) error {
+	p, err := d.path(name, true)
+	if err != nil {
+		return err
+	}
+	if !d.localTarget(path.Dir(path.Clean(name)), target) {
+		return ErrInsecureLink
+	}
+	if err := removeExisting(p); err != nil {
+		return err
+	}
+	return os.Symlink(filepath.FromSlash(target), p)
+}
+
+// localTarget reports whether the symbolic link target, relative to
+// the entry directory dir, resolves within d as described for Symlink.
+func (d *Dir) localTarget(dir, target string) bool {
+	if target == "" || path.IsAbs(target) || filepath.IsAbs(target) {
+		return false
+	}
+	var elems []string
+	if dir != "." {
+		elems = strings.Split(dir, "/")
+	}
+	for _, elem := range strings.Split(target, "/") {
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if len(elems) == 0 {
+				return false
+			}
+			fi, err := os.Lstat(d.join(elems))
+			if err != nil || !fi.IsDir() {
+				return false
+			}
+			elems = elems[:len(elems)-1]
+			continue
+		}
+		elems = append(elems, elem)
+		p := d.join(elems)
+		if p == "" {
+			return false
+		}
+		if fi, err := os.Lstat(p); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// join returns the path within d of the slash-separated elements,
+// or "" if they do not name a local path.
+func (d *Dir) join(elems []string) string {
+	local, err := filepath.Localize(path.Join(elems...))
+	if err != nil || !filepath.IsLocal(local) {
+		return ""
+	}
+	return filepath.Join(d.dir, local)
+}
+
+// Link creates the hard link name to the entry target,
+// which must be a regular file already extracted.
+func (d *Dir) Link(name, target string) error {
+	t, err := d.path(target, false)
+	if err != nil {
+		return ErrInsecureLink
+	}
+	if fi, err := os.Lstat(t); err != nil || !fi.Mode().IsRegular() {
+		return ErrInsecureLink
+	}
+	p, err := d.path(name, true)
+	if err != nil {
+		return err
+	}
+	if t == p {
+		return nil
+	}
+	if err := removeExisting(p); err != nil {
+		return err
+	}
+	return os.Link(t, p)
+}
+
+// Copy copies r to w, enforcing the size limit.
+func (d *Dir) Copy(w io.Writer, r io.Reader) error {
+	if d.maxSize <= 0 {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	n, err := io.Copy(w, io.LimitReader(r, d.maxSize-d.size))
+	d.size += n
+	if err != nil {
+		return err
+	}
+	// Check that r has no more data.
+	var b [1]byte
+	switch n, err := io.ReadFull(r, b[:]); {
+	case n > 0:
+		return ErrSizeLimit
+	case err != io.EOF:
+		return err
+	}
+	return nil
+}
+
+// path returns the path within d of the entry with the given name.
+// If mkdir is true, it creates the parent directories of the entry
+// if necessary; otherwise it returns an error if one does not exist.
+// It returns ErrInsecurePath if the path is outside d, or if any of
+// its parent directories is a symbolic link.
+func (d *Dir) path(name string, mkdir bool) (string, error) {
+	local, err := filepath.Localize(path.Clean(name))
+	if err != nil || !filepath.IsLocal(local) {
+		return "", ErrInsecurePath
+	}
+	dir := d.dir
+	for _, elem := range strings.Split(filepath.Dir(local), string(filepath.Separator)) {
+		if elem == "." {
+			break
+		}
+		dir = filepath.Join(dir, elem)
+		fi, err := os.Lstat(dir)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && mkdir:
+			err = os.Mkdir(dir, 0o777)
+		case err == nil && fi.Mode()&fs.ModeSymlink != 0:
+			return "", ErrInsecurePath
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(d.dir, local), nil
+}
+
+// removeExisting removes any file at name, other than a directory,
+// so that it may be replaced without following a symbolic link.
+func removeExisting(name string) error {
+	fi, err := os.Lstat(name)
+	if err != nil || fi.IsDir() {
+		return nil
+	}
+	return os.Remove(name)
+}
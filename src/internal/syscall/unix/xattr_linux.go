@@ -0,0 +1,76 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unix
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Llistxattr calls the llistxattr system call, which lists the extended
+// attributes of path without following a final symbolic link.
+func Llistxattr(path string, dest []byte) (int, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	n, _, errno := syscall.Syscall(syscall.SYS_LLISTXATTR,
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(unsafe.SliceData(dest))),
+		uintptr(len(dest)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// Lgetxattr calls the lgetxattr system call, which retrieves the value
+// of the extended attribute attr of path without following a final
+// symbolic link.
+func Lgetxattr(path, attr string, dest []byte) (int, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	a, err := syscall.BytePtrFromString(attr)
+	if err != nil {
+		return 0, err
+	}
+	n, _, errno := syscall.Syscall6(syscall.SYS_LGETXATTR,
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(a)),
+		uintptr(unsafe.Pointer(unsafe.SliceData(dest))),
+		uintptr(len(dest)),
+		0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// Lsetxattr calls the lsetxattr system call, which sets the value
+// of the extended attribute attr of path without following a final
+// symbolic link.
+func Lsetxattr(path, attr string, data []byte, flags int) error {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	a, err := syscall.BytePtrFromString(attr)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_LSETXATTR,
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(a)),
+		uintptr(unsafe.Pointer(unsafe.SliceData(data))),
+		uintptr(len(data)),
+		uintptr(flags),
+		0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// dictMagic is the magic number at the start of a formatted dictionary.
+// RFC 5.
+const dictMagic = 0xec30a437
+
+// A Dict is a zstd dictionary.
+// It is either a formatted dictionary as described in RFC 5,
+// or raw content that is used as a prefix for the data.
+// A Dict is not modified after it is created, and may be shared
+// by multiple Readers and Writers.
+type Dict struct {
+	// The dictionary ID. This is 0 for a raw content dictionary.
+	id uint32
+
+	// The dictionary content.
+	content []byte
+
+	// The initial repeated offsets.
+	repeatedOffsets [3]uint32
+
+	// The Huffman table used for literals, if any.
+	huffmanTable     []uint16
+	huffmanTableBits int
+
+	// The FSE tables used for sequences, if any.
+	seqTables    [3][]fseBaselineEntry
+	seqTableBits [3]uint8
+}
+
+// ParseDict parses a zstd dictionary.
+// If b starts with the magic number for a formatted dictionary,
+// it must be a valid formatted dictionary.
+// Otherwise b is treated as raw content.
+// The returned Dict retains b.
+func ParseDict(b []byte) (*Dict, error) {
+	d := &Dict{
+		repeatedOffsets: [3]uint32{1, 4, 8},
+	}
+	if len(b) < 8 || binary.LittleEndian.Uint32(b) != dictMagic {
+		d.content = b
+		return d, nil
+	}
+
+	d.id = binary.LittleEndian.Uint32(b[4:])
+	if d.id == 0 {
+		return nil, errors.New("zstd: invalid dictionary: zero dictionary ID")
+	}
+
+	// Use a Reader to parse the entropy tables. RFC 5.
+	r := new(Reader)
+	data := block(b)
+	off := 8
+
+	d.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+	huffBits, off, err := r.readHuff(data, off, d.huffmanTable)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: invalid dictionary: %w", err)
+	}
+	d.huffmanTableBits = huffBits
+
+	for _, kind := range [...]seqCode{seqOffset, seqMatch, seqLiteral} {
+		info := &seqCodeInfo[kind]
+		scratch := make([]fseEntry, 1<<info.maxBits)
+		tableBits, roff, err := r.readFSE(data, off, info.maxSym, info.maxBits, scratch)
+		if err != nil {
+			return nil, fmt.Errorf("zstd: invalid dictionary: %w", err)
+		}
+		table := make([]fseBaselineEntry, 1<<tableBits)
+		if err := info.toBaseline(r, roff, scratch[:1<<tableBits], table); err != nil {
+			return nil, fmt.Errorf("zstd: invalid dictionary: %w", err)
+		}
+		d.seqTables[kind] = table
+		d.seqTableBits[kind] = uint8(tableBits)
+		off = roff
+	}
+
+	if off+12 > len(b) {
+		return nil, errors.New("zstd: invalid dictionary: missing repeated offsets")
+	}
+	d.content = b[off+12:]
+	for i := range d.repeatedOffsets {
+		rep := binary.LittleEndian.Uint32(b[off+4*i:])
+		if rep == 0 || rep > uint32(len(d.content)) {
+			return nil, errors.New("zstd: invalid dictionary: invalid repeated offset")
+		}
+		d.repeatedOffsets[i] = rep
+	}
+
+	return d, nil
+}
+
+// ID returns the dictionary ID.
+// This is 0 for a raw content dictionary.
+func (d *Dict) ID() uint32 {
+	return d.id
+}
@@ -0,0 +1,212 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+// encoder holds the state used to compress blocks.
+type encoder struct {
+	m matcher
+
+	// Scratch space for a block.
+	seqs []seq
+	lits []byte
+
+	// Sequence codes for the block.
+	llCodes []uint8
+	mlCodes []uint8
+	ofCodes []uint8
+
+	// Scratch space for building tables.
+	litCounts [256]uint32
+	huff      huffEncoder
+	seqEnc    [3]fseEncoder
+}
+
+// Lookup tables for small literal lengths and match lengths.
+var (
+	llCodeTable [64]uint8
+	mlCodeTable [128]uint8
+)
+
+func init() {
+	for ll := range llCodeTable {
+		if ll < literalLengthOffset {
+			llCodeTable[ll] = uint8(ll)
+			continue
+		}
+		for i, b := range literalLengthBase {
+			if b&0xffffff <= uint32(ll) {
+				llCodeTable[ll] = uint8(literalLengthOffset + i)
+			}
+		}
+	}
+	for mlBase := range mlCodeTable {
+		if mlBase < matchLengthOffset {
+			mlCodeTable[mlBase] = uint8(mlBase)
+			continue
+		}
+		for i, b := range matchLengthBase {
+			if b&0xffffff <= uint32(mlBase+3) {
+				mlCodeTable[mlBase] = uint8(matchLengthOffset + i)
+			}
+		}
+	}
+}
+
+// llCode returns the code for a literal length. RFC 3.1.1.3.2.1.1.
+func llCode(ll uint32) uint8 {
+	if ll < uint32(len(llCodeTable)) {
+		return llCodeTable[ll]
+	}
+	return uint8(highBit(ll) + 19)
+}
+
+// mlCode returns the code for a match length. RFC 3.1.1.3.2.1.1.
+func mlCode(ml uint32) uint8 {
+	mlBase := ml - 3
+	if mlBase < uint32(len(mlCodeTable)) {
+		return mlCodeTable[mlBase]
+	}
+	return uint8(highBit(mlBase) + 36)
+}
+
+// appendCompressedBlock appends the contents of a Compressed_Block
+// holding seqs and lits to out. RFC 3.1.1.3.
+func (e *encoder) appendCompressedBlock(out []byte, seqs []seq, lits []byte) []byte {
+	// The literals in the sequences come first,
+	// followed by any trailing literals.
+	out = e.appendLiterals(out, lits)
+
+	// Sequences_Section_Header. RFC 3.1.1.3.2.1.
+	nbSeq := len(seqs)
+	switch {
+	case nbSeq < 128:
+		out = append(out, byte(nbSeq))
+	case nbSeq < 0x7f00:
+		out = append(out, byte(nbSeq>>8)+128, byte(nbSeq))
+	default:
+		n := nbSeq - 0x7f00
+		out = append(out, 255, byte(n), byte(n>>8))
+	}
+	if nbSeq == 0 {
+		return out
+	}
+
+	e.llCodes = e.llCodes[:0]
+	e.mlCodes = e.mlCodes[:0]
+	e.ofCodes = e.ofCodes[:0]
+	var llCounts [36]uint32
+	var ofCounts [32]uint32
+	var mlCounts [53]uint32
+	for _, s := range seqs {
+		ll := llCode(s.litLen)
+		ml := mlCode(s.matchLen)
+		of := uint8(highBit(s.offVal))
+		e.llCodes = append(e.llCodes, ll)
+		e.mlCodes = append(e.mlCodes, ml)
+		e.ofCodes = append(e.ofCodes, of)
+		llCounts[ll]++
+		mlCounts[ml]++
+		ofCounts[of]++
+	}
+
+	// Symbol_Compression_Modes, followed by the tables.
+	modeOff := len(out)
+	out = append(out, 0)
+	var mode byte
+	var llEnc, ofEnc, mlEnc *fseEncoder
+	out, mode, llEnc = e.appendSeqTable(out, seqLiteral, llCounts[:], nbSeq)
+	out[modeOff] |= mode << 6
+	out, mode, ofEnc = e.appendSeqTable(out, seqOffset, ofCounts[:], nbSeq)
+	out[modeOff] |= mode << 4
+	out, mode, mlEnc = e.appendSeqTable(out, seqMatch, mlCounts[:], nbSeq)
+	out[modeOff] |= mode << 2
+
+	// The bitstream is read in reverse,
+	// so we write the sequences in reverse.
+	// RFC 3.1.1.3.2.2.
+	bw := bitWriter{out: out}
+	last := nbSeq - 1
+	llState := llEnc.init(e.llCodes[last])
+	mlState := mlEnc.init(e.mlCodes[last])
+	ofState := ofEnc.init(e.ofCodes[last])
+	e.addExtraBits(&bw, seqs[last], e.llCodes[last], e.mlCodes[last], e.ofCodes[last])
+	for i := last - 1; i >= 0; i-- {
+		ofState = ofEnc.encode(&bw, ofState, e.ofCodes[i])
+		mlState = mlEnc.encode(&bw, mlState, e.mlCodes[i])
+		llState = llEnc.encode(&bw, llState, e.llCodes[i])
+		e.addExtraBits(&bw, seqs[i], e.llCodes[i], e.mlCodes[i], e.ofCodes[i])
+	}
+	mlEnc.flush(&bw, mlState)
+	ofEnc.flush(&bw, ofState)
+	llEnc.flush(&bw, llState)
+	return bw.close()
+}
+
+// addExtraBits writes the bits that are added to the baselines
+// of the codes for s. The reader reads these in the order
+// offset, match length, literal length.
+func (e *encoder) addExtraBits(bw *bitWriter, s seq, ll, ml, of uint8) {
+	if ll >= literalLengthOffset {
+		b := literalLengthBase[ll-literalLengthOffset]
+		bw.addBits(s.litLen-b&0xffffff, uint8(b>>24))
+	}
+	if ml >= matchLengthOffset {
+		b := matchLengthBase[ml-matchLengthOffset]
+		bw.addBits(s.matchLen-b&0xffffff, uint8(b>>24))
+	}
+	bw.addBits(s.offVal-1<<of, of)
+}
+
+// appendSeqTable chooses how to encode the codes of one kind,
+// whose frequencies are in counts, and appends any table description
+// to out. It returns the Compression_Mode and the encoder to use.
+// RFC 3.1.1.3.2.1.
+func (e *encoder) appendSeqTable(out []byte, kind seqCode, counts []uint32, nbSeq int) ([]byte, byte, *fseEncoder) {
+	maxSym := 0
+	for i, c := range counts {
+		if c > 0 {
+			maxSym = i
+		}
+	}
+	if int(counts[maxSym]) == nbSeq {
+		// RLE_Mode.
+		e.seqEnc[kind].buildRLE()
+		return append(out, byte(maxSym)), 1, &e.seqEnc[kind]
+	}
+
+	var predef *fseEncoder
+	var predefNorm []int16
+	switch kind {
+	case seqLiteral:
+		predef, predefNorm = &predefinedLiteralEncoder, predefinedLiteralNorm[:]
+	case seqOffset:
+		predef, predefNorm = &predefinedOffsetEncoder, predefinedOffsetNorm[:]
+	case seqMatch:
+		predef, predefNorm = &predefinedMatchEncoder, predefinedMatchNorm[:]
+	}
+	predefCost, predefOK := fseCost(counts, predefNorm, int(predef.tableBits))
+
+	// For a handful of sequences a new table is unlikely to pay
+	// for itself.
+	if predefOK && nbSeq < 16 {
+		return out, 0, predef
+	}
+
+	info := &seqCodeInfo[kind]
+	tableBits := fseTableBits(nbSeq, maxSym, info.maxBits)
+	var norm [53]int16
+	normalizeFSE(norm[:maxSym+1], counts[:maxSym+1], nbSeq, tableBits)
+	newOut := writeFSE(out, norm[:maxSym+1], tableBits)
+	cost, _ := fseCost(counts, norm[:maxSym+1], tableBits)
+	cost += (len(newOut) - len(out)) * 8
+
+	if predefOK && predefCost <= cost {
+		return out, 0, predef
+	}
+
+	// FSE_Compressed_Mode.
+	e.seqEnc[kind].build(norm[:maxSym+1], tableBits)
+	return newOut, 2, &e.seqEnc[kind]
+}
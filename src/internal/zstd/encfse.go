@@ -0,0 +1,389 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"math/bits"
+)
+
+// bitWriter writes a bit stream. Bits are written starting with the
+// low bit of each byte. A bitWriter is used both for forward streams,
+// such as FSE table descriptions, and for streams that are read in
+// reverse, such as Huffman and sequence streams.
+type bitWriter struct {
+	out  []byte // the bytes written so far
+	bits uint64 // pending bits
+	cnt  uint   // number of valid bits in bits
+}
+
+// addBits adds the low n bits of v to the stream.
+// n must be no more than 32.
+func (bw *bitWriter) addBits(v uint32, n uint8) {
+	bw.bits |= uint64(v&(1<<n-1)) << bw.cnt
+	bw.cnt += uint(n)
+	for bw.cnt >= 8 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits >>= 8
+		bw.cnt -= 8
+	}
+}
+
+// flush writes any pending bits, padded with zeroes to a byte boundary,
+// and returns the resulting bytes.
+func (bw *bitWriter) flush() []byte {
+	if bw.cnt > 0 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits = 0
+		bw.cnt = 0
+	}
+	return bw.out
+}
+
+// close finishes a stream that will be read in reverse by adding the
+// final 1 bit that marks the start of the stream for the reader.
+func (bw *bitWriter) close() []byte {
+	bw.addBits(1, 1)
+	return bw.flush()
+}
+
+// fseSymbolTransform is the information needed to encode one symbol.
+type fseSymbolTransform struct {
+	deltaFindState int32
+	deltaNbBits    uint32
+}
+
+// fseEncoder is an FSE encoding table. It is the inverse of the
+// decoding table built by buildFSE.
+type fseEncoder struct {
+	tableBits  uint8
+	stateTable []uint16
+	symTT      [256]fseSymbolTransform
+
+	// rle is set for a table that always produces a single symbol
+	// and uses no bits at all. This is used for RLE_Mode.
+	rle bool
+}
+
+// fseState is the state of an FSE encoder.
+type fseState uint32
+
+// build builds the encoding table for the probabilities in norm.
+// The probabilities must have been produced by normalizeFSE or
+// taken from the RFC, so that they sum to 1<<tableBits.
+func (e *fseEncoder) build(norm []int16, tableBits int) {
+	tableSize := 1 << tableBits
+	highThreshold := tableSize - 1
+
+	e.tableBits = uint8(tableBits)
+	e.rle = false
+	if cap(e.stateTable) < tableSize {
+		e.stateTable = make([]uint16, tableSize)
+	}
+	e.stateTable = e.stateTable[:tableSize]
+
+	// Spread the symbols the same way that buildFSE does.
+	var tableSym [1 << 9]uint8
+	var cumul [257]int
+	for i, n := range norm {
+		if n == -1 {
+			cumul[i+1] = cumul[i] + 1
+			tableSym[highThreshold] = uint8(i)
+			highThreshold--
+		} else {
+			cumul[i+1] = cumul[i] + int(n)
+		}
+	}
+
+	pos := 0
+	step := (tableSize >> 1) + (tableSize >> 3) + 3
+	mask := tableSize - 1
+	for i, n := range norm {
+		for j := 0; j < int(n); j++ {
+			tableSym[pos] = uint8(i)
+			pos = (pos + step) & mask
+			for pos > highThreshold {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+
+	// The states for each symbol are in the order that
+	// the decoder assigns them.
+	for u := 0; u < tableSize; u++ {
+		sym := tableSym[u]
+		e.stateTable[cumul[sym]] = uint16(tableSize + u)
+		cumul[sym]++
+	}
+
+	total := int32(0)
+	for i, n := range norm {
+		tt := &e.symTT[i]
+		switch n {
+		case 0:
+			*tt = fseSymbolTransform{}
+		case -1, 1:
+			tt.deltaNbBits = uint32(tableBits<<16) - uint32(tableSize)
+			tt.deltaFindState = total - 1
+			total++
+		default:
+			maxBitsOut := uint32(tableBits - highBit(uint32(n-1)))
+			minStatePlus := uint32(n) << maxBitsOut
+			tt.deltaNbBits = maxBitsOut<<16 - minStatePlus
+			tt.deltaFindState = total - int32(n)
+			total += int32(n)
+		}
+	}
+}
+
+// buildRLE builds a table for RLE_Mode.
+func (e *fseEncoder) buildRLE() {
+	e.tableBits = 0
+	e.rle = true
+}
+
+// init returns the initial state for encoding sym.
+// The initial state is the one that will be decoded last.
+func (e *fseEncoder) init(sym uint8) fseState {
+	if e.rle {
+		return 0
+	}
+	tt := e.symTT[sym]
+	nbBitsOut := (tt.deltaNbBits + (1 << 15)) >> 16
+	value := nbBitsOut<<16 - tt.deltaNbBits
+	return fseState(e.stateTable[int32(value>>nbBitsOut)+tt.deltaFindState])
+}
+
+// encode writes the bits needed to move from state to a state that
+// decodes as sym, and returns the new state.
+func (e *fseEncoder) encode(bw *bitWriter, state fseState, sym uint8) fseState {
+	if e.rle {
+		return state
+	}
+	tt := e.symTT[sym]
+	nbBitsOut := (uint32(state) + tt.deltaNbBits) >> 16
+	bw.addBits(uint32(state), uint8(nbBitsOut))
+	return fseState(e.stateTable[int32(uint32(state)>>nbBitsOut)+tt.deltaFindState])
+}
+
+// flush writes the final state, which the decoder reads first.
+func (e *fseEncoder) flush(bw *bitWriter, state fseState) {
+	bw.addBits(uint32(state), e.tableBits)
+}
+
+// highBit returns the index of the highest set bit in v.
+// v must not be 0.
+func highBit(v uint32) int {
+	return 31 - bits.LeadingZeros32(v)
+}
+
+// fseTableBits returns the table size to use when encoding total
+// symbols whose largest value is maxSym, with a table of at most
+// maxBits bits.
+func fseTableBits(total, maxSym, maxBits int) int {
+	tableBits := maxBits
+	if total > 1 {
+		// No point in a table larger than the input.
+		if b := highBit(uint32(total-1)) - 2; b < tableBits {
+			tableBits = b
+		}
+	}
+	// The table must be large enough for all the symbols.
+	minBits := highBit(uint32(maxSym)) + 2
+	if total > 1 {
+		if b := highBit(uint32(total-1)) + 1; b < minBits {
+			minBits = b
+		}
+	}
+	if minBits > tableBits {
+		tableBits = minBits
+	}
+	if tableBits < 5 {
+		tableBits = 5
+	}
+	if tableBits > maxBits {
+		tableBits = maxBits
+	}
+	return tableBits
+}
+
+// normalizeFSE converts the symbol counts in counts, which sum to
+// total, into FSE probabilities that sum to 1<<tableBits. Every symbol
+// with a non-zero count gets a non-zero probability. Symbols whose
+// probability rounds to zero get the special value -1.
+// The number of symbols with a non-zero count must be
+// less than 1<<tableBits.
+func normalizeFSE(norm []int16, counts []uint32, total int, tableBits int) {
+	tableSize := 1 << tableBits
+	sum := 0
+	largest := 0
+	for i, c := range counts {
+		switch {
+		case c == 0:
+			norm[i] = 0
+		default:
+			p := (int(c)<<tableBits + total/2) / total
+			if p == 0 {
+				norm[i] = -1
+				p = 1
+			} else {
+				norm[i] = int16(p)
+			}
+			sum += p
+			if norm[i] > norm[largest] {
+				largest = i
+			}
+		}
+	}
+
+	// Fix up rounding errors. Prefer to adjust the largest
+	// probability, as that costs the least.
+	diff := tableSize - sum
+	if diff == 0 {
+		return
+	}
+	if int(norm[largest])+diff >= int(norm[largest])/2+1 {
+		norm[largest] += int16(diff)
+		return
+	}
+
+	// The largest probability can't absorb the difference,
+	// so spread it over all the symbols that can afford it.
+	for diff < 0 {
+		best := -1
+		for i, n := range norm {
+			if n > 1 && (best < 0 || n > norm[best]) {
+				best = i
+			}
+		}
+		norm[best]--
+		diff++
+	}
+	for diff > 0 {
+		norm[largest]++
+		diff--
+	}
+}
+
+// writeFSE appends the description of the FSE probabilities in norm
+// to out. This is the inverse of readFSE. RFC 4.1.1.
+func writeFSE(out []byte, norm []int16, tableBits int) []byte {
+	bw := bitWriter{out: out}
+	bw.addBits(uint32(tableBits-5), 4)
+
+	// See readFSE for the meaning of these variables.
+	remaining := (1 << tableBits) + 1
+	threshold := 1 << tableBits
+	bitsNeeded := tableBits + 1
+
+	sym := 0
+	prev0 := false
+	for sym < len(norm) && remaining > 1 {
+		if prev0 {
+			start := sym
+			for sym < len(norm) && norm[sym] == 0 {
+				sym++
+			}
+			for sym >= start+3 {
+				start += 3
+				bw.addBits(3, 2)
+			}
+			bw.addBits(uint32(sym-start), 2)
+		}
+
+		count := int(norm[sym])
+		sym++
+		max := (2*threshold - 1) - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		count++
+		if count >= threshold {
+			count += max
+		}
+		if count < max {
+			bw.addBits(uint32(count), uint8(bitsNeeded-1))
+		} else {
+			bw.addBits(uint32(count), uint8(bitsNeeded))
+		}
+		prev0 = count == 1
+
+		for remaining < threshold {
+			bitsNeeded--
+			threshold >>= 1
+		}
+	}
+
+	return bw.flush()
+}
+
+// fseCost returns an estimate, in bits, of encoding the symbols
+// in counts using the probabilities in norm. It reports false if some
+// symbol can't be encoded.
+func fseCost(counts []uint32, norm []int16, tableBits int) (int, bool) {
+	cost := 0
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if i >= len(norm) || norm[i] == 0 {
+			return 0, false
+		}
+		n := int(norm[i])
+		if n < 0 {
+			n = 1
+		}
+		// The cost of a symbol is log2(tableSize/n) bits.
+		// Approximate using 1/16 bit units.
+		cost += int(c) * (tableBits*16 - log2x16(n))
+	}
+	return cost / 16, true
+}
+
+// log2x16 returns an approximation of 16*log2(n).
+func log2x16(n int) int {
+	hb := highBit(uint32(n))
+	// Use the next 4 bits for the fractional part.
+	var frac int
+	if hb >= 4 {
+		frac = (n >> (hb - 4)) & 15
+	} else {
+		frac = (n << (4 - hb)) & 15
+	}
+	return hb*16 + frac
+}
+
+// Predefined encoding tables. RFC 3.1.1.3.2.2.
+var (
+	predefinedLiteralEncoder fseEncoder
+	predefinedOffsetEncoder  fseEncoder
+	predefinedMatchEncoder   fseEncoder
+)
+
+// Predefined distributions. RFC 3.1.1.3.2.2.
+var (
+	predefinedLiteralNorm = [...]int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	predefinedOffsetNorm = [...]int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+	predefinedMatchNorm = [...]int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+)
+
+func init() {
+	predefinedLiteralEncoder.build(predefinedLiteralNorm[:], 6)
+	predefinedOffsetEncoder.build(predefinedOffsetNorm[:], 5)
+	predefinedMatchEncoder.build(predefinedMatchNorm[:], 6)
+}
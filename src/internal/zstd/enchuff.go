@@ -0,0 +1,356 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// huffEncoder is a Huffman code used to compress literals.
+type huffEncoder struct {
+	tableBits int         // length of the longest code
+	maxSym    int         // largest symbol with a code
+	nbits     [256]uint8  // code lengths; 0 for unused symbols
+	codes     [256]uint16 // codes, nbits long
+	weights   [256]uint8  // weights as described in RFC 4.2.1
+}
+
+// build builds a Huffman code for the symbol counts in counts.
+// At least two symbols must have non-zero counts.
+// RFC 4.2.
+func (h *huffEncoder) build(counts *[256]uint32) {
+	// Sort the symbols by increasing count.
+	var syms [256]uint8
+	n := 0
+	for i, c := range counts {
+		if c > 0 {
+			syms[n] = uint8(i)
+			n++
+			h.maxSym = i
+		}
+	}
+	sorted := syms[:n]
+	slices.SortStableFunc(sorted, func(a, b uint8) int {
+		return int(counts[a]) - int(counts[b])
+	})
+
+	// Build the Huffman tree with the two-queue method.
+	// The first n nodes are the leaves, the remainder are
+	// internal nodes, which are created in increasing order
+	// of weight.
+	var weight [512]uint32
+	var parent [512]int16
+	for i, sym := range sorted {
+		weight[i] = counts[sym]
+	}
+	leaf, inner, next := 0, n, n
+	pick := func() int {
+		if leaf < n && (inner >= next || weight[leaf] <= weight[inner]) {
+			leaf++
+			return leaf - 1
+		}
+		inner++
+		return inner - 1
+	}
+	for next < 2*n-1 {
+		a := pick()
+		b := pick()
+		weight[next] = weight[a] + weight[b]
+		parent[a] = int16(next)
+		parent[b] = int16(next)
+		next++
+	}
+
+	// Compute the depth of each node.
+	var depth [512]uint8
+	depth[2*n-2] = 0
+	for i := 2*n - 3; i >= 0; i-- {
+		depth[i] = depth[parent[i]] + 1
+	}
+
+	// Limit the code lengths to maxHuffmanBits.
+	// Measure the code space in units of 1<<-maxHuffmanBits.
+	const full = 1 << maxHuffmanBits
+	lens := depth[:n]
+	space := 0
+	for i := range lens {
+		if lens[i] > maxHuffmanBits {
+			lens[i] = maxHuffmanBits
+		}
+		space += full >> lens[i]
+	}
+	// Lengthen the codes of the least frequent symbols
+	// until the code space is not oversubscribed.
+	for i := 0; space > full; i++ {
+		for lens[i] < maxHuffmanBits && space > full {
+			lens[i]++
+			space -= full >> lens[i]
+		}
+	}
+	// Shorten the codes of the most frequent symbols
+	// until the code is complete, as required by the weights.
+	for i := n - 1; i >= 0 && space < full; i-- {
+		for lens[i] > 1 && space+(full>>lens[i]) <= full {
+			space += full >> lens[i]
+			lens[i]--
+		}
+	}
+
+	h.tableBits = 0
+	h.nbits = [256]uint8{}
+	for i, sym := range sorted {
+		h.nbits[sym] = lens[i]
+		h.tableBits = max(h.tableBits, int(lens[i]))
+	}
+
+	// Assign codes the way that readHuff does:
+	// in order of increasing weight, then increasing symbol.
+	var rankStart [maxHuffmanBits + 2]uint32
+	var rankCount [maxHuffmanBits + 2]uint32
+	h.weights = [256]uint8{}
+	for sym := 0; sym <= h.maxSym; sym++ {
+		if l := h.nbits[sym]; l > 0 {
+			w := uint8(h.tableBits) + 1 - l
+			h.weights[sym] = w
+			rankCount[w]++
+		}
+	}
+	start := uint32(0)
+	for w := 1; w <= h.tableBits; w++ {
+		rankStart[w] = start
+		start += rankCount[w] << (w - 1)
+	}
+	for sym := 0; sym <= h.maxSym; sym++ {
+		if w := h.weights[sym]; w > 0 {
+			h.codes[sym] = uint16(rankStart[w] >> (w - 1))
+			rankStart[w] += 1 << (w - 1)
+		}
+	}
+}
+
+// size returns the number of bytes needed to encode
+// the symbols in counts, not counting the table.
+func (h *huffEncoder) size(counts *[256]uint32) int {
+	total := 0
+	for sym, c := range counts[:h.maxSym+1] {
+		total += int(c) * int(h.nbits[sym])
+	}
+	return (total + 7) / 8
+}
+
+// appendTable appends the Huffman tree description to out.
+// It reports false if the table can't be described.
+// RFC 4.2.1.
+func (h *huffEncoder) appendTable(out []byte) ([]byte, bool) {
+	// The weight of the last symbol is implied.
+	weights := h.weights[:h.maxSym]
+
+	if len(weights) <= 128 {
+		// Direct representation, 4 bits per weight.
+		out = append(out, byte(127+len(weights)))
+		for i := 0; i < len(weights); i += 2 {
+			b := weights[i] << 4
+			if i+1 < len(weights) {
+				b |= weights[i+1]
+			}
+			out = append(out, b)
+		}
+		return out, true
+	}
+
+	// Compress the weights with FSE. RFC 4.2.1.2.
+	var counts [maxHuffmanBits + 1]uint32
+	distinct := 0
+	maxWeight := 0
+	for _, w := range weights {
+		if counts[w] == 0 {
+			distinct++
+		}
+		counts[w]++
+		maxWeight = max(maxWeight, int(w))
+	}
+	if distinct < 2 {
+		// An FSE table with a single symbol can't
+		// describe where the stream ends.
+		return out, false
+	}
+
+	tableBits := fseTableBits(len(weights), maxWeight, 6)
+	var norm [maxHuffmanBits + 1]int16
+	normalizeFSE(norm[:maxWeight+1], counts[:maxWeight+1], len(weights), tableBits)
+
+	hdrOff := len(out)
+	out = append(out, 0)
+	out = writeFSE(out, norm[:maxWeight+1], tableBits)
+
+	var enc fseEncoder
+	enc.build(norm[:maxWeight+1], tableBits)
+
+	// Two interleaved states, which the reader decodes alternately,
+	// starting with state1.
+	bw := bitWriter{out: out}
+	i := len(weights)
+	var state1, state2 fseState
+	if i&1 != 0 {
+		state1 = enc.init(weights[i-1])
+		state2 = enc.init(weights[i-2])
+		state1 = enc.encode(&bw, state1, weights[i-3])
+		i -= 3
+	} else {
+		state2 = enc.init(weights[i-1])
+		state1 = enc.init(weights[i-2])
+		i -= 2
+	}
+	for i > 0 {
+		state2 = enc.encode(&bw, state2, weights[i-1])
+		state1 = enc.encode(&bw, state1, weights[i-2])
+		i -= 2
+	}
+	enc.flush(&bw, state2)
+	enc.flush(&bw, state1)
+	out = bw.close()
+
+	size := len(out) - hdrOff - 1
+	if size >= 128 {
+		return out, false
+	}
+	out[hdrOff] = byte(size)
+	return out, true
+}
+
+// appendStream appends lits, compressed as a single Huffman stream,
+// to out. RFC 4.2.2.
+func (h *huffEncoder) appendStream(out []byte, lits []byte) []byte {
+	// The stream is read in reverse, so write the literals in reverse.
+	bw := bitWriter{out: out}
+	for i := len(lits) - 1; i >= 0; i-- {
+		c := lits[i]
+		bw.addBits(uint32(h.codes[c]), h.nbits[c])
+	}
+	return bw.close()
+}
+
+// minHuffLiterals is the smallest number of literals
+// that we try to compress with a Huffman code.
+const minHuffLiterals = 64
+
+// appendLiterals appends the literals section describing lits to out.
+// RFC 3.1.1.3.1.
+func (e *encoder) appendLiterals(out []byte, lits []byte) []byte {
+	if len(lits) == 0 {
+		return append(out, 0)
+	}
+
+	counts := &e.litCounts
+	*counts = [256]uint32{}
+	for _, c := range lits {
+		counts[c]++
+	}
+
+	distinct := 0
+	for _, c := range counts {
+		if c > 0 {
+			distinct++
+		}
+	}
+	if distinct == 1 {
+		// RLE_Literals_Block.
+		out = appendRawRLEHeader(out, 1, len(lits))
+		return append(out, lits[0])
+	}
+	if len(lits) < minHuffLiterals {
+		return appendRawLiterals(out, lits)
+	}
+
+	h := &e.huff
+	h.build(counts)
+
+	// Don't bother with a Huffman code unless it saves something.
+	streams := 1
+	if len(lits) > 1023 {
+		streams = 4
+	}
+	est := h.size(counts) + 6 + len(lits)>>6
+	if est >= len(lits) {
+		return appendRawLiterals(out, lits)
+	}
+
+	// Leave room for the largest header, and move the data down
+	// afterward if the header is smaller.
+	hdrOff := len(out)
+	out = append(out, 0, 0, 0, 0, 0)
+	dataOff := len(out)
+
+	out, ok := h.appendTable(out)
+	if !ok {
+		return appendRawLiterals(out[:hdrOff], lits)
+	}
+
+	if streams == 1 {
+		out = h.appendStream(out, lits)
+	} else {
+		// Four streams, preceded by a jump table. RFC 3.1.1.3.1.6.
+		jumpOff := len(out)
+		out = append(out, 0, 0, 0, 0, 0, 0)
+		segment := (len(lits) + 3) / 4
+		for i := 0; i < 4; i++ {
+			streamOff := len(out)
+			end := min((i+1)*segment, len(lits))
+			out = h.appendStream(out, lits[i*segment:end])
+			if i < 3 {
+				binary.LittleEndian.PutUint16(out[jumpOff+2*i:], uint16(len(out)-streamOff))
+			}
+		}
+	}
+
+	compressedSize := len(out) - dataOff
+	if compressedSize >= len(lits) {
+		return appendRawLiterals(out[:hdrOff], lits)
+	}
+
+	// Literals section header. RFC 3.1.1.3.1.1.
+	regeneratedSize := len(lits)
+	var sizeFormat, hdrLen int
+	var sizeBits uint
+	switch {
+	case streams == 1:
+		sizeFormat, hdrLen, sizeBits = 0, 3, 10
+	case regeneratedSize <= 1023 && compressedSize <= 1023:
+		sizeFormat, hdrLen, sizeBits = 1, 3, 10
+	case regeneratedSize <= 16383 && compressedSize <= 16383:
+		sizeFormat, hdrLen, sizeBits = 2, 4, 14
+	default:
+		sizeFormat, hdrLen, sizeBits = 3, 5, 18
+	}
+	hdr := uint64(2) | uint64(sizeFormat)<<2 | uint64(regeneratedSize)<<4 | uint64(compressedSize)<<(4+sizeBits)
+	for i := 0; i < hdrLen; i++ {
+		out[hdrOff+i] = byte(hdr >> (8 * i))
+	}
+	if hdrLen < 5 {
+		copy(out[hdrOff+hdrLen:], out[dataOff:])
+		out = out[:len(out)-(5-hdrLen)]
+	}
+	return out
+}
+
+// appendRawLiterals appends a Raw_Literals_Block to out.
+func appendRawLiterals(out []byte, lits []byte) []byte {
+	out = appendRawRLEHeader(out, 0, len(lits))
+	return append(out, lits...)
+}
+
+// appendRawRLEHeader appends the literals section header for a
+// Raw_Literals_Block or RLE_Literals_Block. RFC 3.1.1.3.1.1.
+func appendRawRLEHeader(out []byte, typ byte, size int) []byte {
+	switch {
+	case size < 32:
+		return append(out, typ|byte(size)<<3)
+	case size < 4096:
+		return append(out, typ|1<<2|byte(size&15)<<4, byte(size>>4))
+	default:
+		return append(out, typ|3<<2|byte(size&15)<<4, byte(size>>4), byte(size>>12))
+	}
+}
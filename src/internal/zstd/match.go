@@ -0,0 +1,365 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// maxBlockSize is the largest block we write. RFC 3.1.1.2.3.
+const maxBlockSize = 128 << 10
+
+// minMatch is the shortest match that the matcher looks for.
+// The format permits matches of length 3, but using 4 lets us
+// find matches using 32-bit loads.
+const minMatch = 4
+
+// levelParams are the parameters that control a compression level.
+type levelParams struct {
+	windowBits int  // log2 of the window size
+	hashBits   int  // log2 of the size of the hash table
+	chainBits  int  // log2 of the size of the hash chain; 0 for none
+	depth      int  // maximum number of hash chain entries to check
+	lazy       bool // whether to look for a better match at the next byte
+	nice       int  // stop searching when we find a match this long
+}
+
+// levels are the parameters for each compression level.
+// Level 0 does not compress at all, so it has no parameters.
+var levels = [...]levelParams{
+	1: {windowBits: 20, hashBits: 16},
+	2: {windowBits: 21, hashBits: 17},
+	3: {windowBits: 21, hashBits: 17, chainBits: 16, depth: 4, lazy: true, nice: 32},
+	4: {windowBits: 21, hashBits: 17, chainBits: 17, depth: 8, lazy: true, nice: 48},
+	5: {windowBits: 21, hashBits: 18, chainBits: 18, depth: 16, lazy: true, nice: 64},
+	6: {windowBits: 22, hashBits: 18, chainBits: 19, depth: 32, lazy: true, nice: 128},
+	7: {windowBits: 22, hashBits: 19, chainBits: 20, depth: 64, lazy: true, nice: 128},
+	8: {windowBits: 23, hashBits: 20, chainBits: 21, depth: 128, lazy: true, nice: 256},
+	9: {windowBits: 23, hashBits: 20, chainBits: 22, depth: 512, lazy: true, nice: 512},
+}
+
+// MaxLevel is the largest supported compression level.
+const MaxLevel = len(levels) - 1
+
+// seq is a single sequence: some literals followed by a match.
+// RFC 3.1.1.3.2.
+type seq struct {
+	litLen   uint32 // number of literals
+	matchLen uint32 // length of the match; at least 3
+	offVal   uint32 // Offset_Value; 1 to 3 for a repeated offset
+}
+
+// matcher finds matches in a stream of data.
+type matcher struct {
+	p levelParams
+
+	// hist holds the data that matches may refer to,
+	// followed by the data being compressed.
+	hist []byte
+
+	// table maps a hash of 4 bytes to the most recent position
+	// in hist with that hash, or -1.
+	table []int32
+
+	// chain maps a position in hist, modulo the size of the chain,
+	// to the previous position with the same hash, or -1.
+	chain []int32
+
+	// inserted is the position in hist of the next byte to add
+	// to table and chain.
+	inserted int
+
+	// The current repeated offsets. These track the values
+	// that the reader will compute. RFC 3.1.2.5.
+	reps [3]uint32
+}
+
+// reset prepares to compress a new frame, with optional dictionary
+// content that precedes the frame.
+func (m *matcher) reset(p levelParams, d *Dict) {
+	m.p = p
+	windowSize := 1 << p.windowBits
+	histCap := 2*windowSize + maxBlockSize
+	if cap(m.hist) < histCap {
+		m.hist = make([]byte, 0, histCap)
+	}
+	m.hist = m.hist[:0]
+
+	if len(m.table) != 1<<p.hashBits {
+		m.table = make([]int32, 1<<p.hashBits)
+	}
+	for i := range m.table {
+		m.table[i] = -1
+	}
+	if p.chainBits > 0 {
+		if len(m.chain) != 1<<p.chainBits {
+			m.chain = make([]int32, 1<<p.chainBits)
+		}
+		for i := range m.chain {
+			m.chain[i] = -1
+		}
+	} else {
+		m.chain = nil
+	}
+	m.inserted = 0
+	m.reps = [3]uint32{1, 4, 8}
+
+	if d != nil {
+		m.reps = d.repeatedOffsets
+		content := d.content
+		if len(content) > windowSize {
+			content = content[len(content)-windowSize:]
+		}
+		m.hist = append(m.hist, content...)
+		m.insert(len(m.hist))
+	}
+}
+
+// addBlock adds src to the history, and returns the position of src
+// in the history.
+func (m *matcher) addBlock(src []byte) int {
+	windowSize := 1 << m.p.windowBits
+	if len(m.hist)+len(src) > cap(m.hist) {
+		// Discard old data. We keep at least windowSize bytes.
+		// Moving by a multiple of the chain size means that
+		// the chain indexes stay the same.
+		delta := (len(m.hist) - windowSize) &^ (windowSize - 1)
+		n := copy(m.hist, m.hist[delta:])
+		m.hist = m.hist[:n]
+		slide := func(s []int32) {
+			for i, v := range s {
+				if v = v - int32(delta); v < 0 {
+					v = -1
+				}
+				s[i] = v
+			}
+		}
+		slide(m.table)
+		slide(m.chain)
+		m.inserted -= delta
+	}
+	start := len(m.hist)
+	m.hist = append(m.hist, src...)
+	return start
+}
+
+// hash4 returns the hash of the 4 bytes starting at hist[pos].
+func (m *matcher) hash4(pos int) uint32 {
+	return (binary.LittleEndian.Uint32(m.hist[pos:]) * 0x9e3779b1) >> (32 - m.p.hashBits)
+}
+
+// insert adds all positions before end to the hash table and chain.
+func (m *matcher) insert(end int) {
+	end = min(end, len(m.hist)-minMatch+1)
+	for ; m.inserted < end; m.inserted++ {
+		h := m.hash4(m.inserted)
+		if m.chain != nil {
+			m.chain[m.inserted&(len(m.chain)-1)] = m.table[h]
+		}
+		m.table[h] = int32(m.inserted)
+	}
+}
+
+// findSeqs finds the sequences for the block at hist[start:end].
+// It appends the sequences to seqs and the literals to lits.
+// Any literals after the last sequence are appended to lits but
+// are not part of a sequence.
+func (m *matcher) findSeqs(start, end int, seqs []seq, lits []byte) ([]seq, []byte) {
+	s := start
+	litStart := start
+	for s+minMatch <= end {
+		length, offset, score := m.bestMatch(s, end, s-litStart)
+		if length == 0 {
+			if m.chain == nil {
+				// Skip faster through data that doesn't match.
+				s += 1 + (s-litStart)>>5
+			} else {
+				s++
+			}
+			continue
+		}
+
+		if m.p.lazy {
+			// Use the match at the next position instead if it is
+			// better by more than the cost of an extra literal.
+			for s+1+minMatch <= end && length < m.p.nice {
+				l2, o2, score2 := m.bestMatch(s+1, end, s+1-litStart)
+				if score2 <= score+4 {
+					break
+				}
+				s++
+				length, offset, score = l2, o2, score2
+			}
+		}
+
+		litLen := s - litStart
+		lits = append(lits, m.hist[litStart:s]...)
+		seqs = append(seqs, seq{
+			litLen:   uint32(litLen),
+			matchLen: uint32(length),
+			offVal:   m.offsetValue(uint32(offset), litLen),
+		})
+
+		s += length
+		litStart = s
+		if m.chain == nil {
+			// Only remember a couple of positions in the match.
+			m.inserted = s - 2
+		}
+		m.insert(s)
+	}
+	lits = append(lits, m.hist[litStart:end]...)
+	m.insert(end)
+	return seqs, lits
+}
+
+// matchScore estimates the value of a match with the given length
+// and offset cost in bits. Each matched byte saves roughly a byte,
+// and a larger offset takes more bits to encode.
+func matchScore(length, offsetBits int) int {
+	return 4*length - offsetBits
+}
+
+// bestMatch returns the length and offset of the best match at
+// hist[pos], not extending past end, along with its matchScore.
+// litLen is the number of literals that precede the match,
+// which determines which repeated offsets may be used.
+// It returns a length of 0 if there is no match.
+// This adds all positions up to and including pos to the hash table.
+func (m *matcher) bestMatch(pos, end, litLen int) (length, offset, score int) {
+	windowSize := 1 << m.p.windowBits
+	src := m.hist[pos:end]
+
+	// Check the repeated offsets first, as they are cheap to encode.
+	// RFC 3.1.2.5.
+	reps := [3]uint32{m.reps[0], m.reps[1], m.reps[2]}
+	if litLen == 0 {
+		reps = [3]uint32{m.reps[1], m.reps[2], m.reps[0] - 1}
+	}
+	for _, rep := range reps {
+		if rep == 0 || int(rep) > pos || int(rep) >= windowSize {
+			continue
+		}
+		if l := matchLen(src, m.hist[pos-int(rep):]); l >= minMatch && l > length {
+			length, offset = l, int(rep)
+			score = matchScore(l, 1)
+		}
+	}
+
+	if m.chain == nil {
+		// Only positions that we look at go in the hash table.
+		m.inserted = pos
+	}
+	m.insert(pos)
+	h := m.hash4(pos)
+	cand := int(m.table[h])
+	if m.chain != nil {
+		m.chain[pos&(len(m.chain)-1)] = int32(cand)
+	}
+	m.table[h] = int32(pos)
+	m.inserted = pos + 1
+
+	// The candidates are in order of increasing offset,
+	// so each must be longer than the last to be better.
+	for depth := max(m.p.depth, 1); depth > 0 && cand >= 0 && pos-cand < windowSize; depth-- {
+		if length >= len(src) {
+			break
+		}
+		// Any longer match must match at src[length].
+		if m.hist[cand+length] == src[length] {
+			l := matchLen(src, m.hist[cand:])
+			if sc := matchScore(l, highBit(uint32(pos-cand+3))); l >= minMatch && sc > score {
+				length, offset, score = l, pos-cand, sc
+				if m.p.nice > 0 && l >= m.p.nice {
+					break
+				}
+			}
+		}
+		if m.chain == nil {
+			break
+		}
+		next := int(m.chain[cand&(len(m.chain)-1)])
+		if next >= cand || pos-next >= len(m.chain) {
+			break
+		}
+		cand = next
+	}
+
+	return length, offset, score
+}
+
+// offsetValue returns the Offset_Value to use for a match at offset
+// preceded by litLen literals, and updates the repeated offsets the
+// same way that the reader will. RFC 3.1.2.5.
+func (m *matcher) offsetValue(offset uint32, litLen int) uint32 {
+	var offVal uint32
+	if litLen > 0 {
+		switch offset {
+		case m.reps[0]:
+			offVal = 1
+		case m.reps[1]:
+			offVal = 2
+		case m.reps[2]:
+			offVal = 3
+		default:
+			offVal = offset + 3
+		}
+	} else {
+		switch offset {
+		case m.reps[1]:
+			offVal = 1
+		case m.reps[2]:
+			offVal = 2
+		case m.reps[0] - 1:
+			offVal = 3
+		default:
+			offVal = offset + 3
+		}
+	}
+
+	// This mirrors the code in execSeqs.
+	if offVal > 3 {
+		m.reps[2] = m.reps[1]
+		m.reps[1] = m.reps[0]
+		m.reps[0] = offset
+		return offVal
+	}
+	v := offVal
+	if litLen == 0 {
+		v++
+	}
+	switch v {
+	case 2:
+		m.reps[1] = m.reps[0]
+		m.reps[0] = offset
+	case 3, 4:
+		m.reps[2] = m.reps[1]
+		m.reps[1] = m.reps[0]
+		m.reps[0] = offset
+	}
+	return offVal
+}
+
+// matchLen returns the number of bytes at the start of a and b
+// that are the same. b must be at least as long as a.
+func matchLen(a, b []byte) int {
+	n := 0
+	for len(a) >= 8 {
+		if diff := binary.LittleEndian.Uint64(a) ^ binary.LittleEndian.Uint64(b); diff != 0 {
+			return n + bits.TrailingZeros64(diff)>>3
+		}
+		n += 8
+		a = a[8:]
+		b = b[8:]
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			break
+		}
+		n++
+	}
+	return n
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// magic is the magic number at the start of a frame. RFC 3.1.1.
+const magic = 0xfd2fb528
+
+// errWriterClosed is returned when writing to a closed Writer.
+var errWriterClosed = errors.New("zstd: write to closed Writer")
+
+// Writer implements [io.WriteCloser] to write a zstd compressed stream.
+// Each Writer writes a single frame, with a checksum.
+type Writer struct {
+	w     io.Writer
+	level int
+	dict  *Dict
+
+	wroteHeader bool
+	closed      bool
+	err         error
+
+	// Data waiting to be compressed; at most maxBlockSize bytes.
+	buf []byte
+
+	// Compressed output for the current block.
+	out []byte
+
+	enc      encoder
+	checksum xxhash64
+}
+
+// NewWriter returns a new Writer that compresses data written to it
+// and writes the compressed data to w. The level must be between 0,
+// meaning no compression, and MaxLevel. The dictionary d may be nil.
+func NewWriter(w io.Writer, level int, d *Dict) *Writer {
+	if level < 0 || level > MaxLevel {
+		panic("zstd: invalid compression level")
+	}
+	zw := &Writer{
+		level: level,
+		dict:  d,
+	}
+	zw.Reset(w)
+	return zw
+}
+
+// Reset discards the current state and starts writing a new stream to w,
+// with the same compression level and dictionary.
+// This permits reusing a Writer rather than allocating a new one.
+func (zw *Writer) Reset(w io.Writer) {
+	zw.w = w
+	zw.wroteHeader = false
+	zw.closed = false
+	zw.err = nil
+	if cap(zw.buf) < maxBlockSize {
+		zw.buf = make([]byte, 0, maxBlockSize)
+	}
+	zw.buf = zw.buf[:0]
+	zw.checksum.reset()
+	if zw.level > 0 {
+		zw.enc.m.reset(levels[zw.level], zw.dict)
+	}
+}
+
+// Write implements [io.Writer].
+func (zw *Writer) Write(p []byte) (int, error) {
+	if zw.err != nil {
+		return 0, zw.err
+	}
+	if zw.closed {
+		return 0, errWriterClosed
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(zw.buf) == maxBlockSize {
+			// Only write a full block when we know that
+			// it is not the last one.
+			if err := zw.writeBlock(false); err != nil {
+				return n, err
+			}
+		}
+		c := copy(zw.buf[len(zw.buf):maxBlockSize], p)
+		zw.buf = zw.buf[:len(zw.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Flush writes any pending data to the underlying writer.
+// The data written so far may then be decompressed
+// without waiting for the end of the frame.
+func (zw *Writer) Flush() error {
+	if zw.err != nil {
+		return zw.err
+	}
+	if zw.closed {
+		return nil
+	}
+	if len(zw.buf) == 0 {
+		if !zw.wroteHeader {
+			return zw.writeHeader()
+		}
+		return nil
+	}
+	return zw.writeBlock(false)
+}
+
+// Close writes any pending data and the end of the frame
+// to the underlying writer. It does not close the underlying writer.
+func (zw *Writer) Close() error {
+	if zw.err != nil {
+		return zw.err
+	}
+	if zw.closed {
+		return nil
+	}
+	if err := zw.writeBlock(true); err != nil {
+		return err
+	}
+	zw.closed = true
+
+	// Content_Checksum. RFC 3.1.1.
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], uint32(zw.checksum.digest()))
+	_, zw.err = zw.w.Write(sum[:])
+	return zw.err
+}
+
+// writeHeader writes the frame header. RFC 3.1.1.1.
+func (zw *Writer) writeHeader() error {
+	zw.wroteHeader = true
+
+	windowBits := 17
+	if zw.level > 0 {
+		windowBits = levels[zw.level].windowBits
+	}
+
+	// We don't know the content size, so we always use
+	// a window descriptor and omit the Frame_Content_Size.
+	// The Content_Checksum_flag is always set.
+	descriptor := byte(1 << 2)
+	var dictID []byte
+	if zw.dict != nil && zw.dict.id != 0 {
+		descriptor |= 3
+		dictID = binary.LittleEndian.AppendUint32(nil, zw.dict.id)
+	}
+
+	hdr := binary.LittleEndian.AppendUint32(zw.out[:0], magic)
+	hdr = append(hdr, descriptor, byte(windowBits-10)<<3)
+	hdr = append(hdr, dictID...)
+	_, zw.err = zw.w.Write(hdr)
+	return zw.err
+}
+
+// writeBlock compresses zw.buf and writes it as a single block.
+// RFC 3.1.1.2.
+func (zw *Writer) writeBlock(last bool) error {
+	if !zw.wroteHeader {
+		if err := zw.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	src := zw.buf
+	zw.checksum.update(src)
+
+	var blockType byte
+	out := append(zw.out[:0], 0, 0, 0)
+	switch {
+	case len(src) == 0 || zw.level == 0:
+		// Raw_Block.
+		blockType = 0
+		out = append(out, src...)
+	case isRLE(src):
+		// RLE_Block. Still add the data to the history
+		// so that later blocks may refer to it.
+		blockType = 1
+		out = append(out, src[0])
+		zw.enc.m.addBlock(src)
+		zw.enc.m.insert(len(zw.enc.m.hist))
+	default:
+		e := &zw.enc
+		start := e.m.addBlock(src)
+		savedReps := e.m.reps
+		e.seqs, e.lits = e.m.findSeqs(start, len(e.m.hist), e.seqs[:0], e.lits[:0])
+		out = e.appendCompressedBlock(out, e.seqs, e.lits)
+		if len(out)-3 < len(src) {
+			blockType = 2
+		} else {
+			// Compression didn't help, so write a Raw_Block.
+			// The reader won't see the sequences,
+			// so it won't see the updated repeated offsets.
+			e.m.reps = savedReps
+			blockType = 0
+			out = append(out[:3], src...)
+		}
+	}
+
+	size := len(out) - 3
+	if blockType == 1 {
+		size = len(src)
+	}
+	hdr := uint32(size)<<3 | uint32(blockType)<<1
+	if last {
+		hdr |= 1
+	}
+	out[0] = byte(hdr)
+	out[1] = byte(hdr >> 8)
+	out[2] = byte(hdr >> 16)
+
+	zw.out = out
+	zw.buf = zw.buf[:0]
+	_, zw.err = zw.w.Write(out)
+	return zw.err
+}
+
+// isRLE reports whether all the bytes in b are the same.
+func isRLE(b []byte) bool {
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,292 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writerInputs returns some inputs to use to test the Writer.
+func writerInputs(t testing.TB) map[string][]byte {
+	r := rand.New(rand.NewPCG(1, 2))
+	random := make([]byte, 300<<10)
+	for i := range random {
+		random[i] = byte(r.Uint32())
+	}
+	// Text-like data with a small alphabet and some repetition.
+	var text []byte
+	words := strings.Fields("the quick brown fox jumps over the lazy dog zstd compression frame block")
+	for len(text) < 200<<10 {
+		text = append(text, words[r.IntN(len(words))]...)
+		text = append(text, ' ')
+	}
+	// Binary data with long-distance repeats.
+	binary := make([]byte, 0, 1<<20)
+	chunk := random[:5000]
+	for len(binary) < cap(binary)-len(chunk) {
+		binary = append(binary, chunk[:r.IntN(len(chunk))]...)
+		binary = append(binary, byte(r.Uint32()))
+	}
+
+	inputs := map[string][]byte{
+		"empty":  nil,
+		"byte":   {'x'},
+		"rle":    bytes.Repeat([]byte{'a'}, 200<<10),
+		"random": random,
+		"text":   text,
+		"binary": binary,
+		"counter": func() []byte {
+			var b []byte
+			for i := 0; len(b) < 100<<10; i++ {
+				b = fmt.Appendf(b, "%d\n", i)
+			}
+			return b
+		}(),
+	}
+	for _, test := range tests {
+		inputs["tests/"+test.name] = []byte(test.uncompressed)
+	}
+	if !testing.Short() {
+		inputs["big"] = bigData(t)
+	}
+	return inputs
+}
+
+func compress(t testing.TB, data []byte, level int, d *Dict) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, level, d)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for name, data := range writerInputs(t) {
+		for level := 0; level <= MaxLevel; level++ {
+			t.Run(fmt.Sprintf("%s/%d", name, level), func(t *testing.T) {
+				compressed := compress(t, data, level, nil)
+				got, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					showDiffs(t, got, data)
+				}
+				if level > 0 && len(data) > 1000 && name != "random" && len(compressed) > len(data)/2 {
+					t.Errorf("compressed %d bytes to %d", len(data), len(compressed))
+				}
+			})
+		}
+	}
+}
+
+func TestWriterZstd(t *testing.T) {
+	zstd := findZstd(t)
+	for name, data := range writerInputs(t) {
+		for _, level := range []int{0, 1, 3, 9} {
+			t.Run(fmt.Sprintf("%s/%d", name, level), func(t *testing.T) {
+				cmd := exec.Command(zstd, "-d")
+				cmd.Stdin = bytes.NewReader(compress(t, data, level, nil))
+				var out, stderr bytes.Buffer
+				cmd.Stdout = &out
+				cmd.Stderr = &stderr
+				if err := cmd.Run(); err != nil {
+					t.Fatalf("zstd -d failed: %v\n%s", err, stderr.Bytes())
+				}
+				if !bytes.Equal(out.Bytes(), data) {
+					showDiffs(t, out.Bytes(), data)
+				}
+			})
+		}
+	}
+}
+
+func TestWriterFlushReset(t *testing.T) {
+	data := writerInputs(t)["text"]
+	var buf bytes.Buffer
+	w := NewWriter(&buf, 3, nil)
+	r := NewReader(&buf)
+	for i := 0; i < len(data); i += 10000 {
+		chunk := data[i:min(i+10000, len(data))]
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, len(chunk))
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, chunk) {
+			t.Fatalf("chunk at %d differs after Flush", i)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+
+	// Reset must produce the same output as a new Writer.
+	var buf2 bytes.Buffer
+	w.Reset(&buf2)
+	w.Write(data)
+	w.Close()
+	if want := compress(t, data, 3, nil); !bytes.Equal(buf2.Bytes(), want) {
+		t.Error("output after Reset differs from new Writer")
+	}
+}
+
+// makeDict returns a formatted dictionary with the given content.
+// It uses the predefined FSE tables, and a Huffman table
+// built from the content.
+func makeDict(id uint32, content []byte) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, dictMagic)
+	b = binary.LittleEndian.AppendUint32(b, id)
+
+	var counts [256]uint32
+	for i := range counts {
+		counts[i] = 1
+	}
+	for _, c := range content {
+		counts[c]++
+	}
+	var h huffEncoder
+	h.build(&counts)
+	b, ok := h.appendTable(b)
+	if !ok {
+		panic("can't describe Huffman table")
+	}
+
+	b = writeFSE(b, predefinedOffsetNorm[:], 5)
+	b = writeFSE(b, predefinedMatchNorm[:], 6)
+	b = writeFSE(b, predefinedLiteralNorm[:], 6)
+	for _, rep := range []uint32{1, 4, 8} {
+		b = binary.LittleEndian.AppendUint32(b, rep)
+	}
+	return append(b, content...)
+}
+
+func TestDict(t *testing.T) {
+	inputs := writerInputs(t)
+	text := inputs["text"]
+	content := text[:16<<10]
+	data := text[len(text)-5000:]
+
+	dicts := map[string][]byte{
+		"raw":       content,
+		"formatted": makeDict(1234, content),
+	}
+	for name, db := range dicts {
+		t.Run(name, func(t *testing.T) {
+			d, err := ParseDict(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			withDict := compress(t, data, 3, d)
+			without := compress(t, data, 3, nil)
+			if len(withDict) >= len(without) {
+				t.Errorf("dictionary did not help: got %d bytes, %d without", len(withDict), len(without))
+			}
+
+			got, err := io.ReadAll(NewReaderDict(bytes.NewReader(withDict), d))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				showDiffs(t, got, data)
+			}
+
+			if d.ID() != 0 {
+				// A frame that names a dictionary can't be
+				// read without it.
+				_, err = io.ReadAll(NewReader(bytes.NewReader(withDict)))
+				if err == nil {
+					t.Error("reading without dictionary succeeded")
+				}
+			}
+
+			zstd, err := exec.LookPath("zstd")
+			if err != nil {
+				return
+			}
+			dictFile := filepath.Join(t.TempDir(), "dict")
+			if err := os.WriteFile(dictFile, db, 0o666); err != nil {
+				t.Fatal(err)
+			}
+
+			// Check that zstd can read our output.
+			cmd := exec.Command(zstd, "-d", "-D", dictFile)
+			cmd.Stdin = bytes.NewReader(withDict)
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("zstd -d failed: %v", err)
+			}
+			if !bytes.Equal(out, data) {
+				showDiffs(t, out, data)
+			}
+
+			// Check that we can read zstd output.
+			cmd = exec.Command(zstd, "-z", "-D", dictFile)
+			cmd.Stdin = bytes.NewReader(data)
+			out, err = cmd.Output()
+			if err != nil {
+				t.Fatalf("zstd -z failed: %v", err)
+			}
+			got, err = io.ReadAll(NewReaderDict(bytes.NewReader(out), d))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				showDiffs(t, got, data)
+			}
+		})
+	}
+}
+
+func TestParseDictBad(t *testing.T) {
+	good := makeDict(1, []byte("some dictionary content"))
+	for i := 8; i < len(good)-len("some dictionary content"); i++ {
+		if _, err := ParseDict(good[:i]); err == nil {
+			t.Errorf("ParseDict of %d bytes succeeded", i)
+		}
+	}
+	zeroID := bytes.Clone(good)
+	zeroID[4], zeroID[5], zeroID[6], zeroID[7] = 0, 0, 0, 0
+	if _, err := ParseDict(zeroID); err == nil {
+		t.Error("ParseDict with zero ID succeeded")
+	}
+}
+
+func BenchmarkWriter(b *testing.B) {
+	data := bigData(b)
+	for _, level := range []int{1, 3, 9} {
+		b.Run(fmt.Sprint(level), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			w := NewWriter(io.Discard, level, nil)
+			for i := 0; i < b.N; i++ {
+				w.Reset(io.Discard)
+				w.Write(data)
+				w.Close()
+			}
+		})
+	}
+}
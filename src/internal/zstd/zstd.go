@@ -2,8 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package zstd provides a decompressor for zstd streams,
-// described in RFC 8878. It does not support dictionaries.
+// Package zstd provides a compressor and decompressor for zstd streams,
+// described in RFC 8878.
 package zstd
 
 import (
@@ -22,6 +22,9 @@ type Reader struct {
 	// The underlying Reader.
 	r io.Reader
 
+	// The dictionary, if any.
+	dict *Dict
+
 	// Whether we have read the frame header.
 	// This is of interest when buffer is empty.
 	// If true we expect to see a new block.
@@ -91,8 +94,18 @@ func NewReader(input io.Reader) *Reader {
 	return r
 }
 
+// NewReaderDict is like NewReader but uses a dictionary.
+// The dictionary is used for every frame that either requests it
+// by ID or does not request any dictionary.
+func NewReaderDict(input io.Reader, d *Dict) *Reader {
+	r := &Reader{dict: d}
+	r.Reset(input)
+	return r
+}
+
 // Reset discards the current state and starts reading a new stream from r.
 // This permits reusing a Reader rather than allocating a new one.
+// The dictionary, if any, is retained.
 func (r *Reader) Reset(input io.Reader) {
 	r.r = input
 
@@ -256,11 +269,18 @@ retry:
 
 	// Dictionary_ID. RFC 3.1.1.1.3.
 	if dictionaryIdSize != 0 {
-		dictionaryId := r.scratch[windowDescriptorSize : windowDescriptorSize+dictionaryIdSize]
-		// Allow only zero Dictionary ID.
-		for _, b := range dictionaryId {
-			if b != 0 {
-				return r.makeError(relativeOffset, "dictionaries are not supported")
+		var dictionaryId uint32
+		for i, b := range r.scratch[windowDescriptorSize : windowDescriptorSize+dictionaryIdSize] {
+			dictionaryId |= uint32(b) << (8 * i)
+		}
+		// A zero Dictionary ID means that no specific
+		// dictionary is required.
+		if dictionaryId != 0 {
+			if r.dict == nil {
+				return r.wrapError(relativeOffset, fmt.Errorf("missing dictionary %d", dictionaryId))
+			}
+			if r.dict.id != dictionaryId {
+				return r.wrapError(relativeOffset, fmt.Errorf("wrong dictionary: got %d want %d", r.dict.id, dictionaryId))
 			}
 		}
 	}
@@ -313,9 +333,44 @@ retry:
 	r.seqTables[1] = nil
 	r.seqTables[2] = nil
 
+	if r.dict != nil {
+		r.useDict()
+	}
+
 	return nil
 }
 
+// useDict prepares to read blocks from a frame that uses r.dict.
+// The dictionary content acts as though it precedes the frame,
+// and the dictionary supplies the initial entropy tables.
+// RFC 5.
+func (r *Reader) useDict() {
+	d := r.dict
+
+	r.repeatedOffset1 = d.repeatedOffsets[0]
+	r.repeatedOffset2 = d.repeatedOffsets[1]
+	r.repeatedOffset3 = d.repeatedOffsets[2]
+
+	if d.huffmanTableBits > 0 {
+		if len(r.huffmanTable) < 1<<maxHuffmanBits {
+			r.huffmanTable = make([]uint16, 1<<maxHuffmanBits)
+		}
+		copy(r.huffmanTable, d.huffmanTable)
+		r.huffmanTableBits = d.huffmanTableBits
+	}
+
+	// The seqTables are never written to, so we can share them.
+	r.seqTables = d.seqTables
+	r.seqTableBits = d.seqTableBits
+
+	// Matches may refer to all of the dictionary content,
+	// so the window must be large enough to hold it.
+	if len(d.content) > 0 {
+		r.window.reset(r.window.size + len(d.content))
+		r.window.save(d.content)
+	}
+}
+
 // skipFrame skips a skippable frame. RFC 3.1.2.
 func (r *Reader) skipFrame() error {
 	relativeOffset := 0
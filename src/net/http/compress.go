@@ -0,0 +1,321 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Compression of server responses.
+
+package http
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"compress/zstd"
+	"io"
+	"mime"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressMinSize is the size of the smallest response body that
+// CompressHandler compresses, unless the handler flushes it sooner.
+const compressMinSize = 1024
+
+// compressEncodings lists the content codings supported by
+// CompressHandler, in order of preference.
+var compressEncodings = []string{"zstd", "gzip", "deflate"}
+
+// CompressHandler returns a handler that runs h and compresses its
+// responses using a content coding acceptable to the client, as indicated
+// by the Accept-Encoding header of the request. The supported content
+// codings are zstd, gzip and deflate; when the client accepts several of
+// them equally, they are preferred in that order.
+//
+// A response is compressed only if its body is at least 1024 bytes long,
+// or h flushes it earlier, and only if neither its Content-Type nor the
+// start of its body, as recognized by [DetectContentType], indicates data
+// that is already compressed, such as most image, audio and video formats,
+// or binary data of an unknown type. Event streams (text/event-stream) are
+// not compressed, since compression would delay the delivery of events.
+// If h does not set a Content-Type, CompressHandler sets one using
+// [DetectContentType], as the server would for an uncompressed response.
+// Responses that already have a Content-Encoding or Content-Range, partial
+// responses, responses to HEAD requests, and responses with the
+// Cache-Control directive no-transform are never compressed.
+//
+// CompressHandler adds "Accept-Encoding" to the Vary header of the
+// responses whose encoding depends on the request. When it compresses a
+// response, it removes its Content-Length and Accept-Ranges headers,
+// and makes its ETag, if any, a weak validator, since the compressed
+// bytes may differ from one response to the next.
+//
+// Flushing the response with [ResponseController.Flush] or
+// [Flusher.Flush] flushes the compressed data written so far.
+// Other methods of [ResponseController] apply to the underlying
+// [ResponseWriter]. If the end of the response cannot be written after h
+// returns, CompressHandler panics with [ErrAbortHandler], so that the
+// client does not mistake the truncated response for a complete one.
+func CompressHandler(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		cw := &compressWriter{rw: w, req: r}
+		h.ServeHTTP(cw, r)
+		if err := cw.close(); err != nil {
+			panic(ErrAbortHandler)
+		}
+	})
+}
+
+// A compressWriter is the ResponseWriter used by CompressHandler.
+// It buffers the start of the body until it has decided whether to
+// compress it.
+type compressWriter struct {
+	rw      ResponseWriter
+	req     *Request
+	code    int    // status code passed to WriteHeader, or 0
+	started bool   // whether the header has been written to rw
+	buf     []byte // body written before started
+	enc     string // content coding of the body, or "" for identity
+	zw      compressor
+}
+
+func (cw *compressWriter) Header() Header { return cw.rw.Header() }
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if code >= 100 && code <= 199 && code != StatusSwitchingProtocols {
+		// Informational headers are sent immediately.
+		cw.rw.WriteHeader(code)
+		return
+	}
+	if cw.started {
+		// Let the underlying ResponseWriter report the superfluous call.
+		cw.rw.WriteHeader(code)
+		return
+	}
+	if cw.code != 0 {
+		caller := relevantCaller()
+		logf(cw.req, "http: superfluous response.WriteHeader call from %s (%s:%d)", caller.Function, path.Base(caller.File), caller.Line)
+		return
+	}
+	cw.code = code
+	if !bodyAllowedForStatus(code) {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.code == 0 {
+		cw.code = StatusOK
+	}
+	if cw.started {
+		if cw.zw != nil {
+			return cw.zw.Write(p)
+		}
+		return cw.rw.Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= compressMinSize {
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush implements [Flusher].
+func (cw *compressWriter) Flush() {
+	cw.FlushError()
+}
+
+// FlushError flushes the compressed data written so far and then
+// the underlying ResponseWriter. It is used by [ResponseController.Flush].
+func (cw *compressWriter) FlushError() error {
+	if cw.code == 0 {
+		cw.code = StatusOK
+	}
+	if !cw.started {
+		if err := cw.start(true); err != nil {
+			return err
+		}
+	}
+	if cw.zw != nil {
+		if err := cw.zw.Flush(); err != nil {
+			return err
+		}
+	}
+	return NewResponseController(cw.rw).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter,
+// for use by [ResponseController].
+func (cw *compressWriter) Unwrap() ResponseWriter {
+	return cw.rw
+}
+
+// close finishes the response after the handler has returned.
+func (cw *compressWriter) close() error {
+	if !cw.started {
+		if cw.code == 0 {
+			// Nothing was written.
+			return nil
+		}
+		if err := cw.start(false); err != nil {
+			return err
+		}
+	}
+	if cw.zw == nil {
+		return nil
+	}
+	err := cw.zw.Close()
+	putCompressor(cw.enc, cw.zw)
+	cw.zw = nil
+	return err
+}
+
+// start decides how to encode the response, writes its header, and
+// writes any buffered body. If compress is false, the response is not
+// compressed.
+func (cw *compressWriter) start(compress bool) error {
+	cw.started = true
+	h := cw.rw.Header()
+	if _, ok := h["Content-Type"]; !ok && len(cw.buf) > 0 {
+		h.Set("Content-Type", DetectContentType(cw.buf))
+	}
+	if compress && cw.compressible() {
+		h.Add("Vary", "Accept-Encoding")
+		cw.enc = negotiateEncoding(cw.req.Header["Accept-Encoding"])
+	}
+	if cw.enc != "" {
+		h.Set("Content-Encoding", cw.enc)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		if etag := h.Get("Etag"); strings.HasPrefix(etag, `"`) {
+			h.Set("Etag", "W/"+etag)
+		}
+	}
+	cw.rw.WriteHeader(cw.code)
+
+	var w io.Writer = cw.rw
+	if cw.enc != "" {
+		cw.zw = getCompressor(cw.enc, cw.rw)
+		w = cw.zw
+	}
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) > 0 {
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compressible reports whether the response may be compressed,
+// regardless of the encodings accepted by the client.
+func (cw *compressWriter) compressible() bool {
+	if cw.req.Method == "HEAD" || cw.code == StatusPartialContent || !bodyAllowedForStatus(cw.code) {
+		return false
+	}
+	h := cw.rw.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	for _, v := range h["Cache-Control"] {
+		for d := range strings.SplitSeq(v, ",") {
+			if ascii.EqualFold(textproto.TrimString(d), "no-transform") {
+				return false
+			}
+		}
+	}
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n < compressMinSize {
+			return false
+		}
+	}
+	return compressibleContentType(h.Get("Content-Type"), cw.buf)
+}
+
+// compressibleContentType reports whether compressing content of
+// type ct that begins with data is likely to make it smaller.
+func compressibleContentType(ct string, data []byte) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil || mt == "text/event-stream" {
+		return false
+	}
+	return !isCompressedType(mt) && !isCompressedType(DetectContentType(data))
+}
+
+// negotiateEncoding returns the content coding to use for a response
+// to a request with the given Accept-Encoding header values, as described
+// in RFC 9110, Section 12.5.3, or "" if the response should not be
+// compressed.
+func negotiateEncoding(accept []string) string {
+	qs := make(map[string]float64)
+	wildcard := -1.0
+	for _, v := range accept {
+		for item := range strings.SplitSeq(v, ",") {
+			coding, params, _ := strings.Cut(item, ";")
+			coding, _ = ascii.ToLower(textproto.TrimString(coding))
+			q := 1.0
+			for p := range strings.SplitSeq(params, ";") {
+				name, value, _ := strings.Cut(p, "=")
+				if ascii.EqualFold(textproto.TrimString(name), "q") {
+					var err error
+					if q, err = strconv.ParseFloat(textproto.TrimString(value), 64); err != nil {
+						q = 0
+					}
+				}
+			}
+			switch coding {
+			case "":
+				continue
+			case "*":
+				wildcard = q
+				continue
+			case "x-gzip":
+				coding = "gzip"
+			}
+			qs[coding] = q
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range compressEncodings {
+		q, ok := qs[enc]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// A compressor is a writer that compresses data in some content coding.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+var compressorPools = map[string]*sync.Pool{
+	"gzip":    {New: func() any { return gzip.NewWriter(nil) }},
+	"deflate": {New: func() any { return zlib.NewWriter(nil) }},
+	"zstd":    {New: func() any { return zstd.NewWriter(nil) }},
+}
+
+// getCompressor returns a compressor for enc that writes to w.
+func getCompressor(enc string, w io.Writer) compressor {
+	zw := compressorPools[enc].Get().(compressor)
+	zw.Reset(w)
+	return zw
+}
+
+// putCompressor returns a compressor obtained from getCompressor
+// to its pool.
+func putCompressor(enc string, zw compressor) {
+	zw.Reset(nil)
+	compressorPools[enc].Put(zw)
+}
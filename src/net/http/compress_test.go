@@ -0,0 +1,251 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"compress/zstd"
+	"context"
+	"io"
+	"log"
+	. "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var compressBody = strings.Repeat("All work and no play makes Jack a dull boy.\n", 100)
+
+// decompress decodes body according to its content coding.
+func decompress(t *testing.T, enc string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	var err error
+	switch enc {
+	case "":
+		return string(body)
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	case "zstd":
+		r = zstd.NewReader(bytes.NewReader(body))
+	default:
+		t.Fatalf("unknown content coding %q", enc)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompressing %s: %v", enc, err)
+	}
+	return string(b)
+}
+
+func TestCompressHandlerNegotiation(t *testing.T) {
+	h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, compressBody)
+	}))
+	for _, test := range []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"x-gzip", "gzip"},
+		{"GZIP;q=0.5, deflate;q=0.8", "deflate"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"gzip;q=1.0, zstd;q=0.9", "gzip"},
+		{"*", "zstd"},
+		{"zstd;q=0, *;q=0.1", "gzip"},
+		{"br", ""},
+		{"gzip;q=0", ""},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.accept != "" {
+			req.Header.Set("Accept-Encoding", test.accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		res := rec.Result()
+		if got := res.Header.Get("Content-Encoding"); got != test.want {
+			t.Errorf("Accept-Encoding %q: Content-Encoding = %q, want %q", test.accept, got, test.want)
+			continue
+		}
+		if got := res.Header.Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: Vary = %q, want %q", test.accept, got, "Accept-Encoding")
+		}
+		if got := res.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+			t.Errorf("Accept-Encoding %q: Content-Type = %q", test.accept, got)
+		}
+		if got := decompress(t, test.want, rec.Body.Bytes()); got != compressBody {
+			t.Errorf("Accept-Encoding %q: got body of %d bytes, want %d bytes", test.accept, len(got), len(compressBody))
+		}
+		if test.want != "" && rec.Body.Len() >= len(compressBody) {
+			t.Errorf("Accept-Encoding %q: compressed body is %d bytes, uncompressed is %d", test.accept, rec.Body.Len(), len(compressBody))
+		}
+	}
+}
+
+func TestCompressHandlerSkip(t *testing.T) {
+	png := "\x89PNG\x0D\x0A\x1A\x0A" + compressBody
+	for _, test := range []struct {
+		name   string
+		method string
+		body   string
+		header Header
+		code   int
+	}{
+		{name: "small", body: "hello"},
+		{name: "small with length", body: "hello", header: Header{"Content-Length": {"5"}}},
+		{name: "sniffed image", body: png},
+		{name: "image", body: compressBody, header: Header{"Content-Type": {"image/jpeg"}}},
+		{name: "sniffed archive", body: "PK\x03\x04" + compressBody, header: Header{"Content-Type": {"application/x-custom"}}},
+		{name: "event stream", body: compressBody, header: Header{"Content-Type": {"text/event-stream"}}},
+		{name: "encoded", body: compressBody, header: Header{"Content-Encoding": {"br"}}},
+		{name: "no-transform", body: compressBody, header: Header{"Cache-Control": {"public, No-Transform"}}},
+		{name: "partial", body: compressBody, header: Header{"Content-Range": {"bytes 0-9/100"}}, code: StatusPartialContent},
+		{name: "HEAD", method: "HEAD", body: compressBody},
+		{name: "no content", code: StatusNoContent},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+				for k, v := range test.header {
+					w.Header()[k] = v
+				}
+				if test.code != 0 {
+					w.WriteHeader(test.code)
+				}
+				io.WriteString(w, test.body)
+			}))
+			method := test.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			res := rec.Result()
+			if got, want := res.Header.Get("Content-Encoding"), test.header.Get("Content-Encoding"); got != want {
+				t.Errorf("Content-Encoding = %q, want %q", got, want)
+			}
+			if got := res.Header.Get("Vary"); got != "" {
+				t.Errorf("Vary = %q, want none", got)
+			}
+			if test.method != "HEAD" && test.code != StatusNoContent && rec.Body.String() != test.body {
+				t.Errorf("body changed")
+			}
+		})
+	}
+}
+
+func TestCompressHandlerSuperfluousWriteHeader(t *testing.T) {
+	h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "hello")
+		w.WriteHeader(StatusTeapot)
+	}))
+	var logBuf strings.Builder
+	srv := &Server{ErrorLog: log.New(&logBuf, "", 0)}
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ServerContextKey, srv))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != StatusOK || rec.Body.String() != "hello" {
+		t.Errorf("got status %d, body %q; want %d, %q", rec.Code, rec.Body, StatusOK, "hello")
+	}
+	if !strings.Contains(logBuf.String(), "superfluous response.WriteHeader") {
+		t.Errorf("superfluous WriteHeader call was not logged; log: %q", logBuf.String())
+	}
+}
+
+func TestCompressHandlerServeContent(t *testing.T) {
+	h := CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("ETag", `"v1"`)
+		ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(compressBody))
+	}))
+	get := func(header ...string) *Response {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	res := get()
+	if res.StatusCode != StatusOK || res.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got status %d, Content-Encoding %q; want 200, gzip", res.StatusCode, res.Header.Get("Content-Encoding"))
+	}
+	for k, want := range map[string]string{"Etag": `W/"v1"`, "Content-Length": "", "Accept-Ranges": ""} {
+		if got := res.Header.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	body, _ := io.ReadAll(res.Body)
+	if got := decompress(t, "gzip", body); got != compressBody {
+		t.Errorf("got body of %d bytes, want %d bytes", len(got), len(compressBody))
+	}
+
+	// Ranges apply to the uncompressed content.
+	res = get("Range", "bytes=4-7")
+	body, _ = io.ReadAll(res.Body)
+	if res.StatusCode != StatusPartialContent || res.Header.Get("Content-Encoding") != "" || string(body) != "work" {
+		t.Errorf("Range: got status %d, Content-Encoding %q, body %q; want 206, none, %q", res.StatusCode, res.Header.Get("Content-Encoding"), body, "work")
+	}
+
+	// The weak ETag can be used to revalidate.
+	res = get("If-None-Match", `W/"v1"`)
+	if res.StatusCode != StatusNotModified || res.Header.Get("Content-Encoding") != "" {
+		t.Errorf("If-None-Match: got status %d, Content-Encoding %q; want 304, none", res.StatusCode, res.Header.Get("Content-Encoding"))
+	}
+}
+
+func TestCompressHandlerFlush(t *testing.T) { run(t, testCompressHandlerFlush) }
+func testCompressHandlerFlush(t *testing.T, mode testMode) {
+	proceed := make(chan struct{})
+	cst := newClientServerTest(t, mode, CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, "first line\n")
+		if err := NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+		<-proceed
+		io.WriteString(w, "second line\n")
+	})))
+	defer close(proceed)
+
+	req, _ := NewRequest("GET", cst.ts.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if got := res.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(zr)
+	line, err := br.ReadString('\n')
+	if err != nil || line != "first line\n" {
+		t.Fatalf("read %q, %v; want first line before the handler returns", line, err)
+	}
+	proceed <- struct{}{}
+	rest, err := io.ReadAll(br)
+	if err != nil || string(rest) != "second line\n" {
+		t.Fatalf("read %q, %v; want second line", rest, err)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// An Entry is a cookie stored in a [Jar], together with the attributes
+// the jar records for it. Entries are used to save and restore the
+// contents of a jar with [Jar.Export] and [Jar.Import].
+//
+// The JSON encoding of a []Entry, as produced by the encoding/json package,
+// is a stable format suitable for storing a jar in a file.
+type Entry struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Quoted bool   `json:"quoted,omitempty"` // whether Value is to be sent in double quotes
+
+	// Domain is the host name or IP address for which the cookie was set.
+	// If HostOnly is false, the cookie is also sent to subdomains of Domain.
+	Domain   string `json:"domain"`
+	HostOnly bool   `json:"hostOnly,omitempty"`
+	Path     string `json:"path"`
+
+	Secure      bool          `json:"secure,omitempty"`
+	HttpOnly    bool          `json:"httpOnly,omitempty"`
+	Partitioned bool          `json:"partitioned,omitempty"`
+	SameSite    http.SameSite `json:"sameSite,omitempty"` // 0, or one of the http.SameSite constants
+
+	// Expires is the time at which the cookie expires.
+	// It is the zero time for a session cookie, which does not expire
+	// as long as the jar exists.
+	Expires time.Time `json:"expires,omitzero"`
+
+	Creation   time.Time `json:"creation"`   // when the cookie was first set
+	LastAccess time.Time `json:"lastAccess"` // when the cookie was last set or sent
+}
+
+// Export returns the cookies stored in the jar, including session cookies
+// but not expired cookies. The entries are sorted by domain, then by path,
+// then by creation time.
+func (j *Jar) Export() []Entry {
+	return j.export(time.Now())
+}
+
+// export is like Export but takes the current time as a parameter.
+func (j *Jar) export(now time.Time) []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var selected []entry
+	for _, submap := range j.entries {
+		for _, e := range submap {
+			if e.Persistent && !e.Expires.After(now) {
+				continue
+			}
+			selected = append(selected, e)
+		}
+	}
+	slices.SortFunc(selected, func(a, b entry) int {
+		if r := strings.Compare(a.Domain, b.Domain); r != 0 {
+			return r
+		}
+		if r := strings.Compare(a.Path, b.Path); r != 0 {
+			return r
+		}
+		if r := a.Creation.Compare(b.Creation); r != 0 {
+			return r
+		}
+		return cmp.Compare(a.seqNum, b.seqNum)
+	})
+
+	entries := make([]Entry, len(selected))
+	for i, e := range selected {
+		entries[i] = Entry{
+			Name:        e.Name,
+			Value:       e.Value,
+			Quoted:      e.Quoted,
+			Domain:      e.Domain,
+			HostOnly:    e.HostOnly,
+			Path:        e.Path,
+			Secure:      e.Secure,
+			HttpOnly:    e.HttpOnly,
+			Partitioned: e.Partitioned,
+			Creation:    e.Creation,
+			LastAccess:  e.LastAccess,
+		}
+		switch e.SameSite {
+		case "SameSite":
+			entries[i].SameSite = http.SameSiteDefaultMode
+		case "SameSite=Strict":
+			entries[i].SameSite = http.SameSiteStrictMode
+		case "SameSite=Lax":
+			entries[i].SameSite = http.SameSiteLaxMode
+		}
+		if e.Persistent {
+			entries[i].Expires = e.Expires
+		}
+	}
+	return entries
+}
+
+// Import adds the cookies in entries to the jar, replacing any cookies
+// with the same name, domain, and path. Expired entries are ignored.
+//
+// The domain of each entry is validated as it would be by [Jar.SetCookies]
+// for a cookie received from that domain, including the check against the
+// jar's public suffix list; an entry for a public suffix must be host-only.
+// Invalid entries are skipped, and Import returns
+// an error describing them after importing the valid ones.
+func (j *Jar) Import(entries []Entry) error {
+	return j.importEntries(entries, time.Now())
+}
+
+// importEntries is like Import but takes the current time as a parameter.
+func (j *Jar) importEntries(entries []Entry, now time.Time) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var errs []error
+	for _, ie := range entries {
+		e, err := j.importEntry(&ie, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cookie %q for domain %q: %w", ie.Name, ie.Domain, err))
+			continue
+		}
+		if e.Persistent && !e.Expires.After(now) {
+			continue
+		}
+		key := jarKey(e.Domain, j.psList)
+		submap := j.entries[key]
+		if submap == nil {
+			submap = make(map[string]entry)
+			j.entries[key] = submap
+		}
+		e.seqNum = j.nextSeqNum
+		j.nextSeqNum++
+		submap[e.id()] = e
+	}
+	return errors.Join(errs...)
+}
+
+var errMalformedPath = errors.New("cookiejar: malformed cookie path")
+
+// importEntry validates ie and converts it to an entry.
+func (j *Jar) importEntry(ie *Entry, now time.Time) (e entry, err error) {
+	host, err := canonicalHost(ie.Domain)
+	if err != nil || host == "" || host[0] == '.' {
+		return e, errMalformedDomain
+	}
+	if ie.HostOnly {
+		e.Domain, e.HostOnly = host, true
+	} else {
+		// A domain cookie is valid if it could have been
+		// set by a response from the domain itself.
+		e.Domain, e.HostOnly, err = j.domainAndType(host, host)
+		if err != nil {
+			return e, err
+		}
+		if e.HostOnly && !isIP(host) {
+			// domainAndType turns a domain cookie for a public
+			// suffix into a host cookie. An imported entry says
+			// explicitly which it is, so reject it instead.
+			return e, errIllegalDomain
+		}
+	}
+	if ie.Path == "" || ie.Path[0] != '/' {
+		return e, errMalformedPath
+	}
+
+	e.Name = ie.Name
+	e.Value = ie.Value
+	e.Quoted = ie.Quoted
+	e.Path = ie.Path
+	e.Secure = ie.Secure
+	e.HttpOnly = ie.HttpOnly
+	e.Partitioned = ie.Partitioned
+	switch ie.SameSite {
+	case http.SameSiteDefaultMode:
+		e.SameSite = "SameSite"
+	case http.SameSiteStrictMode:
+		e.SameSite = "SameSite=Strict"
+	case http.SameSiteLaxMode:
+		e.SameSite = "SameSite=Lax"
+	}
+	if ie.Expires.IsZero() {
+		e.Expires = endOfTime
+	} else {
+		e.Expires = ie.Expires
+		e.Persistent = true
+	}
+	e.Creation = ie.Creation
+	if e.Creation.IsZero() {
+		e.Creation = now
+	}
+	e.LastAccess = ie.LastAccess
+	if e.LastAccess.IsZero() {
+		e.LastAccess = e.Creation
+	}
+	return e, nil
+}
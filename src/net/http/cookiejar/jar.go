@@ -91,19 +91,20 @@ func New(o *Options) (*Jar, error) {
 // This struct type is not used outside of this package per se, but the exported
 // fields are those of RFC 6265.
 type entry struct {
-	Name       string
-	Value      string
-	Quoted     bool
-	Domain     string
-	Path       string
-	SameSite   string
-	Secure     bool
-	HttpOnly   bool
-	Persistent bool
-	HostOnly   bool
-	Expires    time.Time
-	Creation   time.Time
-	LastAccess time.Time
+	Name        string
+	Value       string
+	Quoted      bool
+	Domain      string
+	Path        string
+	SameSite    string
+	Secure      bool
+	HttpOnly    bool
+	Partitioned bool
+	Persistent  bool
+	HostOnly    bool
+	Expires     time.Time
+	Creation    time.Time
+	LastAccess  time.Time
 
 	// seqNum is a sequence number so that Cookies returns cookies in a
 	// deterministic order, even for cookies that have equal Path length and
@@ -433,6 +434,7 @@ func (j *Jar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e e
 	e.Quoted = c.Quoted
 	e.Secure = c.Secure
 	e.HttpOnly = c.HttpOnly
+	e.Partitioned = c.Partitioned
 
 	switch c.SameSite {
 	case http.SameSiteDefaultMode:
@@ -5,10 +5,13 @@
 package cookiejar
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -1375,3 +1378,108 @@ func TestIssue19384(t *testing.T) {
 		}
 	}
 }
+
+func TestExportImport(t *testing.T) {
+	jar := newTestJar()
+	setCookies := func(u string, lines ...string) {
+		var cookies []*http.Cookie
+		for _, line := range lines {
+			c, err := http.ParseSetCookie(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cookies = append(cookies, c)
+		}
+		jar.setCookies(mustParseURL(u), cookies, tNow)
+	}
+	setCookies("https://www.host.test/some/path",
+		"session=abc; Secure; HttpOnly; SameSite=Strict",
+		"persistent=1; Domain=host.test; Path=/; "+expiresIn(3600),
+		`quoted="a b"; SameSite=Lax; Partitioned; Secure`,
+		"expired=x; "+expiresIn(1),
+	)
+	setCookies("http://192.168.0.10", "ip=1; Max-Age=100")
+
+	later := tNow.Add(2 * time.Second)
+	entries := jar.export(later)
+	want := []Entry{{
+		Name: "ip", Value: "1",
+		Domain: "192.168.0.10", HostOnly: true, Path: "/",
+		Expires:  tNow.Add(100 * time.Second),
+		Creation: tNow, LastAccess: tNow,
+	}, {
+		Name: "persistent", Value: "1",
+		Domain: "host.test", Path: "/",
+		Expires:  tNow.Add(3600 * time.Second),
+		Creation: tNow, LastAccess: tNow,
+	}, {
+		Name: "session", Value: "abc",
+		Domain: "www.host.test", HostOnly: true, Path: "/some",
+		Secure: true, HttpOnly: true, SameSite: http.SameSiteStrictMode,
+		Creation: tNow, LastAccess: tNow,
+	}, {
+		Name: "quoted", Value: "a b", Quoted: true,
+		Domain: "www.host.test", HostOnly: true, Path: "/some",
+		Secure: true, Partitioned: true, SameSite: http.SameSiteLaxMode,
+		Creation: tNow, LastAccess: tNow,
+	}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("export:\ngot  %+v\nwant %+v", entries, want)
+	}
+
+	// The entries must survive a round trip through JSON
+	// and be sent as they were by the original jar.
+	b, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Entry
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	jar2 := newTestJar()
+	if err := jar2.importEntries(decoded, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := jar2.export(later); !reflect.DeepEqual(got, want) {
+		t.Fatalf("export after import:\ngot  %+v\nwant %+v", got, want)
+	}
+	for _, u := range []string{"https://www.host.test/some/x", "http://other.host.test/", "http://192.168.0.10/"} {
+		got := fmt.Sprint(jar2.cookies(mustParseURL(u), later))
+		if want := fmt.Sprint(jar.cookies(mustParseURL(u), later)); got != want {
+			t.Errorf("cookies for %s: got %s, want %s", u, got, want)
+		}
+	}
+}
+
+func TestImportValidation(t *testing.T) {
+	jar := newTestJar()
+	entries := []Entry{
+		{Name: "ok", Domain: "WWW.Example.com.", Path: "/"},
+		{Name: "suffix", Domain: "co.uk", Path: "/"},
+		{Name: "suffixhost", Domain: "co.uk", HostOnly: true, Path: "/"},
+		{Name: "nodomain", Domain: "", Path: "/"},
+		{Name: "baddomain", Domain: ".example.com", Path: "/"},
+		{Name: "badpath", Domain: "example.com", Path: "x"},
+		{Name: "expired", Domain: "example.com", Path: "/", Expires: tNow.Add(-time.Second)},
+	}
+	err := jar.importEntries(entries, tNow)
+	if err == nil {
+		t.Fatal("Import succeeded with invalid entries")
+	}
+	for _, name := range []string{"suffix", "nodomain", "baddomain", "badpath"} {
+		if !strings.Contains(err.Error(), strconv.Quote(name)) {
+			t.Errorf("error %q does not mention %q", err, name)
+		}
+	}
+
+	got := jar.export(tNow)
+	want := []Entry{
+		// A public suffix may only have host cookies.
+		{Name: "suffixhost", Domain: "co.uk", HostOnly: true, Path: "/", Creation: tNow, LastAccess: tNow},
+		{Name: "ok", Domain: "www.example.com", Path: "/", Creation: tNow, LastAccess: tNow},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("export:\ngot  %+v\nwant %+v", got, want)
+	}
+}
@@ -300,6 +300,13 @@ func ExportSetH2GoawayTimeout(d time.Duration) (restore func()) {
 
 func (r *Request) ExportIsReplayable() bool { return r.isReplayable() }
 
+// ExportRateLimiterTake returns the take method of a new rate limiter
+// for rl, which takes the current time as a parameter.
+func ExportRateLimiterTake(rl RateLimit[string]) func(key string, now time.Time) time.Duration {
+	l := &rateLimiter[string]{rl: rl, buckets: make(map[string]*tokenBucket)}
+	return l.take
+}
+
 // ExportCloseTransportConnsAbruptly closes all idle connections from
 // tr in an abrupt way, just reaching into the underlying Conns and
 // closing them, without telling the Transport or its persistConns
@@ -0,0 +1,422 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package httpcache implements a private HTTP cache, as specified by
+// RFC 9111, in the form of an [http.RoundTripper].
+//
+// To use it, set a [Transport] as the Transport of an [http.Client]:
+//
+//	client := &http.Client{
+//		Transport: &httpcache.Transport{
+//			Storage: httpcache.NewMemoryStorage(64 << 20),
+//		},
+//	}
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default values for the fields of Transport.
+const (
+	defaultStorageSize = 32 << 20
+	defaultMaxBodySize = 16 << 20
+)
+
+// timeNow is the clock used by the cache; tests replace it.
+var timeNow = time.Now
+
+// Transport is an [http.RoundTripper] that implements a private cache,
+// as specified by RFC 9111. It serves responses to GET requests from its
+// storage while they are fresh, and revalidates stale responses with their
+// origin server using the ETag and Last-Modified header fields.
+//
+// Transport honors the Cache-Control and Expires header fields of
+// responses, including the stale-while-revalidate directive of RFC 5861,
+// and the Cache-Control header fields of requests. Because the cache is
+// private, it stores responses marked private and ignores the s-maxage
+// directive. It stores at most one response for each URL; a stored
+// response is only used for requests whose header fields named by the
+// response's Vary header field match those of the request it was stored for.
+//
+// Requests with methods other than GET, and GET requests that include
+// a Range header field or a conditional header field such as
+// If-None-Match, bypass the cache. A successful response to a request
+// with an unsafe method, such as POST, invalidates the stored response for
+// the request's URL and for the URLs in its Location and Content-Location
+// header fields.
+//
+// Transport adds an Age header field to the responses it serves from
+// storage, and adds a Cache-Status header field, as specified by RFC 9211,
+// to all its responses to describe how the cache handled the request.
+type Transport struct {
+	// Transport is the RoundTripper used to send requests to the
+	// origin server. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Storage holds the stored responses. If nil, a MemoryStorage
+	// of 32 MiB is created when the Transport is first used.
+	Storage Storage
+
+	// MaxBodySize is the size of the largest response body that is stored.
+	// If zero, 16 MiB is used.
+	MaxBodySize int64
+
+	storageOnce sync.Once
+	storage     Storage
+
+	mu           sync.Mutex
+	revalidating map[string]bool // keys being revalidated in the background
+}
+
+// cacheStatus is the cache identifier used in Cache-Status header fields.
+const cacheStatus = "httpcache"
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) store() Storage {
+	t.storageOnce.Do(func() {
+		t.storage = t.Storage
+		if t.storage == nil {
+			t.storage = NewMemoryStorage(defaultStorageSize)
+		}
+	})
+	return t.storage
+}
+
+func (t *Transport) maxBodySize() int64 {
+	if t.MaxBodySize > 0 {
+		return t.MaxBodySize
+	}
+	return defaultMaxBodySize
+}
+
+// cacheKey returns the key of the stored response for a URL.
+func cacheKey(u *url.URL) string {
+	return u.String()
+}
+
+// RoundTrip implements the [http.RoundTripper] interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqCC := parseCacheControl(req.Header)
+	if !cacheableRequest(req, reqCC) {
+		resp, err := t.transport().RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if !isSafe(req.Method) && resp.StatusCode < 400 {
+			t.invalidate(req.URL, resp)
+		}
+		setCacheStatus(resp, "fwd=bypass", resp.StatusCode)
+		return resp, nil
+	}
+
+	key := cacheKey(req.URL)
+	e := t.load(key)
+	fwd := "fwd=uri-miss"
+	if e != nil && !e.matches(req) {
+		e, fwd = nil, "fwd=vary-miss"
+	}
+	if e != nil {
+		now := timeNow()
+		age := e.age(now)
+		respCC := parseCacheControl(e.header)
+		lifetime := e.freshnessLifetime(respCC)
+		switch {
+		case usable(reqCC, respCC, age, lifetime):
+			resp := e.response(req, age)
+			setCacheStatus(resp, "hit", 0)
+			return resp, nil
+		case canServeStale(reqCC, respCC, age, lifetime):
+			resp := e.response(req, age)
+			setCacheStatus(resp, "hit", 0)
+			t.revalidateInBackground(req, key, e)
+			return resp, nil
+		}
+		fwd = "fwd=stale"
+	}
+	if reqCC.has("only-if-cached") {
+		// RFC 9111, Section 5.2.1.7.
+		resp := &http.Response{
+			Status:     "504 Gateway Timeout",
+			StatusCode: http.StatusGatewayTimeout,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}
+		setCacheStatus(resp, fwd, 0)
+		return resp, nil
+	}
+
+	resp, status, err := t.fetch(req, key, e)
+	if err != nil {
+		return nil, err
+	}
+	setCacheStatus(resp, fwd, status)
+	return resp, nil
+}
+
+// fetch sends req to the origin server, as a conditional request if e is
+// not nil, and stores the response if possible. If the server responds
+// that e has not been modified, fetch returns the updated stored response.
+// It also returns the status code of the server's response.
+func (t *Transport) fetch(req *http.Request, key string, e *entry) (_ *http.Response, status int, _ error) {
+	outreq := req
+	if e != nil {
+		etag, lastModified := e.header.Get("Etag"), e.header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outreq = req.Clone(req.Context())
+			if etag != "" {
+				outreq.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outreq.Header.Set("If-Modified-Since", lastModified)
+			}
+		} else {
+			e = nil
+		}
+	}
+
+	requestTime := timeNow()
+	resp, err := t.transport().RoundTrip(outreq)
+	if err != nil {
+		return nil, 0, err
+	}
+	responseTime := timeNow()
+
+	if e != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		e.update(resp, requestTime, responseTime)
+		t.store().Set(key, e.marshal())
+		return e.response(req, e.age(responseTime)), http.StatusNotModified, nil
+	}
+	t.storeResponse(req, resp, key, requestTime, responseTime)
+	return resp, resp.StatusCode, nil
+}
+
+// storeResponse arranges for resp, a response to req, to be stored
+// under key once its body has been read, if it may be stored.
+func (t *Transport) storeResponse(req *http.Request, resp *http.Response, key string, requestTime, responseTime time.Time) {
+	reqCC, respCC := parseCacheControl(req.Header), parseCacheControl(resp.Header)
+	if reqCC.has("no-store") || respCC.has("no-store") {
+		t.store().Delete(key)
+		return
+	}
+	if !storable(resp, respCC) {
+		return
+	}
+	limit := t.maxBodySize()
+	if resp.ContentLength > limit {
+		return
+	}
+	e := newEntry(req, resp, requestTime, responseTime)
+	resp.Body = &cachingBody{
+		ReadCloser: resp.Body,
+		limit:      limit,
+		done: func(body []byte) {
+			e.body = body
+			t.store().Set(key, e.marshal())
+		},
+	}
+}
+
+// revalidateInBackground revalidates e, a stale response to req stored
+// under key, unless it is already being revalidated.
+func (t *Transport) revalidateInBackground(req *http.Request, key string, e *entry) {
+	t.mu.Lock()
+	if t.revalidating[key] {
+		t.mu.Unlock()
+		return
+	}
+	if t.revalidating == nil {
+		t.revalidating = make(map[string]bool)
+	}
+	t.revalidating[key] = true
+	t.mu.Unlock()
+
+	req = req.Clone(context.WithoutCancel(req.Context()))
+	go func() {
+		defer func() {
+			t.mu.Lock()
+			delete(t.revalidating, key)
+			t.mu.Unlock()
+		}()
+		resp, _, err := t.fetch(req, key, e)
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
+
+// load returns the entry stored under key, or nil if there is none.
+func (t *Transport) load(key string) *entry {
+	data, ok := t.store().Get(key)
+	if !ok {
+		return nil
+	}
+	e, err := unmarshalEntry(data)
+	if err != nil {
+		t.store().Delete(key)
+		return nil
+	}
+	return e
+}
+
+// invalidate removes the stored responses invalidated by resp, a response
+// to a request with an unsafe method for u (RFC 9111, Section 4.4).
+func (t *Transport) invalidate(u *url.URL, resp *http.Response) {
+	t.store().Delete(cacheKey(u))
+	for _, name := range []string{"Location", "Content-Location"} {
+		v := resp.Header.Get(name)
+		if v == "" {
+			continue
+		}
+		loc, err := u.Parse(v)
+		if err != nil || loc.Scheme != u.Scheme || loc.Host != u.Host {
+			continue
+		}
+		t.store().Delete(cacheKey(loc))
+	}
+}
+
+// cacheableRequest reports whether a response to req may be
+// served from or stored in the cache.
+func cacheableRequest(req *http.Request, cc cacheControl) bool {
+	if req.Method != "" && req.Method != "GET" {
+		return false
+	}
+	if cc.has("no-store") {
+		return false
+	}
+	for _, name := range []string{"Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range"} {
+		if _, ok := req.Header[name]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// storable reports whether resp may be stored (RFC 9111, Section 3).
+func storable(resp *http.Response, cc cacheControl) bool {
+	if !heuristicallyCacheable[resp.StatusCode] {
+		return false
+	}
+	for _, name := range varyFields(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+	// Only store responses that can be used later: those that are
+	// fresh for some time or that can be revalidated.
+	for _, name := range []string{"Expires", "Etag", "Last-Modified"} {
+		if _, ok := resp.Header[name]; ok {
+			return true
+		}
+	}
+	return cc.has("max-age")
+}
+
+// usable reports whether a stored response, with Cache-Control respCC,
+// may be used without validation to satisfy a request with Cache-Control
+// reqCC (RFC 9111, Sections 4.2 and 5.2).
+func usable(reqCC, respCC cacheControl, age, lifetime time.Duration) bool {
+	if reqCC.has("no-cache") || respCC.has("no-cache") {
+		return false
+	}
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := reqCC.seconds("min-fresh"); ok && lifetime-age < minFresh {
+		return false
+	}
+	if lifetime > age {
+		return true
+	}
+	if !reqCC.has("max-stale") || respCC.has("must-revalidate") {
+		return false
+	}
+	maxStale, ok := reqCC.seconds("max-stale")
+	return !ok || age-lifetime <= maxStale
+}
+
+// canServeStale reports whether a stale stored response may be served
+// while it is revalidated in the background, as permitted by the
+// stale-while-revalidate directive (RFC 5861, Section 3).
+func canServeStale(reqCC, respCC cacheControl, age, lifetime time.Duration) bool {
+	if reqCC.has("no-cache") || reqCC.has("max-age") || reqCC.has("min-fresh") {
+		return false
+	}
+	if respCC.has("no-cache") || respCC.has("must-revalidate") {
+		return false
+	}
+	swr, ok := respCC.seconds("stale-while-revalidate")
+	return ok && age-lifetime <= swr
+}
+
+// isSafe reports whether method is safe (RFC 9110, Section 9.2.1).
+func isSafe(method string) bool {
+	switch method {
+	case "", "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+// setCacheStatus adds a Cache-Status header field to resp (RFC 9211)
+// with the given parameters, and with the fwd-status parameter if
+// fwdStatus is not zero.
+func setCacheStatus(resp *http.Response, params string, fwdStatus int) {
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	if fwdStatus != 0 {
+		params += "; fwd-status=" + strconv.Itoa(fwdStatus)
+	}
+	resp.Header.Add("Cache-Status", cacheStatus+"; "+params)
+}
+
+// A cachingBody is a response body that keeps a copy of the data read
+// from it. When the whole body has been read, it passes the copy to done,
+// unless the body is larger than limit.
+type cachingBody struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	limit int64
+	done  func(body []byte)
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.done == nil {
+		return n, err
+	}
+	if int64(b.buf.Len()+n) > b.limit {
+		b.done = nil
+		b.buf = bytes.Buffer{}
+		return n, err
+	}
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.done(b.buf.Bytes())
+		b.done = nil
+	}
+	return n, err
+}
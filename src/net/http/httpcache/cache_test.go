@@ -0,0 +1,361 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpcache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock replaces timeNow for the duration of a test.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(t *testing.T) *fakeClock {
+	c := &fakeClock{now: time.Now()}
+	old := timeNow
+	timeNow = c.Now
+	t.Cleanup(func() { timeNow = old })
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// cacheTest is a server and a client using a cache to access it.
+type cacheTest struct {
+	t       *testing.T
+	srv     *httptest.Server
+	client  *http.Client
+	mu      sync.Mutex
+	fetches int // requests received by the server
+}
+
+func newCacheTest(t *testing.T, h http.HandlerFunc) *cacheTest {
+	ct := &cacheTest{t: t}
+	ct.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ct.mu.Lock()
+		ct.fetches++
+		ct.mu.Unlock()
+		// Use the test's clock for the date of the response.
+		w.Header().Set("Date", timeNow().UTC().Format(http.TimeFormat))
+		h(w, r)
+	}))
+	t.Cleanup(ct.srv.Close)
+	ct.client = &http.Client{Transport: &Transport{Transport: ct.srv.Client().Transport}}
+	return ct
+}
+
+func (ct *cacheTest) Fetches() int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.fetches
+}
+
+// get sends a request for path with the given header fields,
+// and checks the body and the Cache-Status of the response.
+func (ct *cacheTest) get(method, path string, header http.Header, wantBody, wantStatus string) *http.Response {
+	ct.t.Helper()
+	req, err := http.NewRequest(method, ct.srv.URL+path, nil)
+	if err != nil {
+		ct.t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := ct.client.Do(req)
+	if err != nil {
+		ct.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		ct.t.Fatal(err)
+	}
+	if string(body) != wantBody {
+		ct.t.Errorf("%s %s: body = %q, want %q", method, path, body, wantBody)
+	}
+	if got := resp.Header.Get("Cache-Status"); got != "httpcache; "+wantStatus {
+		ct.t.Errorf("%s %s: Cache-Status = %q, want %q", method, path, got, "httpcache; "+wantStatus)
+	}
+	return resp
+}
+
+func TestFreshnessAndRevalidation(t *testing.T) {
+	clock := newFakeClock(t)
+	version := 1
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request) {
+		etag := fmt.Sprintf(`"v%d"`, version)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "version %d", version)
+	})
+
+	ct.get("GET", "/", nil, "version 1", "fwd=uri-miss; fwd-status=200")
+	clock.Advance(30 * time.Second)
+	resp := ct.get("GET", "/", nil, "version 1", "hit")
+	if age := resp.Header.Get("Age"); age != "30" {
+		t.Errorf("Age = %q, want %q", age, "30")
+	}
+	if n := ct.Fetches(); n != 1 {
+		t.Fatalf("server saw %d requests, want 1", n)
+	}
+
+	// Once stale, the response is revalidated.
+	clock.Advance(31 * time.Second)
+	ct.get("GET", "/", nil, "version 1", "fwd=stale; fwd-status=304")
+	ct.get("GET", "/", nil, "version 1", "hit")
+	if n := ct.Fetches(); n != 2 {
+		t.Fatalf("server saw %d requests, want 2", n)
+	}
+
+	// A new version replaces the stored response.
+	version = 2
+	clock.Advance(61 * time.Second)
+	ct.get("GET", "/", nil, "version 2", "fwd=stale; fwd-status=200")
+	ct.get("GET", "/", nil, "version 2", "hit")
+
+	// Request directives.
+	ct.get("GET", "/", http.Header{"Cache-Control": {"no-cache"}}, "version 2", "fwd=stale; fwd-status=304")
+	clock.Advance(10 * time.Second)
+	ct.get("GET", "/", http.Header{"Cache-Control": {"max-age=5"}}, "version 2", "fwd=stale; fwd-status=304")
+	ct.get("GET", "/", http.Header{"Cache-Control": {"min-fresh=70"}}, "version 2", "fwd=stale; fwd-status=304")
+	clock.Advance(70 * time.Second)
+	ct.get("GET", "/", http.Header{"Cache-Control": {"max-stale=20"}}, "version 2", "hit")
+	ct.get("GET", "/", http.Header{"Cache-Control": {"only-if-cached"}}, "", "fwd=stale")
+	ct.get("GET", "/", http.Header{"Cache-Control": {"no-store"}}, "version 2", "fwd=bypass; fwd-status=200")
+}
+
+func TestLastModified(t *testing.T) {
+	clock := newFakeClock(t)
+	modified := clock.Now().Add(-100 * time.Second).UTC().Format(http.TimeFormat)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", modified)
+		if r.Header.Get("If-Modified-Since") == modified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, "body")
+	})
+
+	// The heuristic freshness lifetime is 10% of the time
+	// since the last modification.
+	ct.get("GET", "/", nil, "body", "fwd=uri-miss; fwd-status=200")
+	clock.Advance(5 * time.Second)
+	ct.get("GET", "/", nil, "body", "hit")
+	clock.Advance(10 * time.Second)
+	ct.get("GET", "/", nil, "body", "fwd=stale; fwd-status=304")
+}
+
+func TestExpires(t *testing.T) {
+	clock := newFakeClock(t)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request) {
+		now := clock.Now()
+		switch r.URL.Path {
+		case "/future":
+			w.Header().Set("Expires", now.Add(time.Minute).UTC().Format(http.TimeFormat))
+		case "/invalid":
+			w.Header().Set("Expires", "0")
+		}
+		io.WriteString(w, r.URL.Path)
+	})
+	ct.get("GET", "/future", nil, "/future", "fwd=uri-miss; fwd-status=200")
+	ct.get("GET", "/future", nil, "/future", "hit")
+	clock.Advance(2 * time.Minute)
+	ct.get("GET", "/future", nil, "/future", "fwd=stale; fwd-status=200")
+	ct.get("GET", "/invalid", nil, "/invalid", "fwd=uri-miss; fwd-status=200")
+	ct.get("GET", "/invalid", nil, "/invalid", "fwd=stale; fwd-status=200")
+}
+
+func TestVary(t *testing.T) {
+	newFakeClock(t)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		io.WriteString(w, r.Header.Get("Accept-Language"))
+	})
+	en := http.Header{"Accept-Language": {"en"}}
+	fr := http.Header{"Accept-Language": {"fr"}}
+	ct.get("GET", "/", en, "en", "fwd=uri-miss; fwd-status=200")
+	ct.get("GET", "/", en, "en", "hit")
+	ct.get("GET", "/", fr, "fr", "fwd=vary-miss; fwd-status=200")
+	ct.get("GET", "/", fr, "fr", "hit")
+	ct.get("GET", "/", nil, "", "fwd=vary-miss; fwd-status=200")
+}
+
+func TestNotStored(t *testing.T) {
+	newFakeClock(t)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/vary-star":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "*")
+		case "/error":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		io.WriteString(w, "body")
+	})
+	for _, test := range []struct {
+		path   string
+		status string
+	}{
+		{"/no-store", "fwd=uri-miss; fwd-status=200"},
+		{"/vary-star", "fwd=uri-miss; fwd-status=200"},
+		{"/error", "fwd=uri-miss; fwd-status=500"},
+		{"/no-validator", "fwd=uri-miss; fwd-status=200"},
+	} {
+		ct.get("GET", test.path, nil, "body", test.status)
+		ct.get("GET", test.path, nil, "body", test.status)
+	}
+}
+
+func TestPartialBodyNotStored(t *testing.T) {
+	newFakeClock(t)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, strings.Repeat("x", 1000))
+	})
+	resp, err := ct.client.Get(ct.srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.CopyN(io.Discard, resp.Body, 10)
+	resp.Body.Close()
+	ct.get("GET", "/", nil, strings.Repeat("x", 1000), "fwd=uri-miss; fwd-status=200")
+	ct.get("GET", "/", nil, strings.Repeat("x", 1000), "hit")
+
+	// Bodies larger than MaxBodySize are not stored.
+	ct.client.Transport.(*Transport).MaxBodySize = 100
+	ct.get("GET", "/large", nil, strings.Repeat("x", 1000), "fwd=uri-miss; fwd-status=200")
+	ct.get("GET", "/large", nil, strings.Repeat("x", 1000), "fwd=uri-miss; fwd-status=200")
+}
+
+func TestInvalidation(t *testing.T) {
+	newFakeClock(t)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.Header().Set("Location", "/other")
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, r.URL.Path)
+	})
+	ct.get("GET", "/", nil, "/", "fwd=uri-miss; fwd-status=200")
+	ct.get("GET", "/other", nil, "/other", "fwd=uri-miss; fwd-status=200")
+	ct.get("GET", "/", nil, "/", "hit")
+	ct.get("POST", "/", nil, "", "fwd=bypass; fwd-status=201")
+	ct.get("GET", "/", nil, "/", "fwd=uri-miss; fwd-status=200")
+	ct.get("GET", "/other", nil, "/other", "fwd=uri-miss; fwd-status=200")
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	clock := newFakeClock(t)
+	var mu sync.Mutex
+	version := 1
+	revalidated := make(chan bool, 1)
+	ct := newCacheTest(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=60")
+		fmt.Fprintf(w, "version %d", version)
+		if r.Header.Get("If-None-Match") != "" || version > 1 {
+			revalidated <- true
+		}
+	})
+	ct.get("GET", "/", nil, "version 1", "fwd=uri-miss; fwd-status=200")
+
+	mu.Lock()
+	version = 2
+	mu.Unlock()
+	clock.Advance(30 * time.Second)
+	ct.get("GET", "/", nil, "version 1", "hit")
+	<-revalidated
+	// Wait for the background revalidation to store the response.
+	for i := 0; ; i++ {
+		tr := ct.client.Transport.(*Transport)
+		tr.mu.Lock()
+		done := !tr.revalidating["http://"+ct.srv.Listener.Addr().String()+"/"]
+		tr.mu.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ct.get("GET", "/", nil, "version 2", "hit")
+
+	// Beyond the stale-while-revalidate window, the request waits.
+	mu.Lock()
+	version = 3
+	mu.Unlock()
+	clock.Advance(100 * time.Second)
+	ct.get("GET", "/", nil, "version 3", "fwd=stale; fwd-status=200")
+	<-revalidated
+}
+
+func TestParseCacheControl(t *testing.T) {
+	h := http.Header{"Cache-Control": {`Max-Age=60, no-cache="Set-Cookie, X-Foo"`, ` private ,max-age=5,stale-while-revalidate="30"`}}
+	want := cacheControl{
+		"max-age":                "60",
+		"no-cache":               "Set-Cookie, X-Foo",
+		"private":                "",
+		"stale-while-revalidate": "30",
+	}
+	if got := parseCacheControl(h); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCacheControl = %v, want %v", got, want)
+	}
+}
+
+func TestEntryMarshal(t *testing.T) {
+	now := time.Unix(1700000000, 123)
+	e := &entry{
+		requestTime:  now,
+		responseTime: now.Add(time.Second),
+		varyHeader:   http.Header{"Accept-Language": {"en"}},
+		status:       "200 OK",
+		statusCode:   200,
+		header:       http.Header{"Content-Type": {"text/plain"}, "Vary": {"Accept-Language"}},
+		body:         []byte("line 1\r\n\r\nline 2"),
+	}
+	got, err := unmarshalEntry(e.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.requestTime.Equal(e.requestTime) || !got.responseTime.Equal(e.responseTime) {
+		t.Errorf("times = %v, %v; want %v, %v", got.requestTime, got.responseTime, e.requestTime, e.responseTime)
+	}
+	got.requestTime, got.responseTime = e.requestTime, e.responseTime
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("unmarshalEntry(marshal()) = %+v, want %+v", got, e)
+	}
+	if _, err := unmarshalEntry([]byte("garbage")); err == nil {
+		t.Error("unmarshalEntry(garbage) succeeded")
+	}
+}
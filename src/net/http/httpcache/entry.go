@@ -0,0 +1,320 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An entry is a stored response.
+type entry struct {
+	requestTime  time.Time   // when the request was sent
+	responseTime time.Time   // when the response was received
+	varyHeader   http.Header // request header fields named by Vary
+	status       string
+	statusCode   int
+	header       http.Header
+	body         []byte
+}
+
+// newEntry returns an entry for resp, which was sent in response to req.
+// The body is filled in later.
+func newEntry(req *http.Request, resp *http.Response, requestTime, responseTime time.Time) *entry {
+	e := &entry{
+		requestTime:  requestTime,
+		responseTime: responseTime,
+		varyHeader:   make(http.Header),
+		status:       resp.Status,
+		statusCode:   resp.StatusCode,
+		header:       resp.Header.Clone(),
+	}
+	if e.header == nil {
+		e.header = make(http.Header)
+	}
+	for _, name := range varyFields(e.header) {
+		if v, ok := req.Header[name]; ok {
+			e.varyHeader[name] = v
+		}
+	}
+	return e
+}
+
+// marshal returns the encoding of e, which consists of a line holding
+// the request and response times and the status, the header fields named by
+// Vary, the response header fields, and the body.
+func (e *entry) marshal() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d %d %s\r\n", e.requestTime.UnixNano(), e.responseTime.UnixNano(), e.status)
+	e.varyHeader.Write(&b)
+	b.WriteString("\r\n")
+	e.header.Write(&b)
+	b.WriteString("\r\n")
+	b.Write(e.body)
+	return b.Bytes()
+}
+
+var errCorruptEntry = errors.New("httpcache: corrupt entry")
+
+// unmarshalEntry decodes an entry encoded by marshal.
+func unmarshalEntry(data []byte) (*entry, error) {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+	line, err := r.ReadLine()
+	if err != nil {
+		return nil, errCorruptEntry
+	}
+	f := strings.SplitN(line, " ", 3)
+	if len(f) != 3 {
+		return nil, errCorruptEntry
+	}
+	reqTime, err1 := strconv.ParseInt(f[0], 10, 64)
+	respTime, err2 := strconv.ParseInt(f[1], 10, 64)
+	code, _, _ := strings.Cut(f[2], " ")
+	statusCode, err3 := strconv.Atoi(code)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, errCorruptEntry
+	}
+	e := &entry{
+		requestTime:  time.Unix(0, reqTime),
+		responseTime: time.Unix(0, respTime),
+		status:       f[2],
+		statusCode:   statusCode,
+	}
+	vary, err := r.ReadMIMEHeader()
+	if err != nil {
+		return nil, errCorruptEntry
+	}
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		return nil, errCorruptEntry
+	}
+	e.varyHeader, e.header = http.Header(vary), http.Header(header)
+	if e.body, err = io.ReadAll(r.R); err != nil {
+		return nil, errCorruptEntry
+	}
+	return e, nil
+}
+
+// response returns a response to req holding the stored response,
+// whose age is age.
+func (e *entry) response(req *http.Request, age time.Duration) *http.Response {
+	header := e.header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	return &http.Response{
+		Status:        e.status,
+		StatusCode:    e.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// matches reports whether the header fields of req named by Vary match
+// those of the request for which e was stored (RFC 9111, Section 4.1).
+func (e *entry) matches(req *http.Request) bool {
+	for _, name := range varyFields(e.header) {
+		if name == "*" {
+			return false
+		}
+		if normalizeFieldValue(req.Header[name]) != normalizeFieldValue(e.varyHeader[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// date returns the value of the Date header field of the stored response,
+// or the time the response was received if it has no valid Date.
+func (e *entry) date() time.Time {
+	if t, err := http.ParseTime(e.header.Get("Date")); err == nil {
+		return t
+	}
+	return e.responseTime
+}
+
+// age returns the current age of the stored response at time now,
+// as defined in RFC 9111, Section 4.2.3.
+func (e *entry) age(now time.Time) time.Duration {
+	apparentAge := max(e.responseTime.Sub(e.date()), 0)
+	ageValue, _ := parseSeconds(e.header.Get("Age"))
+	responseDelay := e.responseTime.Sub(e.requestTime)
+	correctedAgeValue := ageValue + responseDelay
+	correctedInitialAge := max(apparentAge, correctedAgeValue)
+	residentTime := now.Sub(e.responseTime)
+	return correctedInitialAge + residentTime
+}
+
+// freshnessLifetime returns the freshness lifetime of the stored response,
+// as defined in RFC 9111, Section 4.2.1. cc is its Cache-Control.
+func (e *entry) freshnessLifetime(cc cacheControl) time.Duration {
+	if v, ok := cc["max-age"]; ok {
+		d, _ := parseSeconds(v)
+		return d
+	}
+	if v := e.header.Values("Expires"); len(v) > 0 {
+		// An invalid Expires value represents a time in the past.
+		t, err := http.ParseTime(v[0])
+		if err != nil {
+			return 0
+		}
+		return max(t.Sub(e.date()), 0)
+	}
+	// Use a heuristic of 10% of the time since the last modification,
+	// as suggested by RFC 9111, Section 4.2.2.
+	if t, err := http.ParseTime(e.header.Get("Last-Modified")); err == nil && heuristicallyCacheable[e.statusCode] {
+		return max(e.date().Sub(t)/10, 0)
+	}
+	return 0
+}
+
+// update updates the stored response from resp, a 304 (Not Modified)
+// response to a conditional request, as described in RFC 9111, Section 4.3.4.
+func (e *entry) update(resp *http.Response, requestTime, responseTime time.Time) {
+	for k, v := range resp.Header {
+		switch k {
+		case "Content-Length", "Connection", "Keep-Alive", "Proxy-Connection", "Te", "Transfer-Encoding", "Upgrade":
+			continue
+		}
+		e.header[k] = v
+	}
+	e.requestTime = requestTime
+	e.responseTime = responseTime
+}
+
+// heuristicallyCacheable holds the status codes of responses
+// that are heuristically cacheable (RFC 9110, Section 15.1),
+// other than 206 (Partial Content). Only these responses are stored.
+var heuristicallyCacheable = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// A cacheControl holds the directives of Cache-Control header fields,
+// mapping lower-case directive names to their unquoted values.
+type cacheControl map[string]string
+
+// parseCacheControl parses the Cache-Control header fields in h.
+func parseCacheControl(h http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, v := range h.Values("Cache-Control") {
+		for v != "" {
+			var d string
+			d, v = nextDirective(v)
+			name, value, _ := strings.Cut(d, "=")
+			name, _ = ascii.ToLower(textproto.TrimString(name))
+			if name == "" {
+				continue
+			}
+			value = textproto.TrimString(value)
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				value = strings.ReplaceAll(value[1:len(value)-1], `\`, "")
+			}
+			if _, ok := cc[name]; !ok {
+				cc[name] = value
+			}
+		}
+	}
+	return cc
+}
+
+// nextDirective returns the first comma-separated directive in s,
+// ignoring commas in quoted strings, and the rest of s.
+func nextDirective(s string) (directive, rest string) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && quoted:
+			i++
+		case c == ',' && !quoted:
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds returns the value of a directive holding a number of seconds.
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	return parseSeconds(v)
+}
+
+// parseSeconds parses a non-negative number of seconds, as used by the
+// Age header field and Cache-Control directives. Values that are too
+// large are capped, as required by RFC 9111, Section 1.2.2.
+func parseSeconds(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	for _, c := range []byte(s) {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	const maxSeconds = 1 << 31
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n > maxSeconds {
+		n = maxSeconds
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// varyFields returns the canonical names of the fields listed in the
+// Vary header fields of h.
+func varyFields(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for name := range strings.SplitSeq(v, ",") {
+			if name = textproto.TrimString(name); name != "" {
+				names = append(names, textproto.CanonicalMIMEHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// normalizeFieldValue combines the values of a field for comparison.
+func normalizeFieldValue(v []string) string {
+	var parts []string
+	for _, s := range v {
+		for p := range strings.SplitSeq(s, ",") {
+			if p = textproto.TrimString(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+	}
+	return strings.Join(parts, ",")
+}
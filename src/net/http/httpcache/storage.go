@@ -0,0 +1,107 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpcache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Storage stores the entries of a cache, each of which holds a response
+// to a request for a URL.
+//
+// Implementations of Storage must be safe for concurrent use by multiple
+// goroutines. Callers must not modify a value after passing it to Set or
+// receiving it from Get. An implementation may discard entries at any time.
+type Storage interface {
+	// Get returns the value stored for key, if any.
+	Get(key string) (value []byte, ok bool)
+
+	// Set stores value for key, replacing any previous value.
+	Set(key string, value []byte)
+
+	// Delete removes the value stored for key, if any.
+	Delete(key string)
+}
+
+// MemoryStorage is a [Storage] that keeps entries in memory. When the
+// total size of its keys and values exceeds a limit, it discards the
+// least recently used entries.
+type MemoryStorage struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	lru     list.List // of *memoryEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key   string
+	value []byte
+}
+
+func (e *memoryEntry) size() int64 { return int64(len(e.key) + len(e.value)) }
+
+// NewMemoryStorage returns a [MemoryStorage] that holds entries whose keys
+// and values have a total size of at most maxSize bytes.
+func NewMemoryStorage(maxSize int64) *MemoryStorage {
+	return &MemoryStorage{
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get implements [Storage].
+func (s *MemoryStorage) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.lru.MoveToFront(el)
+	return el.Value.(*memoryEntry).value, true
+}
+
+// Set implements [Storage]. A value that is larger than the limit
+// of s is not stored.
+func (s *MemoryStorage) Set(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(key)
+	e := &memoryEntry{key: key, value: value}
+	if e.size() > s.maxSize {
+		return
+	}
+	s.entries[key] = s.lru.PushFront(e)
+	s.size += e.size()
+	for s.size > s.maxSize {
+		s.delete(s.lru.Back().Value.(*memoryEntry).key)
+	}
+}
+
+// Delete implements [Storage].
+func (s *MemoryStorage) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(key)
+}
+
+func (s *MemoryStorage) delete(key string) {
+	el, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	s.lru.Remove(el)
+	delete(s.entries, key)
+	s.size -= el.Value.(*memoryEntry).size()
+}
+
+// Size returns the total size of the keys and values stored in s.
+func (s *MemoryStorage) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpcache
+
+import "testing"
+
+func TestMemoryStorage(t *testing.T) {
+	s := NewMemoryStorage(10)
+	s.Set("a", []byte("1234")) // size 5
+	s.Set("b", []byte("1234")) // size 5
+	if v, ok := s.Get("a"); !ok || string(v) != "1234" {
+		t.Fatalf(`Get("a") = %q, %v`, v, ok)
+	}
+	// Adding c evicts b, the least recently used entry.
+	s.Set("c", []byte("12"))
+	if _, ok := s.Get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	if _, ok := s.Get("a"); !ok {
+		t.Error("a was evicted")
+	}
+	if got := s.Size(); got != 8 {
+		t.Errorf("Size() = %d, want 8", got)
+	}
+
+	// Replacing an entry updates the size.
+	s.Set("a", []byte("1"))
+	if got := s.Size(); got != 5 {
+		t.Errorf("Size() = %d, want 5", got)
+	}
+	s.Delete("a")
+	if _, ok := s.Get("a"); ok || s.Size() != 3 {
+		t.Errorf("after Delete: Get succeeded or Size() = %d, want 3", s.Size())
+	}
+
+	// Values larger than the limit are not stored.
+	s.Set("big", make([]byte, 10))
+	if _, ok := s.Get("big"); ok {
+		t.Error("oversized value was stored")
+	}
+	if _, ok := s.Get("c"); !ok {
+		t.Error("c was evicted by an oversized value")
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httptest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"internal/txtar"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A ReplayMatch selects the parts of a request that must be equal to
+// those of a recorded request for a [ReplayTransport] to replay the
+// recorded response.
+type ReplayMatch uint
+
+const (
+	MatchMethod ReplayMatch = 1 << iota // the request method
+	MatchURL                            // the URL, without its fragment
+	MatchBody                           // the request body
+)
+
+// ReplayOptions configures a [ReplayTransport].
+type ReplayOptions struct {
+	// RedactHeaders lists the request and response header fields whose
+	// values are replaced by "REDACTED" in the recording, such as
+	// Authorization or Set-Cookie.
+	RedactHeaders []string
+
+	// Match selects the parts of a request that must match a recorded
+	// request. If Match is zero, the method, URL and body must match.
+	Match ReplayMatch
+}
+
+// A ReplayTransport is an [http.RoundTripper] that records the requests
+// sent through it and their responses to a file, or that replays the
+// responses recorded in such a file, so that tests of HTTP clients
+// can run without access to the servers they talk to.
+//
+// The file is a txtar archive holding, for each exchange, a request and
+// a response in HTTP/1.1 format, so that it can be reviewed and edited.
+type ReplayTransport struct {
+	file   string
+	rt     http.RoundTripper // nil when replaying
+	redact []string
+	match  ReplayMatch
+
+	mu        sync.Mutex
+	exchanges []*exchange
+}
+
+// An exchange is a recorded request and its response.
+type exchange struct {
+	method string
+	url    string
+	body   []byte
+	req    []byte // the request in HTTP/1.1 format
+	resp   []byte // the response in HTTP/1.1 format
+	used   bool
+}
+
+// NewRecordTransport returns a [ReplayTransport] that sends requests using
+// rt, or [http.DefaultTransport] if rt is nil, and records the exchanges.
+// The recording is written to file by [ReplayTransport.Close].
+//
+// The transport reads the whole body of each request and response.
+// Requests that fail are not recorded.
+func NewRecordTransport(file string, rt http.RoundTripper, opts *ReplayOptions) *ReplayTransport {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t := newReplayTransport(file, opts)
+	t.rt = rt
+	return t
+}
+
+// NewReplayTransport returns a [ReplayTransport] that replays the
+// exchanges recorded in file.
+//
+// Each recorded response is replayed at most once, in response to the
+// first request that matches its request, so that a sequence of identical
+// requests receives the sequence of responses that was recorded.
+// A request that matches no unused recorded request fails.
+func NewReplayTransport(file string, opts *ReplayOptions) (*ReplayTransport, error) {
+	t := newReplayTransport(file, opts)
+	a, err := txtar.ParseFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Files)%2 != 0 {
+		return nil, fmt.Errorf("httptest: %s: unpaired request or response", file)
+	}
+	for i := 0; i < len(a.Files); i += 2 {
+		reqFile, respFile := a.Files[i], a.Files[i+1]
+		if !strings.HasSuffix(reqFile.Name, ".request") || !strings.HasSuffix(respFile.Name, ".response") {
+			return nil, fmt.Errorf("httptest: %s: expected request and response, found %s and %s", file, reqFile.Name, respFile.Name)
+		}
+		req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(reqFile.Data)))
+		if err != nil {
+			return nil, fmt.Errorf("httptest: %s: %s: %v", file, reqFile.Name, err)
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("httptest: %s: %s: %v", file, reqFile.Name, err)
+		}
+		t.exchanges = append(t.exchanges, &exchange{
+			method: req.Method,
+			url:    req.URL.String(),
+			body:   body,
+			resp:   respFile.Data,
+		})
+	}
+	return t, nil
+}
+
+func newReplayTransport(file string, opts *ReplayOptions) *ReplayTransport {
+	t := &ReplayTransport{file: file, match: MatchMethod | MatchURL | MatchBody}
+	if opts != nil {
+		for _, k := range opts.RedactHeaders {
+			t.redact = append(t.redact, http.CanonicalHeaderKey(k))
+		}
+		if opts.Match != 0 {
+			t.match = opts.Match
+		}
+	}
+	return t
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	u := *req.URL
+	u.Fragment, u.RawFragment = "", ""
+	x := &exchange{method: req.Method, url: u.String(), body: body}
+	if x.method == "" {
+		x.method = "GET"
+	}
+	if t.rt == nil {
+		return t.replay(req, x)
+	}
+	return t.record(req, x)
+}
+
+func (t *ReplayTransport) replay(req *http.Request, x *exchange) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, rec := range t.exchanges {
+		if rec.used ||
+			t.match&MatchMethod != 0 && rec.method != x.method ||
+			t.match&MatchURL != 0 && rec.url != x.url ||
+			t.match&MatchBody != 0 && !bytes.Equal(rec.body, x.body) {
+			continue
+		}
+		rec.used = true
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rec.resp)), req)
+		if err != nil {
+			return nil, fmt.Errorf("httptest: %s: %v", t.file, err)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("httptest: %s: no recorded response for %s %s", t.file, x.method, x.url)
+}
+
+func (t *ReplayTransport) record(req *http.Request, x *exchange) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if req.Body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(x.body))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(x.body)), nil
+		}
+	}
+	resp, err := t.rt.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\n", x.method, x.url)
+	t.writeHeader(&b, req.Header, len(x.body))
+	b.Write(x.body)
+	x.req = b.Bytes()
+
+	var rb bytes.Buffer
+	status := resp.Status
+	if status == "" {
+		status = strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode)
+	}
+	fmt.Fprintf(&rb, "HTTP/1.1 %s\n", status)
+	t.writeHeader(&rb, resp.Header, len(respBody))
+	rb.Write(respBody)
+	x.resp = rb.Bytes()
+
+	t.mu.Lock()
+	t.exchanges = append(t.exchanges, x)
+	t.mu.Unlock()
+	return resp, nil
+}
+
+var headerNewlineReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// writeHeader writes h, with redacted values, followed by a Content-Length
+// field for a body of length n, and the blank line that ends the header.
+func (t *ReplayTransport) writeHeader(b *bytes.Buffer, h http.Header, n int) {
+	for _, k := range slices.Sorted(maps.Keys(h)) {
+		switch k {
+		case "Content-Length", "Transfer-Encoding":
+			continue
+		}
+		for _, v := range h[k] {
+			if slices.Contains(t.redact, k) {
+				v = "REDACTED"
+			}
+			fmt.Fprintf(b, "%s: %s\n", k, headerNewlineReplacer.Replace(v))
+		}
+	}
+	if n > 0 {
+		b.WriteString("Content-Length: " + strconv.Itoa(n) + "\n")
+	}
+	b.WriteString("\n")
+}
+
+// Close writes the recorded exchanges to the file, if t is recording.
+// A ReplayTransport replaying exchanges does nothing.
+func (t *ReplayTransport) Close() error {
+	if t.rt == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a := &txtar.Archive{Comment: []byte("HTTP exchanges recorded by net/http/httptest.\n")}
+	for i, x := range t.exchanges {
+		a.Files = append(a.Files,
+			txtar.File{Name: strconv.Itoa(i+1) + ".request", Data: x.req},
+			txtar.File{Name: strconv.Itoa(i+1) + ".response", Data: x.resp},
+		)
+	}
+	data := txtar.Format(a)
+	if len(txtar.Parse(data).Files) != len(a.Files) {
+		return errors.New("httptest: a recorded body contains a txtar file marker line")
+	}
+	return os.WriteFile(t.file, data, 0o666)
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httptest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplayTransport(t *testing.T) {
+	count := 0
+	ts := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Count", fmt.Sprint(count))
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, body)
+	}))
+	defer ts.Close()
+
+	type exchange struct {
+		method, path, body string
+		status             int
+		respBody           string
+	}
+	exchanges := []exchange{
+		{"GET", "/a", "", 200, "GET /a "},
+		{"GET", "/a", "", 200, "GET /a "},
+		{"POST", "/b", "one", 200, "POST /b one"},
+		{"POST", "/b", "two\n-- not a marker", 200, "POST /b two\n-- not a marker"},
+		{"GET", "/missing", "", 404, "404 page not found\n"},
+	}
+	do := func(c *http.Client, x exchange) (*http.Response, string, error) {
+		req, _ := http.NewRequest(x.method, ts.URL+x.path, strings.NewReader(x.body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := c.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp, string(body), err
+	}
+
+	file := filepath.Join(t.TempDir(), "exchanges.txtar")
+	opts := &ReplayOptions{RedactHeaders: []string{"authorization", "Set-Cookie"}}
+	rt := NewRecordTransport(file, ts.Client().Transport, opts)
+	c := &http.Client{Transport: rt}
+	for _, x := range exchanges {
+		resp, body, err := do(c, x)
+		if err != nil {
+			t.Fatalf("recording %s %s: %v", x.method, x.path, err)
+		}
+		if resp.StatusCode != x.status || body != x.respBody {
+			t.Fatalf("recording %s %s: got %d %q, want %d %q", x.method, x.path, resp.StatusCode, body, x.status, x.respBody)
+		}
+	}
+	if err := rt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("recording contains redacted values:\n%s", data)
+	}
+	if !strings.Contains(string(data), "\nAuthorization: REDACTED\n") {
+		t.Errorf("recording does not contain the redacted Authorization header:\n%s", data)
+	}
+	ts.Close()
+
+	rt, err = NewReplayTransport(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = &http.Client{Transport: rt}
+	for i, x := range exchanges {
+		resp, body, err := do(c, x)
+		if err != nil {
+			t.Fatalf("replaying %s %s: %v", x.method, x.path, err)
+		}
+		if resp.StatusCode != x.status || body != x.respBody {
+			t.Errorf("replaying %s %s: got %d %q, want %d %q", x.method, x.path, resp.StatusCode, body, x.status, x.respBody)
+		}
+		// Identical requests receive the responses in the recorded order.
+		if got, want := resp.Header.Get("X-Count"), fmt.Sprint(i+1); got != want {
+			t.Errorf("replaying %s %s: X-Count = %q, want %q", x.method, x.path, got, want)
+		}
+	}
+	if _, _, err := do(c, exchanges[0]); err == nil {
+		t.Errorf("replaying a request a third time succeeded, want error")
+	}
+}
+
+func TestReplayTransportMatch(t *testing.T) {
+	ts := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+	file := filepath.Join(t.TempDir(), "exchanges.txtar")
+	rt := NewRecordTransport(file, ts.Client().Transport, nil)
+	resp, err := (&http.Client{Transport: rt}).Post(ts.URL+"/path?q=1#frag", "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if err := rt.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		match        ReplayMatch
+		method, url  string
+		body         string
+		wantReplayed bool
+	}{
+		{0, "POST", "/path?q=1", "body", true},
+		{0, "POST", "/path?q=1#other", "body", true},
+		{0, "POST", "/path?q=1", "other", false},
+		{0, "PUT", "/path?q=1", "body", false},
+		{0, "POST", "/path?q=2", "body", false},
+		{MatchMethod | MatchURL, "POST", "/path?q=1", "other", true},
+		{MatchURL, "PUT", "/path?q=1", "other", true},
+		{MatchMethod, "POST", "/elsewhere", "", true},
+	} {
+		rt, err := NewReplayTransport(file, &ReplayOptions{Match: test.match})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest(test.method, ts.URL+test.url, strings.NewReader(test.body))
+		resp, err := rt.RoundTrip(req)
+		if replayed := err == nil; replayed != test.wantReplayed {
+			t.Errorf("match %b, %s %s %q: replayed = %v (%v), want %v", test.match, test.method, test.url, test.body, replayed, err, test.wantReplayed)
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+}
@@ -161,6 +161,10 @@ type ClientTrace struct {
 	// request and any body. It may be called multiple times
 	// in the case of retried requests.
 	WroteRequest func(WroteRequestInfo)
+
+	// Retry is called by net/http.RetryTransport before it waits to
+	// send a request again after a failed attempt.
+	Retry func(RetryInfo)
 }
 
 // WroteRequestInfo contains information provided to the WroteRequest
@@ -170,6 +174,23 @@ type WroteRequestInfo struct {
 	Err error
 }
 
+// RetryInfo contains information provided to the Retry hook.
+type RetryInfo struct {
+	// Attempt is the number of the attempt about to be made.
+	// The first retry is attempt 2.
+	Attempt int
+
+	// Delay is how long the request will wait before it is sent again.
+	Delay time.Duration
+
+	// StatusCode is the status code of the response to the previous
+	// attempt, or 0 if it failed with an error.
+	StatusCode int
+
+	// Err is the error returned by the previous attempt, if any.
+	Err error
+}
+
 // compose modifies t such that it respects the previously-registered hooks in old,
 // subject to the composition policy requested in t.Compose.
 func (t *ClientTrace) compose(old *ClientTrace) {
@@ -0,0 +1,204 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Rate and concurrency limits for handlers.
+
+package http
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A RateLimit configures [RateLimitHandler].
+//
+// To limit the rate of requests from each client IP address,
+// use a Key such as
+//
+//	func(r *http.Request) netip.Addr {
+//		ap, _ := netip.ParseAddrPort(r.RemoteAddr)
+//		return ap.Addr()
+//	}
+type RateLimit[K comparable] struct {
+	// Rate is the number of requests per second allowed for each key,
+	// on average. It must be positive.
+	Rate float64
+
+	// Burst is the number of requests allowed for each key in
+	// a short period of time, above the average rate.
+	// It must be at least 1.
+	Burst int
+
+	// Key returns the key that identifies the client of a request.
+	// If Key is nil, all requests share a single limit.
+	Key func(*Request) K
+
+	// OnReject, if non-nil, is called when a request is rejected,
+	// with the request's key and the time after which the request
+	// would have been allowed.
+	OnReject func(r *Request, key K, retryAfter time.Duration)
+}
+
+// RateLimitHandler returns a handler that limits the rate of requests
+// to h for each key, as configured by rl. It uses a token bucket for each
+// key, which holds up to rl.Burst tokens and is refilled at rl.Rate tokens
+// per second. Each request takes a token from its bucket, and is rejected
+// if the bucket is empty.
+//
+// A rejected request receives a 429 Too Many Requests response with
+// a Retry-After header holding the number of seconds until a token is
+// available.
+func RateLimitHandler[K comparable](h Handler, rl RateLimit[K]) Handler {
+	if !(rl.Rate > 0) || math.IsInf(rl.Rate, 0) {
+		panic("http: RateLimitHandler with invalid Rate")
+	}
+	if rl.Burst < 1 {
+		panic("http: RateLimitHandler with Burst less than 1")
+	}
+	l := &rateLimiter[K]{rl: rl, buckets: make(map[K]*tokenBucket)}
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		var key K
+		if rl.Key != nil {
+			key = rl.Key(r)
+		}
+		if wait := l.take(key, time.Now()); wait > 0 {
+			if rl.OnReject != nil {
+				rl.OnReject(r, key, wait)
+			}
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			Error(w, StatusText(StatusTooManyRequests), StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// A rateLimiter holds the token buckets of a RateLimitHandler.
+type rateLimiter[K comparable] struct {
+	rl RateLimit[K]
+
+	mu        sync.Mutex
+	buckets   map[K]*tokenBucket
+	nextSweep int // number of buckets at which to remove full buckets
+}
+
+// A tokenBucket holds the tokens available for a key.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // when tokens was computed
+}
+
+// take takes a token for key at time now. It returns 0 if it succeeds,
+// or the time until a token is available.
+func (l *rateLimiter[K]) take(key K, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.nextSweep {
+			l.sweep(now)
+		}
+		b = &tokenBucket{tokens: float64(l.rl.Burst), last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	wait := (1 - b.tokens) / l.rl.Rate * float64(time.Second)
+	return time.Duration(min(wait, math.MaxInt64/2))
+}
+
+func (l *rateLimiter[K]) refill(b *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(float64(l.rl.Burst), b.tokens+elapsed.Seconds()*l.rl.Rate)
+		b.last = now
+	}
+}
+
+// sweep removes the buckets that are full, and so are equivalent
+// to new buckets, to bound the memory used by keys no longer in use.
+func (l *rateLimiter[K]) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.rl.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+	l.nextSweep = max(2*len(l.buckets), 1024)
+}
+
+// A ConcurrencyLimit configures [ConcurrencyLimitHandler].
+type ConcurrencyLimit[K comparable] struct {
+	// Max is the maximum number of requests with the same key
+	// served concurrently. It must be at least 1.
+	Max int
+
+	// Key returns the key that identifies the client of a request.
+	// If Key is nil, all requests share a single limit.
+	Key func(*Request) K
+
+	// RetryAfter is the value of the Retry-After header sent with
+	// rejections. If it is zero, a value of 1 second is used.
+	RetryAfter time.Duration
+
+	// OnReject, if non-nil, is called when a request is rejected,
+	// with the request's key and the value of RetryAfter used.
+	OnReject func(r *Request, key K, retryAfter time.Duration)
+}
+
+// ConcurrencyLimitHandler returns a handler that limits the number of
+// requests for each key that h serves at the same time, as configured
+// by cl. A request that would exceed the limit is rejected with a
+// 503 Service Unavailable response and a Retry-After header.
+func ConcurrencyLimitHandler[K comparable](h Handler, cl ConcurrencyLimit[K]) Handler {
+	if cl.Max < 1 {
+		panic("http: ConcurrencyLimitHandler with Max less than 1")
+	}
+	retryAfter := cl.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	var (
+		mu       sync.Mutex
+		inFlight = make(map[K]int)
+	)
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		var key K
+		if cl.Key != nil {
+			key = cl.Key(r)
+		}
+		mu.Lock()
+		n := inFlight[key]
+		if n < cl.Max {
+			inFlight[key] = n + 1
+		}
+		mu.Unlock()
+		if n >= cl.Max {
+			if cl.OnReject != nil {
+				cl.OnReject(r, key, retryAfter)
+			}
+			w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+			Error(w, StatusText(StatusServiceUnavailable), StatusServiceUnavailable)
+			return
+		}
+		defer func() {
+			mu.Lock()
+			if inFlight[key]--; inFlight[key] == 0 {
+				delete(inFlight, key)
+			}
+			mu.Unlock()
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// retryAfterSeconds formats d as the value of a Retry-After header,
+// rounding up to a whole number of seconds.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(max((d+time.Second-1)/time.Second, 1)), 10)
+}
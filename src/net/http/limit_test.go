@@ -0,0 +1,182 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	. "net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+func remoteAddr(r *Request) netip.Addr {
+	ap, _ := netip.ParseAddrPort(r.RemoteAddr)
+	return ap.Addr()
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	type rejection struct {
+		key        netip.Addr
+		retryAfter time.Duration
+	}
+	var rejections []rejection
+	h := RateLimitHandler(HandlerFunc(func(w ResponseWriter, r *Request) {}), RateLimit[netip.Addr]{
+		Rate:  1.0 / 60,
+		Burst: 2,
+		Key:   remoteAddr,
+		OnReject: func(r *Request, key netip.Addr, retryAfter time.Duration) {
+			rejections = append(rejections, rejection{key, retryAfter})
+		},
+	})
+	get := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := range 2 {
+		if rec := get("192.0.2.1:1000"); rec.Code != StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i, rec.Code)
+		}
+	}
+	rec := get("192.0.2.1:1001")
+	if rec.Code != StatusTooManyRequests {
+		t.Fatalf("request beyond burst: status %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" && got != "59" {
+		t.Errorf("Retry-After = %q, want about 60", got)
+	}
+	if len(rejections) != 1 || rejections[0].key != netip.MustParseAddr("192.0.2.1") || rejections[0].retryAfter <= 59*time.Second {
+		t.Errorf("rejections = %v, want one of 192.0.2.1 for about a minute", rejections)
+	}
+
+	// Other clients have their own limit.
+	if rec := get("[2001:db8::1]:1000"); rec.Code != StatusOK {
+		t.Errorf("request from other client: status %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimitHandlerNoKey(t *testing.T) {
+	h := RateLimitHandler(HandlerFunc(func(w ResponseWriter, r *Request) {}), RateLimit[string]{
+		Rate:  1.0 / 60,
+		Burst: 1,
+	})
+	get := func(addr string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := get("192.0.2.1:1000"); code != StatusOK {
+		t.Fatalf("first request: status %d, want 200", code)
+	}
+	// With no key, all requests share the limit.
+	if code := get("192.0.2.2:1000"); code != StatusTooManyRequests {
+		t.Errorf("second request: status %d, want 429", code)
+	}
+}
+
+func TestRateLimitRefill(t *testing.T) {
+	take := ExportRateLimiterTake(RateLimit[string]{
+		Rate:  100,
+		Burst: 2,
+	})
+	now := time.Unix(1e9, 0)
+	for i := range 2 {
+		if wait := take("k", now); wait != 0 {
+			t.Fatalf("request %d: wait %v, want 0", i, wait)
+		}
+	}
+	if wait := take("k", now); wait != 10*time.Millisecond {
+		t.Fatalf("request beyond burst: wait %v, want 10ms", wait)
+	}
+	if wait := take("k", now.Add(5*time.Millisecond)); wait != 5*time.Millisecond {
+		t.Errorf("request after half a refill: wait %v, want 5ms", wait)
+	}
+	if wait := take("k", now.Add(10*time.Millisecond)); wait != 0 {
+		t.Errorf("request after refill: wait %v, want 0", wait)
+	}
+	// The bucket holds at most Burst tokens.
+	now = now.Add(time.Hour)
+	for i := range 3 {
+		if wait := take("k", now); (wait == 0) != (i < 2) {
+			t.Errorf("request %d after an hour: wait %v", i, wait)
+		}
+	}
+}
+
+func TestConcurrencyLimitHandler(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		mu      sync.Mutex
+		rejects []string
+	)
+	h := ConcurrencyLimitHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		started <- struct{}{}
+		<-release
+	}), ConcurrencyLimit[string]{
+		Max:        2,
+		Key:        func(r *Request) string { return r.Header.Get("User") },
+		RetryAfter: 1500 * time.Millisecond,
+		OnReject: func(r *Request, key string, retryAfter time.Duration) {
+			mu.Lock()
+			rejects = append(rejects, key)
+			mu.Unlock()
+		},
+	})
+	serve := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("User", user)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec := serve("alice"); rec.Code != StatusOK {
+				t.Errorf("in-flight request: status %d, want 200", rec.Code)
+			}
+		}()
+		<-started
+	}
+
+	rec := serve("alice")
+	if rec.Code != StatusServiceUnavailable {
+		t.Errorf("request over limit: status %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if len(rejects) != 1 || rejects[0] != "alice" {
+		t.Errorf("rejected keys = %q, want [alice]", rejects)
+	}
+
+	// Another key is not limited.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if rec := serve("bob"); rec.Code != StatusOK {
+			t.Errorf("request of other key: status %d, want 200", rec.Code)
+		}
+	}()
+	<-started
+
+	close(release)
+	wg.Wait()
+	go func() { <-started }()
+	if rec := serve("alice"); rec.Code != StatusOK {
+		t.Errorf("request after others completed: status %d, want 200", rec.Code)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
 	"unicode"
 )
@@ -205,6 +206,74 @@ func pathUnescape(path string) string {
 	return u
 }
 
+// buildPath returns an escaped path that matches p, substituting
+// the given values for its wildcards.
+func (p *pattern) buildPath(values map[string]string) (string, error) {
+	var b strings.Builder
+	used := 0
+	for _, seg := range p.segments {
+		if !seg.wild {
+			// A literal, or "/" for "{$}".
+			b.WriteByte('/')
+			if seg.s != "/" {
+				b.WriteString(url.PathEscape(seg.s))
+			}
+			continue
+		}
+		if seg.s == "" {
+			// Trailing slash.
+			b.WriteByte('/')
+			continue
+		}
+		v, ok := values[seg.s]
+		if !ok {
+			return "", fmt.Errorf("missing value for wildcard %q", seg.s)
+		}
+		used++
+		if !seg.multi {
+			if !validPathSegment(v) {
+				return "", fmt.Errorf("invalid value %q for wildcard %q", v, seg.s)
+			}
+			b.WriteByte('/')
+			b.WriteString(url.PathEscape(v))
+			continue
+		}
+		// A multi wildcard matches the rest of the path, which may end
+		// in a slash, but whose other segments must survive cleaning.
+		b.WriteByte('/')
+		rest, _ := strings.CutSuffix(v, "/")
+		for i, s := range strings.Split(rest, "/") {
+			if rest == "" {
+				break
+			}
+			if !validPathSegment(s) {
+				return "", fmt.Errorf("invalid value %q for wildcard %q", v, seg.s)
+			}
+			if i > 0 {
+				b.WriteByte('/')
+			}
+			b.WriteString(url.PathEscape(s))
+		}
+		if rest != v {
+			b.WriteByte('/')
+		}
+	}
+	if used != len(values) {
+		for name := range values {
+			if !slices.ContainsFunc(p.segments, func(s segment) bool { return s.wild && s.s == name }) {
+				return "", fmt.Errorf("pattern has no wildcard %q", name)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// validPathSegment reports whether s can be matched by a single wildcard,
+// given that request paths are cleaned before they are matched.
+func validPathSegment(s string) bool {
+	return s != "" && s != "." && s != ".."
+}
+
 // relationship is a relationship between two patterns, p1 and p2.
 type relationship string
 
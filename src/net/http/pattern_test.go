@@ -5,6 +5,7 @@
 package http
 
 import (
+	"net/url"
 	"slices"
 	"strings"
 	"testing"
@@ -418,6 +419,75 @@ func TestRegisterConflict(t *testing.T) {
 	}
 }
 
+func TestBuildPath(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		values  map[string]string
+		want    string
+	}{
+		{"/", nil, "/"},
+		{"/{$}", nil, "/"},
+		{"/a/b/", nil, "/a/b/"},
+		{"/a/{$}", nil, "/a/"},
+		{"GET example.com/a%2Fb/%25", nil, "/a%2Fb/%25"},
+		{"/users/{id}", map[string]string{"id": "42"}, "/users/42"},
+		{"/users/{id}/posts", map[string]string{"id": "a/b c?"}, "/users/a%2Fb%20c%3F/posts"},
+		{"/files/{path...}", map[string]string{"path": ""}, "/files/"},
+		{"/files/{path...}", map[string]string{"path": "a/b c/d"}, "/files/a/b%20c/d"},
+		{"/files/{path...}", map[string]string{"path": "dir/"}, "/files/dir/"},
+		{"/{a}/{b}/{$}", map[string]string{"a": "x", "b": "%"}, "/x/%25/"},
+	} {
+		pat := mustParsePattern(t, test.pattern)
+		got, err := pat.buildPath(test.values)
+		if err != nil {
+			t.Errorf("%q: %v", test.pattern, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.pattern, got, test.want)
+		}
+		// The path must match the pattern, with the same values.
+		mux := NewServeMux()
+		mux.Handle(test.pattern, NotFoundHandler())
+		u, err := url.ParseRequestURI(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &Request{Method: "GET", Host: "example.com", URL: u}
+		_, gotPattern, _, matches := mux.findHandler(r)
+		if gotPattern != test.pattern {
+			t.Errorf("%q: path %q matches %q", test.pattern, got, gotPattern)
+			continue
+		}
+		r.pat, r.matches = pat, matches
+		for name, v := range test.values {
+			if g := r.PathValue(name); g != v {
+				t.Errorf("%q: path %q: PathValue(%q) = %q, want %q", test.pattern, got, name, g, v)
+			}
+		}
+	}
+}
+
+func TestBuildPathError(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		values  map[string]string
+		want    string
+	}{
+		{"/users/{id}", nil, `missing value for wildcard "id"`},
+		{"/users/{id}", map[string]string{"id": ""}, "invalid value"},
+		{"/users/{id}", map[string]string{"id": ".."}, "invalid value"},
+		{"/users/{id}", map[string]string{"id": "1", "x": "2"}, `no wildcard "x"`},
+		{"/files/{p...}", map[string]string{"p": "a//b"}, "invalid value"},
+		{"/files/{p...}", map[string]string{"p": "a/../b"}, "invalid value"},
+	} {
+		_, err := mustParsePattern(t, test.pattern).buildPath(test.values)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q, %v: got error %v, want one containing %q", test.pattern, test.values, err, test.want)
+		}
+	}
+}
+
 func TestDescribeConflict(t *testing.T) {
 	for _, test := range []struct {
 		p1, p2 string
@@ -1533,20 +1533,39 @@ func (r *Request) closeBody() error {
 
 func (r *Request) isReplayable() bool {
 	if r.Body == nil || r.Body == NoBody || r.GetBody != nil {
-		switch valueOrDefault(r.Method, "GET") {
-		case "GET", "HEAD", "OPTIONS", "TRACE":
-			return true
-		}
-		// The Idempotency-Key, while non-standard, is widely used to
-		// mean a POST or other request is idempotent. See
-		// https://golang.org/issue/19943#issuecomment-421092421
-		if r.Header.has("Idempotency-Key") || r.Header.has("X-Idempotency-Key") {
-			return true
-		}
+		return r.isSafe() || r.hasIdempotencyKey()
+	}
+	return false
+}
+
+// isSafe reports whether r's method is safe, as defined by
+// RFC 9110, Section 9.2.1.
+func (r *Request) isSafe() bool {
+	switch valueOrDefault(r.Method, "GET") {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
 	}
 	return false
 }
 
+// isIdempotent reports whether r's method is idempotent, as defined by
+// RFC 9110, Section 9.2.2, or r is marked as idempotent by a header.
+func (r *Request) isIdempotent() bool {
+	switch valueOrDefault(r.Method, "GET") {
+	case "PUT", "DELETE":
+		return true
+	}
+	return r.isSafe() || r.hasIdempotencyKey()
+}
+
+// hasIdempotencyKey reports whether r has an Idempotency-Key header.
+func (r *Request) hasIdempotencyKey() bool {
+	// The Idempotency-Key, while non-standard, is widely used to
+	// mean a POST or other request is idempotent. See
+	// https://golang.org/issue/19943#issuecomment-421092421
+	return r.Header.has("Idempotency-Key") || r.Header.has("X-Idempotency-Key")
+}
+
 // outgoingLength reports the Content-Length of this outgoing (Client) request.
 // It maps 0 into -1 (unknown) when the Body is non-nil.
 func (r *Request) outgoingLength() int64 {
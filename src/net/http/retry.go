@@ -0,0 +1,235 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+)
+
+// Default values for the fields of RetryTransport.
+const (
+	defaultMaxRetries = 3
+	defaultMinDelay   = 100 * time.Millisecond
+	defaultMaxDelay   = 30 * time.Second
+)
+
+// A RetryTransport is a [RoundTripper] that sends a request again when
+// an attempt fails with a transient error, waiting between attempts with
+// jittered exponential backoff. To use it, set it as the Transport of a
+// [Client]:
+//
+//	client := &http.Client{Transport: &http.RetryTransport{}}
+//
+// By default, an attempt is retried if it fails with a transient error,
+// or if the response has status 429 (Too Many Requests), 502 (Bad Gateway),
+// 503 (Service Unavailable), or 504 (Gateway Timeout). The transient errors
+// are a timeout (a [net.Error] whose Timeout method reports true), a refused
+// or reset connection, and an unexpected end of file ([io.EOF] or
+// [io.ErrUnexpectedEOF]) on a connection reused from an earlier request,
+// which the server may have closed. Other errors, and all errors once the
+// request's context is done, are returned without retrying.
+//
+// Only idempotent requests are retried, unless RetryNonIdempotent is set.
+// A request is idempotent if its method is GET, HEAD, OPTIONS, TRACE, PUT,
+// or DELETE, or if it has an Idempotency-Key or X-Idempotency-Key header.
+// A request with a body is only retried if its GetBody field is set, as it
+// is by [NewRequest] for common body types; the body of each retry is
+// obtained by calling GetBody.
+//
+// The delay before the nth retry is chosen at random between d/2 and d,
+// where d is MinDelay * 2^(n-1), but no more than MaxDelay. If a response
+// with status 429 or 503 has a Retry-After header, the delay is the one it
+// requests instead; if that is longer than MaxDelay, the response is
+// returned without retrying. The request is also not retried if the delay
+// would end after the deadline of the request's context, and RoundTrip
+// returns the context's cause (see [context.Cause]) if the context is done
+// while it waits.
+//
+// Before waiting to retry a request, RetryTransport closes the body of the
+// previous response and calls the [httptrace.ClientTrace.Retry] hook of
+// the request's context, if any.
+type RetryTransport struct {
+	// Transport is the RoundTripper used for each attempt.
+	// If nil, DefaultTransport is used.
+	Transport RoundTripper
+
+	// MaxRetries is the maximum number of times a request is retried.
+	// If zero, 3 is used. If negative, requests are not retried.
+	MaxRetries int
+
+	// MinDelay is the delay before the first retry, which doubles for
+	// each subsequent retry. If zero, 100 milliseconds is used.
+	MinDelay time.Duration
+
+	// MaxDelay is the maximum delay between attempts.
+	// If zero, 30 seconds is used.
+	MaxDelay time.Duration
+
+	// RetryNonIdempotent specifies that requests are retried
+	// even if they are not idempotent.
+	RetryNonIdempotent bool
+
+	// ShouldRetry, if non-nil, reports whether an attempt that resulted
+	// in resp or err should be retried, in place of the default check of
+	// the error and status code. Exactly one of resp and err is non-nil.
+	// The checks of the request's method and body still apply.
+	ShouldRetry func(resp *Response, err error) bool
+}
+
+// RoundTrip implements the [RoundTripper] interface.
+func (t *RetryTransport) RoundTrip(req *Request) (*Response, error) {
+	rt := t.Transport
+	if rt == nil {
+		rt = DefaultTransport
+	}
+	maxRetries := t.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+	canRetry := (req.Body == nil || req.Body == NoBody || req.GetBody != nil) &&
+		(t.RetryNonIdempotent || req.isIdempotent())
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = new(Request)
+			*r = *req
+			r.Body = body
+		}
+		var reused bool
+		if canRetry {
+			r = r.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+			}))
+		}
+		resp, err := rt.RoundTrip(r)
+		if !canRetry || attempt > maxRetries || !t.shouldRetry(ctx, resp, err, reused) {
+			return resp, err
+		}
+		delay, ok := t.delay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return resp, err
+		}
+
+		info := httptrace.RetryInfo{Attempt: attempt + 1, Delay: delay, Err: err}
+		if resp != nil {
+			info.StatusCode = resp.StatusCode
+			// Read some of the body, so that the connection may be reused.
+			io.CopyN(io.Discard, resp.Body, 4<<10)
+			resp.Body.Close()
+		}
+		if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.Retry != nil {
+			trace.Retry(info)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, context.Cause(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry reports whether an attempt that resulted in resp or err
+// should be retried. Reused reports whether the attempt was sent on
+// a reused connection.
+func (t *RetryTransport) shouldRetry(ctx context.Context, resp *Response, err error, reused bool) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if t.ShouldRetry != nil {
+		return t.ShouldRetry(resp, err)
+	}
+	if err != nil {
+		return isTransientError(err, reused)
+	}
+	switch resp.StatusCode {
+	case StatusTooManyRequests, StatusBadGateway, StatusServiceUnavailable, StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isTransientError reports whether err, from an attempt sent on a reused
+// connection if reused is true, is one of the transient errors
+// retried by default, as described for [RetryTransport].
+func isTransientError(err error, reused bool) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	if isConnRefusedOrReset(err) {
+		return true
+	}
+	return reused && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+// delay returns the delay before retrying the given attempt, which
+// resulted in resp (if not nil). It reports false if the request
+// should not be retried because the server asked for a longer delay
+// than t.MaxDelay.
+func (t *RetryTransport) delay(attempt int, resp *Response) (time.Duration, bool) {
+	minDelay := t.MinDelay
+	if minDelay <= 0 {
+		minDelay = defaultMinDelay
+	}
+	maxDelay := t.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxDelay
+	}
+
+	if resp != nil && (resp.StatusCode == StatusTooManyRequests || resp.StatusCode == StatusServiceUnavailable) {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return d, d <= maxDelay
+		}
+	}
+
+	d := maxDelay
+	if attempt < 32 {
+		d = min(minDelay<<(attempt-1), maxDelay)
+		if d <= 0 {
+			d = maxDelay // overflow
+		}
+	}
+	return d/2 + rand.N(d-d/2+1), true
+}
+
+// retryAfter parses the value of a Retry-After header,
+// which is either a number of seconds or an HTTP date.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseUint(v, 10, 63); err == nil {
+		if secs > uint64(1<<63-1)/uint64(time.Second) {
+			return 1<<63 - 1, true
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,11 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+// isConnRefusedOrReset reports whether err is a refused or reset connection.
+// Plan 9 reports network errors as strings, which are not recognized.
+func isConnRefusedOrReset(err error) bool {
+	return false
+}
@@ -0,0 +1,279 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	. "net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// retryResponses returns a RoundTripper that replies to each request
+// with the next status code from codes, recording the request bodies.
+func retryResponses(bodies *[]string, header Header, codes ...int) RoundTripper {
+	return roundTripFunc(func(req *Request) (*Response, error) {
+		if req.Body != nil {
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			*bodies = append(*bodies, string(b))
+		} else {
+			*bodies = append(*bodies, "")
+		}
+		code := codes[0]
+		if len(codes) > 1 {
+			codes = codes[1:]
+		}
+		return &Response{
+			StatusCode: code,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader("body")),
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestRetryTransport(t *testing.T) {
+	var bodies []string
+	var retries []httptrace.RetryInfo
+	rt := &RetryTransport{
+		Transport: retryResponses(&bodies, nil, 503, 502, 200),
+		MinDelay:  time.Millisecond,
+	}
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		Retry: func(info httptrace.RetryInfo) { retries = append(retries, info) },
+	})
+	req, _ := NewRequestWithContext(ctx, "GET", "http://example.tld/", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || len(bodies) != 3 {
+		t.Fatalf("got status %d after %d attempts; want 200 after 3", resp.StatusCode, len(bodies))
+	}
+	if len(retries) != 2 {
+		t.Fatalf("Retry hook called %d times, want 2", len(retries))
+	}
+	for i, info := range retries {
+		if info.Attempt != i+2 || info.Delay <= 0 || info.Delay > time.Duration(1<<i)*time.Millisecond {
+			t.Errorf("retry %d: %+v", i, info)
+		}
+	}
+	if retries[0].StatusCode != 503 || retries[1].StatusCode != 502 {
+		t.Errorf("retry status codes = %d, %d; want 503, 502", retries[0].StatusCode, retries[1].StatusCode)
+	}
+}
+
+func TestRetryTransportMaxRetries(t *testing.T) {
+	var bodies []string
+	rt := &RetryTransport{
+		Transport:  retryResponses(&bodies, nil, 500),
+		MaxRetries: 2,
+		MinDelay:   time.Millisecond,
+		ShouldRetry: func(resp *Response, err error) bool {
+			return resp != nil && resp.StatusCode >= 500
+		},
+	}
+	req, _ := NewRequest("GET", "http://example.tld/", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 500 || len(bodies) != 3 {
+		t.Errorf("got status %d after %d attempts; want 500 after 3", resp.StatusCode, len(bodies))
+	}
+	if b, err := io.ReadAll(resp.Body); err != nil || string(b) != "body" {
+		t.Errorf("last response body = %q, %v; want %q", b, err, "body")
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryTransportErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		err    error
+		reused bool
+		retry  bool
+	}{
+		{"timeout", &url.Error{Op: "Get", Err: timeoutError{}}, false, true},
+		{"deadline", context.DeadlineExceeded, false, false},
+		{"EOF on new connection", io.EOF, false, false},
+		{"EOF on reused connection", io.EOF, true, true},
+		{"unexpected EOF on reused connection", io.ErrUnexpectedEOF, true, true},
+		{"other", errors.New("other"), true, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			rt := &RetryTransport{
+				Transport: roundTripFunc(func(req *Request) (*Response, error) {
+					attempts++
+					if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GotConn != nil {
+						trace.GotConn(httptrace.GotConnInfo{Reused: tt.reused})
+					}
+					return nil, tt.err
+				}),
+				MaxRetries: 1,
+				MinDelay:   time.Millisecond,
+			}
+			req, _ := NewRequest("GET", "http://example.tld/", nil)
+			if _, err := rt.RoundTrip(req); err != tt.err {
+				t.Fatalf("RoundTrip error = %v, want %v", err, tt.err)
+			}
+			want := 1
+			if tt.retry {
+				want = 2
+			}
+			if attempts != want {
+				t.Errorf("got %d attempts, want %d", attempts, want)
+			}
+		})
+	}
+}
+
+func TestRetryTransportIdempotency(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		method   string
+		header   string
+		body     io.Reader
+		nonIdem  bool
+		attempts int
+	}{
+		{name: "POST", method: "POST", body: strings.NewReader("x"), attempts: 1},
+		{name: "POST with key", method: "POST", header: "Idempotency-Key", body: strings.NewReader("x"), attempts: 2},
+		{name: "POST non-idempotent", method: "POST", body: strings.NewReader("x"), nonIdem: true, attempts: 2},
+		{name: "PUT", method: "PUT", body: strings.NewReader("x"), attempts: 2},
+		{name: "PUT without GetBody", method: "PUT", body: io.MultiReader(strings.NewReader("x")), attempts: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []string
+			rt := &RetryTransport{
+				Transport:          retryResponses(&bodies, nil, 503, 200),
+				MinDelay:           time.Millisecond,
+				RetryNonIdempotent: tt.nonIdem,
+			}
+			req, _ := NewRequest(tt.method, "http://example.tld/", tt.body)
+			if tt.header != "" {
+				req.Header.Set(tt.header, "key")
+			}
+			if _, err := rt.RoundTrip(req); err != nil {
+				t.Fatal(err)
+			}
+			if len(bodies) != tt.attempts {
+				t.Fatalf("made %d attempts, want %d", len(bodies), tt.attempts)
+			}
+			for i, b := range bodies {
+				if b != "x" {
+					t.Errorf("attempt %d: body = %q, want %q", i+1, b, "x")
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	var bodies []string
+	rt := &RetryTransport{
+		Transport: retryResponses(&bodies, Header{"Retry-After": {"0"}}, 429, 200),
+		MinDelay:  time.Hour,
+		MaxDelay:  time.Hour,
+	}
+	req, _ := NewRequest("GET", "http://example.tld/", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("RoundTrip = %v, %v; want status 200", resp, err)
+	}
+
+	// A server asking for a longer delay than MaxDelay isn't retried.
+	bodies = nil
+	rt = &RetryTransport{
+		Transport: retryResponses(&bodies, Header{"Retry-After": {"120"}}, 503, 200),
+		MaxDelay:  time.Minute,
+	}
+	resp, err = rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != 503 || len(bodies) != 1 {
+		t.Fatalf("RoundTrip = %v, %v after %d attempts; want status 503 after 1", resp, err, len(bodies))
+	}
+}
+
+func TestRetryTransportContext(t *testing.T) {
+	var bodies []string
+	rt := &RetryTransport{
+		Transport: retryResponses(&bodies, nil, 503),
+		MinDelay:  time.Hour,
+		MaxDelay:  time.Hour,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	req, _ := NewRequestWithContext(ctx, "GET", "http://example.tld/", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != 503 || len(bodies) != 1 {
+		t.Fatalf("RoundTrip = %v, %v after %d attempts; want status 503 after 1", resp, err, len(bodies))
+	}
+
+	// Canceling the context stops the wait before the next attempt.
+	bodies = nil
+	ctx, cancel = context.WithCancel(context.Background())
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		Retry: func(httptrace.RetryInfo) { cancel() },
+	})
+	req, _ = NewRequestWithContext(ctx, "GET", "http://example.tld/", nil)
+	rt.MaxDelay = 0
+	if _, err := rt.RoundTrip(req); !errors.Is(err, context.Canceled) || len(bodies) != 1 {
+		t.Fatalf("RoundTrip error = %v after %d attempts; want %v after 1", err, len(bodies), context.Canceled)
+	}
+}
+
+func TestRetryTransportServer(t *testing.T) { run(t, testRetryTransportServer) }
+func testRetryTransportServer(t *testing.T, mode testMode) {
+	var attempts int
+	cst := newClientServerTest(t, mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		attempts++
+		b, _ := io.ReadAll(r.Body)
+		if attempts == 1 {
+			w.WriteHeader(StatusServiceUnavailable)
+			return
+		}
+		w.Write(b)
+	}))
+	c := cst.c
+	c.Transport = &RetryTransport{Transport: c.Transport, MinDelay: time.Millisecond}
+	resp, err := c.Post(cst.ts.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// POST isn't idempotent, so the request is not retried.
+	if resp.StatusCode != StatusServiceUnavailable || attempts != 1 {
+		t.Fatalf("got status %d after %d attempts; want 503 after 1", resp.StatusCode, attempts)
+	}
+
+	attempts = 0
+	req, _ := NewRequest("PUT", cst.ts.URL, strings.NewReader("hello"))
+	resp, err = c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if b, err = io.ReadAll(resp.Body); err != nil || string(b) != "hello" || attempts != 2 {
+		t.Fatalf("got body %q, %v after %d attempts; want %q after 2", b, err, attempts, "hello")
+	}
+}
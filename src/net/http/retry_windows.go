@@ -0,0 +1,18 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"errors"
+	"syscall"
+)
+
+// _WSAECONNREFUSED is not defined by package syscall.
+const _WSAECONNREFUSED syscall.Errno = 10061
+
+// isConnRefusedOrReset reports whether err is a refused or reset connection.
+func isConnRefusedOrReset(err error) bool {
+	return errors.Is(err, _WSAECONNREFUSED) || errors.Is(err, syscall.WSAECONNRESET)
+}
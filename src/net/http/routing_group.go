@@ -0,0 +1,192 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Route groups for ServeMux.
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// A RouteGroup registers handlers on a [ServeMux] under a common prefix,
+// wrapping each of them in the group's middleware.
+//
+// A pattern registered with a group is joined to the group's prefix
+// before it is registered with the ServeMux, so all the rules for patterns,
+// precedence and conflicts described in the ServeMux documentation apply
+// to the joined pattern. For example, registering "GET /users/{id}" with
+// a group whose prefix is "/orgs/{org}" registers the pattern
+// "GET /orgs/{org}/users/{id}", and the handler can obtain both wildcards
+// with [Request.PathValue]. The pattern "/" registered with the same group
+// matches the whole subtree under "/orgs/{org}/".
+//
+// Create a RouteGroup with [ServeMux.Group] or [RouteGroup.Group].
+// The methods of a RouteGroup should not be called concurrently with each
+// other; groups are normally set up before the ServeMux begins serving
+// requests.
+type RouteGroup struct {
+	mux        *ServeMux
+	host       string
+	path       string // without trailing slash
+	middleware []func(Handler) Handler
+}
+
+// Group returns a [RouteGroup] that registers handlers on mux under
+// prefix, wrapped in the given middleware.
+//
+// The prefix has the form
+//
+//	[HOST]/[PATH]
+//
+// where PATH may contain wildcards of the form {NAME}, but not "..."
+// wildcards or {$}. A trailing slash in the prefix is ignored, so "/api"
+// and "/api/" are the same prefix. Group panics if the prefix is invalid.
+//
+// Each middleware function is called once for each handler registered
+// with the group, and returns the handler that is registered instead.
+// Middleware is applied in order, so the first middleware is the first to
+// see each request.
+func (mux *ServeMux) Group(prefix string, middleware ...func(Handler) Handler) *RouteGroup {
+	g := &RouteGroup{mux: mux}
+	return g.Group(prefix, middleware...)
+}
+
+// Group returns a [RouteGroup] nested in g: its prefix is prefix appended
+// to the prefix of g, and its handlers are wrapped in the middleware of g
+// and then in the given middleware.
+//
+// The prefix of a nested group may have a host only if the prefix of g
+// does not. See [ServeMux.Group] for the syntax of prefix. Group panics
+// if the prefix is invalid.
+func (g *RouteGroup) Group(prefix string, middleware ...func(Handler) Handler) *RouteGroup {
+	host, path, err := g.joinPrefix(prefix)
+	if err != nil {
+		panic(fmt.Sprintf("http: invalid route group prefix %q: %v", prefix, err))
+	}
+	return &RouteGroup{
+		mux:        g.mux,
+		host:       host,
+		path:       path,
+		middleware: append(slices.Clip(g.middleware), middleware...),
+	}
+}
+
+// Use adds middleware to g. It applies to handlers registered with g,
+// and to groups created from g, after the call.
+func (g *RouteGroup) Use(middleware ...func(Handler) Handler) {
+	g.middleware = append(slices.Clip(g.middleware), middleware...)
+}
+
+// Prefix returns the prefix of g, including the prefixes
+// of the groups it is nested in.
+func (g *RouteGroup) Prefix() string {
+	return g.host + g.path + "/"
+}
+
+// The methods below call ServeMux.register directly so that callerLocation
+// always refers to user code.
+
+// Handle registers the handler for the given pattern, joined to the prefix
+// of g, on the group's [ServeMux]. The handler is wrapped in the middleware
+// of g. If the joined pattern conflicts with one that is already registered,
+// Handle panics.
+func (g *RouteGroup) Handle(pattern string, handler Handler) {
+	if f, ok := handler.(HandlerFunc); handler == nil || ok && f == nil {
+		panic("http: nil handler")
+	}
+	pattern, handler = g.route(pattern, handler)
+	if use121 {
+		g.mux.mux121.handle(pattern, handler)
+	} else {
+		g.mux.register(pattern, handler)
+	}
+}
+
+// HandleFunc registers the handler function for the given pattern, joined
+// to the prefix of g, on the group's [ServeMux]. The handler is wrapped in
+// the middleware of g. If the joined pattern conflicts with one that is
+// already registered, HandleFunc panics.
+func (g *RouteGroup) HandleFunc(pattern string, handler func(ResponseWriter, *Request)) {
+	if handler == nil {
+		panic("http: nil handler")
+	}
+	pattern, h := g.route(pattern, HandlerFunc(handler))
+	if use121 {
+		g.mux.mux121.handle(pattern, h)
+	} else {
+		g.mux.register(pattern, h)
+	}
+}
+
+// Name gives a name to a pattern registered with g, as [ServeMux.Name]
+// does for the pattern joined to the prefix of g.
+func (g *RouteGroup) Name(name, pattern string) {
+	pattern, _ = g.route(pattern, nil)
+	g.mux.Name(name, pattern)
+}
+
+// route returns the pattern and handler to register with the ServeMux
+// for a pattern and handler registered with g. If handler is nil, so is
+// the returned handler. route panics if the pattern can't be joined to
+// the prefix of g.
+func (g *RouteGroup) route(pattern string, handler Handler) (string, Handler) {
+	if pattern == "" {
+		panic("http: invalid pattern")
+	}
+	method, rest, found := "", pattern, false
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		method, rest, found = pattern[:i], strings.TrimLeft(pattern[i+1:], " \t"), true
+	}
+	host, path, err := g.join(rest)
+	if err != nil {
+		panic(fmt.Sprintf("http: invalid pattern %q in route group %q: %v", pattern, g.Prefix(), err))
+	}
+	joined := host + path
+	if found {
+		joined = method + " " + joined
+	}
+	if handler != nil {
+		for _, mw := range slices.Backward(g.middleware) {
+			handler = mw(handler)
+		}
+	}
+	return joined, handler
+}
+
+// joinPrefix joins the prefix of a nested group to the prefix of g.
+func (g *RouteGroup) joinPrefix(prefix string) (host, path string, err error) {
+	if i := strings.IndexAny(prefix, " \t"); i >= 0 && i < strings.IndexByte(prefix, '/') {
+		return "", "", errors.New("prefix contains a method")
+	}
+	host, path, err = g.join(prefix)
+	if err != nil {
+		return "", "", err
+	}
+	path = strings.TrimRight(path, "/")
+	// Check the syntax of the prefix by parsing it as a subtree pattern.
+	if _, err := parsePattern(host + path + "/"); err != nil {
+		return "", "", err
+	}
+	return host, path, nil
+}
+
+// join joins the host and path of a pattern to the prefix of g.
+func (g *RouteGroup) join(s string) (host, path string, err error) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return "", "", errors.New("host/path missing /")
+	}
+	host, path = s[:i], s[i:]
+	if host != "" && g.host != "" {
+		return "", "", errors.New("route group already has a host")
+	}
+	if host == "" {
+		host = g.host
+	}
+	return host, g.path + path, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"fmt"
+	. "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// tagMiddleware returns middleware that appends tag to the X-Tags response header.
+func tagMiddleware(tag string) func(Handler) Handler {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			w.Header().Add("X-Tags", tag)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRouteGroup(t *testing.T) {
+	mux := NewServeMux()
+	show := func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "%s org=%s id=%s", r.Pattern, r.PathValue("org"), r.PathValue("id"))
+	}
+	mux.HandleFunc("/", show)
+	orgs := mux.Group("/orgs/{org}/", tagMiddleware("a"), tagMiddleware("b"))
+	orgs.HandleFunc("GET /{$}", show)
+	orgs.HandleFunc("/", show)
+	users := orgs.Group("/users")
+	users.Use(tagMiddleware("c"))
+	users.HandleFunc("GET /{id}", show)
+	host := mux.Group("example.com/v1")
+	host.HandleFunc("/x", show)
+
+	if got, want := users.Prefix(), "/orgs/{org}/users/"; got != want {
+		t.Errorf("Prefix() = %q, want %q", got, want)
+	}
+
+	for _, test := range []struct {
+		method, host, path string
+		want               string
+		wantTags           string
+	}{
+		{"GET", "", "/orgs/go/", "GET /orgs/{org}/{$} org=go id=", "a,b"},
+		{"POST", "", "/orgs/go/", "/orgs/{org}/ org=go id=", "a,b"},
+		{"GET", "", "/orgs/go/repos", "/orgs/{org}/ org=go id=", "a,b"},
+		{"GET", "", "/orgs/go/users/gopher", "GET /orgs/{org}/users/{id} org=go id=gopher", "a,b,c"},
+		{"GET", "", "/orgs", "/ org= id=", ""},
+		{"GET", "example.com", "/v1/x", "example.com/v1/x org= id=", ""},
+		{"GET", "other.com", "/v1/x", "/ org= id=", ""},
+	} {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.host != "" {
+			req.Host = test.host
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if got := w.Body.String(); got != test.want {
+			t.Errorf("%s %s%s: got %q, want %q", test.method, test.host, test.path, got, test.want)
+		}
+		if got := strings.Join(w.Result().Header["X-Tags"], ","); got != test.wantTags {
+			t.Errorf("%s %s%s: middleware %q, want %q", test.method, test.host, test.path, got, test.wantTags)
+		}
+	}
+}
+
+func TestRouteGroupInvalid(t *testing.T) {
+	h := HandlerFunc(func(ResponseWriter, *Request) {})
+	for _, test := range []struct {
+		name string
+		f    func(*ServeMux)
+		want string
+	}{
+		{"method in prefix", func(m *ServeMux) { m.Group("GET /a") }, "prefix contains a method"},
+		{"multi wildcard in prefix", func(m *ServeMux) { m.Group("/a/{x...}") }, "wildcard not at end"},
+		{"dollar in prefix", func(m *ServeMux) { m.Group("/a/{$}") }, "{$} not at end"},
+		{"no slash in prefix", func(m *ServeMux) { m.Group("a") }, "missing /"},
+		{"two hosts", func(m *ServeMux) { m.Group("a.com/").Group("b.com/") }, "already has a host"},
+		{"duplicate wildcard", func(m *ServeMux) { m.Group("/{x}").Handle("/{x}", h) }, "duplicate wildcard"},
+		{"nil handler", func(m *ServeMux) { m.Group("/a").Handle("/", nil) }, "nil handler"},
+		{"conflict", func(m *ServeMux) {
+			m.Handle("/a/b", h)
+			m.Group("/a").Handle("/b", h)
+		}, "conflicts with pattern"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(fmt.Sprint(r), test.want) {
+					t.Errorf("got panic %v, want one containing %q", r, test.want)
+				}
+			}()
+			test.f(NewServeMux())
+		})
+	}
+}
@@ -7313,3 +7313,56 @@ func testServerReadAfterHandlerAbort100Continue(t *testing.T, mode testMode) {
 	readyc <- struct{}{} // server starts reading from the request body
 	readyc <- struct{}{} // server finishes reading from the request body
 }
+
+func TestServeMuxBuildPath(t *testing.T) {
+	mux := NewServeMux()
+	h := HandlerFunc(func(w ResponseWriter, r *Request) {})
+	mux.Handle("GET /users/{id}/posts/{slug...}", h)
+	mux.Name("post", "GET /users/{id}/posts/{slug...}")
+	api := mux.Group("/api/{version}")
+	api.Handle("/items/{item}", h)
+	api.Name("item", "/items/{item}")
+
+	for _, test := range []struct {
+		name   string
+		values map[string]string
+		want   string
+	}{
+		{"post", map[string]string{"id": "a b", "slug": "2024/hello"}, "/users/a%20b/posts/2024/hello"},
+		{"item", map[string]string{"version": "v1", "item": "x/y"}, "/api/v1/items/x%2Fy"},
+	} {
+		got, err := mux.BuildPath(test.name, test.values)
+		if err != nil || got != test.want {
+			t.Errorf("BuildPath(%q) = %q, %v; want %q", test.name, got, err, test.want)
+			continue
+		}
+		req := httptest.NewRequest("GET", got, nil)
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+		for k, v := range test.values {
+			if g := req.PathValue(k); g != v {
+				t.Errorf("%s: PathValue(%q) = %q, want %q", got, k, g, v)
+			}
+		}
+	}
+
+	if _, err := mux.BuildPath("missing", nil); err == nil {
+		t.Error("BuildPath for unknown name succeeded")
+	}
+	if _, err := mux.BuildPath("post", map[string]string{"id": "1"}); err == nil {
+		t.Error("BuildPath with missing value succeeded")
+	}
+	for _, f := range []func(){
+		func() { mux.Name("post", "GET /users/{id}/posts/{slug...}") },
+		func() { mux.Name("other", "/unregistered") },
+		func() { mux.Name("other", "GET  /users/{id}/posts/{slug...}") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Name did not panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
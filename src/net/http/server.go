@@ -2503,10 +2503,12 @@ func RedirectHandler(url string, code int) Handler {
 //     This change mostly affects how paths with %2F escapes adjacent to slashes are treated.
 //     See https://go.dev/issue/21955 for details.
 type ServeMux struct {
-	mu     sync.RWMutex
-	tree   routingNode
-	index  routingIndex
-	mux121 serveMux121 // used only when GODEBUG=httpmuxgo121=1
+	mu       sync.RWMutex
+	tree     routingNode
+	index    routingIndex
+	patterns map[string]*pattern // registered patterns by string, for Name
+	names    map[string]*pattern // route names, for BuildPath
+	mux121   serveMux121         // used only when GODEBUG=httpmuxgo121=1
 }
 
 // NewServeMux allocates and returns a new [ServeMux].
@@ -2791,6 +2793,75 @@ func HandleFunc(pattern string, handler func(ResponseWriter, *Request)) {
 	}
 }
 
+// Name gives a name to a pattern registered with mux, so that paths matching
+// the pattern can be built with [ServeMux.BuildPath]. The pattern must be
+// the same string that was passed to [ServeMux.Handle] or
+// [ServeMux.HandleFunc]. Name panics if the pattern has not been registered
+// or if the name is already in use.
+func (mux *ServeMux) Name(name, pattern string) {
+	if err := mux.nameErr(name, pattern); err != nil {
+		panic(err)
+	}
+}
+
+func (mux *ServeMux) nameErr(name, patstr string) error {
+	if name == "" {
+		return errors.New("http: empty route name")
+	}
+	var pat *pattern
+	if use121 {
+		mux.mux121.mu.RLock()
+		_, ok := mux.mux121.m[patstr]
+		mux.mux121.mu.RUnlock()
+		if ok {
+			var err error
+			if pat, err = parsePattern(patstr); err != nil {
+				return fmt.Errorf("parsing %q: %w", patstr, err)
+			}
+		}
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if !use121 {
+		pat = mux.patterns[patstr]
+	}
+	if pat == nil {
+		return fmt.Errorf("http: naming route %q: pattern %q is not registered", name, patstr)
+	}
+	if p2, ok := mux.names[name]; ok {
+		return fmt.Errorf("http: route name %q is already used for pattern %q", name, p2)
+	}
+	if mux.names == nil {
+		mux.names = map[string]*pattern{}
+	}
+	mux.names[name] = pat
+	return nil
+}
+
+// BuildPath returns the path of a URL that matches the pattern with the
+// given name, as set by [ServeMux.Name]. The values map wildcard names
+// to their values, which are escaped as needed. A value for a "..."
+// wildcard may contain slashes, which separate path segments.
+// The method and host of the pattern are ignored.
+//
+// BuildPath returns an error if there is no pattern with the given name,
+// if values does not have exactly one value for each named wildcard of the
+// pattern, or if a value would not be matched by its wildcard.
+func (mux *ServeMux) BuildPath(name string, values map[string]string) (string, error) {
+	mux.mu.RLock()
+	pat := mux.names[name]
+	mux.mu.RUnlock()
+	if pat == nil {
+		return "", fmt.Errorf("http: no route named %q", name)
+	}
+	path, err := pat.buildPath(values)
+	if err != nil {
+		return "", fmt.Errorf("http: building path for route %q: %w", name, err)
+	}
+	return path, nil
+}
+
 func (mux *ServeMux) register(pattern string, handler Handler) {
 	if err := mux.registerErr(pattern, handler); err != nil {
 		panic(err)
@@ -2837,6 +2908,10 @@ func (mux *ServeMux) registerErr(patstr string, handler Handler) error {
 	}
 	mux.tree.addPattern(pat, handler)
 	mux.index.addPattern(pat)
+	if mux.patterns == nil {
+		mux.patterns = map[string]*pattern{}
+	}
+	mux.patterns[patstr] = pat
 	return nil
 }
 
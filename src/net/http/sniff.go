@@ -194,6 +194,22 @@ var sniffSignatures = []sniffSig{
 	textSig{}, // should be last
 }
 
+// isCompressedType reports whether data of the MIME type ct, as returned by
+// [DetectContentType], is already compressed, or is of an unknown binary
+// type. Compressing such data again is unlikely to make it smaller.
+func isCompressedType(ct string) bool {
+	switch ct {
+	case "application/octet-stream",
+		"application/pdf",
+		"image/gif", "image/webp", "image/png", "image/jpeg",
+		"audio/mpeg", "application/ogg", "video/avi", "video/mp4", "video/webm",
+		"font/woff", "font/woff2",
+		"application/x-gzip", "application/zip", "application/x-rar-compressed":
+		return true
+	}
+	return false
+}
+
 type exactSig struct {
 	sig []byte
 	ct  string
@@ -0,0 +1,341 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLineLength is the length of the longest line a Reader accepts.
+const maxLineLength = 1 << 20
+
+// A Reader reads events from an event stream.
+type Reader struct {
+	s           *bufio.Scanner
+	started     bool // whether the first line has been read
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewReader returns a [Reader] that reads events from r, such as the
+// body of a response with Content-Type text/event-stream.
+func NewReader(r io.Reader) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxLineLength)
+	s.Split(scanLines)
+	return &Reader{s: s}
+}
+
+// Next returns the next event in the stream. At the end of the stream,
+// Next returns [io.EOF]; an event that is not terminated by a blank line
+// before the end of the stream is discarded. Lines longer than 1 MiB
+// result in an error.
+func (r *Reader) Next() (Event, error) {
+	var (
+		typ     string
+		data    strings.Builder
+		hasData bool
+	)
+	for r.s.Scan() {
+		line := r.s.Bytes()
+		if !r.started {
+			// Skip the byte order mark.
+			line = bytes.TrimPrefix(line, []byte("\ufeff"))
+			r.started = true
+		}
+		if len(line) == 0 {
+			if !hasData {
+				// Nothing to dispatch.
+				typ = ""
+				continue
+			}
+			return Event{Type: typ, ID: r.lastEventID, Data: data.String()}, nil
+		}
+		if line[0] == ':' {
+			// Comment.
+			continue
+		}
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			typ = string(value)
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.Write(value)
+			hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				r.lastEventID = string(value)
+			}
+		case "retry":
+			if ms, ok := parseRetry(value); ok {
+				r.retry = ms
+			}
+		}
+	}
+	if err := r.s.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// LastEventID returns the last event ID set by the stream.
+func (r *Reader) LastEventID() string {
+	return r.lastEventID
+}
+
+// Retry returns the reconnection time set by the stream,
+// or 0 if the stream has not set it.
+func (r *Reader) Retry() time.Duration {
+	return r.retry
+}
+
+// parseRetry parses the value of a retry field, a number of milliseconds
+// consisting only of ASCII digits.
+func parseRetry(value []byte) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	ms, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil || ms > math.MaxInt64/int64(time.Millisecond) {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// scanLines is a split function for a [bufio.Scanner] that returns
+// each line of text, which may be terminated by "\r\n", "\n" or "\r".
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// A '\r' may be followed by a '\n'.
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+	// Discard an unterminated final line.
+	if atEOF && len(data) > 0 {
+		return len(data), nil, nil
+	}
+	return 0, nil, nil
+}
+
+// defaultRetry is the default reconnection time of a Stream.
+const defaultRetry = 3 * time.Second
+
+// ErrStreamClosed is returned by [Stream.Next] after [Stream.Close] is called.
+var ErrStreamClosed = errors.New("sse: stream closed")
+
+// A Stream reads the events of an event stream from an HTTP server,
+// reconnecting to the server when the connection is lost, as an
+// EventSource in a web browser does.
+//
+// When it reconnects, a Stream waits for the reconnection time set by the
+// server, or 3 seconds by default, and sends the last event ID it received
+// in the Last-Event-ID header of the new request. A Stream stops reading
+// events when the server responds with status 204 (No Content), or with any
+// other response that is not a successful event stream, or when the
+// context of its request is done.
+type Stream struct {
+	client *http.Client
+	req    *http.Request
+
+	mu     sync.Mutex
+	body   io.ReadCloser
+	closed bool
+	done   chan struct{} // closed by Close
+
+	r           *Reader // reader of the current response, or nil
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewStream returns a [Stream] that reads events from the responses to req,
+// sent by client. If client is nil, [http.DefaultClient] is used.
+// The request must not have a body.
+func NewStream(client *http.Client, req *http.Request) *Stream {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Stream{client: client, req: req, retry: defaultRetry, done: make(chan struct{})}
+}
+
+// Next returns the next event in the stream, connecting or reconnecting
+// to the server as needed. It returns [io.EOF] if the server responds with
+// status 204 (No Content), the error of the request's context when the
+// context is done, and [ErrStreamClosed] after Close is called. If the server
+// responds with another status or a Content-Type other than
+// text/event-stream, Next returns an error and the stream should not be
+// used again.
+func (s *Stream) Next() (Event, error) {
+	ctx := s.req.Context()
+	for {
+		if s.r == nil {
+			if err := s.connect(); err != nil {
+				if errors.Is(err, io.EOF) || s.isClosed() || ctx.Err() != nil || errors.As(err, new(*responseError)) {
+					return Event{}, s.wrapErr(err)
+				}
+				// Network errors are retried.
+				if err := s.wait(ctx); err != nil {
+					return Event{}, err
+				}
+				continue
+			}
+		}
+		e, err := s.r.Next()
+		s.lastEventID = s.r.LastEventID()
+		if d := s.r.Retry(); d > 0 {
+			s.retry = d
+		}
+		if err == nil {
+			return e, nil
+		}
+		s.disconnect()
+		if s.isClosed() || ctx.Err() != nil {
+			return Event{}, s.wrapErr(err)
+		}
+		if err := s.wait(ctx); err != nil {
+			return Event{}, err
+		}
+	}
+}
+
+// LastEventID returns the last event ID received by the stream.
+func (s *Stream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Close closes the stream's connection, if any. Any blocked Next call
+// returns [ErrStreamClosed].
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	if s.body != nil {
+		return s.body.Close()
+	}
+	return nil
+}
+
+// A responseError reports a response that is not a successful event stream.
+type responseError struct {
+	status      string
+	contentType string
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("sse: server responded with status %q and Content-Type %q", e.status, e.contentType)
+}
+
+func (s *Stream) connect() error {
+	if s.isClosed() {
+		return ErrStreamClosed
+	}
+	req := s.req.Clone(s.req.Context())
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if s.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", s.lastEventID)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		resp.Body.Close()
+		return io.EOF
+	}
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusOK || mt != "text/event-stream" {
+		resp.Body.Close()
+		return &responseError{status: resp.Status, contentType: resp.Header.Get("Content-Type")}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		resp.Body.Close()
+		return ErrStreamClosed
+	}
+	s.body = resp.Body
+	s.r = NewReader(resp.Body)
+	// The last event ID persists across connections.
+	s.r.lastEventID = s.lastEventID
+	return nil
+}
+
+func (s *Stream) disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.body != nil {
+		s.body.Close()
+		s.body = nil
+	}
+	s.r = nil
+}
+
+func (s *Stream) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// wrapErr returns the error to report for err once the stream has stopped.
+func (s *Stream) wrapErr(err error) error {
+	if s.isClosed() {
+		return ErrStreamClosed
+	}
+	if ctxErr := s.req.Context().Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// wait waits for the reconnection time, or until ctx is done
+// or the stream is closed.
+func (s *Stream) wait(ctx context.Context) error {
+	t := time.NewTimer(s.retry)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return ErrStreamClosed
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sse_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/sse"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriterSend(t *testing.T) {
+	for _, test := range []struct {
+		event sse.Event
+		want  string
+	}{
+		{sse.Event{Data: "hello"}, "data: hello\n\n"},
+		{sse.Event{Data: ""}, "\n"},
+		{sse.Event{Type: "ping"}, "event: ping\ndata: \n\n"},
+		{sse.Event{Type: "update", ID: "42", Data: "a\nb\r\nc\rd"}, "event: update\nid: 42\ndata: a\ndata: b\ndata: c\ndata: d\n\n"},
+		{sse.Event{Data: "x\n"}, "data: x\ndata: \n\n"},
+		{sse.Event{ID: "7", Retry: 1500 * time.Millisecond}, "id: 7\nretry: 1500\n\n"},
+	} {
+		rec := httptest.NewRecorder()
+		w, err := sse.NewWriter(rec, httptest.NewRequest("GET", "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Send(test.event); err != nil {
+			t.Errorf("Send(%+v): %v", test.event, err)
+			continue
+		}
+		if got := rec.Body.String(); got != test.want {
+			t.Errorf("Send(%+v) wrote %q, want %q", test.event, got, test.want)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+			t.Errorf("Content-Type = %q, want text/event-stream", got)
+		}
+		if !rec.Flushed {
+			t.Errorf("Send(%+v) did not flush", test.event)
+		}
+	}
+}
+
+func TestWriterSendInvalid(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w, err := sse.NewWriter(rec, httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []sse.Event{
+		{Type: "a\nb"},
+		{ID: "1\r"},
+		{ID: "1\x00"},
+	} {
+		if err := w.Send(e); err == nil {
+			t.Errorf("Send(%+v) succeeded, want error", e)
+		}
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("wrote %q after invalid events", rec.Body.String())
+	}
+}
+
+func TestWriterComment(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w, err := sse.NewWriter(rec, httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Comment("")
+	w.Comment("two\nlines")
+	if got, want := rec.Body.String(), ":\n: two\n: lines\n"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+}
+
+func TestReader(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		input  string
+		want   []sse.Event
+		lastID string
+		retry  time.Duration
+	}{{
+		name:  "simple",
+		input: "data: hello\n\ndata:world\n\n",
+		want:  []sse.Event{{Data: "hello"}, {Data: "world"}},
+	}, {
+		name:  "multiline",
+		input: "data: a\ndata\ndata:  b\n\n",
+		want:  []sse.Event{{Data: "a\n\n b"}},
+	}, {
+		name:  "line endings",
+		input: "event: x\rdata: 1\r\ndata: 2\n\r\ndata: 3\r\r",
+		want:  []sse.Event{{Type: "x", Data: "1\n2"}, {Data: "3"}},
+	}, {
+		name:  "byte order mark",
+		input: "\ufeffdata: a\n\n",
+		want:  []sse.Event{{Data: "a"}},
+	}, {
+		name:   "ids",
+		input:  "id: 1\ndata: a\n\ndata: b\n\nid\ndata: c\n\nid: 2\x00\ndata: d\n\nid: 3\n\n",
+		want:   []sse.Event{{ID: "1", Data: "a"}, {ID: "1", Data: "b"}, {Data: "c"}, {Data: "d"}},
+		lastID: "3",
+	}, {
+		name:  "comments and unknown fields",
+		input: ": hi\nfoo: bar\ndata: a\n:\n\n",
+		want:  []sse.Event{{Data: "a"}},
+	}, {
+		name:  "no data",
+		input: "event: x\n\ndata: a\n\n",
+		want:  []sse.Event{{Data: "a"}},
+	}, {
+		name:  "retry",
+		input: "retry: 2500\n\nretry: 1x\n\nretry: -1\n\n",
+		retry: 2500 * time.Millisecond,
+	}, {
+		name:  "unterminated",
+		input: "data: a\n\ndata: b\n",
+		want:  []sse.Event{{Data: "a"}},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			r := sse.NewReader(strings.NewReader(test.input))
+			var got []sse.Event
+			for {
+				e, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, e)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got events %+v, want %+v", got, test.want)
+			}
+			if r.LastEventID() != test.lastID {
+				t.Errorf("LastEventID() = %q, want %q", r.LastEventID(), test.lastID)
+			}
+			if r.Retry() != test.retry {
+				t.Errorf("Retry() = %v, want %v", r.Retry(), test.retry)
+			}
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	events := make(chan sse.Event)
+	served := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w, err := sse.NewWriter(rw, r)
+		if err != nil {
+			served <- err
+			return
+		}
+		served <- w.Serve(events, 10*time.Millisecond)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL, nil)
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	go func() { events <- sse.Event{Data: "one\ntwo"} }()
+	br := bufio.NewReader(res.Body)
+	var lines []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == ":\n" {
+			// With no events, the writer sends heartbeats.
+			break
+		}
+		lines = append(lines, line)
+	}
+	if want := []string{"data: one\n", "data: two\n", "\n"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("read lines %q before heartbeat, want %q", lines, want)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Serve returned %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Serve did not return after the request was canceled")
+	}
+}
+
+func TestStreamReconnect(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		lastIDs []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		n := len(lastIDs)
+		mu.Unlock()
+		if r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("Accept = %q, want text/event-stream", r.Header.Get("Accept"))
+		}
+		switch n {
+		case 1:
+			w, _ := sse.NewWriter(rw, r)
+			w.Send(sse.Event{ID: "1", Data: "a", Retry: time.Millisecond})
+			w.Send(sse.Event{ID: "2", Data: "b"})
+			// Drop the connection.
+		case 2:
+			w, _ := sse.NewWriter(rw, r)
+			w.Send(sse.Event{Data: "c"})
+		default:
+			rw.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	s := sse.NewStream(ts.Client(), req)
+	defer s.Close()
+	var got []sse.Event
+	for {
+		e, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+	}
+	want := []sse.Event{{ID: "1", Data: "a"}, {ID: "2", Data: "b"}, {ID: "2", Data: "c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %+v, want %+v", got, want)
+	}
+	if want := []string{"", "2", "2"}; !reflect.DeepEqual(lastIDs, want) {
+		t.Errorf("Last-Event-ID of requests = %q, want %q", lastIDs, want)
+	}
+}
+
+func TestStreamBadResponse(t *testing.T) {
+	for _, h := range []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nope", http.StatusInternalServerError)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, "data: a\n\n")
+		},
+	} {
+		ts := httptest.NewServer(h)
+		req, _ := http.NewRequest("GET", ts.URL, nil)
+		s := sse.NewStream(ts.Client(), req)
+		if e, err := s.Next(); err == nil || err == io.EOF {
+			t.Errorf("Next() = %+v, %v; want error", e, err)
+		}
+		ts.Close()
+	}
+}
+
+func TestStreamClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w, _ := sse.NewWriter(rw, r)
+		w.Send(sse.Event{Data: "a"})
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	s := sse.NewStream(ts.Client(), req)
+	if _, err := s.Next(); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		_, err := s.Next()
+		errc <- err
+	}()
+	s.Close()
+	if err := <-errc; err != sse.ErrStreamClosed {
+		t.Errorf("Next() after Close returned %v, want ErrStreamClosed", err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// eofReader closes done when it is read, and returns io.EOF.
+type eofReader struct{ done chan struct{} }
+
+func (r eofReader) Read([]byte) (int, error) {
+	close(r.done)
+	return 0, io.EOF
+}
+
+func TestStreamCloseDuringBackoff(t *testing.T) {
+	eof := eofReader{make(chan struct{})}
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/event-stream"}},
+			Body:       io.NopCloser(io.MultiReader(strings.NewReader("retry: 3600000\n\n"), eof)),
+		}, nil
+	})}
+	req, _ := http.NewRequest("GET", "http://example.tld/", nil)
+	s := sse.NewStream(client, req)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := s.Next()
+		errc <- err
+	}()
+	// Once the connection is lost, Next waits an hour to reconnect.
+	<-eof.done
+	s.Close()
+	select {
+	case err := <-errc:
+		if err != sse.ErrStreamClosed {
+			t.Errorf("Next() after Close returned %v, want ErrStreamClosed", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Next did not return after Close during the reconnection delay")
+	}
+}
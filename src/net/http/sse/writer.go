@@ -0,0 +1,181 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sse implements server-sent events, as specified by the
+// HTML Living Standard's section on server-sent events:
+// https://html.spec.whatwg.org/multipage/server-sent-events.html.
+//
+// A handler sends events to a client with a [Writer]. A client reads
+// events from a response body with a [Reader], or from an event stream
+// that reconnects when the connection is lost with a [Stream].
+package sse
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An Event is a server-sent event.
+type Event struct {
+	// Type is the type of the event. An empty Type stands for
+	// the default type, "message".
+	Type string
+
+	// ID is the ID of the event. When reading, it is the last event ID
+	// set by the stream, which may have been set by an earlier event.
+	// When writing, an empty ID is not sent.
+	ID string
+
+	// Data is the data of the event. It may contain newlines.
+	Data string
+
+	// Retry, if positive, is sent with the event to set the client's
+	// reconnection time. It is ignored when reading; see [Reader.Retry].
+	Retry time.Duration
+}
+
+var errInvalidField = errors.New("sse: event type or ID contains a newline or NUL")
+
+// A Writer writes events to an HTTP response.
+// A Writer is not safe for concurrent use.
+type Writer struct {
+	w   http.ResponseWriter
+	rc  *http.ResponseController
+	ctx context.Context
+	buf []byte
+}
+
+// NewWriter sets the header of w for an event stream, sends it, and
+// returns a [Writer] that writes events to w. The events are sent until
+// the context of r is done.
+//
+// NewWriter returns an error if w does not support flushing.
+func NewWriter(w http.ResponseWriter, r *http.Request) (*Writer, error) {
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	sw := &Writer{w: w, rc: http.NewResponseController(w), ctx: r.Context()}
+	if err := sw.rc.Flush(); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// Send writes e to the stream and flushes it to the client.
+// It returns the error of the request's context if the context is done,
+// and an error if the event's type or ID contain a newline.
+func (w *Writer) Send(e Event) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(e.Type, "\r\n") || strings.ContainsAny(e.ID, "\r\n\x00") {
+		return errInvalidField
+	}
+	b := w.buf[:0]
+	if e.Type != "" {
+		b = append(b, "event: "...)
+		b = append(b, e.Type...)
+		b = append(b, '\n')
+	}
+	if e.ID != "" {
+		b = append(b, "id: "...)
+		b = append(b, e.ID...)
+		b = append(b, '\n')
+	}
+	if e.Retry > 0 {
+		b = append(b, "retry: "...)
+		b = strconv.AppendInt(b, e.Retry.Milliseconds(), 10)
+		b = append(b, '\n')
+	}
+	// An event with neither data nor a type only updates
+	// the client's state, and is not dispatched.
+	if e.Data != "" || e.Type != "" {
+		b = appendLines(b, "data: ", e.Data)
+	}
+	b = append(b, '\n')
+	w.buf = b
+	return w.write(b)
+}
+
+// Comment writes a comment to the stream and flushes it to the client.
+// Clients ignore comments, but they keep the connection active.
+func (w *Writer) Comment(text string) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	if text == "" {
+		return w.write([]byte(":\n"))
+	}
+	w.buf = appendLines(w.buf[:0], ": ", text)
+	return w.write(w.buf)
+}
+
+// Serve sends the events received from events until events is closed,
+// in which case it returns nil, or the request's context is done, in
+// which case it returns the context's error. If heartbeat is positive,
+// Serve sends an empty comment whenever no event has been sent for
+// that long, so that intermediaries do not close an idle connection.
+func (w *Writer) Serve(events <-chan Event, heartbeat time.Duration) error {
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	if heartbeat > 0 {
+		ticker = time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-w.ctx.Done():
+			return w.ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := w.Send(e); err != nil {
+				return err
+			}
+			if ticker != nil {
+				ticker.Reset(heartbeat)
+			}
+		case <-tick:
+			if err := w.Comment(""); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *Writer) write(b []byte) error {
+	if _, err := w.w.Write(b); err != nil {
+		return err
+	}
+	return w.rc.Flush()
+}
+
+// appendLines appends each line of s to b, preceded by prefix.
+// Lines may be terminated by "\r\n", "\n" or "\r".
+func appendLines(b []byte, prefix, s string) []byte {
+	for {
+		i := strings.IndexAny(s, "\r\n")
+		if i < 0 {
+			break
+		}
+		b = append(b, prefix...)
+		b = append(b, s[:i]...)
+		b = append(b, '\n')
+		if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+			i++
+		}
+		s = s[i+1:]
+	}
+	b = append(b, prefix...)
+	b = append(b, s...)
+	return append(b, '\n')
+}
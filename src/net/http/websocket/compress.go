@@ -0,0 +1,170 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The permessage-deflate extension, defined in RFC 7692.
+//
+// Both endpoints compress each message independently, without
+// context takeover, so that no sliding window is kept between messages.
+
+package websocket
+
+import (
+	"compress/flate"
+	"io"
+	"net/http"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+const (
+	deflateExtension = "permessage-deflate"
+
+	// deflateNegotiation is both the extension negotiation offer of
+	// a client and the response of a server accepting an offer.
+	deflateNegotiation = "permessage-deflate; server_no_context_takeover; client_no_context_takeover"
+
+	// deflateTail is removed from the end of a compressed message
+	// by the sender, and appended by the receiver (RFC 7692, Section 7.2).
+	deflateTail = "\x00\x00\xff\xff"
+
+	// deflateFinal is an empty final stored block, which the receiver
+	// appends after deflateTail so that decompression ends cleanly.
+	deflateFinal = "\x01\x00\x00\xff\xff"
+
+	// compressMinSize is the size of the smallest message that
+	// Conn.Write compresses.
+	compressMinSize = 128
+)
+
+// An extension is an element of a Sec-WebSocket-Extensions header field.
+type extension struct {
+	name   string
+	params map[string]string // parameter names are lower case
+}
+
+// parseExtensions parses the Sec-WebSocket-Extensions fields of h.
+// Elements that cannot be parsed are returned with an empty name.
+func parseExtensions(h http.Header) []extension {
+	var exts []extension
+	for _, v := range h.Values("Sec-WebSocket-Extensions") {
+		for e := range strings.SplitSeq(v, ",") {
+			name, params, _ := strings.Cut(e, ";")
+			name, _ = ascii.ToLower(textproto.TrimString(name))
+			if name == "" {
+				continue
+			}
+			ext := extension{name: name, params: make(map[string]string)}
+			for p := range strings.SplitSeq(params, ";") {
+				k, v, _ := strings.Cut(p, "=")
+				k, _ = ascii.ToLower(textproto.TrimString(k))
+				if k == "" {
+					continue
+				}
+				v = strings.Trim(textproto.TrimString(v), `"`)
+				if _, dup := ext.params[k]; dup {
+					ext.name = ""
+				}
+				ext.params[k] = v
+			}
+			exts = append(exts, ext)
+		}
+	}
+	return exts
+}
+
+// acceptDeflate reports whether the server can accept one of the offers
+// of permessage-deflate in the Sec-WebSocket-Extensions fields of a
+// handshake request with header h.
+func acceptDeflate(h http.Header) bool {
+	for _, ext := range parseExtensions(h) {
+		if ext.name != deflateExtension {
+			continue
+		}
+		ok := true
+		for k, v := range ext.params {
+			switch k {
+			case "server_no_context_takeover", "client_no_context_takeover":
+				ok = ok && v == ""
+			case "client_max_window_bits":
+				// The client limits its own window; the server can
+				// decompress any window size.
+			case "server_max_window_bits":
+				// compress/flate always uses a 32 KiB window.
+				ok = ok && v == "15"
+			default:
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDeflateResponse reports whether the Sec-WebSocket-Extensions fields
+// of the handshake response with header h are valid for a client that sent
+// deflateNegotiation if offered is true, and whether they accept permessage-deflate.
+func checkDeflateResponse(h http.Header, offered bool) (deflate, ok bool) {
+	exts := parseExtensions(h)
+	if len(exts) == 0 {
+		return false, true
+	}
+	if !offered || len(exts) > 1 || exts[0].name != deflateExtension {
+		return false, false
+	}
+	for k, v := range exts[0].params {
+		switch k {
+		case "server_no_context_takeover", "client_no_context_takeover":
+			if v != "" {
+				return false, false
+			}
+		case "server_max_window_bits":
+			// The server limits its own window.
+		default:
+			return false, false
+		}
+	}
+	// Without context takeover by the server, every message can be
+	// decompressed independently.
+	if _, ok := exts[0].params["server_no_context_takeover"]; !ok {
+		return false, false
+	}
+	return true, true
+}
+
+var flateWriterPool sync.Pool
+
+// getFlateWriter returns a compressor that writes to w.
+func getFlateWriter(w io.Writer) *flate.Writer {
+	if zw, ok := flateWriterPool.Get().(*flate.Writer); ok {
+		zw.Reset(w)
+		return zw
+	}
+	zw, _ := flate.NewWriter(w, flate.BestSpeed)
+	return zw
+}
+
+func putFlateWriter(zw *flate.Writer) {
+	zw.Reset(nil)
+	flateWriterPool.Put(zw)
+}
+
+var flateReaderPool sync.Pool
+
+// getFlateReader returns a decompressor that reads from r.
+func getFlateReader(r io.Reader) io.ReadCloser {
+	if zr, ok := flateReaderPool.Get().(io.ReadCloser); ok {
+		zr.(flate.Resetter).Reset(r, nil)
+		return zr
+	}
+	return flate.NewReader(r)
+}
+
+func putFlateReader(zr io.ReadCloser) {
+	zr.(flate.Resetter).Reset(strings.NewReader(""), nil)
+	flateReaderPool.Put(zr)
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// An opcode is the opcode of a frame (RFC 6455, Section 5.2).
+type opcode byte
+
+const (
+	opContinuation opcode = 0x0
+	opText         opcode = 0x1
+	opBinary       opcode = 0x2
+	opClose        opcode = 0x8
+	opPing         opcode = 0x9
+	opPong         opcode = 0xA
+)
+
+// isControl reports whether op is the opcode of a control frame.
+func (op opcode) isControl() bool {
+	return op&0x8 != 0
+}
+
+// maxControlPayload is the maximum payload length of a control frame.
+const maxControlPayload = 125
+
+// A header is the header of a frame.
+type header struct {
+	fin    bool
+	rsv1   bool // set on the first frame of a compressed message
+	rsv23  bool // whether either of the reserved bits RSV2 and RSV3 is set
+	op     opcode
+	masked bool
+	mask   [4]byte
+	length int64
+}
+
+var errFrameLength = errors.New("websocket: invalid frame payload length")
+
+// readHeader reads a frame header from br.
+func readHeader(br *bufio.Reader) (header, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(br, b[:2]); err != nil {
+		return header{}, err
+	}
+	h := header{
+		fin:    b[0]&0x80 != 0,
+		rsv1:   b[0]&0x40 != 0,
+		rsv23:  b[0]&0x30 != 0,
+		op:     opcode(b[0] & 0xF),
+		masked: b[1]&0x80 != 0,
+		length: int64(b[1] & 0x7F),
+	}
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(br, b[:2]); err != nil {
+			return header{}, noEOF(err)
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(br, b[:8]); err != nil {
+			return header{}, noEOF(err)
+		}
+		n := binary.BigEndian.Uint64(b[:8])
+		if n > 1<<63-1 {
+			return header{}, errFrameLength
+		}
+		h.length = int64(n)
+	}
+	if h.masked {
+		if _, err := io.ReadFull(br, h.mask[:]); err != nil {
+			return header{}, noEOF(err)
+		}
+	}
+	return h, nil
+}
+
+// appendHeader appends the encoding of h to b, using the shortest
+// encoding of the payload length.
+func appendHeader(b []byte, h header) []byte {
+	b0 := byte(h.op)
+	if h.fin {
+		b0 |= 0x80
+	}
+	if h.rsv1 {
+		b0 |= 0x40
+	}
+	var b1 byte
+	if h.masked {
+		b1 = 0x80
+	}
+	switch {
+	case h.length <= 125:
+		b = append(b, b0, b1|byte(h.length))
+	case h.length <= 0xFFFF:
+		b = append(b, b0, b1|126)
+		b = binary.BigEndian.AppendUint16(b, uint16(h.length))
+	default:
+		b = append(b, b0, b1|127)
+		b = binary.BigEndian.AppendUint64(b, uint64(h.length))
+	}
+	if h.masked {
+		b = append(b, h.mask[:]...)
+	}
+	return b
+}
+
+// maskBytes applies the masking algorithm of RFC 6455, Section 5.3, to b
+// using key, where pos is the offset of b in the payload modulo 4.
+// It returns the position following b.
+func maskBytes(key [4]byte, pos int, b []byte) int {
+	for i := range b {
+		b[i] ^= key[pos&3]
+		pos++
+	}
+	return pos & 3
+}
+
+// noEOF converts io.EOF, which indicates the end of the connection before
+// a frame header, into io.ErrUnexpectedEOF once a frame has started.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
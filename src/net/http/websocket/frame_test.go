@@ -0,0 +1,203 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	for _, h := range []header{
+		{fin: true, op: opText, length: 0},
+		{fin: true, op: opBinary, length: 125},
+		{op: opText, rsv1: true, length: 126},
+		{fin: true, op: opContinuation, length: 0xFFFF},
+		{fin: true, op: opBinary, length: 0x10000, masked: true, mask: [4]byte{1, 2, 3, 4}},
+		{fin: true, op: opPing, length: 5, masked: true, mask: [4]byte{0xff, 0, 0xff, 0}},
+	} {
+		b := appendHeader(nil, h)
+		got, err := readHeader(bufio.NewReader(bytes.NewReader(b)))
+		if err != nil {
+			t.Errorf("readHeader(appendHeader(%+v)): %v", h, err)
+			continue
+		}
+		if got != h {
+			t.Errorf("readHeader(appendHeader(%+v)) = %+v", h, got)
+		}
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example of RFC 6455, Section 1.3.
+	if got, want := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("acceptKey = %q, want %q", got, want)
+	}
+}
+
+func TestUTF8Validator(t *testing.T) {
+	for _, test := range []struct {
+		pieces []string
+		want   bool
+	}{
+		{[]string{"héllo"}, true},
+		{[]string{"h\xc3", "\xa9llo"}, true},
+		{[]string{"\xf0\x9f", "", "\x98", "\x80!"}, true},
+		{[]string{"\xc3"}, false},
+		{[]string{"\xc3", "("}, false},
+		{[]string{"\xff"}, false},
+		{[]string{"ok\xed\xa0\x80"}, false}, // surrogate
+	} {
+		var v utf8Validator
+		ok := true
+		for i, p := range test.pieces {
+			ok = ok && v.valid([]byte(p), i == len(test.pieces)-1)
+		}
+		if ok != test.want {
+			t.Errorf("pieces %q: valid = %v, want %v", test.pieces, ok, test.want)
+		}
+	}
+	var v utf8Validator
+	if !v.valid([]byte("\xef\xbf"), false) || !v.valid([]byte("\xbd"), true) {
+		t.Error("U+FFFD split between pieces is not valid")
+	}
+}
+
+func TestDeflateNegotiation(t *testing.T) {
+	for _, test := range []struct {
+		offer string
+		want  bool
+	}{
+		{"permessage-deflate", true},
+		{"permessage-deflate; client_max_window_bits", true},
+		{"permessage-deflate; server_max_window_bits=10, permessage-deflate", true},
+		{"permessage-deflate; server_max_window_bits=10", false},
+		{"permessage-deflate; server_no_context_takeover; server_no_context_takeover", false},
+		{"permessage-deflate; unknown", false},
+		{"x-webkit-deflate-frame", false},
+	} {
+		if got := acceptDeflate(http.Header{"Sec-Websocket-Extensions": {test.offer}}); got != test.want {
+			t.Errorf("acceptDeflate(%q) = %v, want %v", test.offer, got, test.want)
+		}
+	}
+	for _, test := range []struct {
+		response    string
+		deflate, ok bool
+	}{
+		{"", false, true},
+		{deflateNegotiation, true, true},
+		{"permessage-deflate; server_no_context_takeover", true, true},
+		{"permessage-deflate", false, false},
+		{"permessage-deflate; server_no_context_takeover; client_max_window_bits=10", false, false},
+		{"x-unknown", false, false},
+	} {
+		h := http.Header{}
+		if test.response != "" {
+			h.Set("Sec-WebSocket-Extensions", test.response)
+		}
+		deflate, ok := checkDeflateResponse(h, true)
+		if deflate != test.deflate || ok != test.ok {
+			t.Errorf("checkDeflateResponse(%q) = %v, %v; want %v, %v", test.response, deflate, ok, test.deflate, test.ok)
+		}
+	}
+}
+
+// frame returns the encoding of a frame sent by a client.
+func frame(fin bool, op opcode, payload string) []byte {
+	h := header{fin: fin, op: op, masked: true, mask: [4]byte{0x12, 0x34, 0x56, 0x78}, length: int64(len(payload))}
+	b := appendHeader(nil, h)
+	p := []byte(payload)
+	maskBytes(h.mask, 0, p)
+	return append(b, p...)
+}
+
+// serverConn returns the server side of a connection whose client
+// side sends the given data, and the data that the server sends.
+func serverConn(t *testing.T, data ...[]byte) (*Conn, <-chan []byte) {
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go func() {
+		for _, b := range data {
+			if _, err := client.Write(b); err != nil {
+				return
+			}
+		}
+	}()
+	sent := make(chan []byte, 1)
+	go func() {
+		var buf bytes.Buffer
+		buf.ReadFrom(client)
+		sent <- buf.Bytes()
+	}()
+	return newConn(server, bufio.NewReader(server), bufio.NewWriter(server), false, "", false), sent
+}
+
+func TestReadFragmented(t *testing.T) {
+	c, sent := serverConn(t,
+		frame(false, opText, "hel"),
+		frame(true, opPing, "p"),
+		frame(false, opContinuation, "lo "),
+		frame(true, opContinuation, "world"),
+	)
+	typ, got, err := c.Read(context.Background())
+	if err != nil || typ != TextMessage || string(got) != "hello world" {
+		t.Fatalf("Read() = %v, %q, %v; want text message %q", typ, got, err, "hello world")
+	}
+	c.CloseNow()
+	// The ping was answered by an unmasked pong.
+	if b := <-sent; !bytes.Equal(b, []byte{0x8A, 1, 'p'}) {
+		t.Errorf("server sent %q, want a pong", b)
+	}
+}
+
+func TestReadProtocolError(t *testing.T) {
+	unmasked := appendHeader(nil, header{fin: true, op: opText, length: 1})
+	unmasked = append(unmasked, 'a')
+	for _, test := range []struct {
+		name   string
+		frames [][]byte
+		code   StatusCode
+	}{
+		{"unmasked", [][]byte{unmasked}, StatusProtocolError},
+		{"continuation", [][]byte{frame(true, opContinuation, "a")}, StatusProtocolError},
+		{"unfinished message", [][]byte{frame(false, opText, "a"), frame(true, opText, "b")}, StatusProtocolError},
+		{"fragmented control", [][]byte{frame(false, opPing, "")}, StatusProtocolError},
+		{"opcode", [][]byte{frame(true, 3, "")}, StatusProtocolError},
+		{"compressed", [][]byte{append([]byte{0xC1}, frame(true, opText, "a")[1:]...)}, StatusProtocolError},
+		{"invalid UTF-8", [][]byte{frame(true, opText, "\xff")}, StatusInvalidFramePayloadData},
+		{"close code", [][]byte{frame(true, opClose, "\x03\xed")}, StatusProtocolError},
+	} {
+		c, sent := serverConn(t, test.frames...)
+		if _, _, err := c.Read(context.Background()); err == nil {
+			t.Errorf("%s: Read succeeded, want error", test.name)
+			continue
+		}
+		// The server sent a close frame with the status code.
+		want := []byte{0x88, 2, byte(test.code >> 8), byte(test.code)}
+		if b := <-sent; !bytes.Equal(b, want) {
+			t.Errorf("%s: server sent %q, want %q", test.name, b, want)
+		}
+		if err := c.Write(context.Background(), TextMessage, nil); err == nil {
+			t.Errorf("%s: Write after protocol error succeeded", test.name)
+		}
+	}
+}
+
+func TestReadClose(t *testing.T) {
+	c, sent := serverConn(t, frame(true, opClose, "\x03\xe8done"))
+	_, _, err := c.Read(context.Background())
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != StatusNormalClosure || ce.Reason != "done" {
+		t.Fatalf("Read returned %v, want close with status 1000", err)
+	}
+	if b := <-sent; !bytes.Equal(b, []byte{0x88, 2, 0x03, 0xe8}) {
+		t.Errorf("server sent %q, want the close frame echoed", b)
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The opening handshake (RFC 6455, Section 4).
+
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// keyGUID is concatenated with the client's key to compute the
+// server's accept key.
+const keyGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// acceptKey returns the value of the Sec-WebSocket-Accept header field
+// corresponding to the Sec-WebSocket-Key field key.
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + keyGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// AcceptOptions configures [Accept].
+type AcceptOptions struct {
+	// Subprotocols lists the subprotocols supported by the server,
+	// in order of preference. The first of them that the client
+	// requests is selected.
+	Subprotocols []string
+
+	// CheckOrigin reports whether to accept a request, based on its
+	// Origin header. If CheckOrigin is nil, requests are accepted if
+	// they have no Origin header, or if the host of the origin is
+	// the host of the request, so that browsers cannot open
+	// connections on behalf of other sites.
+	CheckOrigin func(r *http.Request) bool
+
+	// Compression enables the permessage-deflate extension,
+	// if the client supports it.
+	Compression bool
+}
+
+// Accept performs the server side of the opening handshake, upgrading
+// the HTTP connection of the request r to a WebSocket connection.
+// If the request is not a valid WebSocket handshake, Accept replies
+// to it with an HTTP error and returns an error.
+//
+// Header fields set in w before calling Accept, such as cookies, are
+// included in the handshake response. After Accept returns successfully,
+// the handler must not use w, and the connection is no longer managed
+// by the HTTP server. Accept requires HTTP/1.1.
+func Accept(w http.ResponseWriter, r *http.Request, opts *AcceptOptions) (*Conn, error) {
+	if opts == nil {
+		opts = &AcceptOptions{}
+	}
+	fail := func(code int, msg string) (*Conn, error) {
+		err := errors.New("websocket: " + msg)
+		http.Error(w, err.Error(), code)
+		return nil, err
+	}
+	if r.Method != "GET" {
+		return fail(http.StatusMethodNotAllowed, "handshake request method is not GET")
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Upgrade", "websocket")
+		return fail(http.StatusUpgradeRequired, "not a websocket handshake request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	var subprotocol string
+	requested := headerTokens(r.Header, "Sec-WebSocket-Protocol")
+	for _, p := range opts.Subprotocols {
+		if slices.Contains(requested, p) {
+			subprotocol = p
+			break
+		}
+	}
+	deflate := opts.Compression && acceptDeflate(r.Header)
+
+	h := w.Header()
+	h.Set("Upgrade", "websocket")
+	h.Set("Connection", "Upgrade")
+	h.Set("Sec-WebSocket-Accept", acceptKey(key))
+	if subprotocol != "" {
+		h.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+	if deflate {
+		h.Set("Sec-WebSocket-Extensions", deflateNegotiation)
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		for _, k := range []string{"Upgrade", "Connection", "Sec-WebSocket-Accept", "Sec-WebSocket-Protocol", "Sec-WebSocket-Extensions"} {
+			h.Del(k)
+		}
+		http.Error(w, "websocket: cannot upgrade connection", http.StatusInternalServerError)
+		return nil, err
+	}
+	// The server may have set deadlines on the connection.
+	netConn.SetDeadline(time.Time{})
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	h.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return newConn(netConn, brw.Reader, brw.Writer, false, subprotocol, deflate), nil
+}
+
+// sameOrigin reports whether the request has no Origin header,
+// or an origin whose host is the host of the request.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header["Origin"]
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin[0])
+	if err != nil {
+		return false
+	}
+	return ascii.EqualFold(u.Host, r.Host)
+}
+
+// DialOptions configures [Dial].
+type DialOptions struct {
+	// Client is the HTTP client used to send the handshake request.
+	// If nil, [http.DefaultClient] is used. The client must not have
+	// a Timeout, which would apply to the whole connection.
+	Client *http.Client
+
+	// Header holds additional header fields sent with the
+	// handshake request, such as Origin or Authorization.
+	Header http.Header
+
+	// Subprotocols lists the subprotocols requested by the client,
+	// in order of preference.
+	Subprotocols []string
+
+	// Compression enables the permessage-deflate extension,
+	// if the server supports it.
+	Compression bool
+}
+
+// Dial performs the client side of the opening handshake with the
+// WebSocket server at urlStr, whose scheme is ws, wss, http or https.
+// The context applies to the handshake only.
+//
+// Dial returns the server's response to the handshake request, whose
+// body must not be used if the handshake succeeds. If the server does
+// not accept the handshake, Dial returns its response, holding at most
+// the first 1024 bytes of the body, and an error.
+func Dial(ctx context.Context, urlStr string, opts *DialOptions) (*Conn, *http.Response, error) {
+	if opts == nil {
+		opts = &DialOptions{}
+	}
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return nil, nil, errors.New("websocket: unsupported URL scheme " + u.Scheme)
+	}
+
+	var b [16]byte
+	rand.Read(b[:])
+	key := base64.StdEncoding.EncodeToString(b[:])
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range opts.Header {
+		req.Header[k] = slices.Clone(v)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if len(opts.Subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(opts.Subprotocols, ", "))
+	}
+	if opts.Compression {
+		req.Header.Set("Sec-WebSocket-Extensions", deflateNegotiation)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	fail := func(msg string) (*Conn, *http.Response, error) {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		resp.Body = io.NopCloser(strings.NewReader(string(body)))
+		return nil, resp, errors.New("websocket: " + msg)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fail("handshake failed with status " + resp.Status)
+	}
+	if !headerHasToken(resp.Header, "Connection", "upgrade") || !headerHasToken(resp.Header, "Upgrade", "websocket") {
+		return fail("invalid Upgrade or Connection in handshake response")
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return fail("invalid Sec-WebSocket-Accept in handshake response")
+	}
+	subprotocol := resp.Header.Get("Sec-WebSocket-Protocol")
+	if subprotocol != "" && !slices.Contains(opts.Subprotocols, subprotocol) {
+		return fail("server selected an unrequested subprotocol")
+	}
+	deflate, ok := checkDeflateResponse(resp.Header, opts.Compression)
+	if !ok {
+		return fail("invalid Sec-WebSocket-Extensions in handshake response")
+	}
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		return fail("response body is not writable; the http.Client must not have a Timeout")
+	}
+	return newConn(rwc, bufio.NewReader(rwc), bufio.NewWriter(rwc), true, subprotocol, deflate), resp, nil
+}
+
+// headerHasToken reports whether the comma-separated list in the
+// header fields h[name] contains token, ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if ascii.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerTokens returns the elements of the comma-separated lists
+// in the header fields h[name].
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for t := range strings.SplitSeq(v, ",") {
+			if t = textproto.TrimString(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,825 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package websocket implements the WebSocket protocol, as defined in
+// RFC 6455, including the permessage-deflate extension defined in RFC 7692.
+//
+// A server upgrades an HTTP request to a WebSocket connection with
+// [Accept], and a client opens one with [Dial]. Both return a [Conn],
+// which reads and writes messages.
+//
+// The I/O methods of a Conn take a [context.Context]. If the context is
+// done before the operation completes, the connection is closed and the
+// operation returns the context's error.
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// A MessageType is the type of a data message.
+type MessageType int
+
+const (
+	// TextMessage is the type of messages holding UTF-8 encoded text.
+	TextMessage = MessageType(opText)
+
+	// BinaryMessage is the type of messages holding binary data.
+	BinaryMessage = MessageType(opBinary)
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case TextMessage:
+		return "text"
+	case BinaryMessage:
+		return "binary"
+	}
+	return "MessageType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// A StatusCode is a status code sent in a close frame, indicating why
+// the connection is closed (RFC 6455, Section 7.4).
+type StatusCode int
+
+const (
+	StatusNormalClosure           StatusCode = 1000
+	StatusGoingAway               StatusCode = 1001
+	StatusProtocolError           StatusCode = 1002
+	StatusUnsupportedData         StatusCode = 1003
+	StatusNoStatusReceived        StatusCode = 1005 // never sent
+	StatusAbnormalClosure         StatusCode = 1006 // never sent
+	StatusInvalidFramePayloadData StatusCode = 1007
+	StatusPolicyViolation         StatusCode = 1008
+	StatusMessageTooBig           StatusCode = 1009
+	StatusMandatoryExtension      StatusCode = 1010
+	StatusInternalError           StatusCode = 1011
+	StatusServiceRestart          StatusCode = 1012
+	StatusTryAgainLater           StatusCode = 1013
+	StatusBadGateway              StatusCode = 1014
+	StatusTLSHandshake            StatusCode = 1015 // never sent
+)
+
+// validCloseCode reports whether code may be sent in a close frame.
+func validCloseCode(code StatusCode) bool {
+	switch {
+	case code >= 1000 && code <= 1003,
+		code >= 1007 && code <= 1014,
+		code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// A CloseError is returned by the methods of a [Conn] once the peer has
+// closed the connection with a close frame. If the frame held no status
+// code, Code is [StatusNoStatusReceived].
+type CloseError struct {
+	Code   StatusCode
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: connection closed with status %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: connection closed with status %d: %s", e.Code, e.Reason)
+}
+
+// ErrClosed is returned by the methods of a [Conn] after Close or
+// CloseNow is called.
+var ErrClosed = errors.New("websocket: use of closed connection")
+
+var errWriterClosed = errors.New("websocket: write to closed message writer")
+
+const (
+	// defaultReadLimit is the default maximum size of a received message.
+	defaultReadLimit = 1 << 20
+
+	// closeTimeout bounds the time spent on the closing handshake.
+	closeTimeout = 5 * time.Second
+
+	// compressedFrameSize is the size of the frames sent by a
+	// compressing message writer, other than the last one.
+	compressedFrameSize = 16 << 10
+)
+
+// A Conn is a WebSocket connection.
+//
+// A Conn is safe for concurrent use by multiple goroutines, although
+// messages are read one at a time and written one at a time. Control
+// frames, such as pings and close frames, are handled while reading, so
+// a Conn must be read from for [Conn.Ping] and [Conn.Close] to complete
+// promptly.
+type Conn struct {
+	rwc         io.ReadWriteCloser
+	br          *bufio.Reader
+	bw          *bufio.Writer
+	client      bool // whether this is the client side of the connection
+	subprotocol string
+	deflate     bool // whether permessage-deflate was negotiated
+
+	readMu    ctxMutex       // held while reading
+	msg       *messageReader // current message, guarded by readMu
+	readLimit atomic.Int64
+
+	msgMu     ctxMutex // held by the writer of the current message
+	writeMu   ctxMutex // held while writing a frame
+	writeBuf  []byte   // guarded by writeMu
+	closeSent bool     // guarded by writeMu
+
+	closing atomic.Bool // whether Close has been called
+
+	pingMu  sync.Mutex
+	pingSeq uint64
+	pings   map[string]chan struct{} // waiting pings, by payload
+
+	errMu  sync.Mutex
+	err    error         // why the connection was closed
+	closed chan struct{} // closed when the connection is closed
+}
+
+func newConn(rwc io.ReadWriteCloser, br *bufio.Reader, bw *bufio.Writer, client bool, subprotocol string, deflate bool) *Conn {
+	c := &Conn{
+		rwc:         rwc,
+		br:          br,
+		bw:          bw,
+		client:      client,
+		subprotocol: subprotocol,
+		deflate:     deflate,
+		readMu:      make(ctxMutex, 1),
+		msgMu:       make(ctxMutex, 1),
+		writeMu:     make(ctxMutex, 1),
+		closed:      make(chan struct{}),
+	}
+	c.readLimit.Store(defaultReadLimit)
+	return c
+}
+
+// Subprotocol returns the subprotocol negotiated during the handshake,
+// or "" if none was.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// SetReadLimit sets the maximum size of a received message, after
+// decompression. If a message exceeds it, the connection is closed with
+// [StatusMessageTooBig]. The default limit is 1 MiB.
+func (c *Conn) SetReadLimit(n int64) {
+	c.readLimit.Store(n)
+}
+
+// Reader returns the type of the next data message received from the
+// peer, and a reader of its contents. The context applies to reading
+// the whole message. The reader is valid until the next call to Reader
+// or Read; the remainder of an unread message is discarded.
+//
+// Once the peer closes the connection, Reader returns a [*CloseError].
+func (c *Conn) Reader(ctx context.Context) (MessageType, io.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+	if err := c.readMu.lock(ctx); err != nil {
+		return 0, nil, err
+	}
+	defer c.readMu.unlock()
+	defer c.watch(ctx)()
+
+	if m := c.msg; m != nil {
+		// Discard the rest of the previous message.
+		m.ctx = ctx
+		var buf [512]byte
+		for {
+			_, err := m.read(buf[:])
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return 0, nil, err
+			}
+		}
+		c.msg = nil
+	}
+
+	h, err := c.nextFrame(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	if h.op == opContinuation {
+		return 0, nil, c.fail(StatusProtocolError, "unexpected continuation frame")
+	}
+	m := &messageReader{
+		c:         c,
+		ctx:       ctx,
+		typ:       MessageType(h.op),
+		h:         h,
+		remaining: h.length,
+	}
+	if h.rsv1 {
+		m.tail = deflateTail + deflateFinal
+		m.zr = getFlateReader(payloadReader{m})
+	}
+	c.msg = m
+	return m.typ, m, nil
+}
+
+// Read reads the next data message received from the peer.
+// See [Conn.Reader].
+func (c *Conn) Read(ctx context.Context) (MessageType, []byte, error) {
+	typ, r, err := c.Reader(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return typ, b, nil
+}
+
+// nextFrame reads the header of the next data frame,
+// handling any control frames that precede it.
+func (c *Conn) nextFrame(ctx context.Context) (header, error) {
+	for {
+		h, err := readHeader(c.br)
+		if err != nil {
+			return header{}, c.ioErr(noEOF(err))
+		}
+		switch {
+		case h.rsv23:
+			return header{}, c.fail(StatusProtocolError, "reserved bits set")
+		case h.rsv1 && (!c.deflate || h.op == opContinuation || h.op.isControl()):
+			return header{}, c.fail(StatusProtocolError, "unexpected RSV1 bit")
+		case h.op != opContinuation && h.op != opText && h.op != opBinary &&
+			h.op != opClose && h.op != opPing && h.op != opPong:
+			return header{}, c.fail(StatusProtocolError, "unknown opcode "+strconv.Itoa(int(h.op)))
+		case h.op.isControl() && (!h.fin || h.length > maxControlPayload):
+			return header{}, c.fail(StatusProtocolError, "invalid control frame")
+		case h.masked == c.client:
+			if c.client {
+				return header{}, c.fail(StatusProtocolError, "masked frame from server")
+			}
+			return header{}, c.fail(StatusProtocolError, "unmasked frame from client")
+		}
+		if !h.op.isControl() {
+			return h, nil
+		}
+		if err := c.handleControl(ctx, h); err != nil {
+			return header{}, err
+		}
+	}
+}
+
+// handleControl handles the control frame with header h.
+func (c *Conn) handleControl(ctx context.Context, h header) error {
+	var buf [maxControlPayload]byte
+	p := buf[:h.length]
+	if _, err := io.ReadFull(c.br, p); err != nil {
+		return c.ioErr(noEOF(err))
+	}
+	if h.masked {
+		maskBytes(h.mask, 0, p)
+	}
+	switch h.op {
+	case opPing:
+		if err := c.writeFrame(ctx, true, opPong, false, p); err != nil && err != ErrClosed {
+			return err
+		}
+	case opPong:
+		c.pingMu.Lock()
+		if done, ok := c.pings[string(p)]; ok {
+			close(done)
+			delete(c.pings, string(p))
+		}
+		c.pingMu.Unlock()
+	case opClose:
+		ce := &CloseError{Code: StatusNoStatusReceived}
+		if len(p) == 1 {
+			return c.fail(StatusProtocolError, "invalid close frame")
+		}
+		if len(p) >= 2 {
+			ce.Code = StatusCode(binary.BigEndian.Uint16(p))
+			ce.Reason = string(p[2:])
+			if !validCloseCode(ce.Code) || !utf8.ValidString(ce.Reason) {
+				return c.fail(StatusProtocolError, "invalid close frame")
+			}
+		}
+		// Echo the close frame, unless it is the response to ours.
+		ctx, cancel := context.WithTimeout(ctx, closeTimeout)
+		c.writeFrame(ctx, true, opClose, false, p[:min(len(p), 2)])
+		cancel()
+		c.closeWithErr(ce)
+		return c.getErr()
+	}
+	return nil
+}
+
+// A messageReader reads a data message.
+type messageReader struct {
+	c         *Conn
+	ctx       context.Context
+	typ       MessageType
+	h         header // header of the current frame
+	remaining int64  // unread payload of the current frame
+	pos       int    // masking position in the current frame
+	zr        io.ReadCloser
+	tail      string // data appended to a compressed message
+	n         int64  // bytes of the message read so far
+	utf8      utf8Validator
+	err       error // sticky error; io.EOF at the end of the message
+}
+
+func (m *messageReader) Read(p []byte) (int, error) {
+	c := m.c
+	if err := c.readMu.lock(m.ctx); err != nil {
+		return 0, err
+	}
+	defer c.readMu.unlock()
+	if m.err != nil {
+		return 0, m.err
+	}
+	if err := m.ctx.Err(); err != nil {
+		return 0, err
+	}
+	defer c.watch(m.ctx)()
+	return m.read(p)
+}
+
+// read reads from the message while holding the connection's read lock.
+func (m *messageReader) read(p []byte) (n int, err error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	if m.zr != nil {
+		n, err = m.zr.Read(p)
+		if err == io.EOF && (m.remaining > 0 || !m.h.fin || m.tail != "") {
+			err = m.c.fail(StatusInvalidFramePayloadData, "compressed message ends early")
+		} else if err != nil && err != io.EOF {
+			// Report the connection's error, if the payload could
+			// not be read, rather than the decompressor's.
+			if cerr := m.c.getErr(); cerr != nil {
+				err = cerr
+			} else {
+				err = m.c.fail(StatusInvalidFramePayloadData, "invalid compressed message")
+			}
+		}
+	} else {
+		n, err = m.readPayload(p)
+	}
+	m.n += int64(n)
+	if m.n > m.c.readLimit.Load() {
+		n, err = 0, m.c.fail(StatusMessageTooBig, "message exceeds read limit")
+	}
+	if m.typ == TextMessage && !m.utf8.valid(p[:n], err == io.EOF) {
+		n, err = 0, m.c.fail(StatusInvalidFramePayloadData, "invalid UTF-8 in text message")
+	}
+	if err != nil {
+		m.err = err
+		if m.zr != nil {
+			putFlateReader(m.zr)
+			m.zr = nil
+		}
+	}
+	return n, err
+}
+
+// readPayload reads the payload of the message's frames,
+// followed by its tail.
+func (m *messageReader) readPayload(p []byte) (int, error) {
+	for m.remaining == 0 {
+		if m.h.fin {
+			if m.tail != "" {
+				n := copy(p, m.tail)
+				m.tail = m.tail[n:]
+				return n, nil
+			}
+			return 0, io.EOF
+		}
+		h, err := m.c.nextFrame(m.ctx)
+		if err != nil {
+			return 0, err
+		}
+		if h.op != opContinuation {
+			return 0, m.c.fail(StatusProtocolError, "expected continuation frame")
+		}
+		m.h, m.remaining, m.pos = h, h.length, 0
+	}
+	if int64(len(p)) > m.remaining {
+		p = p[:m.remaining]
+	}
+	n, err := m.c.br.Read(p)
+	if m.h.masked {
+		m.pos = maskBytes(m.h.mask, m.pos, p[:n])
+	}
+	m.remaining -= int64(n)
+	if err != nil {
+		return n, m.c.ioErr(noEOF(err))
+	}
+	return n, nil
+}
+
+// A payloadReader reads the payload of a compressed message.
+// It implements [io.ByteReader], so that the decompressor
+// does not read past the end of the message.
+type payloadReader struct {
+	m *messageReader
+}
+
+func (r payloadReader) Read(p []byte) (int, error) {
+	return r.m.readPayload(p)
+}
+
+func (r payloadReader) ReadByte() (byte, error) {
+	var b [1]byte
+	for {
+		n, err := r.m.readPayload(b[:])
+		if n == 1 {
+			return b[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// A utf8Validator validates UTF-8 text received in pieces.
+type utf8Validator struct {
+	partial [utf8.UTFMax]byte // incomplete rune at the end of the last piece
+	n       int
+}
+
+// valid reports whether the text received so far, ending with p, may be
+// valid UTF-8. If end is true, p is the last piece.
+func (v *utf8Validator) valid(p []byte, end bool) bool {
+	if v.n > 0 {
+		for len(p) > 0 && !utf8.FullRune(v.partial[:v.n]) {
+			v.partial[v.n] = p[0]
+			v.n++
+			p = p[1:]
+		}
+		if !utf8.FullRune(v.partial[:v.n]) {
+			return !end
+		}
+		if r, size := utf8.DecodeRune(v.partial[:v.n]); r == utf8.RuneError && size == 1 {
+			return false
+		}
+		v.n = 0
+	}
+	// Hold back an incomplete rune at the end of p.
+	i := len(p)
+	for j := len(p) - 1; j >= 0 && j > len(p)-utf8.UTFMax; j-- {
+		if utf8.RuneStart(p[j]) {
+			if !utf8.FullRune(p[j:]) {
+				i = j
+			}
+			break
+		}
+	}
+	if !utf8.Valid(p[:i]) {
+		return false
+	}
+	v.n = copy(v.partial[:], p[i:])
+	return !end || v.n == 0
+}
+
+// Writer returns a writer of a data message of type typ. Each call to
+// the writer's Write method sends a frame, or, if the message is
+// compressed, as much of it as is ready. Closing the writer ends the
+// message. The context applies to writing the whole message.
+//
+// No other message can be written until the writer is closed, although
+// control frames may be sent between its frames.
+func (c *Conn) Writer(ctx context.Context, typ MessageType) (io.WriteCloser, error) {
+	if typ != TextMessage && typ != BinaryMessage {
+		return nil, errors.New("websocket: invalid message type " + typ.String())
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := c.msgMu.lock(ctx); err != nil {
+		return nil, err
+	}
+	w := &messageWriter{c: c, ctx: ctx, op: opcode(typ)}
+	if c.deflate {
+		w.compressed = true
+		w.zw = getFlateWriter(&w.zbuf)
+	}
+	return w, nil
+}
+
+// Write writes a data message of type typ holding p.
+func (c *Conn) Write(ctx context.Context, typ MessageType, p []byte) error {
+	if c.deflate && len(p) >= compressMinSize {
+		w, err := c.Writer(ctx, typ)
+		if err != nil {
+			return err
+		}
+		w.Write(p)
+		return w.Close()
+	}
+	if typ != TextMessage && typ != BinaryMessage {
+		return errors.New("websocket: invalid message type " + typ.String())
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.msgMu.lock(ctx); err != nil {
+		return err
+	}
+	defer c.msgMu.unlock()
+	return c.writeFrame(ctx, true, opcode(typ), false, p)
+}
+
+// A messageWriter writes a data message.
+type messageWriter struct {
+	c          *Conn
+	ctx        context.Context
+	op         opcode // opcode of the next frame
+	compressed bool
+	zw         *flate.Writer
+	zbuf       bytes.Buffer // compressed data not yet sent
+	closed     bool
+	err        error
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if !w.compressed {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		if err := w.frame(false, p); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	w.zw.Write(p)
+	if w.zbuf.Len() >= compressedFrameSize+len(deflateTail) {
+		// Hold back what may be the start of the tail,
+		// which is removed from the end of the message.
+		if err := w.frame(false, w.zbuf.Next(w.zbuf.Len()-len(deflateTail))); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close sends the end of the message.
+func (w *messageWriter) Close() error {
+	if w.closed {
+		return errWriterClosed
+	}
+	w.closed = true
+	defer w.c.msgMu.unlock()
+	var p []byte
+	if w.compressed {
+		w.zw.Flush()
+		p = bytes.TrimSuffix(w.zbuf.Bytes(), []byte(deflateTail))
+		putFlateWriter(w.zw)
+		w.zw = nil
+	}
+	if w.err != nil {
+		return w.err
+	}
+	return w.frame(true, p)
+}
+
+func (w *messageWriter) frame(fin bool, p []byte) error {
+	rsv1 := w.compressed && w.op != opContinuation
+	err := w.c.writeFrame(w.ctx, fin, w.op, rsv1, p)
+	w.op = opContinuation
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
+
+// writeFrame writes a frame holding p.
+func (c *Conn) writeFrame(ctx context.Context, fin bool, op opcode, rsv1 bool, p []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.writeMu.lock(ctx); err != nil {
+		return err
+	}
+	defer c.writeMu.unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	if err := c.getErr(); err != nil {
+		return err
+	}
+	defer c.watch(ctx)()
+
+	h := header{fin: fin, rsv1: rsv1, op: op, masked: c.client, length: int64(len(p))}
+	if c.client {
+		rand.Read(h.mask[:])
+	}
+	c.writeBuf = appendHeader(c.writeBuf[:0], h)
+	c.bw.Write(c.writeBuf)
+	if !c.client {
+		c.bw.Write(p)
+	} else {
+		// Mask a copy of p, in pieces.
+		pos := 0
+		for len(p) > 0 {
+			b := append(c.writeBuf[:0], p[:min(len(p), 4096)]...)
+			pos = maskBytes(h.mask, pos, b)
+			c.bw.Write(b)
+			p = p[len(b):]
+			c.writeBuf = b
+		}
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+	if err := c.bw.Flush(); err != nil {
+		return c.ioErr(err)
+	}
+	return nil
+}
+
+// Ping sends a ping to the peer and waits for the corresponding pong.
+// The pong is received by a concurrent call to Read or Reader.
+func (c *Conn) Ping(ctx context.Context) error {
+	c.pingMu.Lock()
+	c.pingSeq++
+	payload := strconv.FormatUint(c.pingSeq, 10)
+	if c.pings == nil {
+		c.pings = make(map[string]chan struct{})
+	}
+	done := make(chan struct{})
+	c.pings[payload] = done
+	c.pingMu.Unlock()
+	defer func() {
+		c.pingMu.Lock()
+		delete(c.pings, payload)
+		c.pingMu.Unlock()
+	}()
+
+	if err := c.writeFrame(ctx, true, opPing, false, []byte(payload)); err != nil {
+		return err
+	}
+	select {
+	case <-done:
+		return nil
+	case <-c.closed:
+		return c.getErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close performs the closing handshake, sending a close frame with the
+// given status code and reason and waiting for the peer's close frame,
+// and then closes the connection. The reason must be at most 123 bytes.
+//
+// Close waits at most 5 seconds for the handshake to complete.
+// It returns nil if the peer responded with a close frame, or if the
+// peer had already closed the connection with one.
+func (c *Conn) Close(code StatusCode, reason string) error {
+	if !validCloseCode(code) {
+		return errors.New("websocket: invalid close status code " + strconv.Itoa(int(code)))
+	}
+	if len(reason) > maxControlPayload-2 {
+		return errors.New("websocket: close reason too long")
+	}
+	if !c.closing.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	p := binary.BigEndian.AppendUint16(nil, uint16(code))
+	p = append(p, reason...)
+	err := c.writeFrame(ctx, true, opClose, false, p)
+	if err == nil {
+		c.waitClose(ctx)
+	}
+	c.closeWithErr(ErrClosed)
+	if _, ok := c.getErr().(*CloseError); ok {
+		return nil
+	}
+	if err == nil {
+		err = c.getErr()
+	}
+	return fmt.Errorf("websocket: closing handshake: %w", err)
+}
+
+// waitClose waits until the peer's close frame is received,
+// reading and discarding messages if no other goroutine is reading.
+func (c *Conn) waitClose(ctx context.Context) {
+	if c.readMu.tryLock() {
+		c.readMu.unlock()
+		for {
+			_, r, err := c.Reader(ctx)
+			if err != nil {
+				return
+			}
+			if _, err := io.Copy(io.Discard, r); err != nil {
+				return
+			}
+		}
+	}
+	select {
+	case <-c.closed:
+	case <-ctx.Done():
+	}
+}
+
+// CloseNow closes the connection without a closing handshake.
+func (c *Conn) CloseNow() error {
+	c.closing.Store(true)
+	c.closeWithErr(ErrClosed)
+	return nil
+}
+
+// fail sends a close frame with code, if possible, and closes the
+// connection, after a protocol error described by msg.
+func (c *Conn) fail(code StatusCode, msg string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	c.writeFrame(ctx, true, opClose, false, binary.BigEndian.AppendUint16(nil, uint16(code)))
+	cancel()
+	c.closeWithErr(errors.New("websocket: " + msg))
+	return c.getErr()
+}
+
+// watch closes the connection if ctx is done before the returned
+// function is called.
+func (c *Conn) watch(ctx context.Context) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		c.closeWithErr(ctx.Err())
+	})
+}
+
+// ioErr closes the connection after an I/O error,
+// and returns the error to report.
+func (c *Conn) ioErr(err error) error {
+	c.closeWithErr(err)
+	return c.getErr()
+}
+
+// closeWithErr closes the connection, if it is not closed yet,
+// recording err as the reason.
+func (c *Conn) closeWithErr(err error) {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.closed)
+	c.rwc.Close()
+}
+
+// getErr returns the reason the connection was closed, or nil.
+func (c *Conn) getErr() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.err
+}
+
+// A ctxMutex is a mutual exclusion lock whose Lock operation
+// can be canceled.
+type ctxMutex chan struct{}
+
+func (m ctxMutex) lock(ctx context.Context) error {
+	select {
+	case m <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m ctxMutex) tryLock() bool {
+	select {
+	case m <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m ctxMutex) unlock() {
+	<-m
+}
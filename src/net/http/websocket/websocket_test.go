@@ -0,0 +1,302 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/websocket"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newEchoServer returns a server that sends back each message it receives.
+func newEchoServer(t *testing.T, opts *websocket.AcceptOptions) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, opts)
+		if err != nil {
+			t.Errorf("Accept: %v", err)
+			return
+		}
+		defer c.CloseNow()
+		ctx := context.Background()
+		for {
+			typ, r, err := c.Reader(ctx)
+			if err != nil {
+				return
+			}
+			w, err := c.Writer(ctx, typ)
+			if err != nil {
+				return
+			}
+			if _, err := io.Copy(w, r); err != nil {
+				return
+			}
+			if err := w.Close(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func dial(t *testing.T, ts *httptest.Server, opts *websocket.DialOptions) *websocket.Conn {
+	t.Helper()
+	if opts == nil {
+		opts = &websocket.DialOptions{}
+	}
+	opts.Client = ts.Client()
+	c, _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.CloseNow() })
+	return c
+}
+
+func TestEcho(t *testing.T) {
+	for _, compression := range []bool{false, true} {
+		name := "uncompressed"
+		if compression {
+			name = "compressed"
+		}
+		t.Run(name, func(t *testing.T) {
+			ts := newEchoServer(t, &websocket.AcceptOptions{Compression: true})
+			c := dial(t, ts, &websocket.DialOptions{Compression: compression})
+			ctx := context.Background()
+			for _, msg := range []struct {
+				typ  websocket.MessageType
+				data string
+			}{
+				{websocket.TextMessage, "hello"},
+				{websocket.TextMessage, ""},
+				{websocket.BinaryMessage, "\x00\xff\x01"},
+				{websocket.TextMessage, strings.Repeat("héllo, wörld ", 10000)},
+				{websocket.BinaryMessage, strings.Repeat("\x00\x01\x02", 100000)},
+			} {
+				if err := c.Write(ctx, msg.typ, []byte(msg.data)); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+				typ, got, err := c.Read(ctx)
+				if err != nil {
+					t.Fatalf("Read: %v", err)
+				}
+				if typ != msg.typ || string(got) != msg.data {
+					t.Errorf("echoed %v message of %d bytes, want %v message of %d bytes", typ, len(got), msg.typ, len(msg.data))
+				}
+			}
+
+			// A message written in fragments.
+			w, err := c.Writer(ctx, websocket.TextMessage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Split a multi-byte character between frames.
+			for _, s := range []string{"frag", "ments \xc3", "\xa9", ""} {
+				if _, err := io.WriteString(w, s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, got, err := c.Read(ctx); err != nil || string(got) != "fragments é" {
+				t.Errorf("Read() = %q, %v; want %q", got, err, "fragments é")
+			}
+			if err := c.Close(websocket.StatusNormalClosure, ""); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+		})
+	}
+}
+
+func TestSubprotocol(t *testing.T) {
+	ts := newEchoServer(t, &websocket.AcceptOptions{Subprotocols: []string{"v2", "v1"}})
+	for _, test := range []struct {
+		requested []string
+		want      string
+	}{
+		{nil, ""},
+		{[]string{"v1"}, "v1"},
+		{[]string{"v1", "v2"}, "v2"},
+		{[]string{"v3"}, ""},
+	} {
+		c := dial(t, ts, &websocket.DialOptions{Subprotocols: test.requested})
+		if got := c.Subprotocol(); got != test.want {
+			t.Errorf("requesting %q: Subprotocol() = %q, want %q", test.requested, got, test.want)
+		}
+		c.Close(websocket.StatusNormalClosure, "")
+	}
+}
+
+func TestAcceptError(t *testing.T) {
+	valid := http.Header{
+		"Connection":            {"keep-alive, Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+	}
+	for _, test := range []struct {
+		name   string
+		method string
+		header http.Header
+		want   int
+	}{
+		{"method", "POST", nil, http.StatusMethodNotAllowed},
+		{"no upgrade", "GET", http.Header{"Upgrade": nil}, http.StatusUpgradeRequired},
+		{"version", "GET", http.Header{"Sec-Websocket-Version": {"8"}}, http.StatusUpgradeRequired},
+		{"key", "GET", http.Header{"Sec-Websocket-Key": {"short"}}, http.StatusBadRequest},
+		{"origin", "GET", http.Header{"Origin": {"https://evil.example"}}, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(test.method, "/", nil)
+		for k, v := range valid {
+			req.Header[k] = v
+		}
+		for k, v := range test.header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		if _, err := websocket.Accept(rec, req, nil); err == nil {
+			t.Errorf("%s: Accept succeeded, want error", test.name)
+		}
+		if rec.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, rec.Code, test.want)
+		}
+	}
+}
+
+func TestDialError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no websockets here", http.StatusNotFound)
+	}))
+	defer ts.Close()
+	_, resp, err := websocket.Dial(context.Background(), ts.URL, &websocket.DialOptions{Client: ts.Client()})
+	if err == nil {
+		t.Fatal("Dial succeeded, want error")
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Dial returned response %v, want 404", resp)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "no websockets here\n" {
+		t.Errorf("response body = %q", body)
+	}
+}
+
+func TestPing(t *testing.T) {
+	ts := newEchoServer(t, nil)
+	c := dial(t, ts, nil)
+	ctx := context.Background()
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := c.Read(ctx)
+		errc <- err
+	}()
+	for range 3 {
+		if err := c.Ping(ctx); err != nil {
+			t.Fatalf("Ping: %v", err)
+		}
+	}
+	if err := c.Close(websocket.StatusNormalClosure, ""); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	var ce *websocket.CloseError
+	if err := <-errc; !errors.As(err, &ce) {
+		t.Errorf("Read after Close returned %v, want CloseError", err)
+	}
+}
+
+func TestCloseHandshake(t *testing.T) {
+	result := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			result <- err
+			return
+		}
+		_, _, err = c.Read(context.Background())
+		result <- err
+	}))
+	defer ts.Close()
+
+	c := dial(t, ts, nil)
+	if err := c.Close(websocket.StatusGoingAway, "bye"); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	var ce *websocket.CloseError
+	if err := <-result; !errors.As(err, &ce) || ce.Code != websocket.StatusGoingAway || ce.Reason != "bye" {
+		t.Errorf("server Read returned %v, want close with status 1001 and reason bye", err)
+	}
+	if err := c.Write(context.Background(), websocket.TextMessage, []byte("x")); !errors.Is(err, websocket.ErrClosed) {
+		t.Errorf("Write after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	ts := newEchoServer(t, nil)
+	c := dial(t, ts, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.Read(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Read returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The connection is closed.
+	if err := c.Write(context.Background(), websocket.TextMessage, []byte("x")); err == nil {
+		t.Errorf("Write after canceled Read succeeded")
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	// Canceling the context of Dial after it returns
+	// does not affect the connection.
+	ts := newEchoServer(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	c, _, err := websocket.Dial(ctx, ts.URL, &websocket.DialOptions{Client: ts.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+	cancel()
+	if err := c.Write(context.Background(), websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, got, err := c.Read(context.Background()); err != nil || string(got) != "hello" {
+		t.Errorf("Read() = %q, %v; want hello", got, err)
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	result := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, &websocket.AcceptOptions{Compression: true})
+		if err != nil {
+			result <- err
+			return
+		}
+		c.SetReadLimit(100)
+		_, _, err = c.Read(context.Background())
+		result <- err
+	}))
+	defer ts.Close()
+
+	// The limit applies to the decompressed size.
+	c := dial(t, ts, &websocket.DialOptions{Compression: true})
+	ctx := context.Background()
+	if err := c.Write(ctx, websocket.BinaryMessage, bytes.Repeat([]byte{'a'}, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; err == nil {
+		t.Errorf("server Read of oversized message succeeded")
+	}
+	var ce *websocket.CloseError
+	if _, _, err := c.Read(ctx); !errors.As(err, &ce) || ce.Code != websocket.StatusMessageTooBig {
+		t.Errorf("client Read returned %v, want close with status 1009", err)
+	}
+}